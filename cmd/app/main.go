@@ -2,21 +2,13 @@ package main
 
 import (
 	"context"
-	"errors"
-	"firstmod/internal/cache"
+	"firstmod/internal/app"
 	"firstmod/internal/config"
-	"firstmod/internal/handlers"
-	"firstmod/internal/kafka"
 	"firstmod/internal/repository"
-	"firstmod/internal/service"
 	"flag"
-	"fmt"
 	"log/slog"
-	"net/http"
 	"os"
 	"os/signal"
-	"strings"
-	"time"
 )
 
 func main() {
@@ -24,6 +16,26 @@ func main() {
 	flag.StringVar(&configPath, "config", ".env", "configuration file")
 	flag.Parse()
 
+	if flag.Arg(0) == "migrate" {
+		cfg := config.MustLoadDBCfg(configPath)
+		log := mustMakeLogger(cfg.LogLevel)
+		if cfg.Storage != "postgres" {
+			log.Error("migrations only apply to the postgres storage", "storage", cfg.Storage)
+			os.Exit(1)
+		}
+		storage, err := repository.New(log, cfg.PostgresDSN())
+		if err != nil {
+			log.Error("failed to connect to db", "error", err)
+			os.Exit(1)
+		}
+		defer storage.Close()
+		if err := runMigrateCommand(log, storage, flag.Args()[1:]); err != nil {
+			log.Error("migrate command failed", "error", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	cfg := config.MustLoadCfg(configPath)
 
 	log := mustMakeLogger(cfg.LogLevel)
@@ -32,77 +44,22 @@ func main() {
 
 	log.Debug("debug messages are enabled")
 
-	// db
-
-	dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s sslmode=disable",
-		cfg.DBHost, cfg.DBUser, cfg.DBPassword, cfg.DBName, cfg.DBPort)
-
-	storage, err := repository.New(log, dsn)
-	if err != nil {
-		log.Error("failed to connect to db", "error", err)
-		os.Exit(1)
-	}
-	if err := storage.Migrate(); err != nil {
-		log.Error("failed to migrate db", "error", err)
+	if flag.NArg() > 0 {
+		log.Error("unknown command", "command", flag.Arg(0))
 		os.Exit(1)
 	}
 
-	log.Info("successfully connected to database")
-
-	orderCache := cache.NewCache(log)
-	log.Info("in-memory cache initialized")
-
-	kafkaBrokers := strings.Split(cfg.KafkaBrokers, ",")
-	kafkaProducer := kafka.NewProducer(log, kafkaBrokers, cfg.KafkaTopic)
-	defer kafkaProducer.Close()
-	log.Info("Kafka producer initialized")
-
-	orderService := service.NewOrderService(storage, orderCache, log, kafkaProducer)
-	log.Info("order service initialized")
-
-	loadCtx, cancelLoad := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancelLoad()
-
-	if err := orderService.LoadCacheFromDB(loadCtx); err != nil {
-		log.Error("failed to load cache from database", "error", err)
-	} else {
-		log.Info("cache successfully loaded from database")
-	}
-
-	mux := http.NewServeMux()
-
-	mux.Handle("POST /order", handlers.CreateOrderHandler(log, storage))
-	mux.Handle("DELETE /order/{orderID}", handlers.DeleteOrderHandler(log, storage))
-	mux.Handle("GET /order/{orderID}", handlers.GetOrderByIDHandler(log, storage))
-	mux.Handle("GET /orders/", handlers.GetOrdersIDsHandler(log, storage))
-
-	fileServer := http.FileServer(http.Dir("./static"))
-	mux.Handle("/", fileServer)
-
-	server := http.Server{
-		Addr:        cfg.HttpServerAddress,
-		ReadTimeout: cfg.HttpServerTimeout * time.Second,
-		Handler:     mux,
+	application, err := app.New(cfg, log)
+	if err != nil {
+		log.Error("failed to start", "error", err)
+		os.Exit(1)
 	}
 
-	log.Info("server is listening on", "address", cfg.HttpServerAddress)
-
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
 	defer stop()
 
-	go func() {
-		<-ctx.Done()
-		log.Debug("shutting down server")
-		if err := server.Shutdown(context.Background()); err != nil {
-			log.Error("erroneous shutdown", "error", err)
-		}
-	}()
-
-	if err := server.ListenAndServe(); err != nil {
-		if !errors.Is(err, http.ErrServerClosed) {
-			log.Error("server closed unexpectedly", "error", err)
-			return
-		}
+	if err := application.Run(ctx); err != nil {
+		log.Error("server closed unexpectedly", "error", err)
 	}
 }
 
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"errors"
+	"firstmod/internal/repository"
+	"fmt"
+	"log/slog"
+	"strconv"
+)
+
+const migrateUsage = "usage: app migrate up | down [N] | goto N | version | force N"
+
+// runMigrateCommand executes "migrate" subcommands against storage.
+func runMigrateCommand(log *slog.Logger, storage *repository.DB, args []string) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+
+	switch args[0] {
+	case "up":
+		if err := storage.Migrate(); err != nil {
+			return err
+		}
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				return fmt.Errorf("invalid number of steps %q: %s", args[1], migrateUsage)
+			}
+			steps = n
+		}
+		if err := storage.MigrateDown(steps); err != nil {
+			return err
+		}
+	case "goto":
+		if len(args) < 2 {
+			return errors.New(migrateUsage)
+		}
+		version, err := strconv.ParseUint(args[1], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid version %q: %s", args[1], migrateUsage)
+		}
+		if err := storage.MigrateTo(uint(version)); err != nil {
+			return err
+		}
+	case "force":
+		if len(args) < 2 {
+			return errors.New(migrateUsage)
+		}
+		version, err := strconv.Atoi(args[1])
+		if err != nil {
+			return fmt.Errorf("invalid version %q: %s", args[1], migrateUsage)
+		}
+		if err := storage.ForceMigrationVersion(version); err != nil {
+			return err
+		}
+	case "version":
+	default:
+		return fmt.Errorf("unknown migrate command %q: %s", args[0], migrateUsage)
+	}
+
+	version, dirty, err := storage.MigrationVersion()
+	if err != nil {
+		return err
+	}
+	log.Info("database schema version", "version", version, "dirty", dirty)
+	fmt.Printf("version: %d, dirty: %t\n", version, dirty)
+	return nil
+}
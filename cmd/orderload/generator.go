@@ -0,0 +1,133 @@
+package main
+
+import (
+	"firstmod/internal/models"
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+)
+
+// generatorOptions describes the orders to generate.
+type generatorOptions struct {
+	Prefix     string
+	MinItems   int
+	MaxItems   int
+	Locales    []string
+	Currencies []string
+	Providers  []string
+	// Customers is the number of distinct customers orders are spread over.
+	Customers int
+	// Spread spreads the creation time of orders over the period before now.
+	Spread time.Duration
+}
+
+var (
+	firstNames       = []string{"Ivan", "Anna", "Oleg", "Maria", "Sergey", "Elena", "Dmitry"}
+	lastNames        = []string{"Ivanov", "Petrova", "Sidorov", "Smirnova", "Testov", "Kuznetsov"}
+	cities           = []string{"Moscow", "Saint Petersburg", "Kazan", "Novosibirsk", "Kiryat Mozkin", "Yekaterinburg"}
+	regions          = []string{"Moscow", "Leningrad", "Tatarstan", "Novosibirsk", "Kraiot", "Sverdlovsk"}
+	streets          = []string{"Lenina", "Ploshad Mira", "Tverskaya", "Sadovaya", "Nevsky"}
+	banks            = []string{"alpha", "sber", "tinkoff", "vtb"}
+	deliveryServices = []string{"meest", "cdek", "boxberry", "dpd"}
+	itemNames        = []string{"Mascaras", "Lipstick", "T-shirt", "Sneakers", "Backpack", "Headphones", "Mug", "Notebook"}
+	brands           = []string{"Vivienne Sabo", "Nike", "Adidas", "Xiaomi", "Samsung", "Ikea", "No name"}
+	sizes            = []string{"0", "S", "M", "L", "XL", "42"}
+)
+
+// generator produces valid random orders. It is not safe for concurrent use.
+type generator struct {
+	opts generatorOptions
+	rand *rand.Rand
+}
+
+func newGenerator(opts generatorOptions, seed int64) *generator {
+	return &generator{opts: opts, rand: rand.New(rand.NewSource(seed))}
+}
+
+func (g *generator) order() models.Order {
+	uid := g.opts.Prefix + g.hex(16)
+	track := "WBIL" + g.letters(10)
+	created := time.Now().UTC()
+	if g.opts.Spread > 0 {
+		created = created.Add(-time.Duration(g.rand.Int63n(int64(g.opts.Spread))))
+	}
+
+	items := make([]models.Item, g.opts.MinItems+g.rand.Intn(g.opts.MaxItems-g.opts.MinItems+1))
+	goodsTotal := 0
+	for i := range items {
+		price := 100 + g.rand.Intn(10000)
+		sale := g.rand.Intn(6) * 10
+		total := price * (100 - sale) / 100
+		items[i] = models.Item{
+			ChrtID:      g.rand.Int63n(10_000_000),
+			TrackNumber: track,
+			Price:       price,
+			Rid:         g.hex(16) + "test",
+			Name:        pick(g.rand, itemNames),
+			Sale:        sale,
+			Size:        pick(g.rand, sizes),
+			TotalPrice:  total,
+			NmID:        g.rand.Int63n(10_000_000),
+			Brand:       pick(g.rand, brands),
+			Status:      202,
+		}
+		goodsTotal += total
+	}
+	deliveryCost := 100 * g.rand.Intn(20)
+	city := g.rand.Intn(len(cities))
+
+	return models.Order{
+		OrderUID:    uid,
+		TrackNumber: track,
+		Entry:       "WBIL",
+		DeliveryInfo: models.DeliveryInfo{
+			Name:    pick(g.rand, firstNames) + " " + pick(g.rand, lastNames),
+			Phone:   fmt.Sprintf("+7%010d", g.rand.Int63n(10_000_000_000)),
+			Zip:     fmt.Sprintf("%06d", g.rand.Intn(1_000_000)),
+			City:    cities[city],
+			Address: fmt.Sprintf("%s %d", pick(g.rand, streets), 1+g.rand.Intn(200)),
+			Region:  regions[city],
+			Email:   strings.ToLower(g.letters(8)) + "@example.com",
+		},
+		Payment: models.Payment{
+			Transaction:  uid,
+			Currency:     pick(g.rand, g.opts.Currencies),
+			Provider:     pick(g.rand, g.opts.Providers),
+			Amount:       goodsTotal + deliveryCost,
+			PaymentDT:    created.Unix(),
+			Bank:         pick(g.rand, banks),
+			DeliveryCost: deliveryCost,
+			GoodsTotal:   goodsTotal,
+		},
+		Items:           items,
+		Locale:          pick(g.rand, g.opts.Locales),
+		CustomerID:      fmt.Sprintf("customer-%d", g.rand.Intn(g.opts.Customers)),
+		DeliveryService: pick(g.rand, deliveryServices),
+		Shardkey:        fmt.Sprint(g.rand.Intn(10)),
+		SmID:            int64(g.rand.Intn(100)),
+		DateCreated:     created,
+		OofShard:        fmt.Sprint(1 + g.rand.Intn(2)),
+	}
+}
+
+func (g *generator) hex(n int) string {
+	const digits = "0123456789abcdef"
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = digits[g.rand.Intn(len(digits))]
+	}
+	return string(b)
+}
+
+func (g *generator) letters(n int) string {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = byte('A' + g.rand.Intn(26))
+	}
+	return string(b)
+}
+
+func pick(r *rand.Rand, values []string) string {
+	return values[r.Intn(len(values))]
+}
@@ -0,0 +1,274 @@
+// Command orderload generates random valid orders and sends them to the order
+// service over HTTP or through Kafka at a target rate, reporting throughput,
+// latency percentiles and errors.
+package main
+
+import (
+	"context"
+	"errors"
+	"firstmod/internal/models"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+func main() {
+	if err := run(os.Args[1:], os.Stdout, os.Stderr); err != nil {
+		if !errors.Is(err, flag.ErrHelp) {
+			fmt.Fprintln(os.Stderr, "orderload:", err)
+		}
+		os.Exit(1)
+	}
+}
+
+type options struct {
+	target      string
+	endpoint    string
+	apiKey      string
+	timeout     time.Duration
+	brokers     string
+	topic       string
+	rate        float64
+	duration    time.Duration
+	count       int
+	concurrency int
+	batch       int
+	report      time.Duration
+	seed        int64
+	generator   generatorOptions
+}
+
+func parseOptions(args []string, stderr io.Writer) (options, error) {
+	var opts options
+	var items, locales, currencies, providers string
+	flags := flag.NewFlagSet("orderload", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.StringVar(&opts.target, "target", "http", "where to send orders: http or kafka")
+	flags.StringVar(&opts.endpoint, "endpoint", "http://localhost:8081", "base URL of the order service for -target http")
+	flags.StringVar(&opts.apiKey, "api-key", "", "API key sent in X-API-Key for -target http")
+	flags.DurationVar(&opts.timeout, "timeout", 10*time.Second, "timeout of a request")
+	flags.StringVar(&opts.brokers, "brokers", "localhost:9092", "comma-separated Kafka brokers for -target kafka")
+	flags.StringVar(&opts.topic, "topic", "orders", "Kafka topic for -target kafka")
+	flags.Float64Var(&opts.rate, "rate", 100, "orders per second, 0 sends as fast as the workers can")
+	flags.DurationVar(&opts.duration, "duration", 30*time.Second, "how long to run, 0 runs until -count orders are sent or the run is interrupted")
+	flags.IntVar(&opts.count, "count", 0, "number of orders to send, 0 is unlimited")
+	flags.IntVar(&opts.concurrency, "concurrency", 16, "number of requests in flight")
+	flags.IntVar(&opts.batch, "batch", 1, "orders per request: a batch create over HTTP or a batch of Kafka messages")
+	flags.DurationVar(&opts.report, "report", 5*time.Second, "interval of progress reports, 0 disables them")
+	flags.Int64Var(&opts.seed, "seed", 0, "random seed, the same seed generates the same orders (default random)")
+	flags.StringVar(&opts.generator.Prefix, "prefix", "load-", "prefix of generated order UIDs")
+	flags.StringVar(&items, "items", "1-3", "number of items per order, a number or a range such as 1-5")
+	flags.StringVar(&locales, "locales", "en,ru", "comma-separated locales")
+	flags.StringVar(&currencies, "currencies", "RUB,USD,EUR", "comma-separated payment currencies")
+	flags.StringVar(&providers, "providers", "wbpay,yookassa,cloudpayments", "comma-separated payment providers")
+	flags.IntVar(&opts.generator.Customers, "customers", 1000, "number of distinct customers")
+	flags.DurationVar(&opts.generator.Spread, "spread", 0, "spread creation dates over this period before now, e.g. 720h")
+	if err := flags.Parse(args); err != nil {
+		return opts, err
+	}
+	if flags.NArg() > 0 {
+		return opts, fmt.Errorf("unexpected arguments %q", flags.Args())
+	}
+
+	var err error
+	if opts.generator.MinItems, opts.generator.MaxItems, err = parseRange(items); err != nil {
+		return opts, fmt.Errorf("invalid -items: %w", err)
+	}
+	opts.generator.Locales = splitList(locales)
+	opts.generator.Currencies = splitList(currencies)
+	opts.generator.Providers = splitList(providers)
+	switch {
+	case opts.target != "http" && opts.target != "kafka":
+		return opts, fmt.Errorf("unknown target %q, expected http or kafka", opts.target)
+	case opts.rate < 0, opts.count < 0, opts.duration < 0:
+		return opts, errors.New("-rate, -count and -duration must not be negative")
+	case opts.concurrency < 1, opts.batch < 1, opts.generator.Customers < 1:
+		return opts, errors.New("-concurrency, -batch and -customers must be positive")
+	case len(opts.generator.Locales) == 0, len(opts.generator.Currencies) == 0, len(opts.generator.Providers) == 0:
+		return opts, errors.New("-locales, -currencies and -providers must not be empty")
+	}
+	if opts.seed == 0 {
+		opts.seed = time.Now().UnixNano()
+	}
+	return opts, nil
+}
+
+// parseRange parses "n" or "from-to".
+func parseRange(s string) (int, int, error) {
+	first, last, isRange := strings.Cut(s, "-")
+	from, err := strconv.Atoi(strings.TrimSpace(first))
+	if err != nil {
+		return 0, 0, err
+	}
+	to := from
+	if isRange {
+		if to, err = strconv.Atoi(strings.TrimSpace(last)); err != nil {
+			return 0, 0, err
+		}
+	}
+	if from < 0 || to < from {
+		return 0, 0, fmt.Errorf("%q is not a range of non-negative numbers", s)
+	}
+	return from, to, nil
+}
+
+func splitList(s string) []string {
+	var values []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+func run(args []string, stdout, stderr io.Writer) error {
+	opts, err := parseOptions(args, stderr)
+	if err != nil {
+		return err
+	}
+	log := slog.New(slog.NewTextHandler(stderr, &slog.HandlerOptions{Level: slog.LevelWarn}))
+
+	var t target
+	if opts.target == "kafka" {
+		t = newKafkaTarget(log, splitList(opts.brokers), opts.topic)
+	} else {
+		t = newHTTPTarget(opts.endpoint, opts.apiKey, opts.concurrency)
+	}
+	defer t.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if opts.duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.duration)
+		defer cancel()
+	}
+
+	fmt.Fprintf(stdout, "sending orders to %s at %s, seed %d\n", opts.target, formatRate(opts.rate), opts.seed)
+	st := newStats()
+	jobs := make(chan []models.Order, opts.concurrency)
+	var wg sync.WaitGroup
+	for range opts.concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			work(ctx, t, jobs, st, opts.timeout)
+		}()
+	}
+
+	if opts.report > 0 {
+		reportDone := make(chan struct{})
+		defer close(reportDone)
+		go func() {
+			ticker := time.NewTicker(opts.report)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ticker.C:
+					st.reportInterval(stdout)
+				case <-reportDone:
+					return
+				}
+			}
+		}()
+	}
+
+	pace(ctx, newGenerator(opts.generator, opts.seed), jobs, st, opts)
+	close(jobs)
+	wg.Wait()
+
+	fmt.Fprintln(stdout)
+	st.summary(stdout)
+	return nil
+}
+
+func formatRate(rate float64) string {
+	if rate == 0 {
+		return "the highest rate"
+	}
+	return fmt.Sprintf("%g orders/s", rate)
+}
+
+// pace generates orders and hands them to the workers in batches, keeping to
+// the target rate. Batches that find every worker busy are dropped rather
+// than delaying the following ones, so a slow service shows up as dropped
+// orders instead of a lower rate.
+func pace(ctx context.Context, gen *generator, jobs chan<- []models.Order, st *stats, opts options) {
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+	start := time.Now()
+	issued := 0
+	for opts.count == 0 || issued < opts.count {
+		n := opts.batch
+		if opts.count > 0 {
+			n = min(n, opts.count-issued)
+		}
+		if opts.rate > 0 && float64(issued+n) > time.Since(start).Seconds()*opts.rate {
+			select {
+			case <-ticker.C:
+				continue
+			case <-ctx.Done():
+				return
+			}
+		}
+		if ctx.Err() != nil {
+			return
+		}
+
+		orders := make([]models.Order, n)
+		for i := range orders {
+			orders[i] = gen.order()
+		}
+		if opts.rate > 0 {
+			select {
+			case jobs <- orders:
+			default:
+				st.drop(n)
+			}
+		} else {
+			select {
+			case jobs <- orders:
+			case <-ctx.Done():
+				return
+			}
+		}
+		issued += n
+	}
+}
+
+// work sends batches until there are no more or ctx is done. A request in
+// flight when ctx is done is completed, within timeout, so that its outcome
+// is counted.
+func work(ctx context.Context, t target, jobs <-chan []models.Order, st *stats, timeout time.Duration) {
+	for {
+		var orders []models.Order
+		select {
+		case <-ctx.Done():
+			return
+		case batch, ok := <-jobs:
+			if !ok {
+				return
+			}
+			orders = batch
+		}
+
+		sendCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), timeout)
+		start := time.Now()
+		failed, err := t.send(sendCtx, orders)
+		latency := time.Since(start)
+		cancel()
+		if err != nil {
+			failed = map[string]int{errorReason(err): len(orders)}
+		}
+		st.record(len(orders), latency, failed)
+	}
+}
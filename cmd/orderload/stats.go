@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// stats collects the outcome of the sent orders. Latencies are per request,
+// which carries a whole batch.
+type stats struct {
+	mu        sync.Mutex
+	start     time.Time
+	sent      int
+	failed    int
+	dropped   int
+	errors    map[string]int
+	latencies []time.Duration
+
+	// The counters of the current report interval.
+	intervalStart     time.Time
+	intervalSent      int
+	intervalFailed    int
+	intervalLatencies []time.Duration
+}
+
+func newStats() *stats {
+	now := time.Now()
+	return &stats{start: now, intervalStart: now, errors: make(map[string]int)}
+}
+
+// record adds a request of n orders of which the orders in failed were not
+// accepted.
+func (s *stats) record(n int, latency time.Duration, failed map[string]int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sent += n
+	s.intervalSent += n
+	s.latencies = append(s.latencies, latency)
+	s.intervalLatencies = append(s.intervalLatencies, latency)
+	for reason, count := range failed {
+		s.errors[reason] += count
+		s.failed += count
+		s.intervalFailed += count
+	}
+}
+
+// drop counts orders that were not sent because every worker was busy.
+func (s *stats) drop(n int) {
+	s.mu.Lock()
+	s.dropped += n
+	s.mu.Unlock()
+}
+
+// reportInterval writes a line about the orders sent since the previous
+// call and starts a new interval.
+func (s *stats) reportInterval(w io.Writer) {
+	s.mu.Lock()
+	now := time.Now()
+	elapsed := now.Sub(s.intervalStart)
+	line := fmt.Sprintf("%6s  sent %d  %.1f orders/s  errors %d  dropped %d  %s",
+		now.Sub(s.start).Round(time.Second), s.sent, float64(s.intervalSent)/elapsed.Seconds(),
+		s.intervalFailed, s.dropped, formatPercentiles(s.intervalLatencies))
+	s.intervalStart = now
+	s.intervalSent = 0
+	s.intervalFailed = 0
+	s.intervalLatencies = s.intervalLatencies[:0]
+	s.mu.Unlock()
+	fmt.Fprintln(w, line)
+}
+
+// summary writes the totals of the run.
+func (s *stats) summary(w io.Writer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	elapsed := time.Since(s.start)
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "duration\t%s\n", elapsed.Round(time.Millisecond))
+	fmt.Fprintf(tw, "sent\t%d\n", s.sent)
+	fmt.Fprintf(tw, "succeeded\t%d\n", s.sent-s.failed)
+	fmt.Fprintf(tw, "failed\t%d\n", s.failed)
+	fmt.Fprintf(tw, "dropped\t%d\n", s.dropped)
+	fmt.Fprintf(tw, "throughput\t%.1f orders/s\n", float64(s.sent-s.failed)/elapsed.Seconds())
+	fmt.Fprintf(tw, "requests\t%d\n", len(s.latencies))
+	fmt.Fprintf(tw, "latency\t%s\n", formatPercentiles(s.latencies))
+	for _, reason := range slices.Sorted(maps.Keys(s.errors)) {
+		fmt.Fprintf(tw, "error %s\t%d\n", reason, s.errors[reason])
+	}
+	tw.Flush()
+}
+
+func formatPercentiles(latencies []time.Duration) string {
+	if len(latencies) == 0 {
+		return "no requests"
+	}
+	sorted := slices.Clone(latencies)
+	slices.Sort(sorted)
+	at := func(p float64) time.Duration {
+		return sorted[int(p*float64(len(sorted)-1))].Round(10 * time.Microsecond)
+	}
+	return fmt.Sprintf("p50 %s  p90 %s  p99 %s  max %s", at(0.5), at(0.9), at(0.99), sorted[len(sorted)-1].Round(10*time.Microsecond))
+}
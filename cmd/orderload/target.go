@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"firstmod/internal/kafka"
+	"firstmod/internal/models"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// target is where orders are sent. send returns the number of orders that
+// failed per reason; an error fails every order of the batch.
+type target interface {
+	send(ctx context.Context, orders []models.Order) (map[string]int, error)
+	Close() error
+}
+
+// httpTarget posts orders to the order service, one per request or in
+// batches to /orders:batchCreate.
+type httpTarget struct {
+	endpoint string
+	apiKey   string
+	client   *http.Client
+}
+
+func newHTTPTarget(endpoint, apiKey string, concurrency int) *httpTarget {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConnsPerHost = concurrency
+	return &httpTarget{
+		endpoint: strings.TrimSuffix(endpoint, "/"),
+		apiKey:   apiKey,
+		client:   &http.Client{Transport: transport},
+	}
+}
+
+// statusError is a response with an unexpected status.
+type statusError struct {
+	Status int
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("HTTP %d", e.Status)
+}
+
+func (t *httpTarget) send(ctx context.Context, orders []models.Order) (map[string]int, error) {
+	if len(orders) == 1 {
+		data, err := json.Marshal(orders[0])
+		if err != nil {
+			return nil, err
+		}
+		return nil, t.post(ctx, "/order", "application/json", data, http.StatusCreated, nil)
+	}
+
+	var body bytes.Buffer
+	enc := json.NewEncoder(&body)
+	for _, order := range orders {
+		if err := enc.Encode(order); err != nil {
+			return nil, err
+		}
+	}
+	var response struct {
+		Results []models.BatchItemResult
+	}
+	if err := t.post(ctx, "/orders:batchCreate", "application/x-ndjson", body.Bytes(), http.StatusOK, &response); err != nil {
+		return nil, err
+	}
+	failed := make(map[string]int)
+	for _, result := range response.Results {
+		if result.Status != models.BatchItemCreated {
+			failed[string(result.Status)]++
+		}
+	}
+	return failed, nil
+}
+
+func (t *httpTarget) post(ctx context.Context, path, contentType string, body []byte, want int, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.endpoint+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	if t.apiKey != "" {
+		req.Header.Set("X-API-Key", t.apiKey)
+	}
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != want {
+		io.Copy(io.Discard, resp.Body)
+		return &statusError{Status: resp.StatusCode}
+	}
+	if out == nil {
+		_, err = io.Copy(io.Discard, resp.Body)
+		return err
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+func (t *httpTarget) Close() error {
+	t.client.CloseIdleConnections()
+	return nil
+}
+
+// kafkaTarget publishes orders to the topic the order service consumes,
+// keyed by order UID like the service's own producer.
+type kafkaTarget struct {
+	writer kafka.Writer
+}
+
+func newKafkaTarget(log *slog.Logger, brokers []string, topic string) *kafkaTarget {
+	return &kafkaTarget{writer: kafka.NewBrokerWriter(log, brokers, topic)}
+}
+
+func (t *kafkaTarget) send(ctx context.Context, orders []models.Order) (map[string]int, error) {
+	msgs := make([]kafka.Message, 0, len(orders))
+	for _, order := range orders {
+		data, err := json.Marshal(order)
+		if err != nil {
+			return nil, err
+		}
+		msgs = append(msgs, kafka.Message{Key: []byte(order.OrderUID), Value: data})
+	}
+	return nil, t.writer.WriteMessages(ctx, msgs...)
+}
+
+func (t *kafkaTarget) Close() error {
+	return t.writer.Close()
+}
+
+// errorReason groups errors for the report.
+func errorReason(err error) string {
+	var statusErr *statusError
+	var netErr net.Error
+	switch {
+	case errors.As(err, &statusErr):
+		return statusErr.Error()
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return "timeout"
+	case errors.As(err, new(*net.OpError)):
+		return "connection error"
+	}
+	reason := err.Error()
+	if len(reason) > 60 {
+		reason = reason[:60] + "..."
+	}
+	return reason
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// client calls the order service HTTP API.
+type client struct {
+	cfg  config
+	http *http.Client
+}
+
+func newClient(cfg config) *client {
+	return &client{cfg: cfg, http: &http.Client{}}
+}
+
+// apiError is a response with an unexpected status.
+type apiError struct {
+	Status  int
+	Message string
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("%d %s: %s", e.Status, http.StatusText(e.Status), e.Message)
+}
+
+// request is a call of the API. Body is sent with ContentType, which
+// defaults to JSON.
+type request struct {
+	Method      string
+	Path        string
+	Query       url.Values
+	Header      http.Header
+	Body        io.Reader
+	ContentType string
+}
+
+// do sends req and returns the response if its status is 2xx.
+func (c *client) do(ctx context.Context, req request) (*http.Response, error) {
+	u := strings.TrimSuffix(c.cfg.Endpoint, "/") + req.Path
+	if len(req.Query) > 0 {
+		u += "?" + req.Query.Encode()
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, req.Method, u, req.Body)
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range req.Header {
+		httpReq.Header[name] = values
+	}
+	if req.Body != nil {
+		contentType := req.ContentType
+		if contentType == "" {
+			contentType = "application/json"
+		}
+		httpReq.Header.Set("Content-Type", contentType)
+	}
+	if c.cfg.APIKey != "" {
+		httpReq.Header.Set("X-API-Key", c.cfg.APIKey)
+	}
+	if c.cfg.Actor != "" {
+		httpReq.Header.Set("X-Actor", c.cfg.Actor)
+	}
+	if strings.HasPrefix(req.Path, "/admin/") && c.cfg.AdminToken != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+c.cfg.AdminToken)
+	}
+
+	resp, err := c.http.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		defer resp.Body.Close()
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 4<<10))
+		return nil, &apiError{Status: resp.StatusCode, Message: strings.TrimSpace(string(message))}
+	}
+	return resp, nil
+}
+
+// call sends req with in encoded as the JSON body, unless it is nil, and
+// decodes the JSON response into out, unless it is nil.
+func (c *client) call(ctx context.Context, req request, in, out any) error {
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		req.Body = bytes.NewReader(data)
+	}
+	resp, err := c.do(ctx, req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if out == nil {
+		_, err = io.Copy(io.Discard, resp.Body)
+		return err
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
@@ -0,0 +1,662 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"firstmod/internal/models"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	maxEventBytes  = 4 << 20
+	reconnectDelay = time.Second
+)
+
+// parseFlags parses the flags of a subcommand, which must be followed by at
+// least minArgs arguments. The output format may also be chosen after the
+// subcommand.
+func (e *env) parseFlags(flags *flag.FlagSet, args []string, minArgs int, argsUsage string) error {
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: ordersctl %s [flags] %s\n", flags.Name(), argsUsage)
+		flags.PrintDefaults()
+	}
+	output := flags.String("o", "", "output format: table, json or yaml")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *output != "" {
+		out, err := newPrinter(e.stdout, *output)
+		if err != nil {
+			return err
+		}
+		e.out = out
+	}
+	if flags.NArg() < minArgs {
+		flags.Usage()
+		return flag.ErrHelp
+	}
+	return nil
+}
+
+// openInput opens path for reading, "-" being the standard input.
+func openInput(path string) (io.ReadCloser, error) {
+	if path == "-" {
+		return io.NopCloser(os.Stdin), nil
+	}
+	return os.Open(path)
+}
+
+// orderFilter holds the flags narrowing order listings.
+type orderFilter struct {
+	status, customer, deliveryService, currency, from, to string
+}
+
+func (f *orderFilter) register(flags *flag.FlagSet) {
+	flags.StringVar(&f.status, "status", "", "only orders with this status")
+	flags.StringVar(&f.customer, "customer", "", "only orders of this customer")
+	flags.StringVar(&f.deliveryService, "delivery-service", "", "only orders with this delivery service")
+	flags.StringVar(&f.currency, "currency", "", "only orders paid in this currency")
+	flags.StringVar(&f.from, "from", "", "only orders created at or after this RFC 3339 time or YYYY-MM-DD date")
+	flags.StringVar(&f.to, "to", "", "only orders created before this time, or on or before this date")
+}
+
+func (f orderFilter) query() url.Values {
+	q := url.Values{}
+	for name, value := range map[string]string{
+		"status":           f.status,
+		"customer_id":      f.customer,
+		"delivery_service": f.deliveryService,
+		"currency":         f.currency,
+		"from":             f.from,
+		"to":               f.to,
+	} {
+		if value != "" {
+			q.Set(name, value)
+		}
+	}
+	return q
+}
+
+type ordersPage struct {
+	Orders        []models.Order `json:"orders"`
+	NextPageToken string         `json:"next_page_token"`
+}
+
+func (e *env) ordersPage(ctx context.Context, query url.Values) (ordersPage, error) {
+	var page ordersPage
+	err := e.client.call(ctx, request{Method: http.MethodGet, Path: "/orders/list", Query: query}, nil, &page)
+	return page, err
+}
+
+func runGet(ctx context.Context, e *env, args []string) error {
+	flags := flag.NewFlagSet("get", flag.ContinueOnError)
+	if err := e.parseFlags(flags, args, 1, "<order_uid>..."); err != nil {
+		return err
+	}
+	var orders []models.Order
+	for _, uid := range flags.Args() {
+		var order models.Order
+		if err := e.client.call(ctx, request{Method: http.MethodGet, Path: "/order/" + url.PathEscape(uid)}, nil, &order); err != nil {
+			return fmt.Errorf("get %s: %w", uid, err)
+		}
+		orders = append(orders, order)
+	}
+	if len(orders) == 1 {
+		return e.out.print(orders[0])
+	}
+	return e.out.print(orders)
+}
+
+func runList(ctx context.Context, e *env, args []string) error {
+	flags := flag.NewFlagSet("list", flag.ContinueOnError)
+	var filter orderFilter
+	filter.register(flags)
+	pageSize := flags.Int("page-size", 0, "orders per page (default chosen by the service)")
+	pageToken := flags.String("page-token", "", "continue a previous listing")
+	all := flags.Bool("all", false, "list all pages")
+	if err := e.parseFlags(flags, args, 0, ""); err != nil {
+		return err
+	}
+
+	query := filter.query()
+	if *pageSize > 0 {
+		query.Set("page_size", strconv.Itoa(*pageSize))
+	}
+	token := *pageToken
+	orders := []models.Order{}
+	for {
+		query.Set("page_token", token)
+		page, err := e.ordersPage(ctx, query)
+		if err != nil {
+			return err
+		}
+		orders = append(orders, page.Orders...)
+		token = page.NextPageToken
+		if !*all || token == "" {
+			break
+		}
+	}
+	if err := e.out.print(orders); err != nil {
+		return err
+	}
+	if token != "" {
+		fmt.Fprintf(e.stderr, "more orders available: -page-token %s\n", token)
+	}
+	return nil
+}
+
+func runCreate(ctx context.Context, e *env, args []string) error {
+	flags := flag.NewFlagSet("create", flag.ContinueOnError)
+	file := flags.String("f", "", "JSON file with the order, - for the standard input")
+	if err := e.parseFlags(flags, args, 0, ""); err != nil {
+		return err
+	}
+	if *file == "" {
+		flags.Usage()
+		return errors.New("-f is required")
+	}
+	in, err := openInput(*file)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	var response map[string]string
+	if err := e.client.call(ctx, request{Method: http.MethodPost, Path: "/order", Body: in}, nil, &response); err != nil {
+		return err
+	}
+	return e.out.print(response)
+}
+
+func runDelete(ctx context.Context, e *env, args []string) error {
+	flags := flag.NewFlagSet("delete", flag.ContinueOnError)
+	reason := flags.String("reason", "", "reason of the deletion, kept in the order history")
+	version := flags.Int64("version", 0, "only delete if the order is at this version")
+	if err := e.parseFlags(flags, args, 1, "<order_uid>..."); err != nil {
+		return err
+	}
+
+	results := make(map[string]string)
+	for _, uid := range flags.Args() {
+		req := request{Method: http.MethodDelete, Path: "/order/" + url.PathEscape(uid), Header: http.Header{}}
+		if *reason != "" {
+			req.Query = url.Values{"reason": {*reason}}
+		}
+		if *version > 0 {
+			req.Header.Set("If-Match", strconv.Quote(strconv.FormatInt(*version, 10)))
+		}
+		if err := e.client.call(ctx, req, nil, nil); err != nil {
+			return fmt.Errorf("delete %s: %w", uid, err)
+		}
+		results[uid] = "deleted"
+	}
+	return e.out.print(results)
+}
+
+// fileFormat returns the format of an export or import file: the -format
+// flag or, without it, the extension of the file.
+func fileFormat(format, path string) string {
+	if format != "" {
+		return format
+	}
+	if strings.EqualFold(filepath.Ext(path), ".csv") {
+		return "csv"
+	}
+	return "ndjson"
+}
+
+func runExport(ctx context.Context, e *env, args []string) error {
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	var filter orderFilter
+	filter.register(flags)
+	file := flags.String("f", "-", "output file, - for the standard output")
+	format := flags.String("format", "", "ndjson or csv with a row per item (default by the extension of -f, otherwise ndjson)")
+	if err := e.parseFlags(flags, args, 0, ""); err != nil {
+		return err
+	}
+
+	out := e.stdout
+	if *file != "-" {
+		f, err := os.Create(*file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		out = f
+	}
+
+	query := filter.query()
+	query.Set("format", fileFormat(*format, *file))
+	resp, err := e.client.do(ctx, request{Method: http.MethodGet, Path: "/orders/export", Query: query})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if _, err := io.Copy(out, resp.Body); err != nil {
+		return fmt.Errorf("export was cut off: %w", err)
+	}
+	fmt.Fprintf(e.stderr, "exported %s orders\n", resp.Trailer.Get("X-Exported-Orders"))
+	return nil
+}
+
+// progressReader reports how much of the input was read, at most once a
+// second.
+type progressReader struct {
+	r       io.Reader
+	w       io.Writer
+	size    int64
+	read    int64
+	printed time.Time
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	p.read += int64(n)
+	if time.Since(p.printed) >= time.Second || err == io.EOF {
+		p.printed = time.Now()
+		if p.size > 0 {
+			fmt.Fprintf(p.w, "sent %.1f of %.1f MB (%d%%)\n", float64(p.read)/(1<<20), float64(p.size)/(1<<20), p.read*100/p.size)
+		} else {
+			fmt.Fprintf(p.w, "sent %.1f MB\n", float64(p.read)/(1<<20))
+		}
+	}
+	return n, err
+}
+
+func runImport(ctx context.Context, e *env, args []string) error {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	file := flags.String("f", "", "NDJSON, JSON array or CSV file with the orders, - for the standard input")
+	format := flags.String("format", "", "ndjson or csv (default by the extension of -f, otherwise ndjson)")
+	errorsFile := flags.String("errors", "", "write the orders that were not imported to this file as NDJSON")
+	if err := e.parseFlags(flags, args, 0, ""); err != nil {
+		return err
+	}
+	if *file == "" {
+		flags.Usage()
+		return errors.New("-f is required")
+	}
+	in, err := openInput(*file)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	progress := &progressReader{r: in, w: e.stderr, printed: time.Now()}
+	if f, ok := in.(*os.File); ok {
+		if info, err := f.Stat(); err == nil && info.Mode().IsRegular() {
+			progress.size = info.Size()
+		}
+	}
+
+	name := fileFormat(*format, *file)
+	contentType := "application/x-ndjson"
+	if name == "csv" {
+		contentType = "text/csv"
+	}
+	var summary models.ImportSummary
+	req := request{Method: http.MethodPost, Path: "/orders/import", Query: url.Values{"format": {name}}, Body: progress, ContentType: contentType}
+	if err := e.client.call(ctx, req, nil, &summary); err != nil {
+		return err
+	}
+
+	if *errorsFile != "" && len(summary.Errors) > 0 {
+		if err := writeImportErrors(*errorsFile, summary.Errors); err != nil {
+			return err
+		}
+		fmt.Fprintf(e.stderr, "errors written to %s\n", *errorsFile)
+		summary.Errors = nil
+	}
+	if err := e.out.print(summary); err != nil {
+		return err
+	}
+	if n := summary.Invalid + summary.Failed; n > 0 {
+		return fmt.Errorf("%d orders were not imported", n)
+	}
+	return nil
+}
+
+func writeImportErrors(path string, importErrors []models.ImportError) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	for _, e := range importErrors {
+		if err := enc.Encode(e); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func runTail(ctx context.Context, e *env, args []string) error {
+	flags := flag.NewFlagSet("tail", flag.ContinueOnError)
+	customer := flags.String("customer", "", "only events of orders of this customer")
+	deliveryService := flags.String("delivery-service", "", "only events of orders with this delivery service")
+	lastEventID := flags.Uint64("last-event-id", 0, "replay the buffered events after this one")
+	if err := e.parseFlags(flags, args, 0, ""); err != nil {
+		return err
+	}
+
+	query := url.Values{}
+	if *customer != "" {
+		query.Set("customer_id", *customer)
+	}
+	if *deliveryService != "" {
+		query.Set("delivery_service", *deliveryService)
+	}
+	lastID := *lastEventID
+	for {
+		err := e.streamEvents(ctx, query, &lastID)
+		if ctx.Err() != nil {
+			return nil
+		}
+		var apiErr *apiError
+		if errors.As(err, &apiErr) {
+			return err
+		}
+		fmt.Fprintf(e.stderr, "event stream closed (%v), reconnecting\n", err)
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(reconnectDelay):
+		}
+	}
+}
+
+// streamEvents prints the events of the Server-Sent Events stream of the
+// service until it ends, keeping lastID up to date for reconnects.
+func (e *env) streamEvents(ctx context.Context, query url.Values, lastID *uint64) error {
+	req := request{Method: http.MethodGet, Path: "/orders/stream", Query: query, Header: http.Header{}}
+	if *lastID > 0 {
+		req.Header.Set("Last-Event-ID", strconv.FormatUint(*lastID, 10))
+	}
+	resp, err := e.client.do(ctx, req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64<<10), maxEventBytes)
+	var data []byte
+	for scanner.Scan() {
+		line := scanner.Text()
+		if value, ok := strings.CutPrefix(line, "data:"); ok {
+			data = append(data, strings.TrimPrefix(value, " ")...)
+			continue
+		}
+		if line != "" || len(data) == 0 {
+			continue
+		}
+		var event models.OrderEvent
+		if err := json.Unmarshal(data, &event); err != nil {
+			return fmt.Errorf("invalid event: %w", err)
+		}
+		data = data[:0]
+		*lastID = event.ID
+		if err := e.out.print(event); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return io.EOF
+}
+
+func runCheck(ctx context.Context, e *env, args []string) error {
+	flags := flag.NewFlagSet("check", flag.ContinueOnError)
+	repairCache := flags.Bool("repair-cache", false, "reload the cache entries that differ from the database")
+	republish := flags.Bool("republish", false, "publish the orders that were never published to Kafka")
+	sample := flags.Int("sample", 0, "maximum number of order UIDs listed for each difference (default 100)")
+	if err := e.parseFlags(flags, args, 0, ""); err != nil {
+		return err
+	}
+	req := request{Method: http.MethodGet, Path: "/admin/consistency", Query: url.Values{}}
+	var repairs []string
+	if *repairCache {
+		repairs = append(repairs, "cache")
+	}
+	if *republish {
+		repairs = append(repairs, "events")
+	}
+	if len(repairs) > 0 {
+		req.Method = http.MethodPost
+		req.Query.Set("repair", strings.Join(repairs, ","))
+	}
+	if *sample > 0 {
+		req.Query.Set("sample", strconv.Itoa(*sample))
+	}
+	var report models.ConsistencyReport
+	if err := e.client.call(ctx, req, nil, &report); err != nil {
+		return err
+	}
+	if err := e.out.print(report); err != nil {
+		return err
+	}
+	// Differences left unrepaired fail the command so that it can be
+	// scripted.
+	cacheDiffs := report.MissingInCache.Count + report.StaleInCache.Count + report.ExtraInCache.Count
+	if !*repairCache && cacheDiffs > 0 || !*republish && report.Unpublished.Count > 0 {
+		return errors.New("the cache, database and published events are inconsistent")
+	}
+	return nil
+}
+
+func runConsumer(ctx context.Context, e *env, args []string) error {
+	paths := map[string]request{
+		"status": {Method: http.MethodGet, Path: "/admin/consumer"},
+		"pause":  {Method: http.MethodPost, Path: "/admin/consumer/pause"},
+		"resume": {Method: http.MethodPost, Path: "/admin/consumer/resume"},
+	}
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		args = append([]string{"status"}, args...)
+	}
+	req, ok := paths[args[0]]
+	if !ok {
+		fmt.Fprintln(e.stderr, "usage: ordersctl consumer [status | pause | resume]")
+		return flag.ErrHelp
+	}
+	flags := flag.NewFlagSet("consumer "+args[0], flag.ContinueOnError)
+	if err := e.parseFlags(flags, args[1:], 0, ""); err != nil {
+		return err
+	}
+	var status models.ConsumerStatus
+	if err := e.client.call(ctx, req, nil, &status); err != nil {
+		return err
+	}
+	return e.out.print(status)
+}
+
+func runReplay(ctx context.Context, e *env, args []string) error {
+	flags := flag.NewFlagSet("replay", flag.ContinueOnError)
+	to := flags.String("to", "", "where to move the consumer group: earliest, latest, offset or timestamp")
+	offset := flags.Int64("offset", 0, "offset to move to with -to offset")
+	at := flags.String("time", "", "RFC 3339 time to move to with -to timestamp")
+	partitions := flags.String("partitions", "", "comma-separated partitions to move (default all)")
+	dryRun := flags.Bool("dry-run", false, "only show what would be reprocessed")
+	if err := e.parseFlags(flags, args, 0, ""); err != nil {
+		return err
+	}
+	if *to == "" {
+		flags.Usage()
+		return flag.ErrHelp
+	}
+	query := url.Values{"to": {*to}, "dry_run": {strconv.FormatBool(*dryRun)}}
+	if *to == "offset" {
+		query.Set("offset", strconv.FormatInt(*offset, 10))
+	}
+	if *at != "" {
+		query.Set("time", *at)
+	}
+	if *partitions != "" {
+		query.Set("partitions", *partitions)
+	}
+	var result models.OffsetResetResult
+	if err := e.client.call(ctx, request{Method: http.MethodPost, Path: "/admin/consumer/offsets/reset", Query: query}, nil, &result); err != nil {
+		return err
+	}
+	return e.out.print(result)
+}
+
+func runDLQ(ctx context.Context, e *env, args []string) error {
+	subcommands := map[string]command{
+		"list":    runDLQList,
+		"get":     runDLQGet,
+		"redrive": runDLQRedrive,
+		"delete":  runDLQDelete,
+	}
+	if len(args) == 0 || subcommands[args[0]] == nil {
+		fmt.Fprintln(e.stderr, "usage: ordersctl dlq list | get <id> | redrive <id>... | redrive -all | delete <id>...")
+		return flag.ErrHelp
+	}
+	return subcommands[args[0]](ctx, e, args[1:])
+}
+
+type deadLettersPage struct {
+	DeadLetters []models.DeadLetter `json:"dead_letters"`
+}
+
+func (e *env) deadLetters(ctx context.Context, afterID int64, limit int) ([]models.DeadLetter, error) {
+	query := url.Values{"after_id": {strconv.FormatInt(afterID, 10)}, "limit": {strconv.Itoa(limit)}}
+	var page deadLettersPage
+	err := e.client.call(ctx, request{Method: http.MethodGet, Path: "/admin/dlq", Query: query}, nil, &page)
+	return page.DeadLetters, err
+}
+
+func runDLQList(ctx context.Context, e *env, args []string) error {
+	flags := flag.NewFlagSet("dlq list", flag.ContinueOnError)
+	afterID := flags.Int64("after-id", 0, "list dead letters after this ID")
+	limit := flags.Int("limit", 50, "maximum number of dead letters")
+	if err := e.parseFlags(flags, args, 0, ""); err != nil {
+		return err
+	}
+	letters, err := e.deadLetters(ctx, *afterID, *limit)
+	if err != nil {
+		return err
+	}
+	return e.out.print(letters)
+}
+
+// letterIDs parses the dead letter IDs given as arguments.
+func letterIDs(args []string) ([]int64, error) {
+	ids := make([]int64, 0, len(args))
+	for _, arg := range args {
+		id, err := strconv.ParseInt(arg, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid dead letter ID %q", arg)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+func letterPath(id int64) string {
+	return "/admin/dlq/" + strconv.FormatInt(id, 10)
+}
+
+func runDLQGet(ctx context.Context, e *env, args []string) error {
+	flags := flag.NewFlagSet("dlq get", flag.ContinueOnError)
+	if err := e.parseFlags(flags, args, 1, "<id>"); err != nil {
+		return err
+	}
+	ids, err := letterIDs(flags.Args()[:1])
+	if err != nil {
+		return err
+	}
+	var letter models.DeadLetter
+	if err := e.client.call(ctx, request{Method: http.MethodGet, Path: letterPath(ids[0])}, nil, &letter); err != nil {
+		return err
+	}
+	return e.out.print(letter)
+}
+
+func runDLQRedrive(ctx context.Context, e *env, args []string) error {
+	flags := flag.NewFlagSet("dlq redrive", flag.ContinueOnError)
+	all := flags.Bool("all", false, "re-drive every dead letter")
+	if err := e.parseFlags(flags, args, 0, "<id>..."); err != nil {
+		return err
+	}
+	ids, err := letterIDs(flags.Args())
+	if err != nil {
+		return err
+	}
+	if *all {
+		for afterID := int64(0); ; {
+			letters, err := e.deadLetters(ctx, afterID, 500)
+			if err != nil {
+				return err
+			}
+			if len(letters) == 0 {
+				break
+			}
+			for _, l := range letters {
+				ids = append(ids, l.ID)
+			}
+			afterID = letters[len(letters)-1].ID
+		}
+	}
+	if len(ids) == 0 {
+		flags.Usage()
+		return flag.ErrHelp
+	}
+
+	results := make(map[string]string)
+	failed := 0
+	for _, id := range ids {
+		var result struct {
+			OrderUID string `json:"order_uid"`
+			Status   string `json:"status"`
+		}
+		key := strconv.FormatInt(id, 10)
+		if err := e.client.call(ctx, request{Method: http.MethodPost, Path: letterPath(id) + "/redrive"}, nil, &result); err != nil {
+			failed++
+			results[key] = "error: " + err.Error()
+			continue
+		}
+		results[key] = result.Status + " " + result.OrderUID
+	}
+	if err := e.out.print(results); err != nil {
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d dead letters failed again", failed, len(ids))
+	}
+	return nil
+}
+
+func runDLQDelete(ctx context.Context, e *env, args []string) error {
+	flags := flag.NewFlagSet("dlq delete", flag.ContinueOnError)
+	if err := e.parseFlags(flags, args, 1, "<id>..."); err != nil {
+		return err
+	}
+	ids, err := letterIDs(flags.Args())
+	if err != nil {
+		return err
+	}
+	results := make(map[string]string)
+	for _, id := range ids {
+		if err := e.client.call(ctx, request{Method: http.MethodDelete, Path: letterPath(id)}, nil, nil); err != nil {
+			return fmt.Errorf("delete dead letter %d: %w", id, err)
+		}
+		results[strconv.FormatInt(id, 10)] = "deleted"
+	}
+	return e.out.print(results)
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// config is read from a YAML file such as
+//
+//	endpoint: http://localhost:8081
+//	api_key: secret-key
+//	admin_token: secret-token
+//	actor: alice
+//	output: table
+type config struct {
+	Endpoint   string `yaml:"endpoint"`
+	APIKey     string `yaml:"api_key"`
+	AdminToken string `yaml:"admin_token"`
+	Actor      string `yaml:"actor"`
+	Output     string `yaml:"output"`
+}
+
+func defaultConfig() config {
+	return config{Endpoint: "http://localhost:8081", Output: "table"}
+}
+
+// loadConfig reads the configuration file at path. Without a path it reads
+// $ORDERSCTL_CONFIG or the file in the user configuration directory, which
+// may be missing.
+func loadConfig(path string) (config, error) {
+	cfg := defaultConfig()
+	explicit := path != ""
+	if !explicit {
+		path = os.Getenv("ORDERSCTL_CONFIG")
+		explicit = path != ""
+	}
+	if !explicit {
+		dir, err := os.UserConfigDir()
+		if err != nil {
+			return cfg, nil
+		}
+		path = filepath.Join(dir, "ordersctl", "config.yaml")
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !explicit && errors.Is(err, fs.ErrNotExist) {
+			return cfg, nil
+		}
+		return cfg, err
+	}
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return cfg, fmt.Errorf("invalid configuration file %s: %w", path, err)
+	}
+	return cfg, nil
+}
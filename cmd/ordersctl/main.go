@@ -0,0 +1,116 @@
+// Command ordersctl is a command-line client of the order service HTTP API
+// for operators.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+)
+
+const usage = `usage: ordersctl [-config file] [-endpoint url] [-o table|json|yaml] <command> [arguments]
+
+commands:
+  get <order_uid>...             show orders
+  list [filters]                 list orders page by page
+  create -f <file>               create an order from a JSON file
+  delete [-reason r] [-version n] <order_uid>...
+                                 delete orders
+  export [-f file] [-format ndjson|csv] [filters]
+                                 write orders as NDJSON or CSV with a row per item
+  import -f <file> [-format ndjson|csv] [-errors file]
+                                 create orders from an NDJSON, JSON array or CSV file
+  check [-repair-cache] [-republish] [-sample n]
+                                 compare the cache, database and published events
+  consumer [status | pause | resume]
+                                 show, pause or resume the Kafka consumer
+  replay -to earliest|latest|offset|timestamp [-offset n] [-time t] [-partitions p,...] [-dry-run]
+                                 move the consumer group to reprocess or skip messages
+  tail [-customer id] [-delivery-service s] [-last-event-id n]
+                                 follow the live order event stream
+  dlq list [-after-id n] [-limit n]
+  dlq get <id>
+  dlq redrive <id>... | -all     process dead letters again
+  dlq delete <id>...
+
+filters: -status s -customer id -delivery-service s -currency c -from date -to date
+
+Run "ordersctl <command> -h" for the flags of a command.`
+
+// command runs a subcommand with its arguments.
+type command func(ctx context.Context, env *env, args []string) error
+
+var commands = map[string]command{
+	"get":      runGet,
+	"list":     runList,
+	"create":   runCreate,
+	"delete":   runDelete,
+	"export":   runExport,
+	"import":   runImport,
+	"check":    runCheck,
+	"replay":   runReplay,
+	"consumer": runConsumer,
+	"tail":     runTail,
+	"dlq":      runDLQ,
+}
+
+// env is what the subcommands work with.
+type env struct {
+	client *client
+	out    *printer
+	stdout io.Writer
+	stderr io.Writer
+}
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		if !errors.Is(err, flag.ErrHelp) {
+			fmt.Fprintln(os.Stderr, "ordersctl:", err)
+		}
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	flags := flag.NewFlagSet("ordersctl", flag.ContinueOnError)
+	flags.Usage = func() { fmt.Fprintln(flags.Output(), usage) }
+	configPath := flags.String("config", "", "configuration file (default $ORDERSCTL_CONFIG or <user config dir>/ordersctl/config.yaml)")
+	endpoint := flags.String("endpoint", "", "base URL of the order service, overrides the configuration file")
+	output := flags.String("o", "", "output format: table, json or yaml")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return flag.ErrHelp
+	}
+
+	cfg, err := loadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+	if *endpoint != "" {
+		cfg.Endpoint = *endpoint
+	}
+	if *output != "" {
+		cfg.Output = *output
+	}
+	out, err := newPrinter(os.Stdout, cfg.Output)
+	if err != nil {
+		return err
+	}
+
+	cmd, ok := commands[flags.Arg(0)]
+	if !ok {
+		flags.Usage()
+		return fmt.Errorf("unknown command %q", flags.Arg(0))
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	return cmd(ctx, &env{client: newClient(cfg), out: out, stdout: os.Stdout, stderr: os.Stderr}, flags.Args()[1:])
+}
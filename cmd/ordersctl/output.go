@@ -0,0 +1,199 @@
+package main
+
+import (
+	"encoding/json"
+	"firstmod/internal/models"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// printer writes results in the output format chosen by the user.
+type printer struct {
+	w      io.Writer
+	format string
+}
+
+func newPrinter(w io.Writer, format string) (*printer, error) {
+	switch format {
+	case "table", "json", "yaml":
+		return &printer{w: w, format: format}, nil
+	}
+	return nil, fmt.Errorf("unknown output format %q, expected table, json or yaml", format)
+}
+
+func (p *printer) print(v any) error {
+	switch p.format {
+	case "json":
+		enc := json.NewEncoder(p.w)
+		enc.SetIndent("", "    ")
+		return enc.Encode(v)
+	case "yaml":
+		return p.yaml(v)
+	}
+	return p.table(v)
+}
+
+// yaml writes v as YAML with the same field names as its JSON encoding.
+// The JSON is parsed as YAML, JSON being a subset of it, which keeps the
+// order of the fields.
+func (p *printer) yaml(v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	var node yaml.Node
+	if err := yaml.Unmarshal(data, &node); err != nil {
+		return err
+	}
+	blockStyle(&node)
+	if _, err := fmt.Fprintln(p.w, "---"); err != nil {
+		return err
+	}
+	enc := yaml.NewEncoder(p.w)
+	enc.SetIndent(2)
+	if err := enc.Encode(&node); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+// blockStyle drops the flow style and quoting of parsed JSON. Strings that
+// need quotes in YAML still get them.
+func blockStyle(node *yaml.Node) {
+	node.Style = 0
+	for _, child := range node.Content {
+		blockStyle(child)
+	}
+}
+
+func (p *printer) table(v any) error {
+	tw := tabwriter.NewWriter(p.w, 0, 4, 2, ' ', 0)
+	switch v := v.(type) {
+	case models.Order:
+		writeOrders(tw, []models.Order{v})
+	case []models.Order:
+		writeOrders(tw, v)
+	case models.DeadLetter:
+		writeDeadLetters(tw, []models.DeadLetter{v})
+	case []models.DeadLetter:
+		writeDeadLetters(tw, v)
+	case models.OrderEvent:
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\n", v.ID, v.Time.Local().Format(time.DateTime), v.Type, v.OrderUID, v.Order.Status)
+	case models.ImportSummary:
+		fmt.Fprintf(tw, "orders\t%d\ncreated\t%d\nduplicate\t%d\ninvalid\t%d\nfailed\t%d\n", v.Orders, v.Created, v.Duplicate, v.Invalid, v.Failed)
+		for _, e := range v.Errors {
+			fmt.Fprintf(tw, "line %d\t%s\t%s\t%s\n", e.Line, e.OrderUID, e.Status, e.Error)
+		}
+	case models.ConsistencyReport:
+		writeConsistencyReport(tw, v)
+	case models.ConsumerStatus:
+		writeConsumerStatus(tw, v)
+	case models.OffsetResetResult:
+		writeOffsetReset(tw, v)
+	case map[string]string:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			fmt.Fprintf(tw, "%s\t%s\n", k, v[k])
+		}
+	default:
+		tw.Flush()
+		return (&printer{w: p.w, format: "yaml"}).print(v)
+	}
+	return tw.Flush()
+}
+
+func writeOrders(w io.Writer, orders []models.Order) {
+	fmt.Fprintln(w, "ORDER_UID\tSTATUS\tCUSTOMER\tCREATED\tITEMS\tAMOUNT\tVERSION")
+	for _, o := range orders {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%d %s\t%d\n", o.OrderUID, o.Status, o.CustomerID,
+			o.DateCreated.Local().Format(time.DateTime), len(o.Items), o.Payment.Amount, o.Payment.Currency, o.Version)
+	}
+}
+
+func writeDeadLetters(w io.Writer, letters []models.DeadLetter) {
+	fmt.Fprintln(w, "ID\tTOPIC\tPARTITION\tOFFSET\tKEY\tATTEMPTS\tFAILED\tERROR")
+	for _, l := range letters {
+		fmt.Fprintf(w, "%d\t%s\t%d\t%d\t%s\t%d\t%s\t%s\n", l.ID, l.Topic, l.Partition, l.Offset, l.Key, l.Attempts,
+			l.FailedAt.Local().Format(time.DateTime), shorten(l.Error, 60))
+	}
+}
+
+func writeConsistencyReport(w io.Writer, r models.ConsistencyReport) {
+	fmt.Fprintf(w, "checked at\t%s\ndatabase orders\t%d\ncached orders\t%d\n",
+		r.CheckedAt.Local().Format(time.DateTime), r.DBOrders, r.CachedOrders)
+	diffs := []struct {
+		name string
+		diff models.ConsistencyDiff
+	}{
+		{"missing in cache", r.MissingInCache},
+		{"stale in cache", r.StaleInCache},
+		{"extra in cache", r.ExtraInCache},
+		{"incomplete", r.Incomplete},
+		{"unpublished", r.Unpublished},
+	}
+	for _, d := range diffs {
+		uids := strings.Join(d.diff.OrderUIDs, " ")
+		if len(d.diff.OrderUIDs) < d.diff.Count {
+			uids += " …"
+		}
+		fmt.Fprintf(w, "%s\t%d\t%s\n", d.name, d.diff.Count, uids)
+	}
+	fmt.Fprintf(w, "cache repaired\t%d\nrepublished\t%d\n", r.CacheRepaired, r.Republished)
+}
+
+func writeConsumerStatus(w io.Writer, s models.ConsumerStatus) {
+	state := string(s.State)
+	if !s.PausedAt.IsZero() {
+		state += " since " + s.PausedAt.Local().Format(time.DateTime)
+	}
+	lastMessage := "never"
+	if !s.LastMessageAt.IsZero() {
+		lastMessage = s.LastMessageAt.Local().Format(time.DateTime)
+	}
+	fmt.Fprintf(w, "state\t%s\nlast message\t%s\nrate\t%.1f msg/s\nprocessed\t%d\nduplicates\t%d\n",
+		state, lastMessage, s.Rate, s.Processed, s.Duplicates)
+	e := s.Errors
+	fmt.Fprintf(w, "errors\tfetch %d, invalid %d, failed %d, commit %d, dead letter %d\n", e.Fetch, e.Invalid, e.Failed, e.Commit, e.DeadLetter)
+	if s.OffsetsError != "" {
+		fmt.Fprintf(w, "offsets\t%s\n", s.OffsetsError)
+		return
+	}
+	fmt.Fprintf(w, "lag\t%d\n\n", s.Lag)
+	fmt.Fprintln(w, "PARTITION\tASSIGNED\tCURRENT\tCOMMITTED\tEND\tLAG")
+	for _, p := range s.Partitions {
+		current := "-"
+		if p.Current >= 0 {
+			current = fmt.Sprint(p.Current)
+		}
+		fmt.Fprintf(w, "%d\t%t\t%s\t%d\t%d\t%d\n", p.Partition, p.Assigned, current, p.Committed, p.End, p.Lag)
+	}
+}
+
+func writeOffsetReset(w io.Writer, r models.OffsetResetResult) {
+	if r.DryRun {
+		fmt.Fprintln(w, "dry run, offsets were not changed")
+	}
+	fmt.Fprintln(w, "PARTITION\tFIRST\tEND\tCOMMITTED\tTARGET\tREPLAYED\tSKIPPED")
+	for _, p := range r.Partitions {
+		fmt.Fprintf(w, "%d\t%d\t%d\t%d\t%d\t%d\t%d\n", p.Partition, p.FirstOffset, p.EndOffset, p.Committed, p.Target, p.Replayed, p.Skipped)
+	}
+	fmt.Fprintf(w, "total\t\t\t\t\t%d\t%d\n", r.Replayed, r.Skipped)
+}
+
+func shorten(s string, n int) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if len([]rune(s)) <= n {
+		return s
+	}
+	return string([]rune(s)[:n-1]) + "…"
+}
@@ -0,0 +1,124 @@
+package actor
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Header carries the name of the person or system acting on orders. The
+// service has no user accounts, so the value is taken at face value.
+const Header = "X-Actor"
+
+// RequestIDHeader carries the ID used to correlate a request across logs and
+// the audit trail. A new ID is generated if the client does not send one.
+const RequestIDHeader = "X-Request-ID"
+
+const Anonymous = "anonymous"
+
+// MaxLength is the longest actor or request ID accepted; both are stored in
+// VARCHAR(255) columns.
+const MaxLength = 255
+
+// Source is the interface through which a change entered the service.
+type Source string
+
+const (
+	SourceUnknown Source = "unknown"
+	SourceHTTP    Source = "http"
+	SourceGRPC    Source = "grpc"
+	SourceKafka   Source = "kafka"
+	SourceSystem  Source = "system"
+)
+
+type (
+	ctxKey          struct{}
+	sourceCtxKey    struct{}
+	requestIDCtxKey struct{}
+)
+
+func WithName(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, ctxKey{}, name)
+}
+
+// From returns the actor stored in ctx, or Anonymous if there is none.
+func From(ctx context.Context) string {
+	if name, ok := ctx.Value(ctxKey{}).(string); ok && name != "" {
+		return name
+	}
+	return Anonymous
+}
+
+func WithSource(ctx context.Context, source Source) context.Context {
+	return context.WithValue(ctx, sourceCtxKey{}, source)
+}
+
+// SourceFrom returns the source stored in ctx, or SourceUnknown if there is none.
+func SourceFrom(ctx context.Context) Source {
+	if source, ok := ctx.Value(sourceCtxKey{}).(Source); ok && source != "" {
+		return source
+	}
+	return SourceUnknown
+}
+
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDCtxKey{}, id)
+}
+
+// RequestIDFrom returns the request ID stored in ctx, or "" if there is none.
+func RequestIDFrom(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDCtxKey{}).(string)
+	return id
+}
+
+// Valid reports whether value may be used as an actor or request ID: at most
+// MaxLength characters, all of them printable.
+func Valid(value string) bool {
+	if !utf8.ValidString(value) || utf8.RuneCountInString(value) > MaxLength {
+		return false
+	}
+	for _, r := range value {
+		if !unicode.IsPrint(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// NewRequestID returns a random request ID.
+func NewRequestID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Middleware stores the actor, the request ID and SourceHTTP in the request
+// context and echoes the request ID in the response. Requests with an
+// invalid actor or request ID are rejected.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name, requestID := r.Header.Get(Header), r.Header.Get(RequestIDHeader)
+		if !Valid(name) {
+			http.Error(w, "Invalid "+Header+" header", http.StatusBadRequest)
+			return
+		}
+		if !Valid(requestID) {
+			http.Error(w, "Invalid "+RequestIDHeader+" header", http.StatusBadRequest)
+			return
+		}
+
+		ctx := WithSource(r.Context(), SourceHTTP)
+		if name != "" {
+			ctx = WithName(ctx, name)
+		}
+		if requestID == "" {
+			requestID = NewRequestID()
+		}
+		ctx = WithRequestID(ctx, requestID)
+		w.Header().Set(RequestIDHeader, requestID)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
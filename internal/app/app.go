@@ -0,0 +1,333 @@
+// Package app wires the order service together: storage, cache, Kafka,
+// the HTTP and gRPC servers and the background workers.
+package app
+
+import (
+	"context"
+	"errors"
+	"firstmod/internal/archive"
+	"firstmod/internal/cache"
+	"firstmod/internal/config"
+	"firstmod/internal/events"
+	"firstmod/internal/grpcserver"
+	"firstmod/internal/kafka"
+	kafkamemory "firstmod/internal/kafka/memory"
+	"firstmod/internal/partition"
+	"firstmod/internal/ports"
+	"firstmod/internal/ratelimit"
+	"firstmod/internal/repository"
+	"firstmod/internal/repository/memory"
+	"firstmod/internal/repository/sqlite"
+	"firstmod/internal/service"
+	"firstmod/internal/webhook"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+// shutdownTimeout bounds how long Run waits for open requests and streams
+// when stopping.
+const shutdownTimeout = 5 * time.Second
+
+// App is the assembled service. Create it with New and start it with Run.
+type App struct {
+	cfg config.Config
+	log *slog.Logger
+
+	orders ports.Repository
+	// storage is nil unless orders are kept in Postgres; webhooks, archiving,
+	// partitions and replicas are only available there.
+	storage      *repository.DB
+	deadLetters  ports.DeadLetterRepository
+	publications ports.PublicationRepository
+	cache        *cache.Cache
+	bus          *events.Bus
+	service      *service.OrderService
+
+	broker   *kafkamemory.Broker
+	producer *kafka.KafkaProducerImpl
+	consumer *kafka.KafkaConsumerImpl
+
+	limiter      *ratelimit.Limiter
+	httpServer   *http.Server
+	httpListener net.Listener
+	grpcServer   *grpc.Server
+	grpcListener net.Listener
+
+	// ctx is cancelled when the app stops, ending event streams and workers.
+	ctx    context.Context
+	cancel context.CancelFunc
+	// closers release the resources acquired by New, in reverse order.
+	closers []func()
+}
+
+// Option customizes New.
+type Option func(*App)
+
+// WithKafkaBroker makes the app use broker when KAFKA_TRANSPORT is memory
+// instead of creating its own, so messages outlive a restart of the app.
+func WithKafkaBroker(broker *kafkamemory.Broker) Option {
+	return func(a *App) {
+		a.broker = broker
+	}
+}
+
+// New connects to the storage and Kafka, warms up the cache and binds the
+// HTTP and gRPC listeners. Nothing is served until Run is called.
+func New(cfg config.Config, log *slog.Logger, opts ...Option) (_ *App, err error) {
+	a := &App{cfg: cfg, log: log}
+	for _, opt := range opts {
+		opt(a)
+	}
+	a.ctx, a.cancel = context.WithCancel(context.Background())
+	defer func() {
+		if err != nil {
+			a.close()
+		}
+	}()
+
+	if err := a.openStorage(); err != nil {
+		return nil, err
+	}
+
+	a.cache = cache.NewCache(log)
+	log.Info("in-memory cache initialized")
+
+	if err := a.openKafka(); err != nil {
+		return nil, err
+	}
+
+	a.bus = events.NewBus(log)
+
+	a.service = service.NewOrderService(a.orders, a.cache, log, a.producer, a.bus, a.publications)
+	log.Info("order service initialized")
+
+	if a.cfg.KafkaConsume {
+		a.consumer = kafka.NewConsumer(log, a.kafkaGroup(), a.service, a.deadLetters)
+		a.closers = append(a.closers, func() { a.consumer.Close() })
+	}
+
+	loadCtx, cancelLoad := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancelLoad()
+
+	if err := a.service.LoadCacheFromDB(loadCtx); err != nil {
+		log.Error("failed to load cache from database", "error", err)
+	} else {
+		log.Info("cache successfully loaded from database")
+	}
+
+	routeBudgets, err := ratelimit.ParseRoutes(cfg.RateLimitRoutes)
+	if err != nil {
+		log.Error("failed to parse rate limit routes", "error", err)
+		return nil, err
+	}
+	a.limiter = ratelimit.New(log, ratelimit.Budget{Rate: cfg.RateLimitRPS, Burst: cfg.RateLimitBurst}, routeBudgets,
+		strings.Split(cfg.RateLimitAPIKeys, ","), cfg.MaxInFlight)
+
+	a.httpServer = &http.Server{
+		ReadTimeout: cfg.HttpServerTimeout * time.Second,
+		Handler:     a.routes(),
+	}
+	if a.httpListener, err = net.Listen("tcp", cfg.HttpServerAddress); err != nil {
+		log.Error("failed to listen for HTTP", "address", cfg.HttpServerAddress, "error", err)
+		return nil, err
+	}
+	a.closers = append(a.closers, func() { a.httpListener.Close() })
+
+	a.grpcServer = grpcserver.New(log, a.service)
+	if a.grpcListener, err = net.Listen("tcp", cfg.GRPCServerAddress); err != nil {
+		log.Error("failed to listen for gRPC", "address", cfg.GRPCServerAddress, "error", err)
+		return nil, err
+	}
+	a.closers = append(a.closers, func() { a.grpcListener.Close() })
+
+	return a, nil
+}
+
+func (a *App) openStorage() error {
+	switch a.cfg.Storage {
+	case "postgres":
+		var replicas []string
+		if a.cfg.DBReplicas != "" {
+			replicas = strings.Split(a.cfg.DBReplicas, ",")
+		}
+		db, err := repository.New(a.log, a.cfg.PostgresDSN(), replicas...)
+		if err != nil {
+			a.log.Error("failed to connect to db", "error", err)
+			return err
+		}
+		a.closers = append(a.closers, db.Close)
+		a.storage, a.orders, a.deadLetters, a.publications = db, db, db, db
+
+		if a.cfg.AutoMigrate {
+			if err := db.Migrate(); err != nil {
+				a.log.Error("failed to migrate db", "error", err)
+				return err
+			}
+		} else {
+			a.log.Info("automatic migration is disabled")
+		}
+		a.log.Info("successfully connected to database")
+	case "sqlite":
+		db, err := sqlite.New(a.log, a.cfg.SQLitePath)
+		if err != nil {
+			a.log.Error("failed to open SQLite database", "error", err)
+			return err
+		}
+		a.closers = append(a.closers, func() { db.Close() })
+		a.orders, a.deadLetters, a.publications = db, db, db
+	case "memory":
+		repo := memory.New(a.log)
+		a.orders, a.deadLetters, a.publications = repo, repo, repo
+	default:
+		return fmt.Errorf("unknown storage %q", a.cfg.Storage)
+	}
+	if a.storage == nil {
+		a.log.Warn("webhooks, archiving, partition maintenance and replicas need the postgres storage and are disabled", "storage", a.cfg.Storage)
+	}
+	return nil
+}
+
+// openKafka opens the producer of order events. They go to their own topic,
+// so the consumer of the input topic does not read back the orders the
+// service created itself.
+func (a *App) openKafka() error {
+	if a.cfg.KafkaEventsTopic == a.cfg.KafkaTopic {
+		return fmt.Errorf("KAFKA_EVENTS_TOPIC must differ from KAFKA_TOPIC %q", a.cfg.KafkaTopic)
+	}
+	var writer kafka.Writer
+	switch a.cfg.KafkaTransport {
+	case "broker":
+		writer = kafka.NewBrokerWriter(a.log, strings.Split(a.cfg.KafkaBrokers, ","), a.cfg.KafkaEventsTopic)
+	case "memory":
+		if a.broker == nil {
+			a.broker = kafkamemory.NewBroker(a.cfg.KafkaPartitions)
+		}
+		writer = a.broker.Writer(a.cfg.KafkaEventsTopic)
+		a.log.Warn("using in-memory Kafka transport, messages are not sent to brokers", "topic", a.cfg.KafkaEventsTopic)
+	default:
+		return fmt.Errorf("unknown Kafka transport %q", a.cfg.KafkaTransport)
+	}
+	a.producer = kafka.NewProducer(a.log, writer)
+	a.closers = append(a.closers, func() { a.producer.Close() })
+	a.log.Info("Kafka producer initialized")
+	return nil
+}
+
+// kafkaGroup returns the consumer group of the input topic.
+func (a *App) kafkaGroup() kafka.Group {
+	if a.broker != nil {
+		return a.broker.Group(a.cfg.KafkaTopic, a.cfg.KafkaGroupID)
+	}
+	return kafka.NewBrokerGroup(a.log, strings.Split(a.cfg.KafkaBrokers, ","), a.cfg.KafkaTopic, a.cfg.KafkaGroupID)
+}
+
+// HTTPAddr returns the address the HTTP server listens on.
+func (a *App) HTTPAddr() string {
+	return a.httpListener.Addr().String()
+}
+
+// GRPCAddr returns the address the gRPC server listens on.
+func (a *App) GRPCAddr() string {
+	return a.grpcListener.Addr().String()
+}
+
+// Run serves requests and runs the consumer and the background workers
+// until ctx is done, then shuts down gracefully and releases the resources
+// of the app.
+func (a *App) Run(ctx context.Context) error {
+	defer a.close()
+
+	var workers sync.WaitGroup
+	start := func(run func(ctx context.Context)) {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			run(a.ctx)
+		}()
+	}
+
+	start(a.limiter.Run)
+	if a.storage != nil {
+		dispatcher := webhook.NewDispatcher(a.log, a.storage, a.bus, webhook.Config{
+			MaxAttempts:    a.cfg.WebhookAttempts,
+			InitialBackoff: a.cfg.WebhookBackoff,
+			DisableAfter:   a.cfg.WebhookDisable,
+			Timeout:        a.cfg.WebhookTimeout,
+			Concurrency:    8,
+		})
+		start(dispatcher.Run)
+
+		archiver := archive.NewArchiver(a.log, a.storage, a.cfg.ArchiveRetention, a.cfg.ArchiveInterval)
+		start(archiver.Run)
+
+		start(func(ctx context.Context) {
+			a.storage.MonitorReplicas(ctx, a.cfg.ReplicaInterval, a.cfg.ReplicaMaxLag)
+		})
+
+		maintainer := partition.NewMaintainer(a.log, a.storage, a.cache, a.cfg.PartitionPremake, a.cfg.PartitionRetention, a.cfg.PartitionDetach, a.cfg.PartitionInterval)
+		start(maintainer.Run)
+	}
+	if a.consumer != nil {
+		start(a.consumer.StartConsuming)
+	}
+
+	serveErr := make(chan error, 2)
+	go func() {
+		a.log.Info("gRPC server is listening on", "address", a.GRPCAddr())
+		serveErr <- a.grpcServer.Serve(a.grpcListener)
+	}()
+	go func() {
+		a.log.Info("server is listening on", "address", a.HTTPAddr())
+		if err := a.httpServer.Serve(a.httpListener); !errors.Is(err, http.ErrServerClosed) {
+			serveErr <- err
+		}
+	}()
+
+	var err error
+	select {
+	case <-ctx.Done():
+	case err = <-serveErr:
+		a.log.Error("server closed unexpectedly", "error", err)
+	}
+
+	a.log.Debug("shutting down server")
+	a.cancel()
+	a.shutdown()
+	workers.Wait()
+	return err
+}
+
+func (a *App) shutdown() {
+	stopped := make(chan struct{})
+	go func() {
+		a.grpcServer.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(shutdownTimeout):
+		a.log.Warn("gRPC graceful stop timed out, closing open streams")
+		a.grpcServer.Stop()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := a.httpServer.Shutdown(ctx); err != nil {
+		a.log.Error("erroneous shutdown", "error", err)
+	}
+}
+
+func (a *App) close() {
+	a.cancel()
+	for i := len(a.closers) - 1; i >= 0; i-- {
+		a.closers[i]()
+	}
+	a.closers = nil
+}
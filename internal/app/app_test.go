@@ -0,0 +1,471 @@
+package app
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"firstmod/internal/config"
+	"firstmod/internal/kafka"
+	kafkamemory "firstmod/internal/kafka/memory"
+	"firstmod/internal/models"
+	"firstmod/internal/repository/repotest"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const (
+	ordersTopic = "orders"
+	eventsTopic = "order_events"
+	adminToken  = "test-token"
+)
+
+var day = time.Date(2025, 3, 3, 10, 0, 0, 0, time.UTC)
+
+// testConfig returns the configuration of an app that needs no network:
+// orders are kept in a SQLite file in a temporary directory and Kafka is the
+// in-memory broker.
+func testConfig(t *testing.T) config.Config {
+	return config.Config{
+		HttpServerAddress: "127.0.0.1:0",
+		HttpServerTimeout: 5,
+		GRPCServerAddress: "127.0.0.1:0",
+		DBConfig: config.DBConfig{
+			Storage:    "sqlite",
+			SQLitePath: filepath.Join(t.TempDir(), "orders.db"),
+		},
+		KafkaTopic:       ordersTopic,
+		KafkaEventsTopic: eventsTopic,
+		KafkaGroupID:     "order_service",
+		KafkaTransport:   "memory",
+		KafkaPartitions:  3,
+		KafkaConsume:     true,
+		AdminToken:       adminToken,
+	}
+}
+
+// harness is a running app.
+type harness struct {
+	app  *App
+	url  string
+	stop func() error
+}
+
+// start boots the app and stops it at the end of the test unless the test
+// stops it first.
+func start(t *testing.T, cfg config.Config, opts ...Option) *harness {
+	t.Helper()
+	a, err := New(cfg, slog.New(slog.NewTextHandler(io.Discard, nil)), opts...)
+	if err != nil {
+		t.Fatalf("start app: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- a.Run(ctx)
+	}()
+
+	h := &harness{app: a, url: "http://" + a.HTTPAddr()}
+	var stopped bool
+	var runErr error
+	h.stop = func() error {
+		if !stopped {
+			stopped = true
+			cancel()
+			select {
+			case runErr = <-done:
+			case <-time.After(2 * shutdownTimeout):
+				t.Fatal("app did not stop")
+			}
+		}
+		return runErr
+	}
+	t.Cleanup(func() { h.stop() })
+	return h
+}
+
+func (h *harness) do(t *testing.T, method, path string, body any) (*http.Response, []byte) {
+	t.Helper()
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			t.Fatalf("marshal request: %v", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, h.url+path, reader)
+	if err != nil {
+		t.Fatalf("build request: %v", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+adminToken)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("read response of %s %s: %v", method, path, err)
+	}
+	return resp, data
+}
+
+func (h *harness) expect(t *testing.T, method, path string, body any, status int) []byte {
+	t.Helper()
+	resp, data := h.do(t, method, path, body)
+	if resp.StatusCode != status {
+		t.Fatalf("%s %s returned %d, want %d: %s", method, path, resp.StatusCode, status, data)
+	}
+	return data
+}
+
+func (h *harness) orderIDs(t *testing.T) []string {
+	t.Helper()
+	var list struct {
+		OrderUIDs []string `json:"order_uids"`
+	}
+	if err := json.Unmarshal(h.expect(t, http.MethodGet, "/orders/", nil, http.StatusOK), &list); err != nil {
+		t.Fatalf("decode order list: %v", err)
+	}
+	return list.OrderUIDs
+}
+
+// eventually retries check until it succeeds or the deadline passes.
+func eventually(t *testing.T, what string, check func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !check() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestOrderLifecycle(t *testing.T) {
+	h := start(t, testConfig(t))
+
+	for i := range 3 {
+		h.expect(t, http.MethodPost, "/order", repotest.NewOrder(fmt.Sprintf("http-%d", i), day), http.StatusCreated)
+	}
+	h.expect(t, http.MethodPost, "/order", repotest.NewOrder("http-0", day), http.StatusConflict)
+	invalid := repotest.NewOrder("invalid", day)
+	invalid.TrackNumber = ""
+	h.expect(t, http.MethodPost, "/order", invalid, http.StatusBadRequest)
+
+	var order models.Order
+	if err := json.Unmarshal(h.expect(t, http.MethodGet, "/order/http-1", nil, http.StatusOK), &order); err != nil {
+		t.Fatalf("decode order: %v", err)
+	}
+	if order.OrderUID != "http-1" || order.Status != models.StatusCreated || order.Version != models.FirstVersion {
+		t.Errorf("got order %s with status %q and version %d", order.OrderUID, order.Status, order.Version)
+	}
+	if ids := h.orderIDs(t); len(ids) != 3 {
+		t.Errorf("listed orders %v, want 3", ids)
+	}
+
+	h.expect(t, http.MethodDelete, "/order/http-1?reason=test", nil, http.StatusOK)
+	h.expect(t, http.MethodGet, "/order/http-1", nil, http.StatusNotFound)
+	h.expect(t, http.MethodDelete, "/order/missing", nil, http.StatusNotFound)
+	if ids := h.orderIDs(t); len(ids) != 2 {
+		t.Errorf("listed orders %v after delete, want 2", ids)
+	}
+}
+
+// TestItemTransitions cancels and returns single items: once none is left
+// open, the order follows them even though their final states differ.
+func TestItemTransitions(t *testing.T) {
+	h := start(t, testConfig(t))
+	order := repotest.NewOrder("items", day)
+	order.Items = append(order.Items, models.Item{ChrtID: 2, TrackNumber: order.TrackNumber, Name: "Brush", Brand: "Sabo", TotalPrice: 100})
+	h.expect(t, http.MethodPost, "/order", order, http.StatusCreated)
+
+	transition := func(req any) models.Order {
+		t.Helper()
+		var order models.Order
+		if err := json.Unmarshal(h.expect(t, http.MethodPost, "/order/items:transition", req, http.StatusOK), &order); err != nil {
+			t.Fatalf("decode order: %v", err)
+		}
+		return order
+	}
+	// The request uses the field names of the other models, as in the README.
+	transition(json.RawMessage(`{"Status": "cancelled", "ChrtIDs": [2], "Reason": "out of stock"}`))
+	for _, status := range []models.Status{models.StatusPaid, models.StatusAssembling, models.StatusShipped} {
+		transition(models.TransitionRequest{Status: status})
+	}
+	got := transition(models.TransitionRequest{Status: models.StatusReturned, ChrtIDs: []int64{order.Items[0].ChrtID}})
+	if got.Status != models.StatusReturned || got.Items[0].State != models.StatusReturned || got.Items[1].State != models.StatusCancelled {
+		t.Errorf("order is %s with items %s and %s, want returned with a returned and a cancelled item",
+			got.Status, got.Items[0].State, got.Items[1].State)
+	}
+}
+
+func TestKafkaIngestion(t *testing.T) {
+	broker := kafkamemory.NewBroker(3)
+	h := start(t, testConfig(t), WithKafkaBroker(broker))
+
+	writer := broker.Writer(ordersTopic)
+	for _, value := range [][]byte{[]byte("{not json"), mustJSON(t, repotest.NewOrder("kafka-1", day)), mustJSON(t, repotest.NewOrder("kafka-2", day))} {
+		if err := writer.WriteMessages(context.Background(), kafka.Message{Value: value}); err != nil {
+			t.Fatalf("write message: %v", err)
+		}
+	}
+
+	eventually(t, "orders from Kafka", func() bool {
+		resp, _ := h.do(t, http.MethodGet, "/order/kafka-2", nil)
+		return resp.StatusCode == http.StatusOK && len(h.orderIDs(t)) == 2
+	})
+	eventually(t, "the consumer group to commit", func() bool {
+		return broker.Lag(ordersTopic, "order_service") == 0
+	})
+
+	// The malformed message waits in the dead letter queue.
+	var list struct {
+		DeadLetters []models.DeadLetter `json:"dead_letters"`
+	}
+	if err := json.Unmarshal(h.expect(t, http.MethodGet, "/admin/dlq", nil, http.StatusOK), &list); err != nil {
+		t.Fatalf("decode dead letters: %v", err)
+	}
+	if len(list.DeadLetters) != 1 || list.DeadLetters[0].Value != "{not json" {
+		t.Fatalf("dead letters = %+v, want the malformed message", list.DeadLetters)
+	}
+	path := fmt.Sprintf("/admin/dlq/%d", list.DeadLetters[0].ID)
+	h.expect(t, http.MethodPost, path+"/redrive", nil, http.StatusUnprocessableEntity)
+	var letter models.DeadLetter
+	if err := json.Unmarshal(h.expect(t, http.MethodGet, path, nil, http.StatusOK), &letter); err != nil {
+		t.Fatalf("decode dead letter: %v", err)
+	}
+	if letter.Attempts != 2 {
+		t.Errorf("dead letter has %d attempts after a failed re-drive, want 2", letter.Attempts)
+	}
+	h.expect(t, http.MethodDelete, path, nil, http.StatusOK)
+	h.expect(t, http.MethodGet, path, nil, http.StatusNotFound)
+}
+
+func TestReplay(t *testing.T) {
+	broker := kafkamemory.NewBroker(3)
+	h := start(t, testConfig(t), WithKafkaBroker(broker))
+	writer := broker.Writer(ordersTopic)
+	for _, uid := range []string{"replay-1", "replay-2"} {
+		if err := writer.WriteMessages(context.Background(), kafka.Message{Value: mustJSON(t, repotest.NewOrder(uid, day))}); err != nil {
+			t.Fatalf("write message: %v", err)
+		}
+	}
+	eventually(t, "the consumer group to commit", func() bool {
+		return broker.Lag(ordersTopic, "order_service") == 0
+	})
+
+	var result models.OffsetResetResult
+	data := h.expect(t, http.MethodPost, "/admin/consumer/offsets/reset?to=earliest&dry_run=true", nil, http.StatusOK)
+	if err := json.Unmarshal(data, &result); err != nil {
+		t.Fatalf("decode reset: %v", err)
+	}
+	// The orders the service creates go to the events topic and are not
+	// replayed.
+	if !result.DryRun || result.Replayed != 2 {
+		t.Errorf("dry run = %+v, want the 2 input messages replayed", result)
+	}
+	h.expect(t, http.MethodPost, "/admin/consumer/offsets/reset?to=offset", nil, http.StatusBadRequest)
+
+	// A paused consumer stays paused after the reset.
+	var status models.ConsumerStatus
+	if err := json.Unmarshal(h.expect(t, http.MethodPost, "/admin/consumer/pause", nil, http.StatusOK), &status); err != nil {
+		t.Fatalf("decode consumer status: %v", err)
+	}
+	if status.State != models.ConsumerPaused {
+		t.Errorf("consumer is %s after pausing", status.State)
+	}
+	h.expect(t, http.MethodPost, "/admin/consumer/offsets/reset?to=earliest", nil, http.StatusOK)
+	if err := json.Unmarshal(h.expect(t, http.MethodGet, "/admin/consumer", nil, http.StatusOK), &status); err != nil {
+		t.Fatalf("decode consumer status: %v", err)
+	}
+	if status.State != models.ConsumerPaused || status.Lag != result.Replayed {
+		t.Errorf("status after the reset = %+v, want a paused consumer lagging %d messages", status, result.Replayed)
+	}
+	h.expect(t, http.MethodPost, "/admin/consumer/resume", nil, http.StatusOK)
+	eventually(t, "the replay", func() bool {
+		return broker.Lag(ordersTopic, "order_service") == 0
+	})
+}
+
+func TestCacheWarmUpAfterRestart(t *testing.T) {
+	cfg := testConfig(t)
+	h := start(t, cfg)
+	h.expect(t, http.MethodPost, "/order", repotest.NewOrder("warm", day), http.StatusCreated)
+	if err := h.stop(); err != nil {
+		t.Fatalf("stop app: %v", err)
+	}
+
+	h = start(t, cfg)
+	order, ok := h.app.cache.Get("warm")
+	if !ok {
+		t.Fatal("order is not in the cache after restart")
+	}
+	if order.Status != models.StatusCreated {
+		t.Errorf("cached order has status %q, want %q", order.Status, models.StatusCreated)
+	}
+	h.expect(t, http.MethodGet, "/order/warm", nil, http.StatusOK)
+}
+
+func TestGracefulShutdown(t *testing.T) {
+	h := start(t, testConfig(t))
+
+	resp, err := http.Get(h.url + "/orders/stream")
+	if err != nil {
+		t.Fatalf("open event stream: %v", err)
+	}
+	defer resp.Body.Close()
+	h.expect(t, http.MethodPost, "/order", repotest.NewOrder("streamed", day), http.StatusCreated)
+
+	stream := bufio.NewScanner(resp.Body)
+	var received bool
+	for !received && stream.Scan() {
+		received = bytes.Contains(stream.Bytes(), []byte("streamed"))
+	}
+	if !received {
+		t.Fatalf("event stream ended before the order was created: %v", stream.Err())
+	}
+
+	if err := h.stop(); err != nil {
+		t.Fatalf("stop app: %v", err)
+	}
+	// The open stream is closed by the shutdown rather than holding it up.
+	for stream.Scan() {
+	}
+	if _, err := http.Get(h.url + "/orders/"); err == nil {
+		t.Error("app still serves requests after shutdown")
+	}
+}
+
+func TestBulkExportImport(t *testing.T) {
+	source := start(t, testConfig(t))
+	for i := range 3 {
+		order := repotest.NewOrder(fmt.Sprintf("bulk-%d", i), day)
+		if i == 2 {
+			order.Payment.Currency = "RUB"
+		}
+		order.Items = append(order.Items, order.Items[0])
+		source.expect(t, http.MethodPost, "/order", order, http.StatusCreated)
+	}
+
+	resp, err := http.Get(source.url + "/orders/export?format=csv&currency=USD")
+	if err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	exported, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("export returned %d: %v", resp.StatusCode, err)
+	}
+	if n := resp.Trailer.Get("X-Exported-Orders"); n != "2" {
+		t.Errorf("exported %s orders, want 2", n)
+	}
+	if rows := bytes.Count(exported, []byte("\n")); rows != 5 {
+		t.Errorf("export has %d lines, want a header and a row per item:\n%s", rows, exported)
+	}
+
+	target := start(t, testConfig(t))
+	importFile := func(contentType string, body []byte) models.ImportSummary {
+		t.Helper()
+		resp, err := http.Post(target.url+"/orders/import", contentType, bytes.NewReader(body))
+		if err != nil {
+			t.Fatalf("import: %v", err)
+		}
+		defer resp.Body.Close()
+		var summary models.ImportSummary
+		if err := json.NewDecoder(resp.Body).Decode(&summary); err != nil {
+			t.Fatalf("decode import summary: %v", err)
+		}
+		return summary
+	}
+	summary := importFile("text/csv", exported)
+	if summary.Orders != 2 || summary.Created != 2 || len(summary.Errors) != 0 {
+		t.Fatalf("CSV import summary = %+v, want 2 created orders", summary)
+	}
+	var order models.Order
+	if err := json.Unmarshal(target.expect(t, http.MethodGet, "/order/bulk-1", nil, http.StatusOK), &order); err != nil {
+		t.Fatalf("decode order: %v", err)
+	}
+	if len(order.Items) != 2 || order.Payment.Currency != "USD" {
+		t.Errorf("imported order has %d items paid in %s, want 2 items paid in USD", len(order.Items), order.Payment.Currency)
+	}
+
+	ndjson := append(mustJSON(t, repotest.NewOrder("bulk-1", day)), "\n{broken\n"...)
+	ndjson = append(ndjson, mustJSON(t, repotest.NewOrder("bulk-3", day))...)
+	summary = importFile("application/x-ndjson", ndjson)
+	if summary.Created != 1 || summary.Duplicate != 1 || summary.Invalid != 1 ||
+		len(summary.Errors) != 1 || summary.Errors[0].Line != 2 {
+		t.Errorf("NDJSON import summary = %+v, want a created, a duplicate and an invalid order at line 2", summary)
+	}
+}
+
+func TestConsistencyCheck(t *testing.T) {
+	broker := kafkamemory.NewBroker(3)
+	cfg := testConfig(t)
+	cfg.KafkaConsume = false
+	h := start(t, cfg, WithKafkaBroker(broker))
+	for _, uid := range []string{"fine", "uncached", "stale"} {
+		h.expect(t, http.MethodPost, "/order", repotest.NewOrder(uid, day), http.StatusCreated)
+	}
+	// Drift the cache, change an order and add another behind the service's
+	// back, so that the first is stale in the cache and the second is never
+	// published.
+	h.app.cache.Delete("uncached", 0)
+	stale, _ := h.app.cache.Get("stale")
+	stale.TrackNumber = "CHANGED"
+	if _, err := h.app.orders.Update(context.Background(), stale, stale.Version); err != nil {
+		t.Fatalf("update order: %v", err)
+	}
+	h.app.cache.Set(repotest.NewOrder("ghost", day))
+	if err := h.app.orders.Add(context.Background(), repotest.NewOrder("unpublished", day)); err != nil {
+		t.Fatalf("add order: %v", err)
+	}
+	published := len(broker.Messages(eventsTopic))
+
+	check := func(method, query string) models.ConsistencyReport {
+		t.Helper()
+		var report models.ConsistencyReport
+		if err := json.Unmarshal(h.expect(t, method, "/admin/consistency"+query, nil, http.StatusOK), &report); err != nil {
+			t.Fatalf("decode consistency report: %v", err)
+		}
+		return report
+	}
+	report := check(http.MethodGet, "")
+	if report.DBOrders != 4 || report.CachedOrders != 3 {
+		t.Errorf("checked %d orders in the database and %d cached, want 4 and 3", report.DBOrders, report.CachedOrders)
+	}
+	if report.MissingInCache.Count != 2 || report.StaleInCache.OrderUIDs[0] != "stale" ||
+		report.ExtraInCache.OrderUIDs[0] != "ghost" || len(report.Unpublished.OrderUIDs) != 1 ||
+		report.Unpublished.OrderUIDs[0] != "unpublished" {
+		t.Fatalf("report = %+v, want 2 missing, a stale, an extra and an unpublished order", report)
+	}
+	h.expect(t, http.MethodGet, "/admin/consistency?repair=cache", nil, http.StatusMethodNotAllowed)
+
+	report = check(http.MethodPost, "?repair=cache,events")
+	if report.CacheRepaired != 4 || report.Republished != 1 {
+		t.Errorf("repaired %d cache entries and republished %d orders, want 4 and 1", report.CacheRepaired, report.Republished)
+	}
+	if n := len(broker.Messages(eventsTopic)); n != published+1 {
+		t.Errorf("%d messages were published by the repair, want 1", n-published)
+	}
+	report = check(http.MethodGet, "")
+	if report.MissingInCache.Count+report.StaleInCache.Count+report.ExtraInCache.Count+report.Unpublished.Count != 0 {
+		t.Errorf("report after the repair = %+v, want no differences", report)
+	}
+}
+
+func mustJSON(t *testing.T, v any) []byte {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	return data
+}
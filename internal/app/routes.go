@@ -0,0 +1,78 @@
+package app
+
+import (
+	"expvar"
+	"firstmod/internal/actor"
+	"firstmod/internal/handlers"
+	"net/http"
+)
+
+// routes builds the HTTP handler of the app.
+func (a *App) routes() http.Handler {
+	log, orderService := a.log, a.service
+	mux := http.NewServeMux()
+
+	handle := func(pattern string, handler http.Handler) {
+		mux.Handle(pattern, a.limiter.Limit(pattern, handler))
+	}
+
+	handle("POST /order", handlers.CreateOrderHandler(log, orderService))
+	handle("PUT /order/{orderID}", handlers.UpdateOrderHandler(log, orderService))
+	handle("DELETE /order/{orderID}", handlers.DeleteOrderHandler(log, orderService))
+	handle("GET /order/{orderID}", handlers.GetOrderByIDHandler(log, orderService))
+	handle("GET /order/{orderID}/history", handlers.GetOrderHistoryHandler(log, orderService))
+	handle("GET /order/{orderID}/transitions", handlers.GetOrderTransitionsHandler(log, orderService))
+	handle("POST /order/{orderAction}", handlers.OrderActionsHandler(log, map[string]http.Handler{
+		"restore":    handlers.RestoreOrderHandler(log, orderService),
+		"transition": handlers.TransitionOrderHandler(log, orderService),
+	}))
+	handle("GET /orders/", handlers.GetOrdersIDsHandler(log, orderService))
+	handle("GET /orders/list", handlers.ListOrdersHandler(log, orderService))
+	handle("GET /orders/search", handlers.SearchOrdersHandler(log, orderService))
+	handle("GET /orders/deleted", handlers.GetDeletedOrdersHandler(log, orderService))
+	handle("GET /reports/sales", handlers.SalesReportHandler(log, orderService))
+	handle("GET /reports/breakdown/{dimension}", handlers.BreakdownReportHandler(log, orderService))
+	handle("GET /reports/top-items", handlers.TopItemsReportHandler(log, orderService))
+	handle("POST /orders:batchCreate", handlers.BatchCreateOrdersHandler(log, orderService))
+	handle("POST /orders:batchGet", handlers.BatchGetOrdersHandler(log, orderService))
+	handle("GET /orders/export", handlers.ExportOrdersHandler(log, orderService))
+	handle("POST /orders/import", handlers.ImportOrdersHandler(log, orderService))
+	handle("GET /orders/stream", handlers.StreamOrdersSSEHandler(log, orderService, a.ctx))
+	handle("GET /orders/stream/ws", handlers.StreamOrdersWebSocketHandler(log, orderService, a.ctx))
+
+	admin := func(pattern string, handler http.Handler) {
+		handle(pattern, handlers.RequireAdmin(log, a.cfg.AdminToken, handler))
+	}
+
+	admin("GET /admin/dlq", handlers.ListDeadLettersHandler(log, a.deadLetters))
+	admin("GET /admin/dlq/{letterID}", handlers.GetDeadLetterHandler(log, a.deadLetters))
+	admin("DELETE /admin/dlq/{letterID}", handlers.DeleteDeadLetterHandler(log, a.deadLetters))
+	admin("POST /admin/dlq/{letterID}/redrive", handlers.RedriveDeadLetterHandler(log, a.deadLetters, orderService))
+	admin("GET /admin/consistency", handlers.CheckConsistencyHandler(log, orderService))
+	admin("POST /admin/consistency", handlers.CheckConsistencyHandler(log, orderService))
+	if a.consumer != nil {
+		admin("GET /admin/consumer", handlers.ConsumerStatusHandler(log, a.consumer))
+		admin("POST /admin/consumer/pause", handlers.PauseConsumerHandler(log, a.consumer))
+		admin("POST /admin/consumer/resume", handlers.ResumeConsumerHandler(log, a.consumer))
+		admin("POST /admin/consumer/offsets/reset", handlers.ResetConsumerOffsetsHandler(log, a.consumer))
+	}
+
+	if a.storage != nil {
+		admin("POST /admin/webhooks", handlers.CreateWebhookHandler(log, a.storage))
+		admin("GET /admin/webhooks", handlers.ListWebhooksHandler(log, a.storage))
+		admin("GET /admin/webhooks/{webhookID}", handlers.GetWebhookHandler(log, a.storage))
+		admin("DELETE /admin/webhooks/{webhookID}", handlers.DeleteWebhookHandler(log, a.storage))
+		admin("POST /admin/webhooks/{webhookID}/enable", handlers.SetWebhookEnabledHandler(log, a.storage, true))
+		admin("POST /admin/webhooks/{webhookID}/disable", handlers.SetWebhookEnabledHandler(log, a.storage, false))
+		admin("GET /admin/webhooks/{webhookID}/deliveries", handlers.ListWebhookDeliveriesHandler(log, a.storage))
+	}
+
+	mux.Handle("GET /debug/vars", expvar.Handler())
+
+	fileServer := http.FileServer(http.Dir("./static"))
+	mux.Handle("/", fileServer)
+
+	// Event streams are long-lived and would otherwise hold in-flight slots
+	// for their whole lifetime.
+	return a.limiter.LimitInFlight(actor.Middleware(mux), "/orders/stream", "/orders/stream/ws")
+}
@@ -0,0 +1,61 @@
+package archive
+
+import (
+	"context"
+	"firstmod/internal/ports"
+	"log/slog"
+	"time"
+)
+
+const batchSize = 500
+
+// Archiver periodically moves orders that were soft-deleted longer than the
+// retention period ago into the archive.
+type Archiver struct {
+	log       *slog.Logger
+	repo      ports.ArchiveRepository
+	retention time.Duration
+	interval  time.Duration
+}
+
+func NewArchiver(log *slog.Logger, repo ports.ArchiveRepository, retention, interval time.Duration) *Archiver {
+	return &Archiver{
+		log:       log,
+		repo:      repo,
+		retention: retention,
+		interval:  interval,
+	}
+}
+
+// Run archives orders every interval until ctx is cancelled.
+func (a *Archiver) Run(ctx context.Context) {
+	a.log.Info("archiver started", "retention", a.retention, "interval", a.interval)
+	ticker := time.NewTicker(a.interval)
+	defer ticker.Stop()
+	for {
+		a.archive(ctx)
+		select {
+		case <-ctx.Done():
+			a.log.Info("archiver stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (a *Archiver) archive(ctx context.Context) {
+	deletedBefore := time.Now().Add(-a.retention)
+	total := 0
+	for ctx.Err() == nil {
+		n, err := a.repo.ArchiveDeleted(ctx, deletedBefore, batchSize)
+		if err != nil {
+			a.log.Error("failed to archive deleted orders", "error", err)
+			return
+		}
+		total += n
+		if n < batchSize {
+			break
+		}
+	}
+	a.log.Debug("archiving pass finished", "archived", total)
+}
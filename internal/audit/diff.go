@@ -0,0 +1,81 @@
+package audit
+
+import (
+	"encoding/json"
+	"strconv"
+)
+
+// Change is the old and new value of a single field.
+type Change struct {
+	Before any `json:"before"`
+	After  any `json:"after"`
+}
+
+// Diff compares two JSON documents and returns the changed fields keyed by
+// their path, e.g. "DeliveryInfo.City" or "Items.0.Price". A null or empty
+// document is treated as having no fields.
+func Diff(before, after []byte) (map[string]Change, error) {
+	oldFields := make(map[string]any)
+	newFields := make(map[string]any)
+	if err := flattenJSON(before, oldFields); err != nil {
+		return nil, err
+	}
+	if err := flattenJSON(after, newFields); err != nil {
+		return nil, err
+	}
+
+	changes := make(map[string]Change)
+	for path, oldValue := range oldFields {
+		newValue, ok := newFields[path]
+		if !ok {
+			changes[path] = Change{Before: oldValue}
+			continue
+		}
+		if oldValue != newValue {
+			changes[path] = Change{Before: oldValue, After: newValue}
+		}
+	}
+	for path, newValue := range newFields {
+		if _, ok := oldFields[path]; !ok {
+			changes[path] = Change{After: newValue}
+		}
+	}
+	return changes, nil
+}
+
+func flattenJSON(data []byte, fields map[string]any) error {
+	if len(data) == 0 {
+		return nil
+	}
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	flatten("", v, fields)
+	return nil
+}
+
+func flatten(prefix string, v any, fields map[string]any) {
+	join := func(key string) string {
+		if prefix == "" {
+			return key
+		}
+		return prefix + "." + key
+	}
+	switch v := v.(type) {
+	case map[string]any:
+		for key, value := range v {
+			flatten(join(key), value, fields)
+		}
+	case []any:
+		for i, value := range v {
+			flatten(join(strconv.Itoa(i)), value, fields)
+		}
+	case nil:
+		if prefix != "" {
+			fields[prefix] = nil
+		}
+	default:
+		fields[prefix] = v
+	}
+}
@@ -0,0 +1,49 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"firstmod/internal/actor"
+	"firstmod/internal/models"
+	"time"
+)
+
+// NewEntry builds the audit entry for a change of an order made on behalf of
+// the actor in ctx. A nil before or after means the order did not exist
+// before or after the change. ID is left for the storage to assign.
+func NewEntry(ctx context.Context, action models.EventType, orderUID string, before, after *models.Order, changedAt time.Time) (models.AuditEntry, error) {
+	beforeJSON, err := orderJSON(before)
+	if err != nil {
+		return models.AuditEntry{}, err
+	}
+	afterJSON, err := orderJSON(after)
+	if err != nil {
+		return models.AuditEntry{}, err
+	}
+	changes, err := Diff(beforeJSON, afterJSON)
+	if err != nil {
+		return models.AuditEntry{}, err
+	}
+	diff, err := json.Marshal(changes)
+	if err != nil {
+		return models.AuditEntry{}, err
+	}
+	return models.AuditEntry{
+		OrderUID:  orderUID,
+		Action:    action,
+		Actor:     actor.From(ctx),
+		Source:    string(actor.SourceFrom(ctx)),
+		RequestID: actor.RequestIDFrom(ctx),
+		Before:    beforeJSON,
+		After:     afterJSON,
+		Diff:      diff,
+		ChangedAt: changedAt,
+	}, nil
+}
+
+func orderJSON(order *models.Order) ([]byte, error) {
+	if order == nil {
+		return nil, nil
+	}
+	return json.Marshal(order)
+}
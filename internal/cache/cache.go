@@ -6,18 +6,31 @@ import (
 	"firstmod/internal/ports"
 	"log/slog"
 	"sync"
+	"time"
 )
 
+// tombstoneTTL is how long a deleted order keeps older copies of itself out
+// of the cache. It only has to outlast a read of the order that started
+// before the deletion.
+const tombstoneTTL = time.Minute
+
+type tombstone struct {
+	version   int64
+	deletedAt time.Time
+}
+
 type Cache struct {
-	data map[string]models.Order
-	mu   sync.RWMutex
-	log  *slog.Logger
+	data    map[string]models.Order
+	deleted map[string]tombstone
+	mu      sync.RWMutex
+	log     *slog.Logger
 }
 
 func NewCache(log *slog.Logger) *Cache {
 	return &Cache{
-		data: make(map[string]models.Order),
-		log:  log,
+		data:    make(map[string]models.Order),
+		deleted: make(map[string]tombstone),
+		log:     log,
 	}
 }
 
@@ -33,17 +46,46 @@ func (c *Cache) Get(orderUID string) (models.Order, bool) {
 	return order, found
 }
 
-func (c *Cache) Set(order models.Order) {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
+// Set stores the order unless the cache already holds a newer version of it,
+// or it was deleted at that version or later. It reports whether the order
+// was stored.
+func (c *Cache) Set(order models.Order) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if cached, ok := c.data[order.OrderUID]; ok && cached.Version > order.Version {
+		c.log.Debug("newer order version already in cache", "orderUID", order.OrderUID,
+			"version", order.Version, "cached_version", cached.Version)
+		return false
+	}
+	if tomb, ok := c.deleted[order.OrderUID]; ok {
+		if time.Since(tomb.deletedAt) < tombstoneTTL && order.Version <= tomb.version {
+			c.log.Debug("order was deleted at a newer version", "orderUID", order.OrderUID,
+				"version", order.Version, "deleted_version", tomb.version)
+			return false
+		}
+		delete(c.deleted, order.OrderUID)
+	}
 	c.data[order.OrderUID] = order
 	c.log.Debug("order added in cache", "orderUID", order.OrderUID)
+	return true
 }
 
-func (c *Cache) Delete(orderUID string) {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
+// Delete removes the order from the cache. If version, the version of the
+// order after the deletion, is not zero, Set refuses copies up to it for a
+// while.
+func (c *Cache) Delete(orderUID string, version int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	delete(c.data, orderUID)
+	now := time.Now()
+	for uid, tomb := range c.deleted {
+		if now.Sub(tomb.deletedAt) >= tombstoneTTL {
+			delete(c.deleted, uid)
+		}
+	}
+	if version != 0 {
+		c.deleted[orderUID] = tombstone{version: version, deletedAt: now}
+	}
 	c.log.Debug("order was removed from cache", "orderUID", orderUID)
 }
 
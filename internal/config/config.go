@@ -1,6 +1,7 @@
 package config
 
 import (
+	"fmt"
 	"log"
 	"time"
 
@@ -8,30 +9,80 @@ import (
 	"github.com/joho/godotenv"
 )
 
+// Config is the configuration of the service.
 type Config struct {
-	HttpServerAddress string        `env:"HTTP_SERVER_ADDRESS" env-default:"localhost:8081"`
-	HttpServerTimeout time.Duration `env:"HTTP_SERVER_TIMEOUT" env-default:"5s"`
-	LogLevel          string        `env:"LOG_LEVEL" env-default:"DEBUG"`
-	DBHost            string        `env:"DB_HOST" env-default:"db"`
-	DBUser            string        `env:"POSTGRES_USER" env-default:"postgres"`
-	DBPassword        string        `env:"POSTGRES_PASSWORD" env-default:"postgres"`
-	DBName            string        `env:"POSTGRES_NAME" env-default:"postgres"`
-	DBPort            string        `env:"POSTGRES_PORT" env-default:"5432"`
-	KafkaBrokers      string        `env:"KAFKA_BROKERS" env-required:"true"`
-	KafkaTopic        string        `env:"KAFKA_TOPIC" env-required:"true"`
-	KafkaGroupID      string        `env:"KAFKA_GROUP_ID" env-required:"true"`
+	DBConfig
+
+	HttpServerAddress  string        `env:"HTTP_SERVER_ADDRESS" env-default:"localhost:8081"`
+	HttpServerTimeout  time.Duration `env:"HTTP_SERVER_TIMEOUT" env-default:"5s"`
+	GRPCServerAddress  string        `env:"GRPC_SERVER_ADDRESS" env-default:":9090"`
+	DBReplicas         string        `env:"POSTGRES_REPLICAS" env-default:""`
+	ReplicaInterval    time.Duration `env:"REPLICA_CHECK_INTERVAL" env-default:"5s"`
+	ReplicaMaxLag      time.Duration `env:"REPLICA_MAX_LAG" env-default:"5s"`
+	AutoMigrate        bool          `env:"AUTO_MIGRATE" env-default:"true"`
+	KafkaBrokers       string        `env:"KAFKA_BROKERS" env-required:"true"`
+	KafkaTopic         string        `env:"KAFKA_TOPIC" env-required:"true"`
+	KafkaEventsTopic   string        `env:"KAFKA_EVENTS_TOPIC" env-default:"order_events"`
+	KafkaGroupID       string        `env:"KAFKA_GROUP_ID" env-required:"true"`
+	KafkaTransport     string        `env:"KAFKA_TRANSPORT" env-default:"broker"`
+	KafkaPartitions    int           `env:"KAFKA_MEMORY_PARTITIONS" env-default:"3"`
+	KafkaConsume       bool          `env:"KAFKA_CONSUME" env-default:"false"`
+	RateLimitRPS       float64       `env:"RATE_LIMIT_RPS" env-default:"20"`
+	RateLimitBurst     int           `env:"RATE_LIMIT_BURST" env-default:"40"`
+	RateLimitRoutes    string        `env:"RATE_LIMIT_ROUTES" env-default:""`
+	RateLimitAPIKeys   string        `env:"RATE_LIMIT_API_KEYS" env-default:""`
+	MaxInFlight        int           `env:"MAX_IN_FLIGHT_REQUESTS" env-default:"200"`
+	ArchiveRetention   time.Duration `env:"ARCHIVE_RETENTION" env-default:"720h"`
+	ArchiveInterval    time.Duration `env:"ARCHIVE_INTERVAL" env-default:"1h"`
+	PartitionPremake   int           `env:"PARTITION_PREMAKE_MONTHS" env-default:"3"`
+	PartitionRetention int           `env:"PARTITION_RETENTION_MONTHS" env-default:"0"`
+	PartitionDetach    bool          `env:"PARTITION_DETACH_ONLY" env-default:"false"`
+	PartitionInterval  time.Duration `env:"PARTITION_INTERVAL" env-default:"6h"`
+	AdminToken         string        `env:"ADMIN_TOKEN" env-default:""`
+	WebhookAttempts    int           `env:"WEBHOOK_MAX_ATTEMPTS" env-default:"5"`
+	WebhookBackoff     time.Duration `env:"WEBHOOK_INITIAL_BACKOFF" env-default:"1s"`
+	WebhookTimeout     time.Duration `env:"WEBHOOK_TIMEOUT" env-default:"5s"`
+	WebhookDisable     int           `env:"WEBHOOK_DISABLE_AFTER" env-default:"10"`
+}
+
+// DBConfig is the part of the configuration the migrate command needs, so
+// migrations can run without the Kafka and HTTP settings.
+type DBConfig struct {
+	LogLevel   string `env:"LOG_LEVEL" env-default:"DEBUG"`
+	DBHost     string `env:"DB_HOST" env-default:"db"`
+	DBUser     string `env:"POSTGRES_USER" env-default:"postgres"`
+	DBPassword string `env:"POSTGRES_PASSWORD" env-default:"postgres"`
+	DBName     string `env:"POSTGRES_NAME" env-default:"postgres"`
+	DBPort     string `env:"POSTGRES_PORT" env-default:"5432"`
+	Storage    string `env:"STORAGE" env-default:"postgres"`
+	SQLitePath string `env:"SQLITE_PATH" env-default:"orders.db"`
+}
+
+// PostgresDSN returns the connection string of the primary database.
+func (c DBConfig) PostgresDSN() string {
+	return fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s sslmode=disable",
+		c.DBHost, c.DBUser, c.DBPassword, c.DBName, c.DBPort)
 }
 
 func MustLoadCfg(configPath string) Config {
+	var cfg Config
+	mustLoad(configPath, &cfg)
+	return cfg
+}
+
+// MustLoadDBCfg loads only the database settings.
+func MustLoadDBCfg(configPath string) DBConfig {
+	var cfg DBConfig
+	mustLoad(configPath, &cfg)
+	return cfg
+}
+
+func mustLoad(configPath string, cfg any) {
 	if err := godotenv.Load(configPath); err != nil {
 		log.Fatalf("failed to load .env file: %s", err)
 	}
 
-	var cfg Config
-
-	if err := cleanenv.ReadEnv(&cfg); err != nil {
+	if err := cleanenv.ReadEnv(cfg); err != nil {
 		log.Fatalf("failed to read environment variables: %s", err)
 	}
-
-	return cfg
 }
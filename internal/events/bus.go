@@ -0,0 +1,105 @@
+package events
+
+import (
+	"context"
+	"firstmod/internal/models"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+const (
+	subscriberBuffer = 64
+	historySize      = 1024
+)
+
+// Bus is an in-process fan-out of order events. It keeps the most recent
+// events so subscribers can resume after a disconnect. Subscribers that cannot
+// keep up are disconnected instead of blocking publishers.
+type Bus struct {
+	log     *slog.Logger
+	mu      sync.Mutex
+	nextID  uint64
+	history []models.OrderEvent
+	subs    map[chan models.OrderEvent]struct{}
+}
+
+func NewBus(log *slog.Logger) *Bus {
+	return &Bus{
+		log: log,
+		// Seeding IDs with the start time keeps them increasing across restarts,
+		// so a client resuming with an ID from a previous process gets a replay
+		// of everything retained instead of silently skipping new events.
+		nextID:  uint64(time.Now().UnixMicro()),
+		history: make([]models.OrderEvent, 0, historySize),
+		subs:    make(map[chan models.OrderEvent]struct{}),
+	}
+}
+
+func (b *Bus) Publish(event models.OrderEvent) models.OrderEvent {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.nextID++
+	event.ID = b.nextID
+	if event.Time.IsZero() {
+		event.Time = time.Now().UTC()
+	}
+
+	if len(b.history) == historySize {
+		copy(b.history, b.history[1:])
+		b.history = b.history[:historySize-1]
+	}
+	b.history = append(b.history, event)
+
+	for ch := range b.subs {
+		select {
+		case ch <- event:
+		default:
+			b.log.Warn("event subscriber is too slow, disconnecting", "event_id", event.ID)
+			delete(b.subs, ch)
+			close(ch)
+		}
+	}
+	b.log.Debug("order event published", "event_id", event.ID, "type", event.Type, "order_uid", event.OrderUID, "subscribers", len(b.subs))
+	return event
+}
+
+// Subscribe returns a channel receiving every retained event with an ID
+// greater than afterID followed by every event published after the call.
+// Pass 0 to receive only new events. The channel is closed when ctx is done
+// or the subscriber falls behind.
+func (b *Bus) Subscribe(ctx context.Context, afterID uint64) <-chan models.OrderEvent {
+	b.mu.Lock()
+	var replay []models.OrderEvent
+	if afterID > 0 {
+		for _, event := range b.history {
+			if event.ID > afterID {
+				replay = append(replay, event)
+			}
+		}
+		if len(b.history) > 0 && b.history[0].ID > afterID+1 {
+			b.log.Warn("subscriber resumes from an event that is no longer retained", "last_event_id", afterID, "oldest_event_id", b.history[0].ID)
+		}
+	}
+	ch := make(chan models.OrderEvent, subscriberBuffer+len(replay))
+	for _, event := range replay {
+		ch <- event
+	}
+	b.subs[ch] = struct{}{}
+	b.mu.Unlock()
+	b.log.Debug("event subscriber added", "replayed", len(replay))
+
+	go func() {
+		<-ctx.Done()
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if _, ok := b.subs[ch]; ok {
+			delete(b.subs, ch)
+			close(ch)
+		}
+		b.log.Debug("event subscriber removed")
+	}()
+
+	return ch
+}
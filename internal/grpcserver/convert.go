@@ -0,0 +1,150 @@
+package grpcserver
+
+import (
+	orderv1 "firstmod/api/order/v1"
+	"firstmod/internal/models"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func orderToProto(order models.Order) *orderv1.Order {
+	items := make([]*orderv1.Item, 0, len(order.Items))
+	for _, item := range order.Items {
+		items = append(items, &orderv1.Item{
+			ChrtId:      item.ChrtID,
+			TrackNumber: item.TrackNumber,
+			Price:       int64(item.Price),
+			Rid:         item.Rid,
+			Name:        item.Name,
+			Sale:        int64(item.Sale),
+			Size:        item.Size,
+			TotalPrice:  int64(item.TotalPrice),
+			NmId:        item.NmID,
+			Brand:       item.Brand,
+			Status:      int64(item.Status),
+			State:       string(item.State),
+		})
+	}
+	return &orderv1.Order{
+		OrderUid:    order.OrderUID,
+		TrackNumber: order.TrackNumber,
+		Entry:       order.Entry,
+		Delivery: &orderv1.DeliveryInfo{
+			Name:    order.DeliveryInfo.Name,
+			Phone:   order.DeliveryInfo.Phone,
+			Zip:     order.DeliveryInfo.Zip,
+			City:    order.DeliveryInfo.City,
+			Address: order.DeliveryInfo.Address,
+			Region:  order.DeliveryInfo.Region,
+			Email:   order.DeliveryInfo.Email,
+		},
+		Payment: &orderv1.Payment{
+			Transaction:  order.Payment.Transaction,
+			RequestId:    order.Payment.RequestID,
+			Currency:     order.Payment.Currency,
+			Provider:     order.Payment.Provider,
+			Amount:       int64(order.Payment.Amount),
+			PaymentDt:    order.Payment.PaymentDT,
+			Bank:         order.Payment.Bank,
+			DeliveryCost: int64(order.Payment.DeliveryCost),
+			GoodsTotal:   int64(order.Payment.GoodsTotal),
+			CustomFee:    int64(order.Payment.CustomFee),
+		},
+		Items:             items,
+		Locale:            order.Locale,
+		InternalSignature: order.InternalSignature,
+		CustomerId:        order.CustomerID,
+		DeliveryService:   order.DeliveryService,
+		Shardkey:          order.Shardkey,
+		SmId:              order.SmID,
+		DateCreated:       timestamppb.New(order.DateCreated),
+		OofShard:          order.OofShard,
+		Version:           order.Version,
+		Status:            string(order.Status),
+		StatusChangedAt:   timestamppb.New(order.StatusChangedAt),
+	}
+}
+
+func orderFromProto(pb *orderv1.Order) models.Order {
+	order := models.Order{
+		OrderUID:          pb.GetOrderUid(),
+		TrackNumber:       pb.GetTrackNumber(),
+		Entry:             pb.GetEntry(),
+		Locale:            pb.GetLocale(),
+		InternalSignature: pb.GetInternalSignature(),
+		CustomerID:        pb.GetCustomerId(),
+		DeliveryService:   pb.GetDeliveryService(),
+		Shardkey:          pb.GetShardkey(),
+		SmID:              pb.GetSmId(),
+		OofShard:          pb.GetOofShard(),
+		Version:           pb.GetVersion(),
+	}
+	if pb.GetDateCreated() != nil {
+		order.DateCreated = pb.GetDateCreated().AsTime()
+	}
+	if d := pb.GetDelivery(); d != nil {
+		order.DeliveryInfo = models.DeliveryInfo{
+			Name:    d.GetName(),
+			Phone:   d.GetPhone(),
+			Zip:     d.GetZip(),
+			City:    d.GetCity(),
+			Address: d.GetAddress(),
+			Region:  d.GetRegion(),
+			Email:   d.GetEmail(),
+		}
+	}
+	if p := pb.GetPayment(); p != nil {
+		order.Payment = models.Payment{
+			Transaction:  p.GetTransaction(),
+			RequestID:    p.GetRequestId(),
+			Currency:     p.GetCurrency(),
+			Provider:     p.GetProvider(),
+			Amount:       int(p.GetAmount()),
+			PaymentDT:    p.GetPaymentDt(),
+			Bank:         p.GetBank(),
+			DeliveryCost: int(p.GetDeliveryCost()),
+			GoodsTotal:   int(p.GetGoodsTotal()),
+			CustomFee:    int(p.GetCustomFee()),
+		}
+	}
+	for _, item := range pb.GetItems() {
+		order.Items = append(order.Items, models.Item{
+			ChrtID:      item.GetChrtId(),
+			TrackNumber: item.GetTrackNumber(),
+			Price:       int(item.GetPrice()),
+			Rid:         item.GetRid(),
+			Name:        item.GetName(),
+			Sale:        int(item.GetSale()),
+			Size:        item.GetSize(),
+			TotalPrice:  int(item.GetTotalPrice()),
+			NmID:        item.GetNmId(),
+			Brand:       item.GetBrand(),
+			Status:      int(item.GetStatus()),
+		})
+	}
+	return order
+}
+
+func eventToProto(event models.OrderEvent) *orderv1.OrderEvent {
+	pb := &orderv1.OrderEvent{
+		Id:       event.ID,
+		OrderUid: event.OrderUID,
+		Time:     timestamppb.New(event.Time),
+	}
+	switch event.Type {
+	case models.EventOrderCreated:
+		pb.Type = orderv1.OrderEvent_TYPE_CREATED
+	case models.EventOrderUpdated:
+		pb.Type = orderv1.OrderEvent_TYPE_UPDATED
+	case models.EventOrderDeleted:
+		pb.Type = orderv1.OrderEvent_TYPE_DELETED
+	case models.EventOrderRestored:
+		pb.Type = orderv1.OrderEvent_TYPE_RESTORED
+	case models.EventOrderStatusChanged:
+		pb.Type = orderv1.OrderEvent_TYPE_STATUS_CHANGED
+	}
+	if event.Order.OrderUID != "" {
+		pb.Order = orderToProto(event.Order)
+	}
+	return pb
+}
@@ -0,0 +1,175 @@
+package grpcserver
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	orderv1 "firstmod/api/order/v1"
+	"firstmod/internal/actor"
+	"firstmod/internal/models"
+	"firstmod/internal/ports"
+	"log/slog"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+type Server struct {
+	orderv1.UnimplementedOrderServiceServer
+	service ports.OrderService
+	log     *slog.Logger
+}
+
+func New(log *slog.Logger, service ports.OrderService) *grpc.Server {
+	srv := grpc.NewServer(grpc.ChainUnaryInterceptor(loggingInterceptor(log), actorInterceptor))
+	orderv1.RegisterOrderServiceServer(srv, &Server{service: service, log: log})
+	return srv
+}
+
+func (s *Server) GetOrder(ctx context.Context, req *orderv1.GetOrderRequest) (*orderv1.GetOrderResponse, error) {
+	if req.GetOrderUid() == "" {
+		return nil, status.Error(codes.InvalidArgument, "order_uid is required")
+	}
+	order, err := s.service.GetOrder(ctx, req.GetOrderUid())
+	if err != nil {
+		return nil, s.toStatus(err, req.GetOrderUid())
+	}
+	return &orderv1.GetOrderResponse{Order: orderToProto(order)}, nil
+}
+
+func (s *Server) ListOrders(ctx context.Context, req *orderv1.ListOrdersRequest) (*orderv1.ListOrdersResponse, error) {
+	orders, next, err := s.service.ListOrders(ctx, models.OrderFilter{}, req.GetPageToken(), int(req.GetPageSize()))
+	if err != nil {
+		return nil, s.toStatus(err, "")
+	}
+	resp := &orderv1.ListOrdersResponse{NextPageToken: next}
+	for _, order := range orders {
+		resp.Orders = append(resp.Orders, orderToProto(order))
+	}
+	return resp, nil
+}
+
+func (s *Server) CreateOrder(ctx context.Context, req *orderv1.CreateOrderRequest) (*orderv1.CreateOrderResponse, error) {
+	if req.GetOrder() == nil {
+		return nil, status.Error(codes.InvalidArgument, "order is required")
+	}
+	order := orderFromProto(req.GetOrder())
+	if err := s.service.Add(ctx, order); err != nil {
+		return nil, s.toStatus(err, order.OrderUID)
+	}
+	order.Version = models.FirstVersion
+	return &orderv1.CreateOrderResponse{Order: orderToProto(order)}, nil
+}
+
+func (s *Server) UpdateOrder(ctx context.Context, req *orderv1.UpdateOrderRequest) (*orderv1.UpdateOrderResponse, error) {
+	if req.GetOrder() == nil {
+		return nil, status.Error(codes.InvalidArgument, "order is required")
+	}
+	order := orderFromProto(req.GetOrder())
+	updated, err := s.service.Update(ctx, order, order.Version)
+	if err != nil {
+		return nil, s.toStatus(err, order.OrderUID)
+	}
+	return &orderv1.UpdateOrderResponse{Order: orderToProto(updated)}, nil
+}
+
+func (s *Server) DeleteOrder(ctx context.Context, req *orderv1.DeleteOrderRequest) (*orderv1.DeleteOrderResponse, error) {
+	if req.GetOrderUid() == "" {
+		return nil, status.Error(codes.InvalidArgument, "order_uid is required")
+	}
+	if err := s.service.Delete(ctx, req.GetOrderUid(), req.GetReason(), req.GetVersion()); err != nil {
+		return nil, s.toStatus(err, req.GetOrderUid())
+	}
+	return &orderv1.DeleteOrderResponse{}, nil
+}
+
+func (s *Server) WatchOrders(req *orderv1.WatchOrdersRequest, stream orderv1.OrderService_WatchOrdersServer) error {
+	ctx := stream.Context()
+	s.log.Debug("gRPC watcher connected")
+	events := s.service.Subscribe(ctx, 0)
+	for {
+		select {
+		case <-ctx.Done():
+			s.log.Debug("gRPC watcher disconnected")
+			return nil
+		case event, ok := <-events:
+			if !ok {
+				return status.Error(codes.ResourceExhausted, "watcher fell behind the event stream")
+			}
+			if err := stream.Send(&orderv1.WatchOrdersResponse{Event: eventToProto(event)}); err != nil {
+				s.log.Debug("failed to send event to gRPC watcher", "error", err)
+				return err
+			}
+		}
+	}
+}
+
+func (s *Server) TransitionOrder(ctx context.Context, req *orderv1.TransitionOrderRequest) (*orderv1.TransitionOrderResponse, error) {
+	if req.GetOrderUid() == "" {
+		return nil, status.Error(codes.InvalidArgument, "order_uid is required")
+	}
+	order, err := s.service.Transition(ctx, req.GetOrderUid(), models.TransitionRequest{
+		Status:  models.Status(req.GetStatus()),
+		ChrtIDs: req.GetChrtIds(),
+		Reason:  req.GetReason(),
+	}, req.GetVersion())
+	if err != nil {
+		return nil, s.toStatus(err, req.GetOrderUid())
+	}
+	return &orderv1.TransitionOrderResponse{Order: orderToProto(order)}, nil
+}
+
+func (s *Server) toStatus(err error, orderUID string) error {
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return status.Error(codes.NotFound, "order not found")
+	case errors.Is(err, models.ErrOrderExists):
+		return status.Error(codes.AlreadyExists, err.Error())
+	case errors.Is(err, models.ErrInvalidOrder), errors.Is(err, models.ErrInvalidSearch), errors.Is(err, models.ErrInvalidPageToken):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, models.ErrVersionMismatch):
+		return status.Error(codes.Aborted, err.Error())
+	case errors.Is(err, models.ErrInvalidTransition):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, models.ErrDataIntegrity):
+		s.log.Error("gRPC request hit inconsistent data", "order_uid", orderUID, "error", err)
+		return status.Error(codes.DataLoss, "order data is incomplete")
+	case errors.Is(err, context.Canceled):
+		return status.Error(codes.Canceled, err.Error())
+	case errors.Is(err, context.DeadlineExceeded):
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	}
+	s.log.Error("gRPC request failed", "order_uid", orderUID, "error", err)
+	return status.Error(codes.Internal, "internal error")
+}
+
+func loggingInterceptor(log *slog.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		resp, err := handler(ctx, req)
+		log.Debug("handled gRPC request", "method", info.FullMethod, "code", status.Code(err))
+		return resp, err
+	}
+}
+
+// actorInterceptor stores the actor and request ID from the "x-actor" and
+// "x-request-id" metadata keys in the request context, mirroring the headers
+// of the HTTP API.
+func actorInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	ctx = actor.WithSource(ctx, actor.SourceGRPC)
+	if values := metadata.ValueFromIncomingContext(ctx, actor.Header); len(values) > 0 && values[0] != "" {
+		if !actor.Valid(values[0]) {
+			return nil, status.Error(codes.InvalidArgument, "invalid x-actor metadata")
+		}
+		ctx = actor.WithName(ctx, values[0])
+	}
+	requestID := actor.NewRequestID()
+	if values := metadata.ValueFromIncomingContext(ctx, actor.RequestIDHeader); len(values) > 0 && values[0] != "" {
+		if !actor.Valid(values[0]) {
+			return nil, status.Error(codes.InvalidArgument, "invalid x-request-id metadata")
+		}
+		requestID = values[0]
+	}
+	return handler(actor.WithRequestID(ctx, requestID), req)
+}
@@ -0,0 +1,32 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+// OrderActionsHandler routes custom methods addressed as /order/{orderID}:action,
+// which ServeMux cannot match on its own because a wildcard must span a whole
+// path segment. The action follows the last colon, since order UIDs may contain
+// colons themselves. The order UID is exposed to the action handler as the
+// "orderID" path value.
+func OrderActionsHandler(log *slog.Logger, actions map[string]http.Handler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		orderAction := r.PathValue("orderAction")
+		i := strings.LastIndex(orderAction, ":")
+		if i <= 0 {
+			http.Error(w, "Order action is missing", http.StatusNotFound)
+			return
+		}
+		orderUID, action := orderAction[:i], orderAction[i+1:]
+		handler, ok := actions[action]
+		if !ok {
+			log.Info("unknown order action", "action", action, "order_uid", orderUID)
+			http.Error(w, "Unknown order action", http.StatusNotFound)
+			return
+		}
+		r.SetPathValue("orderID", orderUID)
+		handler.ServeHTTP(w, r)
+	}
+}
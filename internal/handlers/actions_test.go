@@ -0,0 +1,42 @@
+package handlers_test
+
+import (
+	"firstmod/internal/handlers"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestOrderActionsHandler(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	mux := http.NewServeMux()
+	mux.Handle("POST /order/{orderAction}", handlers.OrderActionsHandler(log, map[string]http.Handler{
+		"restore": http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			io.WriteString(w, r.PathValue("orderID"))
+		}),
+	}))
+
+	for _, tt := range []struct {
+		path     string
+		code     int
+		orderUID string
+	}{
+		{"/order/b563feb7b2b84b6test:restore", http.StatusOK, "b563feb7b2b84b6test"},
+		{"/order/a:b:restore", http.StatusOK, "a:b"},
+		{"/order/a:b", http.StatusNotFound, ""},
+		{"/order/:restore", http.StatusNotFound, ""},
+		{"/order/plain", http.StatusNotFound, ""},
+	} {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, tt.path, nil))
+		if rec.Code != tt.code {
+			t.Errorf("POST %s = %d, want %d", tt.path, rec.Code, tt.code)
+			continue
+		}
+		if tt.code == http.StatusOK && rec.Body.String() != tt.orderUID {
+			t.Errorf("POST %s routed order %q, want %q", tt.path, rec.Body.String(), tt.orderUID)
+		}
+	}
+}
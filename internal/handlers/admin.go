@@ -0,0 +1,27 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+// RequireAdmin only lets through requests carrying "Authorization: Bearer
+// <token>". With an empty token the admin API is disabled altogether.
+func RequireAdmin(log *slog.Logger, token string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if token == "" {
+			log.Warn("admin API is disabled, set ADMIN_TOKEN to enable it", "path", r.URL.Path)
+			http.Error(w, "Admin API is disabled", http.StatusForbidden)
+			return
+		}
+		provided, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+			log.Warn("unauthorized admin request", "path", r.URL.Path, "remote_addr", r.RemoteAddr)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
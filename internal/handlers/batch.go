@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"firstmod/internal/models"
+	"firstmod/internal/ports"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+const (
+	maxBatchCreateItems = 10000
+	maxBatchGetItems    = 1000
+	maxBatchBodyBytes   = 64 << 20
+	maxNDJSONLineBytes  = 4 << 20
+)
+
+type batchCreateResponse struct {
+	Results   []models.BatchItemResult
+	Created   int
+	Duplicate int
+	Invalid   int
+	Failed    int
+}
+
+type batchGetRequest struct {
+	OrderUIDs []string
+}
+
+// decodeBatch reads orders from either a JSON array or NDJSON. For NDJSON a
+// malformed line does not fail the batch; its error is reported at its index.
+func decodeBatch(w http.ResponseWriter, r *http.Request) ([]models.Order, map[int]error, error) {
+	body := bufio.NewReader(http.MaxBytesReader(w, r.Body, maxBatchBodyBytes))
+	isArray := false
+	if !strings.Contains(r.Header.Get("Content-Type"), "ndjson") {
+		for {
+			b, err := body.Peek(1)
+			if err != nil {
+				return nil, nil, err
+			}
+			if bytes.ContainsAny(b, " \t\r\n") {
+				body.ReadByte()
+				continue
+			}
+			isArray = b[0] == '['
+			break
+		}
+	}
+
+	if isArray {
+		var orders []models.Order
+		if err := json.NewDecoder(body).Decode(&orders); err != nil {
+			return nil, nil, err
+		}
+		return orders, nil, nil
+	}
+
+	var orders []models.Order
+	lineErrs := make(map[int]error)
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64<<10), maxNDJSONLineBytes)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var order models.Order
+		if err := json.Unmarshal(line, &order); err != nil {
+			lineErrs[len(orders)] = err
+		}
+		orders = append(orders, order)
+		if len(orders) > maxBatchCreateItems {
+			break
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, err
+	}
+	return orders, lineErrs, nil
+}
+
+func BatchCreateOrdersHandler(log *slog.Logger, service ports.OrderService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		orders, lineErrs, err := decodeBatch(w, r)
+		if err != nil {
+			log.Error("failed to decode batch request body", "error", err)
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		if len(orders) == 0 {
+			http.Error(w, "Batch is empty", http.StatusBadRequest)
+			return
+		}
+		if len(orders) > maxBatchCreateItems {
+			http.Error(w, fmt.Sprintf("Batch is limited to %d orders", maxBatchCreateItems), http.StatusRequestEntityTooLarge)
+			return
+		}
+
+		results := make([]models.BatchItemResult, len(orders))
+		var parsed []models.Order
+		var parsedIdx []int
+		for i, order := range orders {
+			if err, ok := lineErrs[i]; ok {
+				results[i] = models.BatchItemResult{Index: i, Status: models.BatchItemInvalid, Error: "malformed JSON: " + err.Error()}
+				continue
+			}
+			parsed = append(parsed, order)
+			parsedIdx = append(parsedIdx, i)
+		}
+
+		serviceResults, err := service.BatchCreate(r.Context(), parsed)
+		if err != nil {
+			log.Error("failed to create batch of orders", "count", len(parsed), "error", err)
+			http.Error(w, "Failed to create orders", http.StatusInternalServerError)
+			return
+		}
+		for j, result := range serviceResults {
+			result.Index = parsedIdx[j]
+			results[result.Index] = result
+		}
+
+		response := batchCreateResponse{Results: results}
+		for _, result := range results {
+			switch result.Status {
+			case models.BatchItemCreated:
+				response.Created++
+			case models.BatchItemDuplicate:
+				response.Duplicate++
+			case models.BatchItemInvalid:
+				response.Invalid++
+			case models.BatchItemFailed:
+				response.Failed++
+			}
+		}
+		log.Info("batch create request processed", "count", len(results), "created", response.Created,
+			"duplicate", response.Duplicate, "invalid", response.Invalid, "failed", response.Failed)
+		writeJSON(log, w, http.StatusOK, response)
+	}
+}
+
+func BatchGetOrdersHandler(log *slog.Logger, service ports.OrderService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req batchGetRequest
+		if err := json.NewDecoder(io.LimitReader(r.Body, maxBatchBodyBytes)).Decode(&req); err != nil {
+			log.Error("failed to decode batch get request body", "error", err)
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		if len(req.OrderUIDs) == 0 {
+			http.Error(w, "OrderUIDs is empty", http.StatusBadRequest)
+			return
+		}
+		if len(req.OrderUIDs) > maxBatchGetItems {
+			http.Error(w, fmt.Sprintf("Batch is limited to %d orders", maxBatchGetItems), http.StatusRequestEntityTooLarge)
+			return
+		}
+
+		orders, missing, incomplete, err := service.BatchGet(r.Context(), req.OrderUIDs)
+		if err != nil {
+			log.Error("failed to get batch of orders", "count", len(req.OrderUIDs), "error", err)
+			http.Error(w, "Failed to retrieve orders", http.StatusInternalServerError)
+			return
+		}
+		if missing == nil {
+			missing = []string{}
+		}
+		if incomplete == nil {
+			incomplete = []string{}
+		}
+		writeJSON(log, w, http.StatusOK, map[string]any{"orders": orders, "missing": missing, "incomplete": incomplete})
+	}
+}
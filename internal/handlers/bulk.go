@@ -0,0 +1,186 @@
+package handlers
+
+import (
+	"errors"
+	"firstmod/internal/models"
+	"firstmod/internal/orderfile"
+	"firstmod/internal/ports"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	importBatchSize = 500
+
+	// ExportedOrdersTrailer carries the number of orders of an export.
+	ExportedOrdersTrailer = "X-Exported-Orders"
+)
+
+// countingWriter tells whether anything was written to the response.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// ExportOrdersHandler streams the orders narrowed by the parameters of
+// parseOrderFilter as NDJSON or, with format=csv, as CSV with a row per item.
+// An export that fails midway is cut off rather than ended normally.
+func ExportOrdersHandler(log *slog.Logger, service ports.OrderService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		format, err := orderfile.ParseFormat(r.URL.Query().Get("format"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		filter, err := parseOrderFilter(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", format.ContentType())
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=orders.%s", format))
+		w.Header().Set("Trailer", ExportedOrdersTrailer)
+		body := &countingWriter{w: w}
+		out := orderfile.NewWriter(body, format)
+		exported := 0
+		err = service.ExportOrders(r.Context(), filter, func(order models.Order) error {
+			exported++
+			return out.Write(order)
+		})
+		if err == nil {
+			err = out.Flush()
+		}
+		if err != nil {
+			log.Error("failed to export orders", "exported", exported, "error", err)
+			if body.n == 0 {
+				w.Header().Del("Trailer")
+				http.Error(w, "Failed to export orders", http.StatusInternalServerError)
+				return
+			}
+			panic(http.ErrAbortHandler)
+		}
+		w.Header().Set(ExportedOrdersTrailer, strconv.Itoa(exported))
+	}
+}
+
+// ImportOrdersHandler creates the orders of a file streamed in the request
+// body: NDJSON or a JSON array, or CSV with format=csv or a text/csv body.
+// Orders are validated and created importBatchSize at a time like a batch
+// create, and the response sums up the import with the errors by line.
+func ImportOrdersHandler(log *slog.Logger, service ports.OrderService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := r.URL.Query().Get("format")
+		if name == "" && strings.HasPrefix(r.Header.Get("Content-Type"), "text/csv") {
+			name = string(orderfile.CSV)
+		}
+		format, err := orderfile.ParseFormat(name)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		// Reading a large file takes longer than the server read timeout.
+		if err := http.NewResponseController(w).SetReadDeadline(time.Time{}); err != nil {
+			log.Warn("failed to lift the read deadline of an import", "error", err)
+		}
+
+		var summary models.ImportSummary
+		var batch []models.Order
+		var lines []int
+		flush := func() error {
+			if len(batch) == 0 {
+				return nil
+			}
+			results, err := service.BatchCreate(r.Context(), batch)
+			if err != nil {
+				return err
+			}
+			for _, result := range results {
+				switch result.Status {
+				case models.BatchItemCreated:
+					summary.Created++
+				case models.BatchItemDuplicate:
+					summary.Duplicate++
+				case models.BatchItemInvalid:
+					summary.Invalid++
+				case models.BatchItemFailed:
+					summary.Failed++
+				}
+				if result.Status == models.BatchItemInvalid || result.Status == models.BatchItemFailed {
+					summary.Errors = append(summary.Errors, models.ImportError{
+						Line:     lines[result.Index],
+						OrderUID: result.OrderUID,
+						Status:   result.Status,
+						Error:    result.Error,
+					})
+				}
+			}
+			log.Debug("imported batch of orders", "orders", summary.Orders, "created", summary.Created)
+			batch, lines = batch[:0], lines[:0]
+			return nil
+		}
+
+		// fail reports the batches stored so far along with the line the
+		// failed batch starts on, so the client can resume from there.
+		fail := func(err error) {
+			log.Error("failed to import orders", "orders", summary.Orders, "created", summary.Created, "error", err)
+			summary.Error = fmt.Sprintf("Failed to import the orders from line %d on", lines[0])
+			writeJSON(log, w, http.StatusInternalServerError, summary)
+		}
+
+		reader := orderfile.NewReader(r.Body, format)
+		for {
+			record, err := reader.Read()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				if flushErr := flush(); flushErr != nil {
+					err = errors.Join(err, flushErr)
+				}
+				log.Warn("failed to read import", "orders", summary.Orders, "created", summary.Created, "error", err)
+				http.Error(w, fmt.Sprintf("Invalid import file after %d orders, %d of them created: %v",
+					summary.Orders, summary.Created, err), http.StatusBadRequest)
+				return
+			}
+			summary.Orders++
+			if record.Err != nil {
+				summary.Invalid++
+				summary.Errors = append(summary.Errors, models.ImportError{
+					Line:     record.Line,
+					OrderUID: record.Order.OrderUID,
+					Status:   models.BatchItemInvalid,
+					Error:    record.Err.Error(),
+				})
+				continue
+			}
+			batch = append(batch, record.Order)
+			lines = append(lines, record.Line)
+			if len(batch) == importBatchSize {
+				if err := flush(); err != nil {
+					fail(err)
+					return
+				}
+			}
+		}
+		if err := flush(); err != nil {
+			fail(err)
+			return
+		}
+
+		log.Info("orders imported", "orders", summary.Orders, "created", summary.Created, "duplicate", summary.Duplicate,
+			"invalid", summary.Invalid, "failed", summary.Failed)
+		writeJSON(log, w, http.StatusOK, summary)
+	}
+}
@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"firstmod/internal/models"
+	"firstmod/internal/ports"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// CheckConsistencyHandler compares the cache with the database and lists the
+// orders that were never published to Kafka. A POST may also repair the
+// differences: repair=cache reloads the cache entries that differ from the
+// database and repair=events publishes the unpublished orders; both can be
+// given separated by a comma. The sample parameter limits the order UIDs
+// listed for each kind of difference.
+func CheckConsistencyHandler(log *slog.Logger, service ports.OrderService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		var opts models.ConsistencyOptions
+		if v := query.Get("sample"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n <= 0 {
+				http.Error(w, "Invalid sample", http.StatusBadRequest)
+				return
+			}
+			opts.Sample = n
+		}
+		if v := query.Get("repair"); v != "" {
+			if r.Method != http.MethodPost {
+				http.Error(w, "Repairs require POST", http.StatusMethodNotAllowed)
+				return
+			}
+			for _, what := range strings.Split(v, ",") {
+				switch strings.TrimSpace(what) {
+				case "cache":
+					opts.RepairCache = true
+				case "events":
+					opts.Republish = true
+				default:
+					http.Error(w, fmt.Sprintf("Unknown repair %q, expected cache or events", what), http.StatusBadRequest)
+					return
+				}
+			}
+		}
+
+		report, err := service.CheckConsistency(r.Context(), opts)
+		if err != nil {
+			log.Error("failed to check consistency", "error", err)
+			http.Error(w, "Failed to check consistency", http.StatusInternalServerError)
+			return
+		}
+		writeJSON(log, w, http.StatusOK, report)
+	}
+}
@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"errors"
+	"firstmod/internal/models"
+	"firstmod/internal/ports"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// parseOffsetReset reads an offset reset from the query: to is earliest,
+// latest, offset with the offset parameter or timestamp with the time
+// parameter, an RFC 3339 timestamp. partitions lists the partitions to reset
+// separated by commas and dry_run=true only reports the reset.
+func parseOffsetReset(r *http.Request) (models.OffsetReset, error) {
+	query := r.URL.Query()
+	reset := models.OffsetReset{To: models.ResetTarget(query.Get("to"))}
+	if v := query.Get("offset"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return reset, errors.New("invalid offset")
+		}
+		reset.Offset = n
+	} else if reset.To == models.ResetToOffset {
+		return reset, errors.New("no offset")
+	}
+	if v := query.Get("time"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return reset, errors.New("invalid time: expected RFC 3339 timestamp")
+		}
+		reset.Time = t
+	}
+	if v := query.Get("partitions"); v != "" {
+		for _, p := range strings.Split(v, ",") {
+			n, err := strconv.Atoi(strings.TrimSpace(p))
+			if err != nil {
+				return reset, fmt.Errorf("invalid partition %q", p)
+			}
+			reset.Partitions = append(reset.Partitions, n)
+		}
+	}
+	if v := query.Get("dry_run"); v != "" {
+		dryRun, err := strconv.ParseBool(v)
+		if err != nil {
+			return reset, errors.New("invalid dry_run")
+		}
+		reset.DryRun = dryRun
+	}
+	return reset, nil
+}
+
+// ResetConsumerOffsetsHandler moves the consumer group to the offsets of
+// parseOffsetReset, so that the consumer reprocesses or skips messages.
+func ResetConsumerOffsetsHandler(log *slog.Logger, consumer ports.KafkaConsumer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		reset, err := parseOffsetReset(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		result, err := consumer.ResetOffsets(r.Context(), reset)
+		if err != nil {
+			switch {
+			case errors.Is(err, models.ErrInvalidOffsetReset):
+				http.Error(w, err.Error(), http.StatusBadRequest)
+			case errors.Is(err, models.ErrConsumerGroupActive):
+				http.Error(w, "Other consumers are members of the group, stop them before resetting offsets: "+err.Error(), http.StatusConflict)
+			default:
+				log.Error("failed to reset consumer offsets", "to", reset.To, "error", err)
+				http.Error(w, "Failed to reset consumer offsets", http.StatusInternalServerError)
+			}
+			return
+		}
+		writeJSON(log, w, http.StatusOK, result)
+	}
+}
+
+// ConsumerStatusHandler reports the state of the consumer, the offsets of
+// the partitions and the counters of processed messages and errors.
+func ConsumerStatusHandler(log *slog.Logger, consumer ports.KafkaConsumer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(log, w, http.StatusOK, consumer.Status(r.Context()))
+	}
+}
+
+// PauseConsumerHandler stops the consumer until it is resumed, waiting for
+// the message being processed, and responds with the status.
+func PauseConsumerHandler(log *slog.Logger, consumer ports.KafkaConsumer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := consumer.Pause(r.Context()); err != nil {
+			log.Error("failed to pause consumer", "error", err)
+			http.Error(w, "Failed to pause consumer", http.StatusInternalServerError)
+			return
+		}
+		writeJSON(log, w, http.StatusOK, consumer.Status(r.Context()))
+	}
+}
+
+func ResumeConsumerHandler(log *slog.Logger, consumer ports.KafkaConsumer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		consumer.Resume()
+		writeJSON(log, w, http.StatusOK, consumer.Status(r.Context()))
+	}
+}
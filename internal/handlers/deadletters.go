@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"firstmod/internal/models"
+	"firstmod/internal/ports"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+)
+
+const (
+	defaultDeadLettersLimit = 50
+	maxDeadLettersLimit     = 500
+)
+
+func deadLetterID(w http.ResponseWriter, r *http.Request, log *slog.Logger) (int64, bool) {
+	id, err := strconv.ParseInt(r.PathValue("letterID"), 10, 64)
+	if err != nil {
+		log.Info("invalid dead letter ID in URL path", "value", r.PathValue("letterID"))
+		http.Error(w, "Invalid dead letter ID", http.StatusBadRequest)
+		return 0, false
+	}
+	return id, true
+}
+
+// ListDeadLettersHandler returns the dead letters oldest first. The after_id
+// parameter continues the listing after the last returned dead letter.
+func ListDeadLettersHandler(log *slog.Logger, store ports.DeadLetterRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		limit := defaultDeadLettersLimit
+		if v := query.Get("limit"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n <= 0 {
+				http.Error(w, "Invalid limit", http.StatusBadRequest)
+				return
+			}
+			limit = min(n, maxDeadLettersLimit)
+		}
+		var afterID int64
+		if v := query.Get("after_id"); v != "" {
+			n, err := strconv.ParseInt(v, 10, 64)
+			if err != nil || n < 0 {
+				http.Error(w, "Invalid after_id", http.StatusBadRequest)
+				return
+			}
+			afterID = n
+		}
+		letters, err := store.ListDeadLetters(r.Context(), afterID, limit)
+		if err != nil {
+			http.Error(w, "Failed to retrieve dead letters", http.StatusInternalServerError)
+			return
+		}
+		if letters == nil {
+			letters = []models.DeadLetter{}
+		}
+		writeJSON(log, w, http.StatusOK, map[string][]models.DeadLetter{"dead_letters": letters})
+	}
+}
+
+func GetDeadLetterHandler(log *slog.Logger, store ports.DeadLetterRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, ok := deadLetterID(w, r, log)
+		if !ok {
+			return
+		}
+		letter, err := store.GetDeadLetter(r.Context(), id)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				http.Error(w, "Dead letter not found", http.StatusNotFound)
+				return
+			}
+			http.Error(w, "Failed to retrieve dead letter", http.StatusInternalServerError)
+			return
+		}
+		writeJSON(log, w, http.StatusOK, letter)
+	}
+}
+
+func DeleteDeadLetterHandler(log *slog.Logger, store ports.DeadLetterRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, ok := deadLetterID(w, r, log)
+		if !ok {
+			return
+		}
+		if err := store.DeleteDeadLetter(r.Context(), id); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				http.Error(w, "Dead letter not found", http.StatusNotFound)
+				return
+			}
+			http.Error(w, "Failed to delete dead letter", http.StatusInternalServerError)
+			return
+		}
+		writeJSON(log, w, http.StatusOK, map[string]string{"message": "Dead letter deleted successfully"})
+	}
+}
+
+// RedriveDeadLetterHandler processes a dead letter again as the consumer
+// would. The dead letter is deleted once its order is stored, or found to
+// be stored already; otherwise the failure is recorded on it and the
+// request fails with 422.
+func RedriveDeadLetterHandler(log *slog.Logger, store ports.DeadLetterRepository, service ports.OrderService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, ok := deadLetterID(w, r, log)
+		if !ok {
+			return
+		}
+		letter, err := store.GetDeadLetter(r.Context(), id)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				http.Error(w, "Dead letter not found", http.StatusNotFound)
+				return
+			}
+			http.Error(w, "Failed to retrieve dead letter", http.StatusInternalServerError)
+			return
+		}
+
+		var order models.Order
+		err = json.Unmarshal([]byte(letter.Value), &order)
+		if err == nil {
+			err = service.Add(r.Context(), order)
+		}
+		status := models.BatchItemCreated
+		switch {
+		case err == nil:
+		case errors.Is(err, models.ErrOrderExists):
+			status = models.BatchItemDuplicate
+		default:
+			log.Info("dead letter failed again", "dead_letter_id", id, "error", err)
+			if recordErr := store.RecordDeadLetterFailure(r.Context(), id, err.Error()); recordErr != nil {
+				http.Error(w, "Failed to update dead letter", http.StatusInternalServerError)
+				return
+			}
+			http.Error(w, fmt.Sprintf("Dead letter failed again: %s", err), http.StatusUnprocessableEntity)
+			return
+		}
+
+		if err := store.DeleteDeadLetter(r.Context(), id); err != nil && !errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Failed to delete dead letter", http.StatusInternalServerError)
+			return
+		}
+		log.Info("dead letter re-driven", "dead_letter_id", id, "order_uid", order.OrderUID, "status", status)
+		writeJSON(log, w, http.StatusOK, map[string]string{"order_uid": order.OrderUID, "status": string(status)})
+	}
+}
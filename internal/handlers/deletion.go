@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"firstmod/internal/models"
+	"firstmod/internal/ports"
+	"log/slog"
+	"net/http"
+	"strconv"
+)
+
+const (
+	defaultDeletedLimit = 100
+	maxDeletedLimit     = 1000
+)
+
+func RestoreOrderHandler(log *slog.Logger, service ports.OrderService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		orderUID := r.PathValue("orderID")
+		log.Debug("received request to restore order", "order_uid", orderUID)
+
+		if err := service.Restore(r.Context(), orderUID); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				log.Info("attempted to restore order that is not deleted", "order_uid", orderUID)
+				http.Error(w, "Deleted order not found", http.StatusNotFound)
+				return
+			}
+			log.Error("failed to restore order", "order_uid", orderUID, "error", err)
+			http.Error(w, "Failed to restore order", http.StatusInternalServerError)
+			return
+		}
+
+		log.Info("order restored successfully", "order_uid", orderUID)
+		writeJSON(log, w, http.StatusOK, map[string]string{"message": "Order restored successfully", "order_uid": orderUID})
+	}
+}
+
+func GetDeletedOrdersHandler(log *slog.Logger, service ports.OrderService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		limit := defaultDeletedLimit
+		if v := r.URL.Query().Get("limit"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n <= 0 {
+				http.Error(w, "Invalid limit", http.StatusBadRequest)
+				return
+			}
+			limit = min(n, maxDeletedLimit)
+		}
+
+		deletions, err := service.ListDeleted(r.Context(), limit)
+		if err != nil {
+			log.Error("failed to get deleted orders", "error", err)
+			http.Error(w, "Failed to retrieve deleted orders", http.StatusInternalServerError)
+			return
+		}
+		if deletions == nil {
+			deletions = []models.Deletion{}
+		}
+		writeJSON(log, w, http.StatusOK, map[string][]models.Deletion{"deleted_orders": deletions})
+	}
+}
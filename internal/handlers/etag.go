@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+var errInvalidPrecondition = errors.New("invalid If-Match header")
+
+// etag returns the strong entity tag of an order version.
+func etag(version int64) string {
+	return `"` + strconv.FormatInt(version, 10) + `"`
+}
+
+// expectedVersion returns the order version required by the If-Match header
+// of r, or 0 if the request is unconditional. Only a single strong entity
+// tag or "*" is supported.
+func expectedVersion(r *http.Request) (int64, error) {
+	header := strings.TrimSpace(r.Header.Get("If-Match"))
+	if header == "" || header == "*" {
+		return 0, nil
+	}
+	tag, ok := strings.CutPrefix(header, `"`)
+	if !ok {
+		return 0, fmt.Errorf("%w: expected a single strong entity tag", errInvalidPrecondition)
+	}
+	tag, ok = strings.CutSuffix(tag, `"`)
+	version, err := strconv.ParseInt(tag, 10, 64)
+	if !ok || err != nil || version <= 0 {
+		return 0, fmt.Errorf("%w: %q is not an order version", errInvalidPrecondition, header)
+	}
+	return version, nil
+}
+
+// notModified reports whether the If-None-Match header of r matches the
+// entity tag of version. Weak comparison is used, as required for GET.
+func notModified(r *http.Request, version int64) bool {
+	header := r.Header.Get("If-None-Match")
+	if header == "" {
+		return false
+	}
+	current := etag(version)
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == "*" || tag == current {
+			return true
+		}
+	}
+	return false
+}
@@ -6,11 +6,13 @@ import (
 	"errors"
 	"firstmod/internal/models"
 	"firstmod/internal/ports"
+	"fmt"
 	"log/slog"
 	"net/http"
+	"strconv"
 )
 
-func GetOrderByIDHandler(log *slog.Logger, storage ports.Repository) http.HandlerFunc {
+func GetOrderByIDHandler(log *slog.Logger, service ports.OrderService) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 
 		orderUID := r.PathValue("orderID")
@@ -21,18 +23,24 @@ func GetOrderByIDHandler(log *slog.Logger, storage ports.Repository) http.Handle
 		}
 		log.Debug("received request to get order info", "order_uid", orderUID)
 
-		order, err := storage.GetInfo(r.Context(), orderUID)
+		order, err := service.GetOrder(r.Context(), orderUID)
 		if err != nil {
 			if errors.Is(err, sql.ErrNoRows) {
 				log.Info("order not found", "order_uid", orderUID)
 				http.Error(w, "Order not found", http.StatusNotFound)
 				return
 			}
-			log.Error("failed to get order from storage", "order_uid", orderUID, "error", err)
+			log.Error("failed to get order", "order_uid", orderUID, "error", err)
 			http.Error(w, "Failed to retrieve order info", http.StatusInternalServerError)
 			return
 		}
 
+		w.Header().Set("ETag", etag(order.Version))
+		if notModified(r, order.Version) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
 		responseJSON, err := json.MarshalIndent(order, "", "    ")
 		if err != nil {
 			log.Error("failed to marshal JSON response", "order_uid", order.OrderUID, "error", err)
@@ -48,7 +56,7 @@ func GetOrderByIDHandler(log *slog.Logger, storage ports.Repository) http.Handle
 	}
 }
 
-func CreateOrderHandler(log *slog.Logger, storage ports.Repository) http.HandlerFunc {
+func CreateOrderHandler(log *slog.Logger, service ports.OrderService) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 
 		var order models.Order
@@ -59,20 +67,81 @@ func CreateOrderHandler(log *slog.Logger, storage ports.Repository) http.Handler
 			return
 		}
 
-		err = storage.Add(r.Context(), order)
+		err = service.Add(r.Context(), order)
 		if err != nil {
-			log.Error("failed to add order to storage", "order_uid", order.OrderUID, "error", err)
+			if errors.Is(err, models.ErrInvalidOrder) {
+				log.Info("invalid order rejected", "order_uid", order.OrderUID, "error", err)
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if errors.Is(err, models.ErrOrderExists) {
+				log.Info("attempted to create existing order", "order_uid", order.OrderUID)
+				http.Error(w, "Order already exists", http.StatusConflict)
+				return
+			}
+			log.Error("failed to add order", "order_uid", order.OrderUID, "error", err)
 			http.Error(w, "Failed to create order", http.StatusInternalServerError)
 			return
 		}
 
 		log.Info("order created successfully", "order_uid", order.OrderUID)
+		w.Header().Set("ETag", etag(models.FirstVersion))
 		w.WriteHeader(http.StatusCreated)
 		json.NewEncoder(w).Encode(map[string]string{"message": "Order created successfully", "order_uid": order.OrderUID})
 	}
 }
 
-func DeleteOrderHandler(log *slog.Logger, storage ports.Repository) http.HandlerFunc {
+func UpdateOrderHandler(log *slog.Logger, service ports.OrderService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		orderUID := r.PathValue("orderID")
+		log.Debug("received request to update order", "order_uid", orderUID)
+
+		var order models.Order
+		if err := json.NewDecoder(r.Body).Decode(&order); err != nil {
+			log.Error("failed to decode request body", "error", err)
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		if order.OrderUID == "" {
+			order.OrderUID = orderUID
+		}
+		if order.OrderUID != orderUID {
+			http.Error(w, "OrderUID in body does not match URL", http.StatusBadRequest)
+			return
+		}
+
+		version, err := expectedVersion(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		updated, err := service.Update(r.Context(), order, version)
+		if err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				log.Info("attempted to update non-existent order", "order_uid", orderUID)
+				http.Error(w, "Order not found", http.StatusNotFound)
+			case errors.Is(err, models.ErrInvalidOrder):
+				log.Info("invalid order update rejected", "order_uid", orderUID, "error", err)
+				http.Error(w, err.Error(), http.StatusBadRequest)
+			case errors.Is(err, models.ErrVersionMismatch):
+				log.Info("order update precondition failed", "order_uid", orderUID, "error", err)
+				http.Error(w, "Order has been modified", http.StatusPreconditionFailed)
+			default:
+				log.Error("failed to update order", "order_uid", orderUID, "error", err)
+				http.Error(w, "Failed to update order", http.StatusInternalServerError)
+			}
+			return
+		}
+
+		log.Info("order updated successfully", "order_uid", orderUID, "version", updated.Version)
+		w.Header().Set("ETag", etag(updated.Version))
+		writeJSON(log, w, http.StatusOK, updated)
+	}
+}
+
+func DeleteOrderHandler(log *slog.Logger, service ports.OrderService) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodDelete {
 			log.Warn("received non-DELETE request for order deletion", "method", r.Method)
@@ -88,14 +157,25 @@ func DeleteOrderHandler(log *slog.Logger, storage ports.Repository) http.Handler
 		}
 		log.Debug("received request to delete order", "order_uid", orderUID)
 
-		err := storage.Delete(r.Context(), orderUID)
+		version, err := expectedVersion(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		err = service.Delete(r.Context(), orderUID, r.URL.Query().Get("reason"), version)
 		if err != nil {
 			if errors.Is(err, sql.ErrNoRows) {
 				log.Info("attempted to delete non-existent order", "order_uid", orderUID)
 				http.Error(w, "Order not found", http.StatusNotFound)
 				return
 			}
-			log.Error("failed to delete order from storage", "order_uid", orderUID, "error", err)
+			if errors.Is(err, models.ErrVersionMismatch) {
+				log.Info("order deletion precondition failed", "order_uid", orderUID, "error", err)
+				http.Error(w, "Order has been modified", http.StatusPreconditionFailed)
+				return
+			}
+			log.Error("failed to delete order", "order_uid", orderUID, "error", err)
 			http.Error(w, "Failed to delete order", http.StatusInternalServerError)
 			return
 		}
@@ -115,7 +195,7 @@ func DeleteOrderHandler(log *slog.Logger, storage ports.Repository) http.Handler
 	}
 }
 
-func GetOrdersIDsHandler(log *slog.Logger, storage ports.Repository) http.HandlerFunc {
+func GetOrdersIDsHandler(log *slog.Logger, service ports.OrderService) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodGet {
 			log.Warn("received non-GET request for order UIDs list", "method", r.Method)
@@ -123,9 +203,9 @@ func GetOrdersIDsHandler(log *slog.Logger, storage ports.Repository) http.Handle
 			return
 		}
 
-		uids, err := storage.GetIDs(r.Context())
+		uids, err := service.GetOrderIDs(r.Context())
 		if err != nil {
-			log.Error("failed to get order UIDs from storage", "error", err)
+			log.Error("failed to get order UIDs", "error", err)
 			http.Error(w, "Failed to retrieve order IDs", http.StatusInternalServerError)
 			return
 		}
@@ -147,3 +227,76 @@ func GetOrdersIDsHandler(log *slog.Logger, storage ports.Repository) http.Handle
 		log.Info("successfully retrieved and sent all order UIDs", "count", len(uids))
 	}
 }
+
+func writeJSON(log *slog.Logger, w http.ResponseWriter, status int, v any) {
+	responseJSON, err := json.MarshalIndent(v, "", "    ")
+	if err != nil {
+		log.Error("failed to marshal JSON response", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if _, err := w.Write(responseJSON); err != nil {
+		log.Error("failed to write response", "error", err)
+	}
+}
+
+// ListOrdersHandler returns a page of orders narrowed by the parameters of
+// parseOrderFilter; next_page_token continues the listing.
+func ListOrdersHandler(log *slog.Logger, service ports.OrderService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		pageSize := 0
+		if v := query.Get("page_size"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n <= 0 {
+				http.Error(w, "Invalid page_size", http.StatusBadRequest)
+				return
+			}
+			pageSize = n
+		}
+		filter, err := parseOrderFilter(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		orders, next, err := service.ListOrders(r.Context(), filter, query.Get("page_token"), pageSize)
+		if err != nil {
+			if errors.Is(err, models.ErrInvalidPageToken) {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			log.Error("failed to list orders", "error", err)
+			http.Error(w, "Failed to list orders", http.StatusInternalServerError)
+			return
+		}
+		writeJSON(log, w, http.StatusOK, struct {
+			Orders        []models.Order `json:"orders"`
+			NextPageToken string         `json:"next_page_token,omitempty"`
+		}{orders, next})
+	}
+}
+
+// parseOrderFilter reads the status, customer_id, delivery_service, currency,
+// from and to parameters of an order listing.
+func parseOrderFilter(r *http.Request) (models.OrderFilter, error) {
+	dates, err := parseReportFilter(r)
+	if err != nil {
+		return models.OrderFilter{}, err
+	}
+	query := r.URL.Query()
+	status := models.Status(query.Get("status"))
+	if status != "" && !status.Valid() {
+		return models.OrderFilter{}, fmt.Errorf("invalid status %q", status)
+	}
+	return models.OrderFilter{
+		Status:          status,
+		CustomerID:      query.Get("customer_id"),
+		DeliveryService: query.Get("delivery_service"),
+		Currency:        query.Get("currency"),
+		From:            dates.From,
+		To:              dates.To,
+	}, nil
+}
@@ -0,0 +1,29 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"firstmod/internal/models"
+	"firstmod/internal/ports"
+	"log/slog"
+	"net/http"
+)
+
+func GetOrderHistoryHandler(log *slog.Logger, service ports.OrderService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		orderUID := r.PathValue("orderID")
+		log.Debug("received request to get order history", "order_uid", orderUID)
+
+		entries, err := service.History(r.Context(), orderUID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				http.Error(w, "Order history not found", http.StatusNotFound)
+				return
+			}
+			log.Error("failed to get order history", "order_uid", orderUID, "error", err)
+			http.Error(w, "Failed to retrieve order history", http.StatusInternalServerError)
+			return
+		}
+		writeJSON(log, w, http.StatusOK, map[string][]models.AuditEntry{"history": entries})
+	}
+}
@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"errors"
+	"firstmod/internal/models"
+	"firstmod/internal/ports"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+func SalesReportHandler(log *slog.Logger, service ports.OrderService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		filter, err := parseReportFilter(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		points, err := service.SalesReport(r.Context(), models.Granularity(r.URL.Query().Get("granularity")), filter)
+		if err != nil {
+			writeReportError(log, w, err)
+			return
+		}
+		if points == nil {
+			points = []models.SalesPoint{}
+		}
+		writeJSON(log, w, http.StatusOK, map[string][]models.SalesPoint{"sales": points})
+	}
+}
+
+func BreakdownReportHandler(log *slog.Logger, service ports.OrderService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		filter, err := parseReportFilter(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		rows, err := service.Breakdown(r.Context(), models.Dimension(r.PathValue("dimension")), filter)
+		if err != nil {
+			writeReportError(log, w, err)
+			return
+		}
+		if rows == nil {
+			rows = []models.BreakdownRow{}
+		}
+		writeJSON(log, w, http.StatusOK, map[string][]models.BreakdownRow{"breakdown": rows})
+	}
+}
+
+func TopItemsReportHandler(log *slog.Logger, service ports.OrderService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		filter, err := parseReportFilter(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		limit := 0
+		if v := r.URL.Query().Get("limit"); v != "" {
+			if limit, err = strconv.Atoi(v); err != nil || limit <= 0 {
+				http.Error(w, "Invalid limit", http.StatusBadRequest)
+				return
+			}
+		}
+		items, err := service.TopItems(r.Context(), filter, limit)
+		if err != nil {
+			writeReportError(log, w, err)
+			return
+		}
+		if items == nil {
+			items = []models.TopItem{}
+		}
+		writeJSON(log, w, http.StatusOK, map[string][]models.TopItem{"items": items})
+	}
+}
+
+// parseReportFilter reads the from and to query parameters, each either an
+// RFC 3339 timestamp or a date. A date in to includes the whole day.
+func parseReportFilter(r *http.Request) (models.ReportFilter, error) {
+	var filter models.ReportFilter
+	for _, p := range []struct {
+		name  string
+		value *time.Time
+		day   time.Duration
+	}{
+		{"from", &filter.From, 0},
+		{"to", &filter.To, 24 * time.Hour},
+	} {
+		v := r.URL.Query().Get(p.name)
+		if v == "" {
+			continue
+		}
+		if t, err := time.Parse(time.RFC3339, v); err == nil {
+			*p.value = t
+			continue
+		}
+		t, err := time.Parse(time.DateOnly, v)
+		if err != nil {
+			return filter, fmt.Errorf("invalid %s: expected RFC 3339 timestamp or YYYY-MM-DD date", p.name)
+		}
+		*p.value = t.Add(p.day)
+	}
+	return filter, nil
+}
+
+func writeReportError(log *slog.Logger, w http.ResponseWriter, err error) {
+	if errors.Is(err, models.ErrInvalidReport) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	log.Error("failed to build report", "error", err)
+	http.Error(w, "Failed to build report", http.StatusInternalServerError)
+}
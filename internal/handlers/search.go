@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"errors"
+	"firstmod/internal/models"
+	"firstmod/internal/ports"
+	"log/slog"
+	"net/http"
+	"strconv"
+)
+
+func SearchOrdersHandler(log *slog.Logger, service ports.OrderService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		pageSize := 0
+		if v := query.Get("page_size"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n <= 0 {
+				http.Error(w, "Invalid page_size", http.StatusBadRequest)
+				return
+			}
+			pageSize = n
+		}
+		log.Debug("received request to search orders", "q", query.Get("q"))
+
+		summaries, next, err := service.Search(r.Context(), query.Get("q"), query.Get("page_token"), pageSize)
+		if err != nil {
+			if errors.Is(err, models.ErrInvalidSearch) || errors.Is(err, models.ErrInvalidPageToken) {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			log.Error("failed to search orders", "q", query.Get("q"), "error", err)
+			http.Error(w, "Failed to search orders", http.StatusInternalServerError)
+			return
+		}
+		if summaries == nil {
+			summaries = []models.OrderSummary{}
+		}
+		writeJSON(log, w, http.StatusOK, struct {
+			Orders        []models.OrderSummary `json:"orders"`
+			NextPageToken string                `json:"next_page_token,omitempty"`
+		}{summaries, next})
+	}
+}
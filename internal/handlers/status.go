@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"firstmod/internal/models"
+	"firstmod/internal/ports"
+	"log/slog"
+	"net/http"
+)
+
+func TransitionOrderHandler(log *slog.Logger, service ports.OrderService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		orderUID := r.PathValue("orderID")
+
+		var req models.TransitionRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			log.Error("failed to decode request body", "error", err)
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		log.Debug("received request to change order status", "order_uid", orderUID, "status", req.Status, "items", len(req.ChrtIDs))
+
+		version, err := expectedVersion(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		order, err := service.Transition(r.Context(), orderUID, req, version)
+		if err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				http.Error(w, "Order not found", http.StatusNotFound)
+			case errors.Is(err, models.ErrInvalidTransition):
+				log.Info("status transition rejected", "order_uid", orderUID, "error", err)
+				http.Error(w, err.Error(), http.StatusConflict)
+			case errors.Is(err, models.ErrVersionMismatch):
+				log.Info("status transition precondition failed", "order_uid", orderUID, "error", err)
+				http.Error(w, "Order has been modified", http.StatusPreconditionFailed)
+			default:
+				log.Error("failed to change order status", "order_uid", orderUID, "error", err)
+				http.Error(w, "Failed to change order status", http.StatusInternalServerError)
+			}
+			return
+		}
+
+		log.Info("order status changed", "order_uid", orderUID, "status", order.Status)
+		w.Header().Set("ETag", etag(order.Version))
+		writeJSON(log, w, http.StatusOK, order)
+	}
+}
+
+func GetOrderTransitionsHandler(log *slog.Logger, service ports.OrderService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		orderUID := r.PathValue("orderID")
+
+		transitions, err := service.Transitions(r.Context(), orderUID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				http.Error(w, "Order not found", http.StatusNotFound)
+				return
+			}
+			log.Error("failed to get order transitions", "order_uid", orderUID, "error", err)
+			http.Error(w, "Failed to retrieve order transitions", http.StatusInternalServerError)
+			return
+		}
+		if transitions == nil {
+			transitions = []models.StatusTransition{}
+		}
+		writeJSON(log, w, http.StatusOK, map[string][]models.StatusTransition{"transitions": transitions})
+	}
+}
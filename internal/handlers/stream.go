@@ -0,0 +1,201 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"firstmod/internal/models"
+	"firstmod/internal/ports"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const streamHeartbeat = 15 * time.Second
+
+type streamFilter struct {
+	customerID      string
+	deliveryService string
+}
+
+func parseStreamFilter(r *http.Request) streamFilter {
+	q := r.URL.Query()
+	return streamFilter{
+		customerID:      q.Get("customer_id"),
+		deliveryService: q.Get("delivery_service"),
+	}
+}
+
+func (f streamFilter) match(event models.OrderEvent) bool {
+	// A delete whose order could not be loaded has nothing to filter on; pass
+	// it through rather than leave the client with a stale order.
+	if event.Type == models.EventOrderDeleted && event.Order.OrderUID == "" {
+		return true
+	}
+	if f.customerID != "" && event.Order.CustomerID != f.customerID {
+		return false
+	}
+	if f.deliveryService != "" && event.Order.DeliveryService != f.deliveryService {
+		return false
+	}
+	return true
+}
+
+// lastEventID reads the resume point from the Last-Event-ID header sent by
+// EventSource on reconnect, falling back to the last_event_id query parameter.
+func lastEventID(r *http.Request) (uint64, error) {
+	value := r.Header.Get("Last-Event-ID")
+	if value == "" {
+		value = r.URL.Query().Get("last_event_id")
+	}
+	if value == "" {
+		return 0, nil
+	}
+	return strconv.ParseUint(value, 10, 64)
+}
+
+// StreamOrdersSSEHandler pushes order events to the client as Server-Sent
+// Events until the client disconnects or shutdown is cancelled.
+func StreamOrdersSSEHandler(log *slog.Logger, service ports.OrderService, shutdown context.Context) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			log.Error("response writer does not support flushing")
+			http.Error(w, "Streaming is not supported", http.StatusInternalServerError)
+			return
+		}
+		afterID, err := lastEventID(r)
+		if err != nil {
+			log.Info("invalid last event ID", "error", err)
+			http.Error(w, "Invalid last event ID", http.StatusBadRequest)
+			return
+		}
+		filter := parseStreamFilter(r)
+
+		ctx, cancel := context.WithCancel(r.Context())
+		defer cancel()
+		events := service.Subscribe(ctx, afterID)
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		w.WriteHeader(http.StatusOK)
+		flusher.Flush()
+		log.Debug("SSE client connected", "last_event_id", afterID, "remote_addr", r.RemoteAddr)
+
+		heartbeat := time.NewTicker(streamHeartbeat)
+		defer heartbeat.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				log.Debug("SSE client disconnected", "remote_addr", r.RemoteAddr)
+				return
+			case <-shutdown.Done():
+				log.Debug("closing SSE stream on shutdown", "remote_addr", r.RemoteAddr)
+				return
+			case <-heartbeat.C:
+				if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+					return
+				}
+				flusher.Flush()
+			case event, ok := <-events:
+				if !ok {
+					log.Warn("SSE client fell behind the event stream", "remote_addr", r.RemoteAddr)
+					return
+				}
+				if !filter.match(event) {
+					continue
+				}
+				data, err := json.Marshal(event)
+				if err != nil {
+					log.Error("failed to marshal order event", "event_id", event.ID, "error", err)
+					continue
+				}
+				if _, err := fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data); err != nil {
+					log.Debug("failed to write SSE event", "error", err)
+					return
+				}
+				flusher.Flush()
+			}
+		}
+	}
+}
+
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+}
+
+// StreamOrdersWebSocketHandler pushes order events to the client as JSON
+// WebSocket messages until either side closes the connection.
+func StreamOrdersWebSocketHandler(log *slog.Logger, service ports.OrderService, shutdown context.Context) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		afterID, err := lastEventID(r)
+		if err != nil {
+			log.Info("invalid last event ID", "error", err)
+			http.Error(w, "Invalid last event ID", http.StatusBadRequest)
+			return
+		}
+		filter := parseStreamFilter(r)
+
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			log.Info("failed to upgrade to WebSocket", "error", err)
+			return
+		}
+		defer conn.Close()
+		log.Debug("WebSocket client connected", "last_event_id", afterID, "remote_addr", r.RemoteAddr)
+
+		ctx, cancel := context.WithCancel(r.Context())
+		defer cancel()
+		events := service.Subscribe(ctx, afterID)
+
+		// The client is not expected to send anything; reading is needed to
+		// process control frames and to notice when it goes away.
+		go func() {
+			defer cancel()
+			for {
+				if _, _, err := conn.ReadMessage(); err != nil {
+					return
+				}
+			}
+		}()
+
+		heartbeat := time.NewTicker(streamHeartbeat)
+		defer heartbeat.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				log.Debug("WebSocket client disconnected", "remote_addr", r.RemoteAddr)
+				return
+			case <-shutdown.Done():
+				conn.WriteControl(websocket.CloseMessage,
+					websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down"),
+					time.Now().Add(time.Second))
+				return
+			case <-heartbeat.C:
+				if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(time.Second)); err != nil {
+					return
+				}
+			case event, ok := <-events:
+				if !ok {
+					log.Warn("WebSocket client fell behind the event stream", "remote_addr", r.RemoteAddr)
+					conn.WriteControl(websocket.CloseMessage,
+						websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "client fell behind"),
+						time.Now().Add(time.Second))
+					return
+				}
+				if !filter.match(event) {
+					continue
+				}
+				if err := conn.WriteJSON(event); err != nil {
+					log.Debug("failed to write WebSocket event", "error", err)
+					return
+				}
+			}
+		}
+	}
+}
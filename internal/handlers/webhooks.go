@@ -0,0 +1,199 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"firstmod/internal/models"
+	"firstmod/internal/ports"
+	"firstmod/internal/webhook"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+const (
+	defaultDeliveriesLimit = 50
+	maxDeliveriesLimit     = 500
+)
+
+type createWebhookRequest struct {
+	URL        string
+	EventTypes []models.EventType
+	Secret     string
+}
+
+func webhookID(w http.ResponseWriter, r *http.Request, log *slog.Logger) (int64, bool) {
+	id, err := strconv.ParseInt(r.PathValue("webhookID"), 10, 64)
+	if err != nil {
+		log.Info("invalid webhook ID in URL path", "value", r.PathValue("webhookID"))
+		http.Error(w, "Invalid webhook ID", http.StatusBadRequest)
+		return 0, false
+	}
+	return id, true
+}
+
+func validateWebhookRequest(req createWebhookRequest) string {
+	u, err := url.Parse(req.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "URL must be an absolute http(s) URL"
+	}
+	for _, t := range req.EventTypes {
+		switch t {
+		case models.EventOrderCreated, models.EventOrderUpdated, models.EventOrderDeleted, models.EventOrderRestored,
+			models.EventOrderStatusChanged:
+		default:
+			return "unknown event type: " + string(t)
+		}
+	}
+	return ""
+}
+
+// redactSecret hides the signing secret, which is only shown once on creation.
+func redactSecret(sub models.WebhookSubscription) models.WebhookSubscription {
+	sub.Secret = ""
+	return sub
+}
+
+func CreateWebhookHandler(log *slog.Logger, store ports.WebhookRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req createWebhookRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			log.Error("failed to decode webhook request body", "error", err)
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		if msg := validateWebhookRequest(req); msg != "" {
+			http.Error(w, msg, http.StatusBadRequest)
+			return
+		}
+		if req.Secret == "" {
+			secret, err := webhook.GenerateSecret()
+			if err != nil {
+				log.Error("failed to generate webhook secret", "error", err)
+				http.Error(w, "Failed to create webhook", http.StatusInternalServerError)
+				return
+			}
+			req.Secret = secret
+		}
+
+		sub, err := store.AddWebhook(r.Context(), models.WebhookSubscription{
+			URL:        req.URL,
+			Secret:     req.Secret,
+			EventTypes: req.EventTypes,
+		})
+		if err != nil {
+			http.Error(w, "Failed to create webhook", http.StatusInternalServerError)
+			return
+		}
+		writeJSON(log, w, http.StatusCreated, sub)
+	}
+}
+
+func ListWebhooksHandler(log *slog.Logger, store ports.WebhookRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		subs, err := store.ListWebhooks(r.Context(), false)
+		if err != nil {
+			http.Error(w, "Failed to retrieve webhooks", http.StatusInternalServerError)
+			return
+		}
+		response := make([]models.WebhookSubscription, 0, len(subs))
+		for _, sub := range subs {
+			response = append(response, redactSecret(sub))
+		}
+		writeJSON(log, w, http.StatusOK, map[string][]models.WebhookSubscription{"webhooks": response})
+	}
+}
+
+func GetWebhookHandler(log *slog.Logger, store ports.WebhookRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, ok := webhookID(w, r, log)
+		if !ok {
+			return
+		}
+		sub, err := store.GetWebhook(r.Context(), id)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				http.Error(w, "Webhook not found", http.StatusNotFound)
+				return
+			}
+			http.Error(w, "Failed to retrieve webhook", http.StatusInternalServerError)
+			return
+		}
+		writeJSON(log, w, http.StatusOK, redactSecret(sub))
+	}
+}
+
+func DeleteWebhookHandler(log *slog.Logger, store ports.WebhookRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, ok := webhookID(w, r, log)
+		if !ok {
+			return
+		}
+		if err := store.DeleteWebhook(r.Context(), id); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				http.Error(w, "Webhook not found", http.StatusNotFound)
+				return
+			}
+			http.Error(w, "Failed to delete webhook", http.StatusInternalServerError)
+			return
+		}
+		writeJSON(log, w, http.StatusOK, map[string]string{"message": "Webhook deleted successfully"})
+	}
+}
+
+// SetWebhookEnabledHandler enables or disables a subscription. Enabling a
+// subscription that was disabled after repeated failures resets its counter.
+func SetWebhookEnabledHandler(log *slog.Logger, store ports.WebhookRepository, enabled bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, ok := webhookID(w, r, log)
+		if !ok {
+			return
+		}
+		if err := store.SetWebhookEnabled(r.Context(), id, enabled); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				http.Error(w, "Webhook not found", http.StatusNotFound)
+				return
+			}
+			http.Error(w, "Failed to update webhook", http.StatusInternalServerError)
+			return
+		}
+		writeJSON(log, w, http.StatusOK, map[string]bool{"enabled": enabled})
+	}
+}
+
+func ListWebhookDeliveriesHandler(log *slog.Logger, store ports.WebhookRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, ok := webhookID(w, r, log)
+		if !ok {
+			return
+		}
+		limit := defaultDeliveriesLimit
+		if v := r.URL.Query().Get("limit"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n <= 0 {
+				http.Error(w, "Invalid limit", http.StatusBadRequest)
+				return
+			}
+			limit = min(n, maxDeliveriesLimit)
+		}
+		if _, err := store.GetWebhook(r.Context(), id); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				http.Error(w, "Webhook not found", http.StatusNotFound)
+				return
+			}
+			http.Error(w, "Failed to retrieve deliveries", http.StatusInternalServerError)
+			return
+		}
+		deliveries, err := store.ListWebhookDeliveries(r.Context(), id, limit)
+		if err != nil {
+			http.Error(w, "Failed to retrieve deliveries", http.StatusInternalServerError)
+			return
+		}
+		if deliveries == nil {
+			deliveries = []models.WebhookDelivery{}
+		}
+		writeJSON(log, w, http.StatusOK, map[string][]models.WebhookDelivery{"deliveries": deliveries})
+	}
+}
@@ -3,82 +3,373 @@ package kafka
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"firstmod/internal/actor"
 	"firstmod/internal/models"
 	"firstmod/internal/ports"
+	"fmt"
 	"log/slog"
+	"slices"
+	"sync"
 	"time"
+)
 
-	"github.com/segmentio/kafka-go"
+// Failures to add an order that may go away on their own, such as an
+// unavailable database, are retried with a backoff growing between these.
+const (
+	retryInitialBackoff = 100 * time.Millisecond
+	retryMaxBackoff     = 30 * time.Second
 )
 
 type KafkaConsumerImpl struct {
-	reader  *kafka.Reader
-	service ports.OrderService
-	log     *slog.Logger
+	group       Group
+	service     ports.OrderService
+	deadLetters ports.DeadLetterRepository
+	log         *slog.Logger
+
+	// pauseMu serializes pausing and resuming.
+	pauseMu sync.Mutex
+
+	mu sync.Mutex
+	// reader is the membership of the consumer in the group while it
+	// consumes, and cancelFetch interrupts its wait for messages.
+	reader      Reader
+	cancelFetch context.CancelFunc
+	// holds counts the operations keeping the consumer out of the group.
+	holds  int
+	closed bool
+	// resume ends the hold of a pause.
+	resume   func()
+	pausedAt time.Time
+	// changed is closed and replaced whenever the consumer joins or leaves
+	// the group or a hold ends.
+	changed chan struct{}
+	stats   consumerStats
 }
 
-func NewConsumer(log *slog.Logger, brokers []string, topic, groupID string, service ports.OrderService) *KafkaConsumerImpl {
-	reader := kafka.NewReader(kafka.ReaderConfig{
-		Brokers:        brokers,
-		Topic:          topic,
-		GroupID:        groupID,
-		MinBytes:       10e3,
-		MaxBytes:       10e6,
-		CommitInterval: time.Second,
-		Logger:         kafka.LoggerFunc(func(msg string, args ...interface{}) { log.Debug(msg, args...) }),
-		ErrorLogger:    kafka.LoggerFunc(func(msg string, args ...interface{}) { log.Error(msg, args...) }),
-	})
-	log.Info("Kafka consumer initialized", "brokers", brokers, "topic", topic, "group_id", groupID)
-	return &KafkaConsumerImpl{reader: reader, service: service, log: log}
+// NewConsumer returns a consumer adding the orders read as a member of group
+// to service. Messages that cannot be processed are stored in deadLetters,
+// or only logged if it is nil.
+func NewConsumer(log *slog.Logger, group Group, service ports.OrderService, deadLetters ports.DeadLetterRepository) *KafkaConsumerImpl {
+	log.Info("Kafka consumer initialized")
+	return &KafkaConsumerImpl{
+		group:       group,
+		service:     service,
+		deadLetters: deadLetters,
+		log:         log,
+		changed:     make(chan struct{}),
+		stats:       newConsumerStats(time.Now()),
+	}
 }
 
+func (c *KafkaConsumerImpl) notify() {
+	close(c.changed)
+	c.changed = make(chan struct{})
+}
+
+// StartConsuming consumes until ctx is done or the consumer is closed. The
+// consumer joins the group again after being held out of it.
 func (c *KafkaConsumerImpl) StartConsuming(ctx context.Context) {
 	c.log.Info("starting Kafka consumer")
 	for {
-		select {
-		case <-ctx.Done():
+		reader, fetchCtx, ok := c.join(ctx)
+		if !ok {
 			c.log.Info("Kafka consumer shutting down")
 			return
-		default:
-			msg, err := c.reader.FetchMessage(ctx)
-			if err != nil {
-				c.log.Error("failed to fetch message from Kafka", "error", err)
-				if ctx.Err() != nil {
-					return
-				}
-				time.Sleep(time.Second)
-				continue
-			}
+		}
+		c.consume(ctx, fetchCtx, reader)
+		c.leave(reader)
+	}
+}
 
-			c.log.Debug("received message from Kafka", "topic", msg.Topic, "partition", msg.Partition, "offset", msg.Offset, "key", string(msg.Key))
+// join waits until nothing holds the consumer out of the group and joins
+// it. It returns false once ctx is done or the consumer is closed.
+func (c *KafkaConsumerImpl) join(ctx context.Context) (Reader, context.Context, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for c.holds > 0 && !c.closed && ctx.Err() == nil {
+		changed := c.changed
+		c.mu.Unlock()
+		select {
+		case <-ctx.Done():
+		case <-changed:
+		}
+		c.mu.Lock()
+	}
+	if c.closed || ctx.Err() != nil {
+		return nil, nil, false
+	}
+	fetchCtx, cancel := context.WithCancel(ctx)
+	c.reader, c.cancelFetch = c.group.Join(), cancel
+	c.stats.positions = make(map[int]int64)
+	c.notify()
+	c.log.Info("Kafka consumer joined the group")
+	return c.reader, fetchCtx, true
+}
 
-			var order models.Order
-			err = json.Unmarshal(msg.Value, &order)
-			if err != nil {
-				c.log.Error("failed to unmarshal Kafka message value to Order model", "offset", msg.Offset, "error", err, "value", string(msg.Value))
-				if commitErr := c.reader.CommitMessages(ctx, msg); commitErr != nil {
-					c.log.Error("failed to commit invalid message", "offset", msg.Offset, "error", commitErr)
-				}
-				continue
+func (c *KafkaConsumerImpl) leave(reader Reader) {
+	if err := reader.Close(); err != nil {
+		c.log.Error("failed to close Kafka reader", "error", err)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.cancelFetch()
+	c.reader, c.cancelFetch = nil, nil
+	c.notify()
+	c.log.Info("Kafka consumer left the group")
+}
+
+// consume processes messages until fetchCtx is done. A message being
+// processed is finished first, as processing only stops with ctx.
+func (c *KafkaConsumerImpl) consume(ctx, fetchCtx context.Context, reader Reader) {
+	for {
+		msg, err := reader.FetchMessage(fetchCtx)
+		if err != nil {
+			if fetchCtx.Err() != nil {
+				return
 			}
-			err = c.service.Add(ctx, order)
-			if err != nil {
-				c.log.Error("failed to add order from Kafka message via service", "order_uid", order.OrderUID, "offset", msg.Offset, "error", err)
-				if commitErr := c.reader.CommitMessages(ctx, msg); commitErr != nil {
-					c.log.Error("failed to commit message after processing error", "offset", msg.Offset, "error", commitErr)
-				}
-				continue
+			c.log.Error("failed to fetch message from Kafka", "error", err)
+			c.count(func(s *consumerStats) { s.errors.Fetch++ })
+			select {
+			case <-fetchCtx.Done():
+				return
+			case <-time.After(time.Second):
 			}
+			continue
+		}
+		c.process(ctx, fetchCtx, reader, msg)
+	}
+}
 
-			if commitErr := c.reader.CommitMessages(ctx, msg); commitErr != nil {
-				c.log.Error("failed to commit message after successful processing", "offset", msg.Offset, "error", commitErr)
-			}
-			c.log.Info("order processed and committed from Kafka", "order_uid", order.OrderUID, "offset", msg.Offset)
+// process adds the order in msg and commits it. Malformed messages and
+// invalid orders are dead-lettered and committed. Other failures are retried
+// until the order is added; if the consumer stops or leaves the group
+// first, the message is left uncommitted to be delivered again.
+func (c *KafkaConsumerImpl) process(ctx, fetchCtx context.Context, reader Reader, msg Message) {
+	c.count(func(s *consumerStats) { s.received(msg, time.Now()) })
+	c.log.Debug("received message from Kafka", "topic", msg.Topic, "partition", msg.Partition, "offset", msg.Offset, "key", string(msg.Key))
+
+	var order models.Order
+	err := json.Unmarshal(msg.Value, &order)
+	if err != nil {
+		c.log.Error("failed to unmarshal Kafka message value to Order model", "offset", msg.Offset, "error", err, "value", string(msg.Value))
+		c.count(func(s *consumerStats) { s.errors.Invalid++ })
+		c.deadLetter(ctx, msg, err)
+		if commitErr := reader.CommitMessages(ctx, msg); commitErr != nil {
+			c.log.Error("failed to commit invalid message", "offset", msg.Offset, "error", commitErr)
+			c.count(func(s *consumerStats) { s.errors.Commit++ })
+		}
+		return
+	}
+	msgCtx := actor.WithName(actor.WithSource(ctx, actor.SourceKafka), "kafka:"+msg.Topic)
+	msgCtx = actor.WithRequestID(msgCtx, fmt.Sprintf("%s/%d/%d", msg.Topic, msg.Partition, msg.Offset))
+	backoff := retryInitialBackoff
+	for {
+		err = c.service.Add(msgCtx, order)
+		if err == nil || errors.Is(err, models.ErrOrderExists) || errors.Is(err, models.ErrInvalidOrder) {
+			break
+		}
+		c.count(func(s *consumerStats) { s.errors.Failed++ })
+		if ctx.Err() != nil {
+			c.log.Warn("consumer stopped before the order from Kafka message was added, leaving it uncommitted",
+				"order_uid", order.OrderUID, "offset", msg.Offset, "error", err)
+			return
+		}
+		c.log.Error("failed to add order from Kafka message via service, retrying",
+			"order_uid", order.OrderUID, "offset", msg.Offset, "retry_in", backoff, "error", err)
+		select {
+		case <-fetchCtx.Done():
+			c.log.Warn("consumer left the group before the order from Kafka message was added, leaving it uncommitted",
+				"order_uid", order.OrderUID, "offset", msg.Offset)
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, retryMaxBackoff)
+	}
+	if err != nil {
+		if errors.Is(err, models.ErrOrderExists) {
+			c.log.Info("order from Kafka message already exists", "order_uid", order.OrderUID, "offset", msg.Offset)
+			c.count(func(s *consumerStats) { s.duplicates++ })
+		} else {
+			c.log.Error("invalid order in Kafka message", "order_uid", order.OrderUID, "offset", msg.Offset, "error", err)
+			c.count(func(s *consumerStats) { s.errors.Invalid++ })
+			c.deadLetter(ctx, msg, err)
+		}
+		if commitErr := reader.CommitMessages(ctx, msg); commitErr != nil {
+			c.log.Error("failed to commit message after processing error", "offset", msg.Offset, "error", commitErr)
+			c.count(func(s *consumerStats) { s.errors.Commit++ })
 		}
+		return
 	}
+
+	if commitErr := reader.CommitMessages(ctx, msg); commitErr != nil {
+		c.log.Error("failed to commit message after successful processing", "offset", msg.Offset, "error", commitErr)
+		c.count(func(s *consumerStats) { s.errors.Commit++ })
+	}
+	c.log.Info("order processed and committed from Kafka", "order_uid", order.OrderUID, "offset", msg.Offset)
+}
+
+// deadLetter keeps a message that failed with err for later inspection and
+// re-driving.
+func (c *KafkaConsumerImpl) deadLetter(ctx context.Context, msg Message, err error) {
+	if c.deadLetters == nil {
+		return
+	}
+	_, addErr := c.deadLetters.AddDeadLetter(ctx, models.DeadLetter{
+		Topic:     msg.Topic,
+		Partition: msg.Partition,
+		Offset:    msg.Offset,
+		Key:       string(msg.Key),
+		Value:     string(msg.Value),
+		Error:     err.Error(),
+	})
+	if addErr != nil {
+		c.log.Error("failed to store dead letter", "topic", msg.Topic, "partition", msg.Partition, "offset", msg.Offset, "error", addErr)
+		c.count(func(s *consumerStats) { s.errors.DeadLetter++ })
+	}
+}
+
+// leaveGroup makes the consumer leave the group and stay out of it until
+// rejoin is called. The message being processed is finished and committed
+// first, unless it is waiting to be retried.
+func (c *KafkaConsumerImpl) leaveGroup(ctx context.Context) (rejoin func(), err error) {
+	c.mu.Lock()
+	c.holds++
+	rejoin = func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		c.holds--
+		c.notify()
+	}
+	if c.cancelFetch != nil {
+		c.cancelFetch()
+	}
+	for c.reader != nil {
+		changed := c.changed
+		c.mu.Unlock()
+		select {
+		case <-ctx.Done():
+			rejoin()
+			return nil, ctx.Err()
+		case <-changed:
+		}
+		c.mu.Lock()
+	}
+	c.mu.Unlock()
+	return rejoin, nil
 }
 
+// ResetOffsets moves the committed offsets of the consumer group. The
+// consumer leaves the group for the reset, as members would overwrite the
+// offsets, and resumes from the new offsets; other members make the reset
+// fail with models.ErrConsumerGroupActive. A dry run only reports what the
+// reset would change.
+func (c *KafkaConsumerImpl) ResetOffsets(ctx context.Context, reset models.OffsetReset) (models.OffsetResetResult, error) {
+	switch {
+	case reset.To == models.ResetToOffset && reset.Offset < 0:
+		return models.OffsetResetResult{}, fmt.Errorf("%w: negative offset", models.ErrInvalidOffsetReset)
+	case reset.To == models.ResetToTimestamp && reset.Time.IsZero():
+		return models.OffsetResetResult{}, fmt.Errorf("%w: no timestamp", models.ErrInvalidOffsetReset)
+	case reset.To != models.ResetToEarliest && reset.To != models.ResetToLatest &&
+		reset.To != models.ResetToOffset && reset.To != models.ResetToTimestamp:
+		return models.OffsetResetResult{}, fmt.Errorf("%w: unknown target %q, expected earliest, latest, offset or timestamp",
+			models.ErrInvalidOffsetReset, reset.To)
+	}
+
+	result, targets, err := c.planReset(ctx, reset)
+	if err != nil || reset.DryRun {
+		return result, err
+	}
+
+	rejoin, err := c.leaveGroup(ctx)
+	if err != nil {
+		return models.OffsetResetResult{}, err
+	}
+	defer rejoin()
+	// The consumer committed its last messages while leaving.
+	result, targets, err = c.planReset(ctx, reset)
+	if err != nil {
+		return result, err
+	}
+	if err := c.group.CommitOffsets(ctx, targets); err != nil {
+		c.log.Error("failed to reset consumer group offsets", "to", reset.To, "error", err)
+		return models.OffsetResetResult{}, err
+	}
+	c.log.Warn("consumer group offsets reset", "to", reset.To, "offsets", targets,
+		"replayed", result.Replayed, "skipped", result.Skipped)
+	return result, nil
+}
+
+// planReset works out the target offsets of a reset from the current
+// offsets of the partitions.
+func (c *KafkaConsumerImpl) planReset(ctx context.Context, reset models.OffsetReset) (models.OffsetResetResult, map[int]int64, error) {
+	result := models.OffsetResetResult{DryRun: reset.DryRun}
+	offsets, err := c.group.Offsets(ctx)
+	if err != nil {
+		return result, nil, err
+	}
+	for _, p := range reset.Partitions {
+		if !slices.ContainsFunc(offsets, func(o PartitionOffsets) bool { return o.Partition == p }) {
+			return result, nil, fmt.Errorf("%w: no partition %d", models.ErrInvalidOffsetReset, p)
+		}
+	}
+	var byTime map[int]int64
+	if reset.To == models.ResetToTimestamp {
+		if byTime, err = c.group.OffsetsForTime(ctx, reset.Time); err != nil {
+			return result, nil, err
+		}
+	}
+
+	targets := make(map[int]int64)
+	for _, o := range offsets {
+		if len(reset.Partitions) > 0 && !slices.Contains(reset.Partitions, o.Partition) {
+			continue
+		}
+		var target int64
+		switch reset.To {
+		case models.ResetToEarliest:
+			target = o.First
+		case models.ResetToLatest:
+			target = o.End
+		case models.ResetToOffset:
+			target = reset.Offset
+		case models.ResetToTimestamp:
+			target = byTime[o.Partition]
+		}
+		target = min(max(target, o.First), o.End)
+		targets[o.Partition] = target
+
+		// A group without a committed offset starts from the first message.
+		position := o.First
+		if o.Committed >= 0 {
+			position = o.Committed
+		}
+		partition := models.PartitionReset{
+			Partition:   o.Partition,
+			FirstOffset: o.First,
+			EndOffset:   o.End,
+			Committed:   o.Committed,
+			Target:      target,
+			Replayed:    max(position-target, 0),
+			Skipped:     max(target-position, 0),
+		}
+		result.Partitions = append(result.Partitions, partition)
+		result.Replayed += partition.Replayed
+		result.Skipped += partition.Skipped
+	}
+	return result, targets, nil
+}
+
+// Close stops the consumer for good, leaving the group.
 func (c *KafkaConsumerImpl) Close() error {
 	c.log.Info("closing Kafka consumer")
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closed = true
+	c.notify()
+	if c.reader == nil {
+		return nil
+	}
+	c.cancelFetch()
 	return c.reader.Close()
 }
@@ -0,0 +1,403 @@
+package kafka_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"firstmod/internal/cache"
+	"firstmod/internal/events"
+	"firstmod/internal/kafka"
+	kafkamemory "firstmod/internal/kafka/memory"
+	"firstmod/internal/models"
+	"firstmod/internal/ports"
+	"firstmod/internal/repository/memory"
+	"firstmod/internal/repository/repotest"
+	"firstmod/internal/service"
+	"fmt"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+const (
+	ordersTopic = "orders"
+	eventsTopic = "order_events"
+	group       = "order_service"
+)
+
+var day = time.Date(2025, 3, 3, 10, 0, 0, 0, time.UTC)
+
+type env struct {
+	broker  *kafkamemory.Broker
+	repo    *memory.Repository
+	service *service.OrderService
+	log     *slog.Logger
+}
+
+func newEnv(t *testing.T) *env {
+	t.Helper()
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	broker := kafkamemory.NewBroker(3)
+	repo := memory.New(log)
+	producer := kafka.NewProducer(log, broker.Writer(eventsTopic))
+	return &env{
+		broker:  broker,
+		repo:    repo,
+		service: service.NewOrderService(repo, cache.NewCache(log), log, producer, events.NewBus(log), repo),
+		log:     log,
+	}
+}
+
+// consume starts a consumer of the orders topic that stops with the test.
+func (e *env) consume(t *testing.T) *kafka.KafkaConsumerImpl {
+	t.Helper()
+	consumer := kafka.NewConsumer(e.log, e.broker.Group(ordersTopic, group), e.service, e.repo)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		consumer.StartConsuming(ctx)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+		consumer.Close()
+	})
+	return consumer
+}
+
+func (e *env) send(t *testing.T, key string, value []byte) {
+	t.Helper()
+	writer := e.broker.Writer(ordersTopic)
+	if err := writer.WriteMessages(context.Background(), kafka.Message{Key: []byte(key), Value: value}); err != nil {
+		t.Fatalf("write message: %v", err)
+	}
+}
+
+func (e *env) sendOrder(t *testing.T, order models.Order) {
+	t.Helper()
+	value, err := json.Marshal(order)
+	if err != nil {
+		t.Fatalf("marshal order: %v", err)
+	}
+	e.send(t, order.OrderUID, value)
+}
+
+// waitConsumed waits until the group has committed every message of the
+// orders topic.
+func (e *env) waitConsumed(t *testing.T) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for e.broker.Lag(ordersTopic, group) > 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("consumer group lag is still %d", e.broker.Lag(ordersTopic, group))
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestConsumeOrders(t *testing.T) {
+	e := newEnv(t)
+	e.consume(t)
+
+	for i := range 5 {
+		e.sendOrder(t, repotest.NewOrder(fmt.Sprintf("kafka-%d", i), day))
+	}
+	e.waitConsumed(t)
+
+	ctx := context.Background()
+	for i := range 5 {
+		uid := fmt.Sprintf("kafka-%d", i)
+		order, err := e.service.GetOrder(ctx, uid)
+		if err != nil {
+			t.Fatalf("GetOrder(%s): %v", uid, err)
+		}
+		if order.Status != models.StatusCreated {
+			t.Errorf("order %s has status %q, want %q", uid, order.Status, models.StatusCreated)
+		}
+		history, err := e.service.History(ctx, uid)
+		if err != nil || len(history) != 1 {
+			t.Fatalf("History(%s) = %d entries, %v", uid, len(history), err)
+		}
+		if history[0].Source != "kafka" || history[0].Actor != "kafka:"+ordersTopic {
+			t.Errorf("audit of %s has source %q and actor %q", uid, history[0].Source, history[0].Actor)
+		}
+	}
+	if published := e.broker.Messages(eventsTopic); len(published) != 5 {
+		t.Errorf("service published %d messages, want 5", len(published))
+	}
+}
+
+func TestSkipInvalidMessages(t *testing.T) {
+	e := newEnv(t)
+	e.consume(t)
+
+	e.send(t, "broken", []byte("{not json"))
+	invalid := repotest.NewOrder("invalid", day)
+	invalid.CustomerID = ""
+	e.sendOrder(t, invalid)
+	e.sendOrder(t, repotest.NewOrder("valid", day))
+	e.sendOrder(t, repotest.NewOrder("valid", day))
+	e.waitConsumed(t)
+
+	ctx := context.Background()
+	if _, err := e.service.GetOrder(ctx, "valid"); err != nil {
+		t.Fatalf("GetOrder(valid): %v", err)
+	}
+	if _, err := e.service.GetOrder(ctx, "invalid"); err == nil {
+		t.Error("order failing validation was stored")
+	}
+	ids, err := e.service.GetOrderIDs(ctx)
+	if err != nil || len(ids) != 1 {
+		t.Errorf("GetOrderIDs() = %v, %v; want only the valid order", ids, err)
+	}
+
+	// The duplicate is not a failure, the other two are kept for re-driving.
+	letters, err := e.repo.ListDeadLetters(ctx, 0, 10)
+	if err != nil {
+		t.Fatalf("ListDeadLetters: %v", err)
+	}
+	keys := make(map[string]bool)
+	for _, letter := range letters {
+		keys[letter.Key] = true
+	}
+	if len(letters) != 2 || !keys["broken"] || !keys["invalid"] {
+		t.Errorf("dead letters = %+v, want the malformed and the invalid message", letters)
+	}
+}
+
+// flakyService fails to add the first failures orders, as if the database
+// were down.
+type flakyService struct {
+	ports.OrderService
+	failures int
+}
+
+func (s *flakyService) Add(ctx context.Context, order models.Order) error {
+	if s.failures > 0 {
+		s.failures--
+		return errors.New("connection refused")
+	}
+	return s.OrderService.Add(ctx, order)
+}
+
+func TestRetryTransientFailures(t *testing.T) {
+	e := newEnv(t)
+	consumer := kafka.NewConsumer(e.log, e.broker.Group(ordersTopic, group), &flakyService{OrderService: e.service, failures: 2}, e.repo)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		consumer.StartConsuming(ctx)
+	}()
+	defer func() {
+		cancel()
+		<-done
+		consumer.Close()
+	}()
+
+	e.sendOrder(t, repotest.NewOrder("retried", day))
+	e.waitConsumed(t)
+
+	if _, err := e.service.GetOrder(ctx, "retried"); err != nil {
+		t.Fatalf("GetOrder(retried): %v", err)
+	}
+	if letters, err := e.repo.ListDeadLetters(ctx, 0, 10); err != nil || len(letters) != 0 {
+		t.Errorf("dead letters = %+v, %v; want none for a transient failure", letters, err)
+	}
+	if status := consumer.Status(ctx); status.Errors.Failed != 2 || status.Processed != 1 {
+		t.Errorf("status = %+v, want 2 failed attempts and 1 processed message", status)
+	}
+}
+
+func TestConsumerGroup(t *testing.T) {
+	e := newEnv(t)
+	first := e.consume(t)
+	e.consume(t)
+
+	for i := range 12 {
+		e.sendOrder(t, repotest.NewOrder(fmt.Sprintf("group-%d", i), day))
+	}
+	e.waitConsumed(t)
+
+	// The remaining member takes over the partitions of the closed one.
+	if err := first.Close(); err != nil {
+		t.Fatalf("close consumer: %v", err)
+	}
+	for i := 12; i < 24; i++ {
+		e.sendOrder(t, repotest.NewOrder(fmt.Sprintf("group-%d", i), day))
+	}
+	e.waitConsumed(t)
+
+	ids, err := e.service.GetOrderIDs(context.Background())
+	if err != nil {
+		t.Fatalf("GetOrderIDs: %v", err)
+	}
+	if len(ids) != 24 {
+		t.Errorf("stored %d orders, want 24", len(ids))
+	}
+	for _, uid := range ids {
+		history, err := e.service.History(context.Background(), uid)
+		if err != nil || len(history) != 1 {
+			t.Errorf("order %s was processed %d times", uid, len(history))
+		}
+	}
+}
+
+func TestResetOffsets(t *testing.T) {
+	e := newEnv(t)
+	consumer := e.consume(t)
+	ctx := context.Background()
+	deadLetters := func() int {
+		t.Helper()
+		letters, err := e.repo.ListDeadLetters(ctx, 0, 100)
+		if err != nil {
+			t.Fatalf("ListDeadLetters: %v", err)
+		}
+		return len(letters)
+	}
+
+	e.send(t, "broken-1", []byte("{not json"))
+	for i := range 3 {
+		e.sendOrder(t, repotest.NewOrder(fmt.Sprintf("reset-%d", i), day))
+	}
+	e.waitConsumed(t)
+	time.Sleep(time.Millisecond)
+	since := time.Now()
+	e.send(t, "broken-2", []byte("{not json"))
+	e.waitConsumed(t)
+
+	result, err := consumer.ResetOffsets(ctx, models.OffsetReset{To: models.ResetToEarliest, DryRun: true})
+	if err != nil {
+		t.Fatalf("dry run: %v", err)
+	}
+	if result.Replayed != 5 || len(result.Partitions) != 3 {
+		t.Errorf("dry run = %+v, want 5 messages replayed from 3 partitions", result)
+	}
+	if lag := e.broker.Lag(ordersTopic, group); lag != 0 {
+		t.Errorf("lag after a dry run is %d, want 0", lag)
+	}
+
+	// Only the message written since the timestamp is processed again.
+	result, err = consumer.ResetOffsets(ctx, models.OffsetReset{To: models.ResetToTimestamp, Time: since})
+	if err != nil {
+		t.Fatalf("reset to timestamp: %v", err)
+	}
+	if result.Replayed != 1 {
+		t.Errorf("reset to timestamp = %+v, want 1 message replayed", result)
+	}
+	e.waitConsumed(t)
+	if n := deadLetters(); n != 3 {
+		t.Errorf("%d dead letters after the replay, want the broken messages and broken-2 again", n)
+	}
+
+	other := e.broker.Reader(ordersTopic, group)
+	if _, err := consumer.ResetOffsets(ctx, models.OffsetReset{To: models.ResetToEarliest}); !errors.Is(err, models.ErrConsumerGroupActive) {
+		t.Errorf("reset with another member returned %v, want %v", err, models.ErrConsumerGroupActive)
+	}
+	other.Close()
+	for _, reset := range []models.OffsetReset{{To: "beginning"}, {To: models.ResetToLatest, Partitions: []int{7}}} {
+		if _, err := consumer.ResetOffsets(ctx, reset); !errors.Is(err, models.ErrInvalidOffsetReset) {
+			t.Errorf("ResetOffsets(%+v) returned %v, want %v", reset, err, models.ErrInvalidOffsetReset)
+		}
+	}
+
+	// The consumer is back in the group after the failed resets.
+	e.sendOrder(t, repotest.NewOrder("after-reset", day))
+	e.waitConsumed(t)
+	if _, err := e.service.GetOrder(ctx, "after-reset"); err != nil {
+		t.Errorf("GetOrder(after-reset): %v", err)
+	}
+}
+
+func TestPauseResume(t *testing.T) {
+	e := newEnv(t)
+	consumer := e.consume(t)
+	ctx := context.Background()
+
+	e.sendOrder(t, repotest.NewOrder("before-pause", day))
+	e.send(t, "broken", []byte("{not json"))
+	e.waitConsumed(t)
+	status := consumer.Status(ctx)
+	if status.State != models.ConsumerRunning || status.Processed != 2 || status.Errors.Invalid != 1 ||
+		status.Rate <= 0 || status.LastMessageAt.IsZero() || status.Lag != 0 {
+		t.Errorf("status = %+v, want a running consumer that processed 2 messages, one of them invalid", status)
+	}
+	for _, p := range status.Partitions {
+		if !p.Assigned || p.Current != p.End {
+			t.Errorf("partition %+v is not assigned and consumed to the end", p)
+		}
+	}
+
+	if err := consumer.Pause(ctx); err != nil {
+		t.Fatalf("pause: %v", err)
+	}
+	e.sendOrder(t, repotest.NewOrder("while-paused", day))
+	time.Sleep(50 * time.Millisecond)
+	status = consumer.Status(ctx)
+	if status.State != models.ConsumerPaused || status.PausedAt.IsZero() || status.Lag != 1 {
+		t.Errorf("status = %+v, want a paused consumer lagging a message", status)
+	}
+	for _, p := range status.Partitions {
+		if p.Assigned {
+			t.Errorf("partition %d is assigned to the paused consumer", p.Partition)
+		}
+	}
+	if _, err := e.service.GetOrder(ctx, "while-paused"); err == nil {
+		t.Error("paused consumer processed a message")
+	}
+
+	consumer.Resume()
+	e.waitConsumed(t)
+	if _, err := e.service.GetOrder(ctx, "while-paused"); err != nil {
+		t.Errorf("GetOrder(while-paused) after resume: %v", err)
+	}
+	if status := consumer.Status(ctx); status.State != models.ConsumerRunning || status.Processed != 3 {
+		t.Errorf("status after resume = %+v, want a running consumer that processed 3 messages", status)
+	}
+}
+
+func TestRedeliverUncommitted(t *testing.T) {
+	broker := kafkamemory.NewBroker(1)
+	ctx := context.Background()
+	writer := broker.Writer(ordersTopic)
+	for i := range 3 {
+		if err := writer.WriteMessages(ctx, kafka.Message{Value: []byte{byte(i)}}); err != nil {
+			t.Fatalf("write message: %v", err)
+		}
+	}
+
+	reader := broker.Reader(ordersTopic, group)
+	first, err := reader.FetchMessage(ctx)
+	if err != nil {
+		t.Fatalf("fetch: %v", err)
+	}
+	if err := reader.CommitMessages(ctx, first); err != nil {
+		t.Fatalf("commit: %v", err)
+	}
+	if _, err := reader.FetchMessage(ctx); err != nil {
+		t.Fatalf("fetch: %v", err)
+	}
+	reader.Close()
+
+	reader = broker.Reader(ordersTopic, group)
+	defer reader.Close()
+	msg, err := reader.FetchMessage(ctx)
+	if err != nil {
+		t.Fatalf("fetch after rejoin: %v", err)
+	}
+	if msg.Offset != 1 {
+		t.Errorf("fetched offset %d after rejoin, want the uncommitted offset 1", msg.Offset)
+	}
+	if lag := broker.Lag(ordersTopic, group); lag != 2 {
+		t.Errorf("Lag() = %d, want 2", lag)
+	}
+
+	waitCtx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	reader.FetchMessage(ctx)
+	if _, err := reader.FetchMessage(waitCtx); err != context.DeadlineExceeded {
+		t.Errorf("fetch past the end returned %v, want %v", err, context.DeadlineExceeded)
+	}
+}
@@ -0,0 +1,183 @@
+package kafka
+
+import (
+	"context"
+	"firstmod/internal/models"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+type brokerGroup struct {
+	log     *slog.Logger
+	brokers []string
+	topic   string
+	groupID string
+	client  *kafka.Client
+}
+
+// NewBrokerGroup returns the consumer group groupID of topic on Kafka
+// brokers.
+func NewBrokerGroup(log *slog.Logger, brokers []string, topic, groupID string) Group {
+	return &brokerGroup{
+		log:     log,
+		brokers: brokers,
+		topic:   topic,
+		groupID: groupID,
+		client:  &kafka.Client{Addr: kafka.TCP(brokers...), Timeout: 10 * time.Second},
+	}
+}
+
+func (g *brokerGroup) Join() Reader {
+	return NewBrokerReader(g.log, g.brokers, g.topic, g.groupID)
+}
+
+func (g *brokerGroup) partitions(ctx context.Context) ([]int, error) {
+	resp, err := g.client.Metadata(ctx, &kafka.MetadataRequest{Topics: []string{g.topic}})
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Topics) != 1 {
+		return nil, fmt.Errorf("no metadata of topic %s", g.topic)
+	}
+	if err := resp.Topics[0].Error; err != nil {
+		return nil, err
+	}
+	partitions := make([]int, 0, len(resp.Topics[0].Partitions))
+	for _, p := range resp.Topics[0].Partitions {
+		partitions = append(partitions, p.ID)
+	}
+	return partitions, nil
+}
+
+// listOffsets asks for an offset of every partition. Each kind of offset
+// takes its own request, as a request may not name a partition twice.
+func (g *brokerGroup) listOffsets(ctx context.Context, partitions []int, request func(partition int) kafka.OffsetRequest) ([]kafka.PartitionOffsets, error) {
+	requests := make([]kafka.OffsetRequest, 0, len(partitions))
+	for _, p := range partitions {
+		requests = append(requests, request(p))
+	}
+	resp, err := g.client.ListOffsets(ctx, &kafka.ListOffsetsRequest{Topics: map[string][]kafka.OffsetRequest{g.topic: requests}})
+	if err != nil {
+		return nil, err
+	}
+	offsets := resp.Topics[g.topic]
+	for _, o := range offsets {
+		if o.Error != nil {
+			return nil, fmt.Errorf("list offsets of partition %d: %w", o.Partition, o.Error)
+		}
+	}
+	return offsets, nil
+}
+
+func (g *brokerGroup) Offsets(ctx context.Context) ([]PartitionOffsets, error) {
+	partitions, err := g.partitions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	offsets := make(map[int]*PartitionOffsets, len(partitions))
+	result := make([]PartitionOffsets, len(partitions))
+	for i, p := range partitions {
+		result[i] = PartitionOffsets{Partition: p, Committed: -1}
+		offsets[p] = &result[i]
+	}
+
+	first, err := g.listOffsets(ctx, partitions, kafka.FirstOffsetOf)
+	if err != nil {
+		return nil, err
+	}
+	for _, o := range first {
+		offsets[o.Partition].First = o.FirstOffset
+	}
+	last, err := g.listOffsets(ctx, partitions, kafka.LastOffsetOf)
+	if err != nil {
+		return nil, err
+	}
+	for _, o := range last {
+		offsets[o.Partition].End = o.LastOffset
+	}
+
+	committed, err := g.client.OffsetFetch(ctx, &kafka.OffsetFetchRequest{GroupID: g.groupID, Topics: map[string][]int{g.topic: partitions}})
+	if err != nil {
+		return nil, err
+	}
+	if committed.Error != nil {
+		return nil, committed.Error
+	}
+	for _, o := range committed.Topics[g.topic] {
+		if o.Error != nil {
+			return nil, fmt.Errorf("fetch committed offset of partition %d: %w", o.Partition, o.Error)
+		}
+		if o.CommittedOffset >= 0 {
+			offsets[o.Partition].Committed = o.CommittedOffset
+		}
+	}
+	return result, nil
+}
+
+func (g *brokerGroup) OffsetsForTime(ctx context.Context, t time.Time) (map[int]int64, error) {
+	partitions, err := g.partitions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	last, err := g.listOffsets(ctx, partitions, kafka.LastOffsetOf)
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[int]int64, len(partitions))
+	for _, o := range last {
+		result[o.Partition] = o.LastOffset
+	}
+	byTime, err := g.listOffsets(ctx, partitions, func(p int) kafka.OffsetRequest { return kafka.TimeOffsetOf(p, t) })
+	if err != nil {
+		return nil, err
+	}
+	// A partition without messages at or after t answers with offset -1.
+	for _, o := range byTime {
+		for offset := range o.Offsets {
+			if offset >= 0 {
+				result[o.Partition] = offset
+			}
+		}
+	}
+	return result, nil
+}
+
+func (g *brokerGroup) CommitOffsets(ctx context.Context, offsets map[int]int64) error {
+	groups, err := g.client.DescribeGroups(ctx, &kafka.DescribeGroupsRequest{GroupIDs: []string{g.groupID}})
+	if err != nil {
+		return err
+	}
+	for _, group := range groups.Groups {
+		if group.Error != nil {
+			return group.Error
+		}
+		if len(group.Members) > 0 {
+			return fmt.Errorf("%w: %d members", models.ErrConsumerGroupActive, len(group.Members))
+		}
+	}
+
+	commits := make([]kafka.OffsetCommit, 0, len(offsets))
+	for p, offset := range offsets {
+		commits = append(commits, kafka.OffsetCommit{Partition: p, Offset: offset})
+	}
+	// Offsets of a group without members are committed outside of any
+	// generation.
+	resp, err := g.client.OffsetCommit(ctx, &kafka.OffsetCommitRequest{
+		GroupID:      g.groupID,
+		GenerationID: -1,
+		Topics:       map[string][]kafka.OffsetCommit{g.topic: commits},
+	})
+	if err != nil {
+		return err
+	}
+	for _, p := range resp.Topics[g.topic] {
+		if p.Error != nil {
+			return fmt.Errorf("commit offset of partition %d: %w", p.Partition, p.Error)
+		}
+	}
+	g.log.Info("consumer group offsets committed", "topic", g.topic, "group_id", g.groupID, "offsets", offsets)
+	return nil
+}
@@ -0,0 +1,344 @@
+// Package memory is an in-process Kafka broker implementing the kafka.Reader,
+// kafka.Writer and kafka.Group transports, for tests and local runs without
+// Kafka.
+package memory
+
+import (
+	"context"
+	"errors"
+	"firstmod/internal/kafka"
+	"firstmod/internal/models"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"sort"
+	"sync"
+	"time"
+)
+
+var errWriterClosed = errors.New("kafka writer is closed")
+
+// Broker keeps topics as partitioned logs in memory. Topics are created on
+// first use. Readers of a consumer group share the partitions of the topic
+// and resume from the offsets committed by the group, so messages that were
+// fetched but not committed are delivered again after a rebalance.
+type Broker struct {
+	partitions int
+
+	mu     sync.Mutex
+	topics map[string][][]kafka.Message
+	groups map[groupKey]*group
+	next   int
+	// changed is closed and replaced whenever a message is written or a
+	// group rebalances, waking up the waiting readers.
+	changed chan struct{}
+}
+
+type groupKey struct {
+	topic string
+	id    string
+}
+
+type group struct {
+	committed map[int]int64
+	members   []*reader
+}
+
+// NewBroker returns a broker whose topics have the given number of
+// partitions.
+func NewBroker(partitions int) *Broker {
+	if partitions < 1 {
+		partitions = 1
+	}
+	return &Broker{
+		partitions: partitions,
+		topics:     make(map[string][][]kafka.Message),
+		groups:     make(map[groupKey]*group),
+		changed:    make(chan struct{}),
+	}
+}
+
+func (b *Broker) notify() {
+	close(b.changed)
+	b.changed = make(chan struct{})
+}
+
+func (b *Broker) topic(name string) [][]kafka.Message {
+	partitions, ok := b.topics[name]
+	if !ok {
+		partitions = make([][]kafka.Message, b.partitions)
+		b.topics[name] = partitions
+	}
+	return partitions
+}
+
+// partition picks the partition of a message: by the hash of its key, so
+// messages with the same key stay in order, or round-robin without a key.
+func (b *Broker) partition(key []byte) int {
+	if len(key) == 0 {
+		b.next++
+		return b.next % b.partitions
+	}
+	h := fnv.New32a()
+	h.Write(key)
+	return int(h.Sum32() % uint32(b.partitions))
+}
+
+// Messages returns all messages written to topic, partition by partition.
+func (b *Broker) Messages(topic string) []kafka.Message {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var msgs []kafka.Message
+	for _, partition := range b.topic(topic) {
+		msgs = append(msgs, partition...)
+	}
+	return msgs
+}
+
+// Lag returns the number of messages of topic not yet committed by the
+// consumer group.
+func (b *Broker) Lag(topic, groupID string) int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var committed map[int]int64
+	if g, ok := b.groups[groupKey{topic, groupID}]; ok {
+		committed = g.committed
+	}
+	var lag int64
+	for p, partition := range b.topic(topic) {
+		lag += int64(len(partition)) - committed[p]
+	}
+	return lag
+}
+
+// Writer returns a writer appending to topic.
+func (b *Broker) Writer(topic string) kafka.Writer {
+	return &writer{broker: b, topic: topic}
+}
+
+type writer struct {
+	broker *Broker
+	topic  string
+	closed bool
+}
+
+func (w *writer) WriteMessages(ctx context.Context, msgs ...kafka.Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	b := w.broker
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if w.closed {
+		return errWriterClosed
+	}
+	partitions := b.topic(w.topic)
+	now := time.Now()
+	for _, msg := range msgs {
+		p := b.partition(msg.Key)
+		partitions[p] = append(partitions[p], kafka.Message{
+			Topic:     w.topic,
+			Partition: p,
+			Offset:    int64(len(partitions[p])),
+			Key:       msg.Key,
+			Value:     msg.Value,
+			Time:      now,
+		})
+	}
+	b.notify()
+	return nil
+}
+
+func (w *writer) Close() error {
+	w.broker.mu.Lock()
+	defer w.broker.mu.Unlock()
+	w.closed = true
+	return nil
+}
+
+// Reader returns a reader of topic joining the consumer group groupID.
+func (b *Broker) Reader(topic, groupID string) kafka.Reader {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	g := b.group(topic, groupID)
+	r := &reader{broker: b, topic: topic, group: g}
+	g.members = append(g.members, r)
+	b.rebalance(g)
+	return r
+}
+
+func (b *Broker) group(topic, groupID string) *group {
+	key := groupKey{topic, groupID}
+	g, ok := b.groups[key]
+	if !ok {
+		g = &group{committed: make(map[int]int64)}
+		b.groups[key] = g
+	}
+	return g
+}
+
+// rebalance spreads the partitions over the members of the group. Every
+// member continues from the committed offsets of its new partitions.
+func (b *Broker) rebalance(g *group) {
+	for _, m := range g.members {
+		m.positions = make(map[int]int64)
+	}
+	for p := 0; p < b.partitions; p++ {
+		if len(g.members) == 0 {
+			break
+		}
+		g.members[p%len(g.members)].positions[p] = g.committed[p]
+	}
+	b.notify()
+}
+
+type reader struct {
+	broker *Broker
+	topic  string
+	group  *group
+	// positions holds the offset of the next message to fetch from each
+	// assigned partition.
+	positions map[int]int64
+	closed    bool
+}
+
+func (r *reader) FetchMessage(ctx context.Context) (kafka.Message, error) {
+	b := r.broker
+	for {
+		b.mu.Lock()
+		if r.closed {
+			b.mu.Unlock()
+			return kafka.Message{}, io.EOF
+		}
+		partitions := b.topic(r.topic)
+		for p := 0; p < len(partitions); p++ {
+			pos, assigned := r.positions[p]
+			if assigned && pos < int64(len(partitions[p])) {
+				r.positions[p] = pos + 1
+				msg := partitions[p][pos]
+				b.mu.Unlock()
+				return msg, nil
+			}
+		}
+		changed := b.changed
+		b.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return kafka.Message{}, ctx.Err()
+		case <-changed:
+		}
+	}
+}
+
+func (r *reader) CommitMessages(ctx context.Context, msgs ...kafka.Message) error {
+	b := r.broker
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if r.closed {
+		return io.EOF
+	}
+	for _, msg := range msgs {
+		if msg.Offset+1 > r.group.committed[msg.Partition] {
+			r.group.committed[msg.Partition] = msg.Offset + 1
+		}
+	}
+	return nil
+}
+
+func (r *reader) Assignment(ctx context.Context) ([]int, error) {
+	r.broker.mu.Lock()
+	defer r.broker.mu.Unlock()
+
+	partitions := make([]int, 0, len(r.positions))
+	for p := range r.positions {
+		partitions = append(partitions, p)
+	}
+	sort.Ints(partitions)
+	return partitions, nil
+}
+
+// Close leaves the consumer group, handing the partitions of the reader to
+// the remaining members.
+func (r *reader) Close() error {
+	b := r.broker
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if r.closed {
+		return nil
+	}
+	r.closed = true
+	for i, m := range r.group.members {
+		if m == r {
+			r.group.members = append(r.group.members[:i], r.group.members[i+1:]...)
+			break
+		}
+	}
+	b.rebalance(r.group)
+	return nil
+}
+
+// Group returns the consumer group groupID of topic.
+func (b *Broker) Group(topic, groupID string) kafka.Group {
+	return &consumerGroup{broker: b, topic: topic, id: groupID}
+}
+
+type consumerGroup struct {
+	broker *Broker
+	topic  string
+	id     string
+}
+
+func (g *consumerGroup) Join() kafka.Reader {
+	return g.broker.Reader(g.topic, g.id)
+}
+
+func (g *consumerGroup) Offsets(ctx context.Context) ([]kafka.PartitionOffsets, error) {
+	b := g.broker
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	committed := b.group(g.topic, g.id).committed
+	partitions := b.topic(g.topic)
+	offsets := make([]kafka.PartitionOffsets, len(partitions))
+	for p, partition := range partitions {
+		offsets[p] = kafka.PartitionOffsets{Partition: p, End: int64(len(partition)), Committed: -1}
+		if offset, ok := committed[p]; ok {
+			offsets[p].Committed = offset
+		}
+	}
+	return offsets, nil
+}
+
+func (g *consumerGroup) OffsetsForTime(ctx context.Context, t time.Time) (map[int]int64, error) {
+	b := g.broker
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	offsets := make(map[int]int64)
+	for p, partition := range b.topic(g.topic) {
+		offsets[p] = int64(sort.Search(len(partition), func(i int) bool { return !partition[i].Time.Before(t) }))
+	}
+	return offsets, nil
+}
+
+func (g *consumerGroup) CommitOffsets(ctx context.Context, offsets map[int]int64) error {
+	b := g.broker
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	group := b.group(g.topic, g.id)
+	if len(group.members) > 0 {
+		return fmt.Errorf("%w: %d members", models.ErrConsumerGroupActive, len(group.members))
+	}
+	for p, offset := range offsets {
+		group.committed[p] = offset
+	}
+	return nil
+}
@@ -2,30 +2,22 @@ package kafka
 
 import (
 	"context"
+	"firstmod/internal/ports"
 	"log/slog"
-
-	"github.com/segmentio/kafka-go"
 )
 
 type KafkaProducerImpl struct {
-	writer *kafka.Writer
+	writer Writer
 	log    *slog.Logger
 }
 
-func NewProducer(log *slog.Logger, brokers []string, topic string) *KafkaProducerImpl {
-	writer := &kafka.Writer{
-		Addr:        kafka.TCP(brokers...),
-		Topic:       topic,
-		Balancer:    &kafka.LeastBytes{},
-		Logger:      kafka.LoggerFunc(func(msg string, args ...interface{}) { log.Debug(msg, args...) }),
-		ErrorLogger: kafka.LoggerFunc(func(msg string, args ...interface{}) { log.Error(msg, args...) }),
-	}
-	log.Info("Kafka producer initialized", "brokers", brokers, "topic", topic)
+func NewProducer(log *slog.Logger, writer Writer) *KafkaProducerImpl {
+	log.Info("Kafka producer initialized")
 	return &KafkaProducerImpl{writer: writer, log: log}
 }
 
 func (p *KafkaProducerImpl) Publish(ctx context.Context, key string, value []byte) error {
-	msg := kafka.Message{
+	msg := Message{
 		Key:   []byte(key),
 		Value: value,
 	}
@@ -38,6 +30,20 @@ func (p *KafkaProducerImpl) Publish(ctx context.Context, key string, value []byt
 	return nil
 }
 
+func (p *KafkaProducerImpl) PublishBatch(ctx context.Context, messages []ports.KafkaMessage) error {
+	msgs := make([]Message, 0, len(messages))
+	for _, m := range messages {
+		msgs = append(msgs, Message{Key: []byte(m.Key), Value: m.Value})
+	}
+	err := p.writer.WriteMessages(ctx, msgs...)
+	if err != nil {
+		p.log.Error("failed to publish batch of messages to Kafka", "count", len(msgs), "error", err)
+		return err
+	}
+	p.log.Debug("batch of messages published to Kafka", "count", len(msgs))
+	return nil
+}
+
 func (p *KafkaProducerImpl) Close() error {
 	p.log.Info("closing Kafka producer")
 	return p.writer.Close()
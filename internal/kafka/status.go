@@ -0,0 +1,169 @@
+package kafka
+
+import (
+	"context"
+	"firstmod/internal/models"
+	"time"
+)
+
+// rateWindow is the period the processing rate is averaged over, in seconds.
+const rateWindow = 60
+
+// consumerStats are the counters of a consumer, guarded by its mutex.
+type consumerStats struct {
+	started       time.Time
+	processed     int64
+	duplicates    int64
+	errors        models.ConsumerErrors
+	lastMessageAt time.Time
+	// positions holds the offset after the last message received from each
+	// partition since the consumer joined the group.
+	positions map[int]int64
+	// perSecond counts the messages received in each of the last rateWindow
+	// seconds, seconds holding the second each count is for.
+	perSecond [rateWindow]int64
+	seconds   [rateWindow]int64
+}
+
+func newConsumerStats(now time.Time) consumerStats {
+	return consumerStats{started: now, positions: make(map[int]int64)}
+}
+
+func (s *consumerStats) received(msg Message, now time.Time) {
+	s.processed++
+	s.lastMessageAt = now
+	s.positions[msg.Partition] = msg.Offset + 1
+	second := now.Unix()
+	i := second % rateWindow
+	if s.seconds[i] != second {
+		s.seconds[i], s.perSecond[i] = second, 0
+	}
+	s.perSecond[i]++
+}
+
+// rate returns the messages received per second over the last rateWindow
+// seconds, or since the start if that is shorter.
+func (s *consumerStats) rate(now time.Time) float64 {
+	var n int64
+	for i, second := range s.seconds {
+		if now.Unix()-second < rateWindow {
+			n += s.perSecond[i]
+		}
+	}
+	elapsed := min(now.Sub(s.started).Seconds(), rateWindow)
+	return float64(n) / max(elapsed, 1)
+}
+
+func (c *KafkaConsumerImpl) count(update func(s *consumerStats)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	update(&c.stats)
+}
+
+// Pause makes the consumer leave the group and stop consuming until Resume
+// is called, once the message being processed is finished. Its partitions
+// go to the other members of the group, if any. Pausing a paused consumer
+// does nothing.
+func (c *KafkaConsumerImpl) Pause(ctx context.Context) error {
+	c.pauseMu.Lock()
+	defer c.pauseMu.Unlock()
+	if c.paused() {
+		return nil
+	}
+	rejoin, err := c.leaveGroup(ctx)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	c.resume, c.pausedAt = rejoin, time.Now().UTC()
+	c.mu.Unlock()
+	c.log.Warn("Kafka consumer paused")
+	return nil
+}
+
+// Resume lets a paused consumer join the group again and continue from the
+// committed offsets.
+func (c *KafkaConsumerImpl) Resume() {
+	c.pauseMu.Lock()
+	defer c.pauseMu.Unlock()
+	if !c.paused() {
+		return
+	}
+	c.mu.Lock()
+	resume := c.resume
+	c.resume, c.pausedAt = nil, time.Time{}
+	c.mu.Unlock()
+	resume()
+	c.log.Warn("Kafka consumer resumed")
+}
+
+func (c *KafkaConsumerImpl) paused() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.resume != nil
+}
+
+// Status reports the state and the counters of the consumer with the
+// offsets of the partitions of the topic.
+func (c *KafkaConsumerImpl) Status(ctx context.Context) models.ConsumerStatus {
+	c.mu.Lock()
+	now := time.Now()
+	status := models.ConsumerStatus{
+		State:         models.ConsumerStopped,
+		PausedAt:      c.pausedAt,
+		LastMessageAt: c.stats.lastMessageAt,
+		Rate:          c.stats.rate(now),
+		Processed:     c.stats.processed,
+		Duplicates:    c.stats.duplicates,
+		Errors:        c.stats.errors,
+	}
+	switch {
+	case c.resume != nil:
+		status.State = models.ConsumerPaused
+	case c.reader != nil:
+		status.State = models.ConsumerRunning
+	}
+	reader := c.reader
+	positions := make(map[int]int64, len(c.stats.positions))
+	for p, offset := range c.stats.positions {
+		positions[p] = offset
+	}
+	c.mu.Unlock()
+
+	assigned := make(map[int]bool)
+	if reader != nil {
+		partitions, err := reader.Assignment(ctx)
+		if err != nil {
+			c.log.Error("failed to get assigned partitions", "error", err)
+		}
+		for _, p := range partitions {
+			assigned[p] = true
+		}
+	}
+	offsets, err := c.group.Offsets(ctx)
+	if err != nil {
+		c.log.Error("failed to get consumer group offsets", "error", err)
+		status.OffsetsError = err.Error()
+		return status
+	}
+	for _, o := range offsets {
+		partition := models.ConsumerPartition{
+			Partition: o.Partition,
+			Assigned:  assigned[o.Partition],
+			Current:   -1,
+			Committed: o.Committed,
+			End:       o.End,
+			Lag:       o.End - max(o.Committed, o.First),
+		}
+		// An assigned partition without messages yet is read from the
+		// committed offset.
+		if position, ok := positions[o.Partition]; ok && partition.Assigned {
+			partition.Current = position
+		} else if partition.Assigned {
+			partition.Current = max(o.Committed, o.First)
+		}
+		status.Partitions = append(status.Partitions, partition)
+		status.Lag += partition.Lag
+	}
+	return status
+}
@@ -0,0 +1,181 @@
+package kafka
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// Message is a record of a topic partition.
+type Message struct {
+	Topic     string
+	Partition int
+	Offset    int64
+	Key       []byte
+	Value     []byte
+	Time      time.Time
+}
+
+// Reader reads the messages of a topic as a member of a consumer group.
+type Reader interface {
+	// FetchMessage blocks until the next message is available or ctx is
+	// done. The message is delivered again to the group unless committed.
+	FetchMessage(ctx context.Context) (Message, error)
+	// CommitMessages marks the messages, and all earlier messages of their
+	// partitions, as processed by the group.
+	CommitMessages(ctx context.Context, msgs ...Message) error
+	// Assignment returns the partitions the group assigned to the reader.
+	Assignment(ctx context.Context) ([]int, error)
+	Close() error
+}
+
+// Writer appends messages to a topic.
+type Writer interface {
+	WriteMessages(ctx context.Context, msgs ...Message) error
+	Close() error
+}
+
+// PartitionOffsets are the offsets of a topic partition: the offset of the
+// oldest message kept, the offset the next message will be written at and
+// the offset a consumer group resumes reading from.
+type PartitionOffsets struct {
+	Partition int
+	First     int64
+	End       int64
+	// Committed is -1 if the group never committed an offset.
+	Committed int64
+}
+
+// Group is the consumer group of a topic.
+type Group interface {
+	// Join returns a Reader consuming as a new member of the group.
+	Join() Reader
+	// Offsets returns the offsets of every partition of the topic.
+	Offsets(ctx context.Context) ([]PartitionOffsets, error)
+	// OffsetsForTime returns, for every partition, the offset of the first
+	// message written at or after t, or the end offset if there is none.
+	OffsetsForTime(ctx context.Context, t time.Time) (map[int]int64, error)
+	// CommitOffsets sets the committed offsets of partitions. It fails with
+	// models.ErrConsumerGroupActive while the group has members, which
+	// would go on committing their own offsets.
+	CommitOffsets(ctx context.Context, offsets map[int]int64) error
+}
+
+type brokerReader struct {
+	reader   *kafka.Reader
+	client   *kafka.Client
+	topic    string
+	groupID  string
+	clientID string
+}
+
+// NewBrokerReader returns a Reader consuming topic from Kafka brokers. The
+// reader gets a client ID of its own to find its member of the group.
+func NewBrokerReader(log *slog.Logger, brokers []string, topic, groupID string) Reader {
+	b := make([]byte, 8)
+	rand.Read(b)
+	clientID := "order-service-" + hex.EncodeToString(b)
+	reader := kafka.NewReader(kafka.ReaderConfig{
+		Brokers:        brokers,
+		Topic:          topic,
+		GroupID:        groupID,
+		Dialer:         &kafka.Dialer{ClientID: clientID, Timeout: 10 * time.Second, DualStack: true},
+		MinBytes:       10e3,
+		MaxBytes:       10e6,
+		CommitInterval: time.Second,
+		Logger:         kafka.LoggerFunc(func(msg string, args ...interface{}) { log.Debug(msg, args...) }),
+		ErrorLogger:    kafka.LoggerFunc(func(msg string, args ...interface{}) { log.Error(msg, args...) }),
+	})
+	log.Info("Kafka reader initialized", "brokers", brokers, "topic", topic, "group_id", groupID, "client_id", clientID)
+	return &brokerReader{
+		reader:   reader,
+		client:   &kafka.Client{Addr: kafka.TCP(brokers...), Timeout: 10 * time.Second},
+		topic:    topic,
+		groupID:  groupID,
+		clientID: clientID,
+	}
+}
+
+func (r *brokerReader) FetchMessage(ctx context.Context) (Message, error) {
+	msg, err := r.reader.FetchMessage(ctx)
+	if err != nil {
+		return Message{}, err
+	}
+	return Message{
+		Topic:     msg.Topic,
+		Partition: msg.Partition,
+		Offset:    msg.Offset,
+		Key:       msg.Key,
+		Value:     msg.Value,
+		Time:      msg.Time,
+	}, nil
+}
+
+func (r *brokerReader) CommitMessages(ctx context.Context, msgs ...Message) error {
+	converted := make([]kafka.Message, 0, len(msgs))
+	for _, m := range msgs {
+		converted = append(converted, kafka.Message{Topic: m.Topic, Partition: m.Partition, Offset: m.Offset})
+	}
+	return r.reader.CommitMessages(ctx, converted...)
+}
+
+func (r *brokerReader) Assignment(ctx context.Context) ([]int, error) {
+	resp, err := r.client.DescribeGroups(ctx, &kafka.DescribeGroupsRequest{GroupIDs: []string{r.groupID}})
+	if err != nil {
+		return nil, err
+	}
+	var partitions []int
+	for _, group := range resp.Groups {
+		if group.Error != nil {
+			return nil, group.Error
+		}
+		for _, member := range group.Members {
+			if member.ClientID != r.clientID {
+				continue
+			}
+			for _, topic := range member.MemberAssignments.Topics {
+				if topic.Topic == r.topic {
+					partitions = append(partitions, topic.Partitions...)
+				}
+			}
+		}
+	}
+	return partitions, nil
+}
+
+func (r *brokerReader) Close() error {
+	return r.reader.Close()
+}
+
+type brokerWriter struct {
+	writer *kafka.Writer
+}
+
+// NewBrokerWriter returns a Writer publishing to topic on Kafka brokers.
+func NewBrokerWriter(log *slog.Logger, brokers []string, topic string) Writer {
+	writer := &kafka.Writer{
+		Addr:        kafka.TCP(brokers...),
+		Topic:       topic,
+		Balancer:    &kafka.LeastBytes{},
+		Logger:      kafka.LoggerFunc(func(msg string, args ...interface{}) { log.Debug(msg, args...) }),
+		ErrorLogger: kafka.LoggerFunc(func(msg string, args ...interface{}) { log.Error(msg, args...) }),
+	}
+	log.Info("Kafka writer initialized", "brokers", brokers, "topic", topic)
+	return &brokerWriter{writer: writer}
+}
+
+func (w *brokerWriter) WriteMessages(ctx context.Context, msgs ...Message) error {
+	converted := make([]kafka.Message, 0, len(msgs))
+	for _, m := range msgs {
+		converted = append(converted, kafka.Message{Key: m.Key, Value: m.Value})
+	}
+	return w.writer.WriteMessages(ctx, converted...)
+}
+
+func (w *brokerWriter) Close() error {
+	return w.writer.Close()
+}
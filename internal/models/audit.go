@@ -0,0 +1,22 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// AuditEntry is a record of a single change of an order. Before is null for
+// created orders and After is null for deleted ones. Diff maps the path of
+// every changed field to its old and new value.
+type AuditEntry struct {
+	ID        int64
+	OrderUID  string
+	Action    EventType
+	Actor     string
+	Source    string
+	RequestID string
+	Before    json.RawMessage
+	After     json.RawMessage
+	Diff      json.RawMessage
+	ChangedAt time.Time
+}
@@ -0,0 +1,19 @@
+package models
+
+type BatchItemStatus string
+
+const (
+	BatchItemCreated   BatchItemStatus = "created"
+	BatchItemDuplicate BatchItemStatus = "duplicate"
+	BatchItemInvalid   BatchItemStatus = "invalid"
+	BatchItemFailed    BatchItemStatus = "failed"
+)
+
+// BatchItemResult is the outcome of one order of a batch create, Index being
+// its position in the request.
+type BatchItemResult struct {
+	Index    int
+	OrderUID string
+	Status   BatchItemStatus
+	Error    string `json:",omitempty"`
+}
@@ -0,0 +1,44 @@
+package models
+
+import "time"
+
+// ConsistencyOptions choose what a consistency check repairs. Sample limits
+// the order UIDs listed for each kind of difference.
+type ConsistencyOptions struct {
+	RepairCache bool
+	Republish   bool
+	Sample      int
+}
+
+// ConsistencyReport compares the cache with the database and lists orders
+// that were never published to Kafka.
+type ConsistencyReport struct {
+	CheckedAt    time.Time
+	DBOrders     int
+	CachedOrders int
+	// MissingInCache are orders in the database that are not cached,
+	// StaleInCache are cached at another version and ExtraInCache are cached
+	// but not in the database. Incomplete are orders in the database that
+	// lack delivery info or payment; they are neither compared nor repaired.
+	MissingInCache ConsistencyDiff
+	StaleInCache   ConsistencyDiff
+	ExtraInCache   ConsistencyDiff
+	Incomplete     ConsistencyDiff
+	Unpublished    ConsistencyDiff
+	CacheRepaired  int
+	Republished    int
+}
+
+// ConsistencyDiff counts the orders with a difference and lists a sample of
+// them.
+type ConsistencyDiff struct {
+	Count     int
+	OrderUIDs []string
+}
+
+func (d *ConsistencyDiff) Add(orderUID string, sample int) {
+	d.Count++
+	if len(d.OrderUIDs) < sample {
+		d.OrderUIDs = append(d.OrderUIDs, orderUID)
+	}
+}
@@ -0,0 +1,55 @@
+package models
+
+import "time"
+
+type ConsumerState string
+
+const (
+	ConsumerRunning ConsumerState = "running"
+	ConsumerPaused  ConsumerState = "paused"
+	// ConsumerStopped is a consumer that is not consuming and not paused:
+	// not started yet, shut down or out of the group for an offset reset.
+	ConsumerStopped ConsumerState = "stopped"
+)
+
+// ConsumerStatus is the state of the Kafka consumer of the service. Rate is
+// the number of messages processed per second over the last minute.
+// OffsetsError is set when the offsets of the partitions could not be read
+// from Kafka, Partitions and Lag are empty then.
+type ConsumerStatus struct {
+	State         ConsumerState
+	PausedAt      time.Time
+	Partitions    []ConsumerPartition
+	Lag           int64
+	LastMessageAt time.Time
+	Rate          float64
+	Processed     int64
+	Duplicates    int64
+	Errors        ConsumerErrors
+	OffsetsError  string
+}
+
+// ConsumerPartition is a partition of the topic. Current is the offset of
+// the next message the consumer reads from an assigned partition, or -1.
+// Committed is -1 if the group never committed an offset, and Lag counts
+// the messages after the committed offset.
+type ConsumerPartition struct {
+	Partition int
+	Assigned  bool
+	Current   int64
+	Committed int64
+	End       int64
+	Lag       int64
+}
+
+// ConsumerErrors counts the failures of the consumer since the start of the
+// service: failed fetches, malformed messages and invalid orders, attempts
+// to add an order that failed and are retried, and failures to commit
+// offsets or store dead letters.
+type ConsumerErrors struct {
+	Fetch      int64
+	Invalid    int64
+	Failed     int64
+	Commit     int64
+	DeadLetter int64
+}
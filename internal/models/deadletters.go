@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// DeadLetter is a Kafka message the consumer failed to process. It is kept
+// until it is re-driven successfully or deleted.
+type DeadLetter struct {
+	ID        int64
+	Topic     string
+	Partition int
+	Offset    int64
+	Key       string
+	Value     string
+	Error     string
+	Attempts  int
+	FailedAt  time.Time
+}
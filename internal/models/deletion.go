@@ -0,0 +1,11 @@
+package models
+
+import "time"
+
+// Deletion describes a soft-deleted order.
+type Deletion struct {
+	OrderUID  string
+	DeletedAt time.Time
+	DeletedBy string
+	Reason    string
+}
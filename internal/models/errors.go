@@ -0,0 +1,35 @@
+package models
+
+import "errors"
+
+var (
+	ErrInvalidOrder = errors.New("invalid order")
+	ErrOrderExists  = errors.New("order already exists")
+
+	// ErrDataIntegrity means stored data violates an invariant, e.g. an order
+	// without its delivery info or payment.
+	ErrDataIntegrity = errors.New("data integrity violation")
+
+	ErrInvalidPageToken = errors.New("invalid page token")
+
+	// ErrInvalidSearch means the search query is empty or too long.
+	ErrInvalidSearch = errors.New("invalid search query")
+
+	// ErrVersionMismatch means the order was changed since the version the
+	// caller based its change on.
+	ErrVersionMismatch = errors.New("order version mismatch")
+
+	// ErrInvalidTransition means the order lifecycle does not allow the
+	// requested status change.
+	ErrInvalidTransition = errors.New("invalid status transition")
+
+	// ErrInvalidReport means a report was requested with an unknown
+	// granularity or dimension, or with a date range that ends before it
+	// starts.
+	ErrInvalidReport = errors.New("invalid report request")
+
+	ErrInvalidOffsetReset = errors.New("invalid offset reset")
+	// ErrConsumerGroupActive means offsets of a consumer group cannot be
+	// changed because other consumers are members of the group.
+	ErrConsumerGroupActive = errors.New("consumer group has active members")
+)
@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+type EventType string
+
+const (
+	EventOrderCreated  EventType = "created"
+	EventOrderUpdated  EventType = "updated"
+	EventOrderDeleted  EventType = "deleted"
+	EventOrderRestored EventType = "restored"
+	// EventOrderStatusChanged is published when the order or some of its
+	// items move to another lifecycle stage.
+	EventOrderStatusChanged EventType = "status_changed"
+)
+
+// OrderEvent describes a change of an order. For deletions Order holds the
+// last known state of the order and may be empty.
+type OrderEvent struct {
+	ID       uint64
+	Type     EventType
+	OrderUID string
+	Order    Order
+	Time     time.Time
+}
@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+// OrderFilter narrows order listings. Zero values match every order; orders
+// must be created in [From, To).
+type OrderFilter struct {
+	Status          Status
+	CustomerID      string
+	DeliveryService string
+	Currency        string
+	From            time.Time
+	To              time.Time
+}
+
+// Matches reports whether order passes the filter.
+func (f OrderFilter) Matches(order Order) bool {
+	switch {
+	case f.Status != "" && order.Status != f.Status:
+		return false
+	case f.CustomerID != "" && order.CustomerID != f.CustomerID:
+		return false
+	case f.DeliveryService != "" && order.DeliveryService != f.DeliveryService:
+		return false
+	case f.Currency != "" && order.Payment.Currency != f.Currency:
+		return false
+	case !f.From.IsZero() && order.DateCreated.Before(f.From):
+		return false
+	case !f.To.IsZero() && !order.DateCreated.Before(f.To):
+		return false
+	}
+	return true
+}
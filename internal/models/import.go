@@ -0,0 +1,23 @@
+package models
+
+// ImportSummary is the outcome of a bulk import. Errors list the orders that
+// were not created, except duplicates. Error is set when the import stopped
+// early and names the line from which orders may not have been stored.
+type ImportSummary struct {
+	Orders    int
+	Created   int
+	Duplicate int
+	Invalid   int
+	Failed    int
+	Errors    []ImportError
+	Error     string `json:",omitempty"`
+}
+
+// ImportError is an order of an import file that was not created. Line is
+// the line of the order in the file, or its position in a JSON array.
+type ImportError struct {
+	Line     int
+	OrderUID string `json:",omitempty"`
+	Status   BatchItemStatus
+	Error    string
+}
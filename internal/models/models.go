@@ -2,6 +2,10 @@ package models
 
 import "time"
 
+// FirstVersion is the version of a newly created order. The version is
+// incremented on every change of the order.
+const FirstVersion int64 = 1
+
 type Order struct {
 	OrderUID          string
 	TrackNumber       string
@@ -17,6 +21,9 @@ type Order struct {
 	SmID              int64
 	DateCreated       time.Time
 	OofShard          string
+	Version           int64
+	Status            Status
+	StatusChangedAt   time.Time
 }
 
 type DeliveryInfo struct {
@@ -53,5 +60,8 @@ type Item struct {
 	TotalPrice  int
 	NmID        int64
 	Brand       string
-	Status      int
+	// Status is the item status code received with the order, State is the
+	// lifecycle stage of the item.
+	Status int
+	State  Status
 }
@@ -0,0 +1,49 @@
+package models
+
+import "time"
+
+// ResetTarget is where an offset reset moves a consumer group.
+type ResetTarget string
+
+const (
+	ResetToEarliest  ResetTarget = "earliest"
+	ResetToLatest    ResetTarget = "latest"
+	ResetToOffset    ResetTarget = "offset"
+	ResetToTimestamp ResetTarget = "timestamp"
+)
+
+// OffsetReset moves the committed offsets of the consumer group of the
+// service, so that it resumes reading elsewhere. Offset is the target of
+// ResetToOffset and Time the one of ResetToTimestamp. Partitions limits the
+// reset to some partitions of the topic, all of them when empty.
+type OffsetReset struct {
+	To         ResetTarget
+	Offset     int64
+	Time       time.Time
+	Partitions []int
+	DryRun     bool
+}
+
+// PartitionReset is the reset of a partition. The target is kept between
+// the first and the end offset of the partition. Replayed counts the
+// processed messages that will be processed again, Skipped the messages that
+// will not be processed at all.
+type PartitionReset struct {
+	Partition   int
+	FirstOffset int64
+	EndOffset   int64
+	// Committed is -1 if the group never committed an offset.
+	Committed int64
+	Target    int64
+	Replayed  int64
+	Skipped   int64
+}
+
+// OffsetResetResult describes a reset, or the reset that would be made in a
+// dry run.
+type OffsetResetResult struct {
+	DryRun     bool
+	Partitions []PartitionReset
+	Replayed   int64
+	Skipped    int64
+}
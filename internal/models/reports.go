@@ -0,0 +1,59 @@
+package models
+
+import "time"
+
+// ReportFilter limits reports to orders created in [From, To). Zero values
+// leave the range open. Deleted, cancelled and returned orders are never
+// counted.
+type ReportFilter struct {
+	From time.Time
+	To   time.Time
+}
+
+type Granularity string
+
+const (
+	GranularityDay   Granularity = "day"
+	GranularityWeek  Granularity = "week"
+	GranularityMonth Granularity = "month"
+)
+
+// Dimension is a field orders can be broken down by.
+type Dimension string
+
+const (
+	DimensionDeliveryService Dimension = "delivery_service"
+	DimensionProvider        Dimension = "provider"
+	DimensionBank            Dimension = "bank"
+	DimensionCurrency        Dimension = "currency"
+	DimensionRegion          Dimension = "region"
+	DimensionBrand           Dimension = "brand"
+)
+
+// SalesPoint aggregates the orders of one period. Amounts are never summed
+// across currencies.
+type SalesPoint struct {
+	Period        time.Time
+	Currency      string
+	Orders        int64
+	Revenue       int64
+	AverageBasket float64
+}
+
+// BreakdownRow aggregates the orders sharing a value of a dimension. For the
+// brand dimension Revenue is the total price of the items of that brand.
+type BreakdownRow struct {
+	Key      string
+	Currency string
+	Orders   int64
+	Revenue  int64
+}
+
+type TopItem struct {
+	NmID     int64
+	Name     string
+	Brand    string
+	Currency string
+	Quantity int64
+	Revenue  int64
+}
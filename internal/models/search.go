@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// OrderSummary is a search hit. Highlights maps "delivery" and "items" to
+// the HTML-escaped matched text with the matching words wrapped in <mark>
+// tags.
+type OrderSummary struct {
+	OrderUID    string
+	CustomerID  string
+	Status      Status
+	DateCreated time.Time
+	Name        string
+	Phone       string
+	Email       string
+	City        string
+	Rank        float64
+	Highlights  map[string]string
+}
@@ -0,0 +1,93 @@
+package models
+
+import (
+	"slices"
+	"time"
+)
+
+// Status is a stage of the order lifecycle. Items of an order go through the
+// same stages, so a single item can be cancelled or returned on its own.
+type Status string
+
+const (
+	StatusCreated    Status = "created"
+	StatusPaid       Status = "paid"
+	StatusAssembling Status = "assembling"
+	StatusShipped    Status = "shipped"
+	StatusDelivered  Status = "delivered"
+	StatusCancelled  Status = "cancelled"
+	StatusReturned   Status = "returned"
+)
+
+var transitions = map[Status][]Status{
+	StatusCreated:    {StatusPaid, StatusCancelled},
+	StatusPaid:       {StatusAssembling, StatusCancelled},
+	StatusAssembling: {StatusShipped, StatusCancelled},
+	StatusShipped:    {StatusDelivered, StatusReturned},
+	StatusDelivered:  {StatusReturned},
+}
+
+// Valid reports whether s is a known status.
+func (s Status) Valid() bool {
+	switch s {
+	case StatusCreated, StatusPaid, StatusAssembling, StatusShipped,
+		StatusDelivered, StatusCancelled, StatusReturned:
+		return true
+	}
+	return false
+}
+
+// Final reports whether no transitions are allowed from s.
+func (s Status) Final() bool {
+	return len(transitions[s]) == 0
+}
+
+// CanTransition reports whether the lifecycle allows moving from s to next.
+func (s Status) CanTransition(next Status) bool {
+	for _, allowed := range transitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// KeepLifecycle copies the lifecycle state of the stored order onto o, its
+// replacement, since a full update must not change statuses. Items are
+// matched by ChrtID; new items get the status of the order.
+func (o *Order) KeepLifecycle(stored Order) {
+	o.Status = stored.Status
+	o.StatusChangedAt = stored.StatusChangedAt
+	o.Items = slices.Clone(o.Items)
+	states := make(map[int64]Status, len(stored.Items))
+	for _, item := range stored.Items {
+		states[item.ChrtID] = item.State
+	}
+	for i := range o.Items {
+		if state, ok := states[o.Items[i].ChrtID]; ok {
+			o.Items[i].State = state
+		} else {
+			o.Items[i].State = stored.Status
+		}
+	}
+}
+
+// TransitionRequest asks to move an order, or only the items with the given
+// ChrtIDs, to the Status stage.
+type TransitionRequest struct {
+	Status  Status
+	ChrtIDs []int64
+	Reason  string
+}
+
+// StatusTransition is a recorded change of the status of an order or, if
+// ChrtID is set, of one of its items.
+type StatusTransition struct {
+	OrderUID  string
+	ChrtID    *int64 `json:",omitempty"`
+	From      Status
+	To        Status
+	Actor     string
+	Reason    string
+	ChangedAt time.Time
+}
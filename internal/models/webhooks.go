@@ -0,0 +1,42 @@
+package models
+
+import "time"
+
+type WebhookSubscription struct {
+	ID                  int64
+	URL                 string
+	Secret              string
+	EventTypes          []EventType
+	Enabled             bool
+	ConsecutiveFailures int
+	DisabledAt          *time.Time
+	CreatedAt           time.Time
+}
+
+// Accepts reports whether the subscription wants events of type t.
+// A subscription without event types accepts every event.
+func (s WebhookSubscription) Accepts(t EventType) bool {
+	if len(s.EventTypes) == 0 {
+		return true
+	}
+	for _, et := range s.EventTypes {
+		if et == t {
+			return true
+		}
+	}
+	return false
+}
+
+type WebhookDelivery struct {
+	ID             int64
+	SubscriptionID int64
+	EventID        uint64
+	EventType      EventType
+	OrderUID       string
+	Attempt        int
+	StatusCode     int
+	Error          string
+	Success        bool
+	Duration       time.Duration
+	CreatedAt      time.Time
+}
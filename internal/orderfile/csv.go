@@ -0,0 +1,339 @@
+package orderfile
+
+import (
+	"encoding/csv"
+	"errors"
+	"firstmod/internal/models"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// column is a CSV column. Order columns repeat on every row of the order,
+// item columns hold one item per row.
+type column struct {
+	name string
+	item bool
+	get  func(o *models.Order, it *models.Item) string
+	set  func(o *models.Order, it *models.Item, v string) error
+}
+
+func orderString(name string, field func(o *models.Order) *string) column {
+	return column{
+		name: name,
+		get:  func(o *models.Order, _ *models.Item) string { return *field(o) },
+		set:  func(o *models.Order, _ *models.Item, v string) error { *field(o) = v; return nil },
+	}
+}
+
+func orderInt[T int | int64](name string, field func(o *models.Order) *T) column {
+	return column{
+		name: name,
+		get:  func(o *models.Order, _ *models.Item) string { return strconv.FormatInt(int64(*field(o)), 10) },
+		set:  func(o *models.Order, _ *models.Item, v string) error { return parseInt(v, field(o)) },
+	}
+}
+
+func itemString(name string, field func(it *models.Item) *string) column {
+	return column{
+		name: name,
+		item: true,
+		get:  func(_ *models.Order, it *models.Item) string { return *field(it) },
+		set:  func(_ *models.Order, it *models.Item, v string) error { *field(it) = v; return nil },
+	}
+}
+
+func itemInt[T int | int64](name string, field func(it *models.Item) *T) column {
+	return column{
+		name: name,
+		item: true,
+		get:  func(_ *models.Order, it *models.Item) string { return strconv.FormatInt(int64(*field(it)), 10) },
+		set:  func(_ *models.Order, it *models.Item, v string) error { return parseInt(v, field(it)) },
+	}
+}
+
+func parseInt[T int | int64](v string, dst *T) error {
+	if v == "" {
+		*dst = 0
+		return nil
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return err
+	}
+	*dst = T(n)
+	return nil
+}
+
+var columns = []column{
+	orderString("order_uid", func(o *models.Order) *string { return &o.OrderUID }),
+	orderString("track_number", func(o *models.Order) *string { return &o.TrackNumber }),
+	orderString("entry", func(o *models.Order) *string { return &o.Entry }),
+	orderString("locale", func(o *models.Order) *string { return &o.Locale }),
+	orderString("internal_signature", func(o *models.Order) *string { return &o.InternalSignature }),
+	orderString("customer_id", func(o *models.Order) *string { return &o.CustomerID }),
+	orderString("delivery_service", func(o *models.Order) *string { return &o.DeliveryService }),
+	orderString("shardkey", func(o *models.Order) *string { return &o.Shardkey }),
+	orderInt("sm_id", func(o *models.Order) *int64 { return &o.SmID }),
+	{
+		name: "date_created",
+		get:  func(o *models.Order, _ *models.Item) string { return formatTime(o.DateCreated) },
+		set:  func(o *models.Order, _ *models.Item, v string) error { return parseTime(v, &o.DateCreated) },
+	},
+	orderString("oof_shard", func(o *models.Order) *string { return &o.OofShard }),
+	{
+		name: "status",
+		get:  func(o *models.Order, _ *models.Item) string { return string(o.Status) },
+		set:  func(o *models.Order, _ *models.Item, v string) error { o.Status = models.Status(v); return nil },
+	},
+	{
+		name: "status_changed_at",
+		get:  func(o *models.Order, _ *models.Item) string { return formatTime(o.StatusChangedAt) },
+		set:  func(o *models.Order, _ *models.Item, v string) error { return parseTime(v, &o.StatusChangedAt) },
+	},
+	orderInt("version", func(o *models.Order) *int64 { return &o.Version }),
+	orderString("delivery_name", func(o *models.Order) *string { return &o.DeliveryInfo.Name }),
+	orderString("delivery_phone", func(o *models.Order) *string { return &o.DeliveryInfo.Phone }),
+	orderString("delivery_zip", func(o *models.Order) *string { return &o.DeliveryInfo.Zip }),
+	orderString("delivery_city", func(o *models.Order) *string { return &o.DeliveryInfo.City }),
+	orderString("delivery_address", func(o *models.Order) *string { return &o.DeliveryInfo.Address }),
+	orderString("delivery_region", func(o *models.Order) *string { return &o.DeliveryInfo.Region }),
+	orderString("delivery_email", func(o *models.Order) *string { return &o.DeliveryInfo.Email }),
+	orderString("payment_transaction", func(o *models.Order) *string { return &o.Payment.Transaction }),
+	orderInt("payment_request_id", func(o *models.Order) *int64 { return &o.Payment.RequestID }),
+	orderString("payment_currency", func(o *models.Order) *string { return &o.Payment.Currency }),
+	orderString("payment_provider", func(o *models.Order) *string { return &o.Payment.Provider }),
+	orderInt("payment_amount", func(o *models.Order) *int { return &o.Payment.Amount }),
+	orderInt("payment_dt", func(o *models.Order) *int64 { return &o.Payment.PaymentDT }),
+	orderString("payment_bank", func(o *models.Order) *string { return &o.Payment.Bank }),
+	orderInt("payment_delivery_cost", func(o *models.Order) *int { return &o.Payment.DeliveryCost }),
+	orderInt("payment_goods_total", func(o *models.Order) *int { return &o.Payment.GoodsTotal }),
+	orderInt("payment_custom_fee", func(o *models.Order) *int { return &o.Payment.CustomFee }),
+	itemInt("item_chrt_id", func(it *models.Item) *int64 { return &it.ChrtID }),
+	itemString("item_track_number", func(it *models.Item) *string { return &it.TrackNumber }),
+	itemInt("item_price", func(it *models.Item) *int { return &it.Price }),
+	itemString("item_rid", func(it *models.Item) *string { return &it.Rid }),
+	itemString("item_name", func(it *models.Item) *string { return &it.Name }),
+	itemInt("item_sale", func(it *models.Item) *int { return &it.Sale }),
+	itemString("item_size", func(it *models.Item) *string { return &it.Size }),
+	itemInt("item_total_price", func(it *models.Item) *int { return &it.TotalPrice }),
+	itemInt("item_nm_id", func(it *models.Item) *int64 { return &it.NmID }),
+	itemString("item_brand", func(it *models.Item) *string { return &it.Brand }),
+	itemInt("item_status", func(it *models.Item) *int { return &it.Status }),
+	{
+		name: "item_state",
+		item: true,
+		get:  func(_ *models.Order, it *models.Item) string { return string(it.State) },
+		set:  func(_ *models.Order, it *models.Item, v string) error { it.State = models.Status(v); return nil },
+	},
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+func parseTime(v string, dst *time.Time) error {
+	if v == "" {
+		*dst = time.Time{}
+		return nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, v)
+	if err != nil {
+		return err
+	}
+	*dst = t
+	return nil
+}
+
+// csvWriter writes an order as one row per item. An order without items
+// takes a row with empty item columns.
+type csvWriter struct {
+	csv           *csv.Writer
+	headerWritten bool
+}
+
+func newCSVWriter(w io.Writer) *csvWriter {
+	return &csvWriter{csv: csv.NewWriter(w)}
+}
+
+func (w *csvWriter) Write(order models.Order) error {
+	if err := w.writeHeader(); err != nil {
+		return err
+	}
+	items := order.Items
+	if len(items) == 0 {
+		items = []models.Item{{}}
+	}
+	row := make([]string, len(columns))
+	for i := range items {
+		for j, c := range columns {
+			if c.item && len(order.Items) == 0 {
+				row[j] = ""
+				continue
+			}
+			row[j] = c.get(&order, &items[i])
+		}
+		if err := w.csv.Write(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Flush also writes the header of a file without orders.
+func (w *csvWriter) Flush() error {
+	if err := w.writeHeader(); err != nil {
+		return err
+	}
+	w.csv.Flush()
+	return w.csv.Error()
+}
+
+func (w *csvWriter) writeHeader() error {
+	if w.headerWritten {
+		return nil
+	}
+	w.headerWritten = true
+	header := make([]string, len(columns))
+	for i, c := range columns {
+		header[i] = c.name
+	}
+	return w.csv.Write(header)
+}
+
+// csvReader collects consecutive rows with the same order_uid into an
+// order. The header names the columns, which may come in any order; unknown
+// columns are ignored.
+type csvReader struct {
+	csv     *csv.Reader
+	columns []*column
+	uidCol  int
+	// pending is the first row of the next order, read to find where the
+	// current order ends.
+	pending     []string
+	pendingLine int
+	pendingErr  error
+	eof         bool
+}
+
+func newCSVReader(r io.Reader) *csvReader {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	return &csvReader{csv: reader, uidCol: -1}
+}
+
+func (r *csvReader) readHeader() error {
+	header, err := r.csv.Read()
+	if errors.Is(err, io.EOF) {
+		r.eof = true
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("invalid CSV header: %w", err)
+	}
+	byName := make(map[string]*column, len(columns))
+	for i := range columns {
+		byName[columns[i].name] = &columns[i]
+	}
+	r.columns = make([]*column, len(header))
+	for i, name := range header {
+		r.columns[i] = byName[name]
+		if name == "order_uid" {
+			r.uidCol = i
+		}
+	}
+	if r.uidCol < 0 {
+		return errors.New("invalid CSV header: no order_uid column")
+	}
+	return nil
+}
+
+// next reads the next row into pending. Rows that cannot be parsed are kept
+// with their error.
+func (r *csvReader) next() error {
+	r.pending, r.pendingErr = nil, nil
+	row, err := r.csv.Read()
+	var parseErr *csv.ParseError
+	switch {
+	case errors.Is(err, io.EOF):
+		r.eof = true
+		return nil
+	case errors.As(err, &parseErr) && !errors.Is(err, csv.ErrFieldCount):
+		r.pendingLine, r.pendingErr = parseErr.StartLine, parseErr
+		r.pending = []string{}
+		return nil
+	case err != nil:
+		return err
+	}
+	r.pendingLine, _ = r.csv.FieldPos(0)
+	if len(row) != len(r.columns) {
+		r.pendingErr = fmt.Errorf("row has %d fields, the header has %d", len(row), len(r.columns))
+	}
+	r.pending = row
+	return nil
+}
+
+func (r *csvReader) Read() (Record, error) {
+	if r.columns == nil && !r.eof {
+		if err := r.readHeader(); err != nil {
+			return Record{}, err
+		}
+		if err := r.next(); err != nil {
+			return Record{}, err
+		}
+	}
+	if r.pending == nil {
+		return Record{}, io.EOF
+	}
+
+	record := Record{Line: r.pendingLine}
+	uid := r.uid(r.pending)
+	// Rows without an order UID cannot be grouped and make an order each.
+	for first := true; r.pending != nil && (first || uid != "" && r.uid(r.pending) == uid); first = false {
+		if record.Err == nil {
+			record.Err = r.pendingErr
+			if record.Err == nil {
+				record.Err = r.apply(&record.Order, r.pending)
+			}
+			if record.Err != nil && r.pendingLine != record.Line {
+				record.Err = fmt.Errorf("line %d: %w", r.pendingLine, record.Err)
+			}
+		}
+		if err := r.next(); err != nil {
+			return Record{}, err
+		}
+	}
+	return record, nil
+}
+
+func (r *csvReader) uid(row []string) string {
+	if r.uidCol < len(row) {
+		return row[r.uidCol]
+	}
+	return ""
+}
+
+// apply sets the fields of order from a row, adding an item unless the
+// item columns are empty.
+func (r *csvReader) apply(order *models.Order, row []string) error {
+	var item models.Item
+	hasItem := false
+	for i, v := range row {
+		c := r.columns[i]
+		if c == nil {
+			continue
+		}
+		if err := c.set(order, &item, v); err != nil {
+			return fmt.Errorf("invalid %s: %w", c.name, err)
+		}
+		hasItem = hasItem || c.item && v != ""
+	}
+	if hasItem {
+		order.Items = append(order.Items, item)
+	}
+	return nil
+}
@@ -0,0 +1,176 @@
+// Package orderfile reads and writes streams of orders in bulk file formats:
+// NDJSON, one order per line, and CSV flattened to one row per item.
+package orderfile
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"firstmod/internal/models"
+	"fmt"
+	"io"
+)
+
+type Format string
+
+const (
+	NDJSON Format = "ndjson"
+	CSV    Format = "csv"
+)
+
+// ParseFormat parses the name of a format. The empty name is NDJSON.
+func ParseFormat(name string) (Format, error) {
+	switch Format(name) {
+	case "", NDJSON:
+		return NDJSON, nil
+	case CSV:
+		return CSV, nil
+	}
+	return "", fmt.Errorf("unknown format %q, expected ndjson or csv", name)
+}
+
+func (f Format) ContentType() string {
+	if f == CSV {
+		return "text/csv; charset=utf-8"
+	}
+	return "application/x-ndjson"
+}
+
+// Writer writes orders to a file.
+type Writer interface {
+	Write(order models.Order) error
+	// Flush writes buffered data to the underlying writer.
+	Flush() error
+}
+
+// NewWriter returns a Writer of the format writing to w.
+func NewWriter(w io.Writer, format Format) Writer {
+	if format == CSV {
+		return newCSVWriter(w)
+	}
+	buf := bufio.NewWriter(w)
+	return &ndjsonWriter{buf: buf, enc: json.NewEncoder(buf)}
+}
+
+// Record is an order read from a file. Line is the line the order starts at,
+// or its position in a JSON array. Err is set if the order is malformed; the
+// records following it are still read.
+type Record struct {
+	Line  int
+	Order models.Order
+	Err   error
+}
+
+// Reader reads orders from a file.
+type Reader interface {
+	// Read returns the next order, or io.EOF at the end of the file. Other
+	// errors mean the rest of the file cannot be read.
+	Read() (Record, error)
+}
+
+// NewReader returns a Reader of the format reading r. An NDJSON reader also
+// accepts a JSON array of orders.
+func NewReader(r io.Reader, format Format) Reader {
+	if format == CSV {
+		return newCSVReader(r)
+	}
+	return &ndjsonReader{in: bufio.NewReader(r)}
+}
+
+// MaxLineBytes is the longest line an NDJSON file may have.
+const MaxLineBytes = 4 << 20
+
+type ndjsonWriter struct {
+	buf *bufio.Writer
+	enc *json.Encoder
+}
+
+func (w *ndjsonWriter) Write(order models.Order) error {
+	return w.enc.Encode(order)
+}
+
+func (w *ndjsonWriter) Flush() error {
+	return w.buf.Flush()
+}
+
+type ndjsonReader struct {
+	in      *bufio.Reader
+	started bool
+	lines   *bufio.Scanner
+	array   *json.Decoder
+	line    int
+}
+
+func (r *ndjsonReader) Read() (Record, error) {
+	if !r.started {
+		r.started = true
+		if err := r.start(); err != nil {
+			return Record{}, err
+		}
+	}
+	if r.array != nil {
+		return r.readElement()
+	}
+	for r.lines.Scan() {
+		r.line++
+		data := bytes.TrimSpace(r.lines.Bytes())
+		if len(data) == 0 {
+			continue
+		}
+		record := Record{Line: r.line}
+		if err := json.Unmarshal(data, &record.Order); err != nil {
+			record.Err = fmt.Errorf("malformed JSON: %w", err)
+		}
+		return record, nil
+	}
+	if err := r.lines.Err(); err != nil {
+		return Record{}, fmt.Errorf("line %d: %w", r.line+1, err)
+	}
+	return Record{}, io.EOF
+}
+
+// start tells a JSON array from NDJSON by the first non-space byte.
+func (r *ndjsonReader) start() error {
+	for {
+		b, err := r.in.Peek(1)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if bytes.ContainsAny(b, " \t\r\n") {
+			r.in.ReadByte()
+			continue
+		}
+		if b[0] == '[' {
+			r.array = json.NewDecoder(r.in)
+			_, err := r.array.Token()
+			return err
+		}
+		break
+	}
+	r.lines = bufio.NewScanner(r.in)
+	r.lines.Buffer(make([]byte, 64<<10), MaxLineBytes)
+	return nil
+}
+
+func (r *ndjsonReader) readElement() (Record, error) {
+	if !r.array.More() {
+		if _, err := r.array.Token(); err != nil {
+			return Record{}, fmt.Errorf("invalid JSON array: %w", err)
+		}
+		return Record{}, io.EOF
+	}
+	var data json.RawMessage
+	if err := r.array.Decode(&data); err != nil {
+		return Record{}, fmt.Errorf("invalid JSON array at element %d: %w", r.line+1, err)
+	}
+	r.line++
+	record := Record{Line: r.line}
+	if err := json.Unmarshal(data, &record.Order); err != nil {
+		record.Err = fmt.Errorf("malformed JSON: %w", err)
+	}
+	return record, nil
+}
@@ -0,0 +1,111 @@
+package orderfile_test
+
+import (
+	"bytes"
+	"errors"
+	"firstmod/internal/models"
+	"firstmod/internal/orderfile"
+	"firstmod/internal/repository/repotest"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+var day = time.Date(2025, 3, 3, 10, 0, 0, 0, time.UTC)
+
+func readAll(t *testing.T, r orderfile.Reader) []orderfile.Record {
+	t.Helper()
+	var records []orderfile.Record
+	for {
+		record, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			return records
+		}
+		if err != nil {
+			t.Fatalf("read: %v", err)
+		}
+		records = append(records, record)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	twoItems := repotest.NewOrder("two-items", day)
+	twoItems.Items = append(twoItems.Items, twoItems.Items[0])
+	twoItems.Items[1].Name = "Lipstick, \"red\""
+	noItems := repotest.NewOrder("no-items", day.Add(time.Hour))
+	noItems.Items = nil
+	orders := []models.Order{twoItems, noItems, repotest.NewOrder("one-item", day)}
+
+	for _, format := range []orderfile.Format{orderfile.NDJSON, orderfile.CSV} {
+		t.Run(string(format), func(t *testing.T) {
+			var buf bytes.Buffer
+			w := orderfile.NewWriter(&buf, format)
+			for _, order := range orders {
+				if err := w.Write(order); err != nil {
+					t.Fatalf("write: %v", err)
+				}
+			}
+			if err := w.Flush(); err != nil {
+				t.Fatalf("flush: %v", err)
+			}
+
+			records := readAll(t, orderfile.NewReader(&buf, format))
+			if len(records) != len(orders) {
+				t.Fatalf("read %d orders, want %d", len(records), len(orders))
+			}
+			wantLines := map[orderfile.Format][]int{orderfile.NDJSON: {1, 2, 3}, orderfile.CSV: {2, 4, 5}}[format]
+			for i, record := range records {
+				if record.Err != nil {
+					t.Fatalf("order %d: %v", i, record.Err)
+				}
+				if record.Line != wantLines[i] {
+					t.Errorf("order %d starts at line %d, want %d", i, record.Line, wantLines[i])
+				}
+				if !reflect.DeepEqual(record.Order, orders[i]) {
+					t.Errorf("order %d = %+v, want %+v", i, record.Order, orders[i])
+				}
+			}
+		})
+	}
+}
+
+func TestMalformedRecords(t *testing.T) {
+	t.Run("ndjson", func(t *testing.T) {
+		input := "{\"OrderUID\":\"a\"}\n\n{broken\n{\"OrderUID\":\"b\"}\n"
+		records := readAll(t, orderfile.NewReader(strings.NewReader(input), orderfile.NDJSON))
+		if len(records) != 3 || records[1].Err == nil || records[1].Line != 3 || records[2].Order.OrderUID != "b" {
+			t.Errorf("records = %+v, want a, a malformed line 3 and b", records)
+		}
+	})
+	t.Run("json array", func(t *testing.T) {
+		input := ` [{"OrderUID":"a"}, {"SmID":"x"}, {"OrderUID":"b"}]`
+		records := readAll(t, orderfile.NewReader(strings.NewReader(input), orderfile.NDJSON))
+		if len(records) != 3 || records[1].Err == nil || records[1].Line != 2 || records[2].Order.OrderUID != "b" {
+			t.Errorf("records = %+v, want a, a malformed element 2 and b", records)
+		}
+	})
+	t.Run("csv", func(t *testing.T) {
+		input := "order_uid,sm_id,item_name,unknown\na,1,x,\na,1,y,\nb,one,x,\nb,1,y,\nc,3,,\n"
+		records := readAll(t, orderfile.NewReader(strings.NewReader(input), orderfile.CSV))
+		if len(records) != 3 {
+			t.Fatalf("read %d orders, want 3: %+v", len(records), records)
+		}
+		if records[0].Err != nil || len(records[0].Order.Items) != 2 {
+			t.Errorf("order a = %+v, want 2 items", records[0])
+		}
+		if records[1].Err == nil || records[1].Line != 4 {
+			t.Errorf("order b = %+v, want an error at line 4", records[1])
+		}
+		if records[2].Order.SmID != 3 || len(records[2].Order.Items) != 0 {
+			t.Errorf("order c = %+v, want no items", records[2])
+		}
+	})
+	t.Run("csv without order_uid", func(t *testing.T) {
+		_, err := orderfile.NewReader(strings.NewReader("sm_id\n1\n"), orderfile.CSV).Read()
+		if err == nil {
+			t.Error("read a CSV file without the order_uid column")
+		}
+	})
+}
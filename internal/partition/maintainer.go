@@ -0,0 +1,73 @@
+package partition
+
+import (
+	"context"
+	"firstmod/internal/ports"
+	"log/slog"
+	"time"
+)
+
+// Maintainer periodically creates the order partitions for the coming months
+// and removes the partitions that are older than the retention period.
+type Maintainer struct {
+	log        *slog.Logger
+	repo       ports.PartitionRepository
+	cache      ports.CacheRepository
+	premake    int
+	retention  int
+	detachOnly bool
+	interval   time.Duration
+}
+
+// NewMaintainer returns a Maintainer that keeps premake months of partitions
+// ahead and retention months behind the current one. A zero retention keeps
+// all partitions. The orders of removed partitions are evicted from cache.
+func NewMaintainer(log *slog.Logger, repo ports.PartitionRepository, cache ports.CacheRepository, premake, retention int, detachOnly bool, interval time.Duration) *Maintainer {
+	return &Maintainer{
+		log:        log,
+		repo:       repo,
+		cache:      cache,
+		premake:    premake,
+		retention:  retention,
+		detachOnly: detachOnly,
+		interval:   interval,
+	}
+}
+
+// Run maintains the partitions every interval until ctx is cancelled.
+func (m *Maintainer) Run(ctx context.Context) {
+	m.log.Info("partition maintainer started", "premake_months", m.premake, "retention_months", m.retention, "detach_only", m.detachOnly, "interval", m.interval)
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+	for {
+		m.maintain(ctx, time.Now().UTC())
+		select {
+		case <-ctx.Done():
+			m.log.Info("partition maintainer stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (m *Maintainer) maintain(ctx context.Context, now time.Time) {
+	month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	if err := m.repo.EnsurePartitions(ctx, month, month.AddDate(0, m.premake, 0)); err != nil {
+		m.log.Error("failed to create order partitions", "error", err)
+	}
+	if m.retention <= 0 {
+		return
+	}
+	removed, orderUIDs, err := m.repo.DropPartitionsBefore(ctx, month.AddDate(0, -m.retention, 0), m.detachOnly)
+	// Partitions removed before a failure are gone too.
+	for _, uid := range orderUIDs {
+		m.cache.Delete(uid, 0)
+	}
+	if err != nil {
+		m.log.Error("failed to remove old order partitions", "error", err)
+		return
+	}
+	if len(removed) > 0 {
+		m.log.Info("old order partitions removed", "partitions", removed, "orders", len(orderUIDs), "detached", m.detachOnly)
+	}
+}
@@ -3,32 +3,134 @@ package ports
 import (
 	"context"
 	"firstmod/internal/models"
+	"time"
 )
 
+// StatusChange moves the order it is given, as locked by SetStatus, to new
+// lifecycle stages and returns the transitions it made.
+type StatusChange func(order *models.Order) ([]models.StatusTransition, error)
+
 type Repository interface {
 	Add(ctx context.Context, order models.Order) error
+	Update(ctx context.Context, order models.Order, expectedVersion int64) (models.Order, error)
 	GetInfo(ctx context.Context, orderUID string) (models.Order, error)
-	Delete(ctx context.Context, orderUID string) error
+	// Delete returns the order as it was deleted, with the version the
+	// deletion wrote.
+	Delete(ctx context.Context, orderUID, reason string, expectedVersion int64) (models.Order, error)
+	Restore(ctx context.Context, orderUID string) error
+	ListDeleted(ctx context.Context, limit int) ([]models.Deletion, error)
+	History(ctx context.Context, orderUID string) ([]models.AuditEntry, error)
+	SetStatus(ctx context.Context, orderUID string, expectedVersion int64, change StatusChange) (models.Order, error)
+	ListTransitions(ctx context.Context, orderUID string) ([]models.StatusTransition, error)
+	Search(ctx context.Context, query string, limit, offset int) ([]models.OrderSummary, error)
+	SalesReport(ctx context.Context, granularity models.Granularity, filter models.ReportFilter) ([]models.SalesPoint, error)
+	Breakdown(ctx context.Context, dimension models.Dimension, filter models.ReportFilter) ([]models.BreakdownRow, error)
+	TopItems(ctx context.Context, filter models.ReportFilter, limit int) ([]models.TopItem, error)
 	GetIDs(ctx context.Context) ([]string, error)
+	// GetIDsPage returns up to limit UIDs of orders matching filter that are
+	// greater than after, in ascending order.
+	GetIDsPage(ctx context.Context, filter models.OrderFilter, after string, limit int) ([]string, error)
+	AddBatch(ctx context.Context, orders []models.Order) ([]string, error)
+	// GetInfoBatch returns the orders with the given UIDs, skipping those
+	// that do not exist. Orders whose stored data is incomplete are skipped
+	// too and their UIDs returned as incomplete.
+	GetInfoBatch(ctx context.Context, orderUIDs []string) (orders []models.Order, incomplete []string, err error)
+}
+
+type WebhookRepository interface {
+	AddWebhook(ctx context.Context, sub models.WebhookSubscription) (models.WebhookSubscription, error)
+	GetWebhook(ctx context.Context, id int64) (models.WebhookSubscription, error)
+	ListWebhooks(ctx context.Context, onlyEnabled bool) ([]models.WebhookSubscription, error)
+	DeleteWebhook(ctx context.Context, id int64) error
+	SetWebhookEnabled(ctx context.Context, id int64, enabled bool) error
+	RecordWebhookResult(ctx context.Context, id int64, success bool, disableAfter int) (bool, error)
+	AddWebhookDelivery(ctx context.Context, delivery models.WebhookDelivery) error
+	ListWebhookDeliveries(ctx context.Context, id int64, limit int) ([]models.WebhookDelivery, error)
+}
+
+type DeadLetterRepository interface {
+	AddDeadLetter(ctx context.Context, letter models.DeadLetter) (models.DeadLetter, error)
+	GetDeadLetter(ctx context.Context, id int64) (models.DeadLetter, error)
+	ListDeadLetters(ctx context.Context, afterID int64, limit int) ([]models.DeadLetter, error)
+	DeleteDeadLetter(ctx context.Context, id int64) error
+	RecordDeadLetterFailure(ctx context.Context, id int64, reason string) error
+}
+
+// PublicationRepository records which orders were published to Kafka.
+type PublicationRepository interface {
+	MarkPublished(ctx context.Context, orderUIDs []string) error
+	// ListUnpublished returns a page of the UIDs of orders that were never
+	// published, ordered by UID.
+	ListUnpublished(ctx context.Context, after string, limit int) ([]string, error)
+}
+
+type ArchiveRepository interface {
+	ArchiveDeleted(ctx context.Context, deletedBefore time.Time, limit int) (int, error)
+}
+
+type PartitionRepository interface {
+	EnsurePartitions(ctx context.Context, from, to time.Time) error
+	// DropPartitionsBefore returns the removed partitions and the UIDs of
+	// the orders they held.
+	DropPartitionsBefore(ctx context.Context, before time.Time, detachOnly bool) (partitions, orderUIDs []string, err error)
 }
 
 type CacheRepository interface {
 	Get(orderUID string) (models.Order, bool)
-	Set(order models.Order)
-	Delete(orderUID string)
+	// Set stores the order unless a newer version is cached, and reports
+	// whether it did.
+	Set(order models.Order) bool
+	// Delete removes the order. version is its version after the deletion,
+	// or zero if unknown.
+	Delete(orderUID string, version int64)
 	GetAllUIDs() []string
 	LoadToCacheFromDB(ctx context.Context, db Repository) error
 }
 
+type KafkaMessage struct {
+	Key   string
+	Value []byte
+}
+
 type KafkaProducer interface {
 	Publish(ctx context.Context, key string, value []byte) error
+	PublishBatch(ctx context.Context, messages []KafkaMessage) error
 	Close() error
 }
 
+// KafkaConsumer is the admin control of the Kafka consumer.
+type KafkaConsumer interface {
+	ResetOffsets(ctx context.Context, reset models.OffsetReset) (models.OffsetResetResult, error)
+	Pause(ctx context.Context) error
+	Resume()
+	Status(ctx context.Context) models.ConsumerStatus
+}
+
+type EventBus interface {
+	Publish(event models.OrderEvent) models.OrderEvent
+	Subscribe(ctx context.Context, afterID uint64) <-chan models.OrderEvent
+}
+
 type OrderService interface {
 	Add(context.Context, models.Order) error
+	Update(ctx context.Context, order models.Order, expectedVersion int64) (models.Order, error)
 	GetOrder(context.Context, string) (models.Order, error)
-	Delete(context.Context, string) error
+	Delete(ctx context.Context, orderUID, reason string, expectedVersion int64) error
+	Restore(ctx context.Context, orderUID string) error
+	ListDeleted(ctx context.Context, limit int) ([]models.Deletion, error)
+	History(ctx context.Context, orderUID string) ([]models.AuditEntry, error)
+	Transition(ctx context.Context, orderUID string, req models.TransitionRequest, expectedVersion int64) (models.Order, error)
+	Transitions(ctx context.Context, orderUID string) ([]models.StatusTransition, error)
 	GetOrderIDs(context.Context) ([]string, error)
+	ListOrders(ctx context.Context, filter models.OrderFilter, pageToken string, pageSize int) ([]models.Order, string, error)
+	ExportOrders(ctx context.Context, filter models.OrderFilter, fn func(models.Order) error) error
+	Search(ctx context.Context, query, pageToken string, pageSize int) ([]models.OrderSummary, string, error)
+	SalesReport(ctx context.Context, granularity models.Granularity, filter models.ReportFilter) ([]models.SalesPoint, error)
+	Breakdown(ctx context.Context, dimension models.Dimension, filter models.ReportFilter) ([]models.BreakdownRow, error)
+	TopItems(ctx context.Context, filter models.ReportFilter, limit int) ([]models.TopItem, error)
+	BatchCreate(ctx context.Context, orders []models.Order) ([]models.BatchItemResult, error)
+	BatchGet(ctx context.Context, orderUIDs []string) (orders []models.Order, missing, incomplete []string, err error)
+	Subscribe(ctx context.Context, afterID uint64) <-chan models.OrderEvent
 	LoadCacheFromDB(ctx context.Context) error
+	CheckConsistency(ctx context.Context, opts models.ConsistencyOptions) (models.ConsistencyReport, error)
 }
@@ -0,0 +1,251 @@
+package ratelimit
+
+import (
+	"container/list"
+	"context"
+	"expvar"
+	"fmt"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	apiKeyHeader = "X-API-Key"
+
+	// maxBuckets caps the number of buckets kept between cleanups; the least
+	// recently seen bucket is dropped to make room for a new one.
+	maxBuckets = 100_000
+)
+
+var (
+	rejectedTotal = expvar.NewMap("ratelimit_rejected_total")
+	inFlightGauge = expvar.NewInt("http_in_flight_requests")
+)
+
+// Budget is a token bucket configuration: Rate tokens are added per second
+// up to Burst tokens.
+type Budget struct {
+	Rate  float64
+	Burst int
+}
+
+type bucket struct {
+	key      bucketKey
+	tokens   float64
+	last     time.Time
+	lastSeen time.Time
+}
+
+type bucketKey struct {
+	route  string
+	client string
+}
+
+type Limiter struct {
+	log      *slog.Logger
+	def      Budget
+	routes   map[string]Budget
+	apiKeys  map[string]bool
+	inFlight chan struct{}
+	idleTTL  time.Duration
+
+	mu      sync.Mutex
+	buckets map[bucketKey]*list.Element
+	// recent holds the buckets, the most recently seen first.
+	recent *list.List
+}
+
+// New returns a limiter. Clients sending one of apiKeys in X-API-Key get a
+// budget of their own, other clients are limited by IP.
+func New(log *slog.Logger, def Budget, routes map[string]Budget, apiKeys []string, maxInFlight int) *Limiter {
+	l := &Limiter{
+		log:     log,
+		def:     def,
+		routes:  routes,
+		apiKeys: make(map[string]bool, len(apiKeys)),
+		idleTTL: 10 * time.Minute,
+		buckets: make(map[bucketKey]*list.Element),
+		recent:  list.New(),
+	}
+	for _, key := range apiKeys {
+		if key = strings.TrimSpace(key); key != "" {
+			l.apiKeys[key] = true
+		}
+	}
+	if maxInFlight > 0 {
+		l.inFlight = make(chan struct{}, maxInFlight)
+	}
+	log.Info("rate limiter initialized", "rate", def.Rate, "burst", def.Burst, "routes", len(routes), "max_in_flight", maxInFlight)
+	return l
+}
+
+// ParseRoutes parses per-route budgets in the form
+// "GET /order/{orderID}=20:40;POST /order=5:10", where each value is rate:burst.
+func ParseRoutes(s string) (map[string]Budget, error) {
+	routes := make(map[string]Budget)
+	for _, entry := range strings.Split(s, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		route, value, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid route budget %q: missing '='", entry)
+		}
+		rateStr, burstStr, ok := strings.Cut(value, ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid route budget %q: expected rate:burst", entry)
+		}
+		rate, err := strconv.ParseFloat(strings.TrimSpace(rateStr), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rate in %q: %w", entry, err)
+		}
+		burst, err := strconv.Atoi(strings.TrimSpace(burstStr))
+		if err != nil {
+			return nil, fmt.Errorf("invalid burst in %q: %w", entry, err)
+		}
+		routes[strings.TrimSpace(route)] = Budget{Rate: rate, Burst: burst}
+	}
+	return routes, nil
+}
+
+// Limit applies the token bucket budget configured for route to every client
+// of next. Clients are identified by a known API key, otherwise by IP, so
+// that made-up keys do not get fresh budgets.
+func (l *Limiter) Limit(route string, next http.Handler) http.Handler {
+	budget, ok := l.routes[route]
+	if !ok {
+		budget = l.def
+	}
+	if budget.Rate <= 0 {
+		return next
+	}
+	if budget.Burst < 1 {
+		budget.Burst = 1
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		client := l.clientKey(r)
+		allowed, retryAfter := l.take(bucketKey{route: route, client: client}, budget, time.Now())
+		if !allowed {
+			rejectedTotal.Add("rate:"+route, 1)
+			l.log.Warn("request rejected by rate limiter", "route", route, "remote_addr", r.RemoteAddr, "retry_after", retryAfter)
+			reject(w, retryAfter)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// LimitInFlight rejects requests once the number of requests being served
+// concurrently reaches the configured maximum. Requests to the exempt paths
+// are not counted.
+func (l *Limiter) LimitInFlight(next http.Handler, exempt ...string) http.Handler {
+	if l.inFlight == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if slices.Contains(exempt, r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		select {
+		case l.inFlight <- struct{}{}:
+		default:
+			rejectedTotal.Add("in_flight", 1)
+			l.log.Warn("request rejected by in-flight limit", "path", r.URL.Path, "limit", cap(l.inFlight))
+			reject(w, time.Second)
+			return
+		}
+		inFlightGauge.Add(1)
+		defer func() {
+			inFlightGauge.Add(-1)
+			<-l.inFlight
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Run periodically drops buckets of clients that have been idle for a while.
+// It returns when ctx is cancelled.
+func (l *Limiter) Run(ctx context.Context) {
+	ticker := time.NewTicker(l.idleTTL)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			l.cleanup(now)
+		}
+	}
+}
+
+func (l *Limiter) take(key bucketKey, budget Budget, now time.Time) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var b *bucket
+	if e, ok := l.buckets[key]; ok {
+		b = e.Value.(*bucket)
+		l.recent.MoveToFront(e)
+	} else {
+		if len(l.buckets) >= maxBuckets {
+			oldest := l.recent.Back()
+			delete(l.buckets, oldest.Value.(*bucket).key)
+			l.recent.Remove(oldest)
+		}
+		b = &bucket{key: key, tokens: float64(budget.Burst), last: now}
+		l.buckets[key] = l.recent.PushFront(b)
+	}
+	b.lastSeen = now
+
+	elapsed := now.Sub(b.last).Seconds()
+	b.tokens = math.Min(float64(budget.Burst), b.tokens+elapsed*budget.Rate)
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	wait := time.Duration((1 - b.tokens) / budget.Rate * float64(time.Second))
+	return false, wait
+}
+
+func (l *Limiter) cleanup(now time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	removed := 0
+	for e := l.recent.Back(); e != nil && now.Sub(e.Value.(*bucket).lastSeen) > l.idleTTL; e = l.recent.Back() {
+		delete(l.buckets, e.Value.(*bucket).key)
+		l.recent.Remove(e)
+		removed++
+	}
+	l.log.Debug("rate limiter buckets cleaned up", "removed", removed, "remaining", len(l.buckets))
+}
+
+func (l *Limiter) clientKey(r *http.Request) string {
+	if key := r.Header.Get(apiKeyHeader); l.apiKeys[key] {
+		return "key:" + key
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+func reject(w http.ResponseWriter, retryAfter time.Duration) {
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	http.Error(w, "Too many requests", http.StatusTooManyRequests)
+}
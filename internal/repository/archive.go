@@ -0,0 +1,138 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"firstmod/internal/models"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Restore clears the deletion mark of a soft-deleted order.
+func (db *DB) Restore(ctx context.Context, orderUID string) error {
+	db.log.Debug("attempting to restore order", "order_uid", orderUID)
+
+	err := db.inTx(ctx, func(tx pgx.Tx) error {
+		order, err := db.lockOrder(ctx, tx, orderUID, true)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				db.log.Info("attempted to restore order that is not deleted", "order_uid", orderUID)
+			}
+			return err
+		}
+
+		_, err = tx.Exec(ctx, `
+            UPDATE orders SET deleted_at = NULL, deleted_by = NULL, delete_reason = NULL, version = version + 1
+            WHERE order_uid = $1`, orderUID)
+		if err != nil {
+			db.log.Error("failed to restore order", "order_uid", orderUID, "error", err)
+			return err
+		}
+		order.Version++
+		return db.writeAudit(ctx, tx, models.EventOrderRestored, orderUID, nil, &order)
+	})
+	if err != nil {
+		return err
+	}
+
+	db.markWritten(orderUID)
+	db.log.Info("order restored", "order_uid", orderUID)
+	return nil
+}
+
+// ListDeleted returns the most recently deleted orders that are not archived yet.
+func (db *DB) ListDeleted(ctx context.Context, limit int) ([]models.Deletion, error) {
+	rows, err := db.reader(ctx).Query(ctx, `
+        SELECT order_uid, deleted_at, COALESCE(deleted_by, ''), COALESCE(delete_reason, '')
+        FROM orders
+        WHERE deleted_at IS NOT NULL
+        ORDER BY deleted_at DESC
+        LIMIT $1`, limit)
+	if err != nil {
+		db.log.Error("failed to query deleted orders", "error", err)
+		return nil, err
+	}
+	deletions, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (models.Deletion, error) {
+		var d models.Deletion
+		err := row.Scan(&d.OrderUID, &d.DeletedAt, &d.DeletedBy, &d.Reason)
+		return d, err
+	})
+	if err != nil {
+		db.log.Error("failed to scan deleted order rows", "error", err)
+		return nil, err
+	}
+	return deletions, nil
+}
+
+// ArchiveDeleted moves up to limit orders deleted before deletedBefore into
+// archived_orders and removes them with their related rows. It returns the
+// number of archived orders.
+func (db *DB) ArchiveDeleted(ctx context.Context, deletedBefore time.Time, limit int) (int, error) {
+	archived := 0
+	err := db.inTx(ctx, func(tx pgx.Tx) error {
+		rows, err := tx.Query(ctx, orderSelectSQL+`
+        WHERE o.order_uid IN (
+            SELECT order_uid FROM orders
+            WHERE deleted_at < $1
+            ORDER BY deleted_at
+            LIMIT $2
+            FOR UPDATE SKIP LOCKED
+        )`, deletedBefore, limit)
+		if err != nil {
+			db.log.Error("failed to query orders to archive", "error", err)
+			return err
+		}
+		var orders []models.Order
+		for rows.Next() {
+			order, err := scanOrder(rows)
+			if err != nil && !errors.Is(err, models.ErrDataIntegrity) {
+				rows.Close()
+				db.log.Error("failed to scan order to archive", "error", err)
+				return err
+			}
+			// Incomplete orders are archived as they are.
+			orders = append(orders, order)
+		}
+		if err := rows.Err(); err != nil {
+			db.log.Error("error after scanning orders to archive", "error", err)
+			return err
+		}
+
+		for _, order := range orders {
+			data, err := json.Marshal(order)
+			if err != nil {
+				db.log.Error("failed to marshal order for archive", "order_uid", order.OrderUID, "error", err)
+				return err
+			}
+			_, err = tx.Exec(ctx, `
+                INSERT INTO archived_orders (order_uid, data, deleted_at, deleted_by, delete_reason)
+                SELECT order_uid, $2, deleted_at, deleted_by, delete_reason
+                FROM orders WHERE order_uid = $1`, order.OrderUID, data)
+			if err != nil {
+				db.log.Error("failed to archive order", "order_uid", order.OrderUID, "error", err)
+				return err
+			}
+			for _, query := range []string{
+				"DELETE FROM orders WHERE order_uid = $1",
+				"DELETE FROM order_keys WHERE order_uid = $1",
+			} {
+				if _, err := tx.Exec(ctx, query, order.OrderUID); err != nil {
+					db.log.Error("failed to remove archived order", "order_uid", order.OrderUID, "error", err)
+					return err
+				}
+			}
+		}
+		archived = len(orders)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	if archived > 0 {
+		db.log.Info("deleted orders archived", "count", archived)
+	}
+	return archived, nil
+}
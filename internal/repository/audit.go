@@ -0,0 +1,104 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"firstmod/internal/audit"
+	"firstmod/internal/models"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// auditRecord builds the order_audit row for a change of an order. A nil
+// before or after means the order did not exist before or after the change.
+func auditRecord(ctx context.Context, action models.EventType, orderUID string, before, after *models.Order) ([]any, error) {
+	entry, err := audit.NewEntry(ctx, action, orderUID, before, after, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	var requestID any
+	if entry.RequestID != "" {
+		requestID = entry.RequestID
+	}
+	return []any{
+		orderUID, string(action), entry.Actor, entry.Source, requestID,
+		[]byte(entry.Before), []byte(entry.After), []byte(entry.Diff),
+	}, nil
+}
+
+var auditColumns = []string{"order_uid", "action", "actor", "source", "request_id", "before", "after", "diff"}
+
+// writeAudit appends a record of the change to order_audit within tx, so the
+// record is committed or rolled back together with the change itself.
+func (db *DB) writeAudit(ctx context.Context, tx pgx.Tx, action models.EventType, orderUID string, before, after *models.Order) error {
+	record, err := auditRecord(ctx, action, orderUID, before, after)
+	if err != nil {
+		db.log.Error("failed to build audit record", "order_uid", orderUID, "error", err)
+		return err
+	}
+	_, err = tx.Exec(ctx, `
+        INSERT INTO order_audit (order_uid, action, actor, source, request_id, before, after, diff)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`, record...)
+	if err != nil {
+		db.log.Error("failed to write audit record", "order_uid", orderUID, "action", action, "error", err)
+		return err
+	}
+	return nil
+}
+
+// lockOrder locks the order row for the rest of tx and returns the current
+// state of the order. Incomplete orders are returned as they are, so they can
+// still be changed. It returns sql.ErrNoRows if the order does not exist or
+// its deletion state differs from deleted.
+func (db *DB) lockOrder(ctx context.Context, tx pgx.Tx, orderUID string, deleted bool) (models.Order, error) {
+	var exists bool
+	err := tx.QueryRow(ctx, `
+        SELECT TRUE FROM orders
+        WHERE order_uid = $1 AND (deleted_at IS NOT NULL) = $2
+        FOR UPDATE`, orderUID, deleted).Scan(&exists)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.Order{}, sql.ErrNoRows
+		}
+		db.log.Error("failed to lock order", "order_uid", orderUID, "error", err)
+		return models.Order{}, err
+	}
+
+	order, err := scanOrder(tx.QueryRow(ctx, orderSelectSQL+" WHERE o.order_uid = $1", orderUID))
+	if err != nil && !errors.Is(err, models.ErrDataIntegrity) {
+		db.log.Error("failed to read locked order", "order_uid", orderUID, "error", err)
+		return models.Order{}, err
+	}
+	return order, nil
+}
+
+// History returns the audit trail of the order, oldest change first.
+func (db *DB) History(ctx context.Context, orderUID string) ([]models.AuditEntry, error) {
+	db.log.Debug("attempting to get order history", "order_uid", orderUID)
+
+	rows, err := db.reader(ctx, orderUID).Query(ctx, `
+        SELECT id, order_uid, action, actor, source, COALESCE(request_id, ''), before, after, diff, changed_at
+        FROM order_audit
+        WHERE order_uid = $1
+        ORDER BY id`, orderUID)
+	if err != nil {
+		db.log.Error("failed to query order history", "order_uid", orderUID, "error", err)
+		return nil, err
+	}
+	entries, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (models.AuditEntry, error) {
+		var e models.AuditEntry
+		var before, after, diff []byte
+		err := row.Scan(&e.ID, &e.OrderUID, &e.Action, &e.Actor, &e.Source, &e.RequestID, &before, &after, &diff, &e.ChangedAt)
+		e.Before, e.After, e.Diff = before, after, diff
+		return e, err
+	})
+	if err != nil {
+		db.log.Error("failed to scan order history rows", "order_uid", orderUID, "error", err)
+		return nil, err
+	}
+
+	db.log.Debug("order history retrieved", "order_uid", orderUID, "entries", len(entries))
+	return entries, nil
+}
@@ -0,0 +1,197 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"firstmod/internal/models"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// AddBatch inserts orders in a single transaction, skipping orders whose UID
+// already exists. It returns the UIDs of the orders that were inserted.
+func (db *DB) AddBatch(ctx context.Context, orders []models.Order) ([]string, error) {
+	db.log.Debug("attempting to add batch of orders", "count", len(orders))
+
+	dates := make([]time.Time, 0, len(orders))
+	for _, order := range orders {
+		dates = append(dates, order.DateCreated)
+	}
+	if err := db.ensurePartitions(ctx, dates...); err != nil {
+		return nil, err
+	}
+
+	var created []string
+	err := db.inTx(ctx, func(tx pgx.Tx) error {
+		var uids, transactions []string
+		for _, order := range orders {
+			uids = append(uids, order.OrderUID)
+			transactions = append(transactions, order.Payment.Transaction)
+		}
+		keysSQL := `
+        INSERT INTO order_keys (order_uid, transaction_uid, date_created)
+        SELECT * FROM unnest($1::varchar[], $2::varchar[], $3::timestamptz[])
+        ON CONFLICT (order_uid) DO NOTHING
+        RETURNING order_uid`
+		rows, err := tx.Query(ctx, keysSQL, uids, transactions, dates)
+		if err != nil {
+			db.log.Error("failed to register keys of batch of orders", "error", err)
+			return err
+		}
+		created, err = pgx.CollectRows(rows, pgx.RowTo[string])
+		if err != nil {
+			db.log.Error("failed to read inserted order UIDs", "error", err)
+			return err
+		}
+
+		isCreated := make(map[string]bool, len(created))
+		for _, uid := range created {
+			isCreated[uid] = true
+		}
+
+		var (
+			trackNumbers, entries, locales, signatures        []string
+			customers, deliveryServices, shardkeys, oofShards []string
+			statuses                                          []string
+			smIDs                                             []int64
+			createdDates, statusDates                         []time.Time
+		)
+		uids = uids[:0]
+		for _, order := range orders {
+			if !isCreated[order.OrderUID] {
+				continue
+			}
+			uids = append(uids, order.OrderUID)
+			trackNumbers = append(trackNumbers, order.TrackNumber)
+			entries = append(entries, order.Entry)
+			locales = append(locales, order.Locale)
+			signatures = append(signatures, order.InternalSignature)
+			customers = append(customers, order.CustomerID)
+			deliveryServices = append(deliveryServices, order.DeliveryService)
+			shardkeys = append(shardkeys, order.Shardkey)
+			smIDs = append(smIDs, order.SmID)
+			createdDates = append(createdDates, order.DateCreated)
+			oofShards = append(oofShards, order.OofShard)
+			statuses = append(statuses, string(order.Status))
+			statusDates = append(statusDates, order.StatusChangedAt)
+		}
+
+		orderSQL := `
+        INSERT INTO orders (
+            order_uid, track_number, entry, locale, internal_signature,
+            customer_id, delivery_service, shardkey, sm_id, date_created, oof_shard,
+            status, status_changed_at
+        )
+        SELECT * FROM unnest(
+            $1::varchar[], $2::varchar[], $3::varchar[], $4::varchar[], $5::varchar[],
+            $6::varchar[], $7::varchar[], $8::varchar[], $9::bigint[], $10::timestamptz[], $11::varchar[],
+            $12::varchar[], $13::timestamptz[]
+        )`
+		_, err = tx.Exec(ctx, orderSQL,
+			uids, trackNumbers, entries, locales, signatures,
+			customers, deliveryServices, shardkeys, smIDs, createdDates, oofShards,
+			statuses, statusDates,
+		)
+		if err != nil {
+			db.log.Error("failed to insert batch of orders", "error", err)
+			return err
+		}
+
+		var deliveryRows, paymentRows, itemRows, auditRows [][]any
+		for _, order := range orders {
+			if !isCreated[order.OrderUID] {
+				continue
+			}
+			record, err := auditRecord(ctx, models.EventOrderCreated, order.OrderUID, nil, &order)
+			if err != nil {
+				db.log.Error("failed to build audit record", "order_uid", order.OrderUID, "error", err)
+				return err
+			}
+			auditRows = append(auditRows, record)
+			d := order.DeliveryInfo
+			deliveryRows = append(deliveryRows, []any{
+				order.OrderUID, order.DateCreated, d.Name, d.Phone, d.Zip, d.City, d.Address, d.Region, d.Email,
+			})
+			p := order.Payment
+			paymentRows = append(paymentRows, []any{
+				order.OrderUID, order.DateCreated, p.Transaction, p.RequestID, p.Currency, p.Provider, p.Amount,
+				p.PaymentDT, p.Bank, p.DeliveryCost, p.GoodsTotal, p.CustomFee,
+			})
+			for _, item := range order.Items {
+				itemRows = append(itemRows, []any{
+					order.OrderUID, order.DateCreated, item.ChrtID, item.TrackNumber, item.Price, item.Rid, item.Name,
+					item.Sale, item.Size, item.TotalPrice, item.NmID, item.Brand, item.Status, item.State,
+				})
+			}
+		}
+
+		copies := []struct {
+			table   string
+			columns []string
+			rows    [][]any
+		}{
+			{"delivery_info", []string{"order_uid", "date_created", "name", "phone", "zip", "city", "address", "region", "email"}, deliveryRows},
+			{"payments", []string{"order_uid", "date_created", "transaction_uid", "request_id", "currency", "provider", "amount",
+				"payment_dt", "bank", "delivery_cost", "goods_total", "custom_fee"}, paymentRows},
+			{"items", []string{"order_uid", "date_created", "chrt_id", "track_number", "price", "rid", "name",
+				"sale", "size", "total_price", "nm_id", "brand", "status", "state"}, itemRows},
+			{"order_audit", auditColumns, auditRows},
+		}
+		for _, c := range copies {
+			if len(c.rows) == 0 {
+				continue
+			}
+			if _, err := tx.CopyFrom(ctx, pgx.Identifier{c.table}, c.columns, pgx.CopyFromRows(c.rows)); err != nil {
+				db.log.Error("failed to copy batch rows", "table", c.table, "error", err)
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	db.markWritten(created...)
+	db.log.Info("batch of orders added", "requested", len(orders), "created", len(created))
+	return created, nil
+}
+
+// GetInfoBatch returns the orders with the given UIDs. UIDs that do not exist
+// are skipped. Orders lacking delivery info or payment are skipped as well
+// and their UIDs returned as incomplete, so one broken order does not fail
+// the batch.
+func (db *DB) GetInfoBatch(ctx context.Context, orderUIDs []string) ([]models.Order, []string, error) {
+	db.log.Debug("attempting to get batch of orders", "count", len(orderUIDs))
+
+	rows, err := db.reader(ctx, orderUIDs...).Query(ctx, orderSelectSQL+" WHERE o.order_uid = ANY($1) AND o.deleted_at IS NULL", orderUIDs)
+	if err != nil {
+		db.log.Error("failed to query batch of orders", "error", err)
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	var orders []models.Order
+	var incomplete []string
+	for rows.Next() {
+		order, err := scanOrder(rows)
+		if errors.Is(err, models.ErrDataIntegrity) {
+			db.log.Error("skipping incomplete order in batch", "order_uid", order.OrderUID, "error", err)
+			incomplete = append(incomplete, order.OrderUID)
+			continue
+		}
+		if err != nil {
+			db.log.Error("failed to scan order row", "order_uid", order.OrderUID, "error", err)
+			return nil, nil, err
+		}
+		orders = append(orders, order)
+	}
+	if err = rows.Err(); err != nil {
+		db.log.Error("error after scanning order rows", "error", err)
+		return nil, nil, err
+	}
+
+	db.log.Debug("batch of orders retrieved", "requested", len(orderUIDs), "found", len(orders), "incomplete", len(incomplete))
+	return orders, incomplete, nil
+}
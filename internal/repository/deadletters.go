@@ -0,0 +1,108 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"firstmod/internal/models"
+
+	"github.com/jackc/pgx/v5"
+)
+
+func (db *DB) AddDeadLetter(ctx context.Context, letter models.DeadLetter) (models.DeadLetter, error) {
+	err := db.conn.QueryRow(ctx, `
+        INSERT INTO dead_letters (topic, kafka_partition, kafka_offset, message_key, value, error)
+        VALUES ($1, $2, $3, $4, $5, $6)
+        RETURNING id, attempts, failed_at`,
+		letter.Topic, letter.Partition, letter.Offset, letter.Key, []byte(letter.Value), letter.Error,
+	).Scan(&letter.ID, &letter.Attempts, &letter.FailedAt)
+	if err != nil {
+		db.log.Error("failed to insert dead letter", "topic", letter.Topic, "offset", letter.Offset, "error", err)
+		return models.DeadLetter{}, err
+	}
+	db.log.Info("dead letter added", "dead_letter_id", letter.ID, "topic", letter.Topic, "offset", letter.Offset)
+	return letter, nil
+}
+
+const deadLetterColumns = `id, topic, kafka_partition, kafka_offset, message_key, value, error, attempts, failed_at`
+
+func scanDeadLetter(row pgx.Row) (models.DeadLetter, error) {
+	var letter models.DeadLetter
+	var value []byte
+	err := row.Scan(
+		&letter.ID,
+		&letter.Topic,
+		&letter.Partition,
+		&letter.Offset,
+		&letter.Key,
+		&value,
+		&letter.Error,
+		&letter.Attempts,
+		&letter.FailedAt,
+	)
+	letter.Value = string(value)
+	return letter, err
+}
+
+func (db *DB) GetDeadLetter(ctx context.Context, id int64) (models.DeadLetter, error) {
+	letter, err := scanDeadLetter(db.conn.QueryRow(ctx, "SELECT "+deadLetterColumns+" FROM dead_letters WHERE id = $1", id))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.DeadLetter{}, sql.ErrNoRows
+		}
+		db.log.Error("failed to query dead letter", "dead_letter_id", id, "error", err)
+		return models.DeadLetter{}, err
+	}
+	return letter, nil
+}
+
+// ListDeadLetters returns up to limit dead letters with IDs above afterID,
+// oldest first.
+func (db *DB) ListDeadLetters(ctx context.Context, afterID int64, limit int) ([]models.DeadLetter, error) {
+	rows, err := db.conn.Query(ctx, "SELECT "+deadLetterColumns+" FROM dead_letters WHERE id > $1 ORDER BY id LIMIT $2", afterID, limit)
+	if err != nil {
+		db.log.Error("failed to query dead letters", "error", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var letters []models.DeadLetter
+	for rows.Next() {
+		letter, err := scanDeadLetter(rows)
+		if err != nil {
+			db.log.Error("failed to scan dead letter row", "error", err)
+			return nil, err
+		}
+		letters = append(letters, letter)
+	}
+	return letters, rows.Err()
+}
+
+func (db *DB) DeleteDeadLetter(ctx context.Context, id int64) error {
+	cmdTag, err := db.conn.Exec(ctx, "DELETE FROM dead_letters WHERE id = $1", id)
+	if err != nil {
+		db.log.Error("failed to delete dead letter", "dead_letter_id", id, "error", err)
+		return err
+	}
+	if cmdTag.RowsAffected() == 0 {
+		return sql.ErrNoRows
+	}
+	db.log.Info("dead letter deleted", "dead_letter_id", id)
+	return nil
+}
+
+// RecordDeadLetterFailure counts another failed attempt to process the dead
+// letter and stores the reason.
+func (db *DB) RecordDeadLetterFailure(ctx context.Context, id int64, reason string) error {
+	cmdTag, err := db.conn.Exec(ctx, `
+        UPDATE dead_letters SET attempts = attempts + 1, error = $2, failed_at = NOW()
+        WHERE id = $1`, id, reason)
+	if err != nil {
+		db.log.Error("failed to record dead letter failure", "dead_letter_id", id, "error", err)
+		return err
+	}
+	if cmdTag.RowsAffected() == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
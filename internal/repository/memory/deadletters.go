@@ -0,0 +1,84 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"firstmod/internal/models"
+	"slices"
+	"time"
+)
+
+func (r *Repository) AddDeadLetter(ctx context.Context, letter models.DeadLetter) (models.DeadLetter, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.lastLetterID++
+	letter.ID = r.lastLetterID
+	letter.Attempts = 1
+	letter.FailedAt = time.Now().UTC()
+	r.deadLetters = append(r.deadLetters, letter)
+	r.log.Info("dead letter added", "dead_letter_id", letter.ID, "topic", letter.Topic, "offset", letter.Offset)
+	return letter, nil
+}
+
+// deadLetter returns the index of the dead letter with the given ID.
+func (r *Repository) deadLetter(id int64) (int, error) {
+	i, ok := slices.BinarySearchFunc(r.deadLetters, id, func(l models.DeadLetter, id int64) int {
+		return int(l.ID - id)
+	})
+	if !ok {
+		return 0, sql.ErrNoRows
+	}
+	return i, nil
+}
+
+func (r *Repository) GetDeadLetter(ctx context.Context, id int64) (models.DeadLetter, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	i, err := r.deadLetter(id)
+	if err != nil {
+		return models.DeadLetter{}, err
+	}
+	return r.deadLetters[i], nil
+}
+
+func (r *Repository) ListDeadLetters(ctx context.Context, afterID int64, limit int) ([]models.DeadLetter, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var letters []models.DeadLetter
+	for _, letter := range r.deadLetters {
+		if letter.ID > afterID && len(letters) < limit {
+			letters = append(letters, letter)
+		}
+	}
+	return letters, nil
+}
+
+func (r *Repository) DeleteDeadLetter(ctx context.Context, id int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	i, err := r.deadLetter(id)
+	if err != nil {
+		return err
+	}
+	r.deadLetters = slices.Delete(r.deadLetters, i, i+1)
+	r.log.Info("dead letter deleted", "dead_letter_id", id)
+	return nil
+}
+
+func (r *Repository) RecordDeadLetterFailure(ctx context.Context, id int64, reason string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	i, err := r.deadLetter(id)
+	if err != nil {
+		return err
+	}
+	r.deadLetters[i].Attempts++
+	r.deadLetters[i].Error = reason
+	r.deadLetters[i].FailedAt = time.Now().UTC()
+	return nil
+}
@@ -0,0 +1,391 @@
+// Package memory implements ports.Repository in memory, for development and
+// tests without a database. Nothing survives a restart.
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"firstmod/internal/actor"
+	"firstmod/internal/audit"
+	"firstmod/internal/models"
+	"firstmod/internal/ports"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+type record struct {
+	order       models.Order
+	deletedAt   time.Time
+	deletedBy   string
+	reason      string
+	publishedAt time.Time
+}
+
+func (r *record) deleted() bool {
+	return !r.deletedAt.IsZero()
+}
+
+type Repository struct {
+	log *slog.Logger
+
+	mu           sync.Mutex
+	orders       map[string]*record
+	transactions map[string]string // payment transaction -> order UID
+	audit        []models.AuditEntry
+	transitions  []models.StatusTransition
+	deadLetters  []models.DeadLetter
+	lastLetterID int64
+}
+
+func New(log *slog.Logger) *Repository {
+	log.Info("using in-memory order storage")
+	return &Repository{
+		log:          log,
+		orders:       make(map[string]*record),
+		transactions: make(map[string]string),
+	}
+}
+
+// clone returns a copy of order that shares no memory with it.
+func clone(order models.Order) models.Order {
+	order.Items = slices.Clone(order.Items)
+	return order
+}
+
+// checkKeys reports whether order can be stored without breaking the
+// uniqueness of order UIDs and payment transactions.
+func (r *Repository) checkKeys(order models.Order, isNew bool) error {
+	if _, ok := r.orders[order.OrderUID]; ok && isNew {
+		r.log.Info("order already exists", "order_uid", order.OrderUID)
+		return fmt.Errorf("%w: %s", models.ErrOrderExists, order.OrderUID)
+	}
+	if uid, ok := r.transactions[order.Payment.Transaction]; ok && uid != order.OrderUID {
+		r.log.Info("payment transaction is already used by another order", "order_uid", order.OrderUID, "transaction", order.Payment.Transaction)
+		return fmt.Errorf("%w: payment transaction %s is already used", models.ErrInvalidOrder, order.Payment.Transaction)
+	}
+	return nil
+}
+
+func (r *Repository) writeAudit(ctx context.Context, action models.EventType, orderUID string, before, after *models.Order) error {
+	entry, err := audit.NewEntry(ctx, action, orderUID, before, after, time.Now())
+	if err != nil {
+		r.log.Error("failed to build audit record", "order_uid", orderUID, "error", err)
+		return err
+	}
+	entry.ID = int64(len(r.audit) + 1)
+	r.audit = append(r.audit, entry)
+	return nil
+}
+
+// live returns the order if it exists and its deletion state matches deleted.
+func (r *Repository) live(orderUID string, deleted bool) (*record, error) {
+	rec, ok := r.orders[orderUID]
+	if !ok || rec.deleted() != deleted {
+		return nil, sql.ErrNoRows
+	}
+	return rec, nil
+}
+
+func checkVersion(order models.Order, expectedVersion int64) error {
+	if expectedVersion != 0 && order.Version != expectedVersion {
+		return fmt.Errorf("%w: expected %d, current %d", models.ErrVersionMismatch, expectedVersion, order.Version)
+	}
+	return nil
+}
+
+func (r *Repository) Add(ctx context.Context, order models.Order) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.checkKeys(order, true); err != nil {
+		return err
+	}
+	order = clone(order)
+	if err := r.writeAudit(ctx, models.EventOrderCreated, order.OrderUID, nil, &order); err != nil {
+		return err
+	}
+	r.orders[order.OrderUID] = &record{order: order}
+	r.transactions[order.Payment.Transaction] = order.OrderUID
+	r.log.Debug("order added", "order_uid", order.OrderUID)
+	return nil
+}
+
+func (r *Repository) Update(ctx context.Context, order models.Order, expectedVersion int64) (models.Order, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	rec, err := r.live(order.OrderUID, false)
+	if err != nil {
+		return models.Order{}, err
+	}
+	before := rec.order
+	if err := checkVersion(before, expectedVersion); err != nil {
+		return models.Order{}, err
+	}
+	if err := r.checkKeys(order, false); err != nil {
+		return models.Order{}, err
+	}
+	order.Version = before.Version + 1
+	order.KeepLifecycle(before)
+	if err := r.writeAudit(ctx, models.EventOrderUpdated, order.OrderUID, &before, &order); err != nil {
+		return models.Order{}, err
+	}
+	delete(r.transactions, before.Payment.Transaction)
+	r.transactions[order.Payment.Transaction] = order.OrderUID
+	rec.order = order
+	r.log.Debug("order updated", "order_uid", order.OrderUID, "version", order.Version)
+	return clone(order), nil
+}
+
+func (r *Repository) GetInfo(ctx context.Context, orderUID string) (models.Order, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	rec, err := r.live(orderUID, false)
+	if err != nil {
+		return models.Order{}, err
+	}
+	return clone(rec.order), nil
+}
+
+func (r *Repository) Delete(ctx context.Context, orderUID, reason string, expectedVersion int64) (models.Order, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	rec, err := r.live(orderUID, false)
+	if err != nil {
+		return models.Order{}, err
+	}
+	if err := checkVersion(rec.order, expectedVersion); err != nil {
+		return models.Order{}, err
+	}
+	before := rec.order
+	if err := r.writeAudit(ctx, models.EventOrderDeleted, orderUID, &before, nil); err != nil {
+		return models.Order{}, err
+	}
+	rec.order.Version++
+	rec.deletedAt = time.Now()
+	rec.deletedBy = actor.From(ctx)
+	rec.reason = reason
+	r.log.Debug("order marked as deleted", "order_uid", orderUID)
+	return clone(rec.order), nil
+}
+
+func (r *Repository) Restore(ctx context.Context, orderUID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	rec, err := r.live(orderUID, true)
+	if err != nil {
+		return err
+	}
+	after := rec.order
+	after.Version++
+	if err := r.writeAudit(ctx, models.EventOrderRestored, orderUID, nil, &after); err != nil {
+		return err
+	}
+	*rec = record{order: after}
+	r.log.Debug("order restored", "order_uid", orderUID)
+	return nil
+}
+
+func (r *Repository) ListDeleted(ctx context.Context, limit int) ([]models.Deletion, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var deletions []models.Deletion
+	for uid, rec := range r.orders {
+		if rec.deleted() {
+			deletions = append(deletions, models.Deletion{
+				OrderUID: uid, DeletedAt: rec.deletedAt, DeletedBy: rec.deletedBy, Reason: rec.reason,
+			})
+		}
+	}
+	sort.Slice(deletions, func(i, j int) bool { return deletions[i].DeletedAt.After(deletions[j].DeletedAt) })
+	if len(deletions) > limit {
+		deletions = deletions[:limit]
+	}
+	return deletions, nil
+}
+
+func (r *Repository) History(ctx context.Context, orderUID string) ([]models.AuditEntry, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var entries []models.AuditEntry
+	for _, entry := range r.audit {
+		if entry.OrderUID == orderUID {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+func (r *Repository) SetStatus(ctx context.Context, orderUID string, expectedVersion int64, change ports.StatusChange) (models.Order, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	rec, err := r.live(orderUID, false)
+	if err != nil {
+		return models.Order{}, err
+	}
+	before := rec.order
+	if err := checkVersion(before, expectedVersion); err != nil {
+		return models.Order{}, err
+	}
+
+	changed := clone(before)
+	transitions, err := change(&changed)
+	if err != nil {
+		return models.Order{}, err
+	}
+	after := clone(before)
+	after.Version = before.Version + 1
+	after.Status = changed.Status
+	after.StatusChangedAt = changed.StatusChangedAt
+	for _, t := range transitions {
+		if t.ChrtID == nil {
+			continue
+		}
+		for i := range after.Items {
+			if after.Items[i].ChrtID == *t.ChrtID {
+				after.Items[i].State = t.To
+			}
+		}
+	}
+	if err := r.writeAudit(ctx, models.EventOrderStatusChanged, orderUID, &before, &after); err != nil {
+		return models.Order{}, err
+	}
+	rec.order = after
+	r.transitions = append(r.transitions, transitions...)
+	r.log.Debug("order status changed", "order_uid", orderUID, "status", after.Status)
+	return clone(after), nil
+}
+
+func (r *Repository) ListTransitions(ctx context.Context, orderUID string) ([]models.StatusTransition, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var transitions []models.StatusTransition
+	for _, t := range r.transitions {
+		if t.OrderUID == orderUID {
+			transitions = append(transitions, t)
+		}
+	}
+	return transitions, nil
+}
+
+func (r *Repository) GetIDs(ctx context.Context) ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	uids := make([]string, 0, len(r.orders))
+	for uid, rec := range r.orders {
+		if !rec.deleted() {
+			uids = append(uids, uid)
+		}
+	}
+	return uids, nil
+}
+
+func (r *Repository) GetIDsPage(ctx context.Context, filter models.OrderFilter, after string, limit int) ([]string, error) {
+	r.mu.Lock()
+	uids := make([]string, 0, len(r.orders))
+	for uid, rec := range r.orders {
+		if !rec.deleted() && filter.Matches(rec.order) {
+			uids = append(uids, uid)
+		}
+	}
+	r.mu.Unlock()
+	sort.Strings(uids)
+	start := sort.SearchStrings(uids, after)
+	if start < len(uids) && uids[start] == after {
+		start++
+	}
+	uids = uids[start:]
+	if len(uids) > limit {
+		uids = uids[:limit]
+	}
+	return uids, nil
+}
+
+func (r *Repository) AddBatch(ctx context.Context, orders []models.Order) ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// The batch is checked as a whole first, so that it is stored entirely
+	// or not at all.
+	var fresh []models.Order
+	seen := make(map[string]bool)
+	transactions := make(map[string]bool)
+	for _, order := range orders {
+		if _, ok := r.orders[order.OrderUID]; ok || seen[order.OrderUID] {
+			continue
+		}
+		if err := r.checkKeys(order, true); err != nil {
+			return nil, err
+		}
+		if transactions[order.Payment.Transaction] {
+			return nil, fmt.Errorf("%w: payment transaction %s is already used", models.ErrInvalidOrder, order.Payment.Transaction)
+		}
+		seen[order.OrderUID] = true
+		transactions[order.Payment.Transaction] = true
+		fresh = append(fresh, clone(order))
+	}
+
+	created := make([]string, 0, len(fresh))
+	for i := range fresh {
+		order := &fresh[i]
+		if err := r.writeAudit(ctx, models.EventOrderCreated, order.OrderUID, nil, order); err != nil {
+			return nil, err
+		}
+		r.orders[order.OrderUID] = &record{order: *order}
+		r.transactions[order.Payment.Transaction] = order.OrderUID
+		created = append(created, order.OrderUID)
+	}
+	r.log.Debug("batch of orders added", "requested", len(orders), "created", len(created))
+	return created, nil
+}
+
+// GetInfoBatch returns the orders with the given UIDs. Orders kept in memory
+// are always complete, so none is reported as incomplete.
+func (r *Repository) GetInfoBatch(ctx context.Context, orderUIDs []string) ([]models.Order, []string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var orders []models.Order
+	for _, uid := range orderUIDs {
+		if rec, err := r.live(uid, false); err == nil {
+			orders = append(orders, clone(rec.order))
+		}
+	}
+	return orders, nil, nil
+}
+
+// snapshot returns copies of the orders that are not deleted, ordered by UID.
+func (r *Repository) snapshot() []models.Order {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	orders := make([]models.Order, 0, len(r.orders))
+	for _, rec := range r.orders {
+		if !rec.deleted() {
+			orders = append(orders, clone(rec.order))
+		}
+	}
+	sort.Slice(orders, func(i, j int) bool { return orders[i].OrderUID < orders[j].OrderUID })
+	return orders
+}
+
+func itemsText(order models.Order) string {
+	parts := make([]string, 0, len(order.Items))
+	for _, item := range order.Items {
+		parts = append(parts, strings.TrimSpace(item.Brand+" "+item.Name))
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,16 @@
+package memory_test
+
+import (
+	"firstmod/internal/ports"
+	"firstmod/internal/repository/memory"
+	"firstmod/internal/repository/repotest"
+	"io"
+	"log/slog"
+	"testing"
+)
+
+func TestConformance(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) ports.Repository {
+		return memory.New(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	})
+}
@@ -0,0 +1,40 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+)
+
+// MarkPublished records the orders as published now. Orders that do not
+// exist are skipped.
+func (r *Repository) MarkPublished(ctx context.Context, orderUIDs []string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now().UTC()
+	for _, uid := range orderUIDs {
+		if rec, ok := r.orders[uid]; ok {
+			rec.publishedAt = now
+		}
+	}
+	r.log.Debug("orders marked as published", "count", len(orderUIDs))
+	return nil
+}
+
+func (r *Repository) ListUnpublished(ctx context.Context, after string, limit int) ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var uids []string
+	for uid, rec := range r.orders {
+		if uid > after && !rec.deleted() && rec.publishedAt.IsZero() {
+			uids = append(uids, uid)
+		}
+	}
+	sort.Strings(uids)
+	if len(uids) > limit {
+		uids = uids[:limit]
+	}
+	return uids, nil
+}
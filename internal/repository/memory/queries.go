@@ -0,0 +1,205 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"firstmod/internal/models"
+	"firstmod/internal/textsearch"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Search ranks orders by the share of query words found in their delivery
+// info and items. Unlike Postgres it does not forgive misspellings.
+func (r *Repository) Search(ctx context.Context, query string, limit, offset int) ([]models.OrderSummary, error) {
+	q := textsearch.Parse(query)
+	var summaries []models.OrderSummary
+	for _, order := range r.snapshot() {
+		d := order.DeliveryInfo
+		delivery := strings.Join([]string{d.Name, d.Phone, d.Email, d.City, d.Address, d.Region}, ", ")
+		items := itemsText(order)
+		rank := q.Rank(delivery, items)
+		if rank == 0 {
+			continue
+		}
+		summaries = append(summaries, models.OrderSummary{
+			OrderUID:    order.OrderUID,
+			CustomerID:  order.CustomerID,
+			Status:      order.Status,
+			DateCreated: order.DateCreated,
+			Name:        d.Name,
+			Phone:       d.Phone,
+			Email:       d.Email,
+			City:        d.City,
+			Rank:        rank,
+			Highlights:  map[string]string{"delivery": q.Highlight(delivery), "items": q.Highlight(items)},
+		})
+	}
+	slices.SortStableFunc(summaries, func(a, b models.OrderSummary) int {
+		return cmp.Compare(b.Rank, a.Rank)
+	})
+	return page(summaries, limit, offset), nil
+}
+
+func page[T any](rows []T, limit, offset int) []T {
+	if offset >= len(rows) {
+		return nil
+	}
+	rows = rows[offset:]
+	if len(rows) > limit {
+		rows = rows[:limit]
+	}
+	return rows
+}
+
+// sales returns the orders that count as sales under filter.
+func (r *Repository) sales(filter models.ReportFilter) []models.Order {
+	var orders []models.Order
+	for _, order := range r.snapshot() {
+		if order.Status == models.StatusCancelled || order.Status == models.StatusReturned {
+			continue
+		}
+		if !filter.From.IsZero() && order.DateCreated.Before(filter.From) {
+			continue
+		}
+		if !filter.To.IsZero() && !order.DateCreated.Before(filter.To) {
+			continue
+		}
+		orders = append(orders, order)
+	}
+	return orders
+}
+
+// truncate returns the start of the period of t, in UTC.
+func truncate(t time.Time, granularity models.Granularity) time.Time {
+	t = t.UTC()
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	switch granularity {
+	case models.GranularityWeek:
+		return day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+	case models.GranularityMonth:
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	}
+	return day
+}
+
+func (r *Repository) SalesReport(ctx context.Context, granularity models.Granularity, filter models.ReportFilter) ([]models.SalesPoint, error) {
+	type key struct {
+		period   time.Time
+		currency string
+	}
+	points := make(map[key]*models.SalesPoint)
+	for _, order := range r.sales(filter) {
+		k := key{truncate(order.DateCreated, granularity), order.Payment.Currency}
+		p, ok := points[k]
+		if !ok {
+			p = &models.SalesPoint{Period: k.period, Currency: k.currency}
+			points[k] = p
+		}
+		p.Orders++
+		p.Revenue += int64(order.Payment.Amount)
+	}
+
+	report := make([]models.SalesPoint, 0, len(points))
+	for _, p := range points {
+		p.AverageBasket = float64(p.Revenue) / float64(p.Orders)
+		report = append(report, *p)
+	}
+	slices.SortFunc(report, func(a, b models.SalesPoint) int {
+		return cmp.Or(a.Period.Compare(b.Period), cmp.Compare(a.Currency, b.Currency))
+	})
+	return report, nil
+}
+
+func (r *Repository) Breakdown(ctx context.Context, dimension models.Dimension, filter models.ReportFilter) ([]models.BreakdownRow, error) {
+	var keyOf func(order models.Order) string
+	switch dimension {
+	case models.DimensionDeliveryService:
+		keyOf = func(o models.Order) string { return o.DeliveryService }
+	case models.DimensionProvider:
+		keyOf = func(o models.Order) string { return o.Payment.Provider }
+	case models.DimensionBank:
+		keyOf = func(o models.Order) string { return o.Payment.Bank }
+	case models.DimensionCurrency:
+		keyOf = func(o models.Order) string { return o.Payment.Currency }
+	case models.DimensionRegion:
+		keyOf = func(o models.Order) string { return o.DeliveryInfo.Region }
+	case models.DimensionBrand:
+	default:
+		return nil, fmt.Errorf("unknown report dimension %q", dimension)
+	}
+
+	type key struct{ value, currency string }
+	rows := make(map[key]*models.BreakdownRow)
+	add := func(k key, orderUID string, revenue int, counted map[key]map[string]bool) {
+		row, ok := rows[k]
+		if !ok {
+			row = &models.BreakdownRow{Key: k.value, Currency: k.currency}
+			rows[k] = row
+			counted[k] = make(map[string]bool)
+		}
+		if !counted[k][orderUID] {
+			counted[k][orderUID] = true
+			row.Orders++
+		}
+		row.Revenue += int64(revenue)
+	}
+	counted := make(map[key]map[string]bool)
+	for _, order := range r.sales(filter) {
+		if keyOf != nil {
+			add(key{keyOf(order), order.Payment.Currency}, order.OrderUID, order.Payment.Amount, counted)
+			continue
+		}
+		for _, item := range order.Items {
+			if item.State == models.StatusCancelled || item.State == models.StatusReturned {
+				continue
+			}
+			add(key{item.Brand, order.Payment.Currency}, order.OrderUID, item.TotalPrice, counted)
+		}
+	}
+
+	report := make([]models.BreakdownRow, 0, len(rows))
+	for _, row := range rows {
+		report = append(report, *row)
+	}
+	slices.SortFunc(report, func(a, b models.BreakdownRow) int {
+		return cmp.Or(cmp.Compare(b.Revenue, a.Revenue), cmp.Compare(a.Key, b.Key), cmp.Compare(a.Currency, b.Currency))
+	})
+	return report, nil
+}
+
+func (r *Repository) TopItems(ctx context.Context, filter models.ReportFilter, limit int) ([]models.TopItem, error) {
+	type key struct {
+		nmID     int64
+		currency string
+	}
+	items := make(map[key]*models.TopItem)
+	for _, order := range r.sales(filter) {
+		for _, item := range order.Items {
+			if item.State == models.StatusCancelled || item.State == models.StatusReturned {
+				continue
+			}
+			k := key{item.NmID, order.Payment.Currency}
+			top, ok := items[k]
+			if !ok {
+				top = &models.TopItem{NmID: item.NmID, Currency: k.currency}
+				items[k] = top
+			}
+			top.Name = max(top.Name, item.Name)
+			top.Brand = max(top.Brand, item.Brand)
+			top.Quantity++
+			top.Revenue += int64(item.TotalPrice)
+		}
+	}
+
+	report := make([]models.TopItem, 0, len(items))
+	for _, top := range items {
+		report = append(report, *top)
+	}
+	slices.SortFunc(report, func(a, b models.TopItem) int {
+		return cmp.Or(cmp.Compare(b.Revenue, a.Revenue), cmp.Compare(a.NmID, b.NmID), cmp.Compare(a.Currency, b.Currency))
+	})
+	return page(report, limit, 0), nil
+}
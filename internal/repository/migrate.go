@@ -1,6 +1,8 @@
 package repository
 
 import (
+	"errors"
+
 	"github.com/golang-migrate/migrate/v4"
 	"github.com/golang-migrate/migrate/v4/database/pgx"
 	"github.com/golang-migrate/migrate/v4/source/iofs"
@@ -11,6 +13,55 @@ import (
 
 func (db *DB) Migrate() error {
 	db.log.Debug("running migration")
+	return db.withMigrate(func(m *migrate.Migrate) error {
+		return m.Up()
+	})
+}
+
+// MigrateDown rolls back the given number of migrations.
+func (db *DB) MigrateDown(steps int) error {
+	db.log.Debug("rolling back migrations", "steps", steps)
+	return db.withMigrate(func(m *migrate.Migrate) error {
+		return m.Steps(-steps)
+	})
+}
+
+// MigrateTo migrates up or down to the given version.
+func (db *DB) MigrateTo(version uint) error {
+	db.log.Debug("migrating to version", "version", version)
+	return db.withMigrate(func(m *migrate.Migrate) error {
+		return m.Migrate(version)
+	})
+}
+
+// MigrationVersion returns the current schema version and whether the last
+// migration failed halfway, leaving the schema dirty. Version 0 means no
+// migration has been applied.
+func (db *DB) MigrationVersion() (uint, bool, error) {
+	var version uint
+	var dirty bool
+	err := db.withMigrate(func(m *migrate.Migrate) error {
+		var err error
+		version, dirty, err = m.Version()
+		if errors.Is(err, migrate.ErrNilVersion) {
+			return nil
+		}
+		return err
+	})
+	return version, dirty, err
+}
+
+// ForceMigrationVersion records version as the current one and clears the
+// dirty flag without running any migration. It is used to recover after a
+// failed migration has been fixed by hand.
+func (db *DB) ForceMigrationVersion(version int) error {
+	db.log.Warn("forcing migration version", "version", version)
+	return db.withMigrate(func(m *migrate.Migrate) error {
+		return m.Force(version)
+	})
+}
+
+func (db *DB) withMigrate(fn func(m *migrate.Migrate) error) error {
 	files, err := iofs.New(migrations.MigrationFiles, ".")
 	if err != nil {
 		db.log.Error("failed to load migration files", "error", err)
@@ -21,6 +72,8 @@ func (db *DB) Migrate() error {
 	sqlDB := stdlib.OpenDBFromPool(db.conn)
 	defer sqlDB.Close()
 
+	// The pgx driver holds an advisory lock while a command runs, so replicas
+	// starting at the same time do not race each other.
 	driver, err := pgx.WithInstance(sqlDB, &pgx.Config{})
 	if err != nil {
 		db.log.Error("failed to create pgx driver for migrations", "error", err)
@@ -31,8 +84,9 @@ func (db *DB) Migrate() error {
 		db.log.Error("failed to initialize migrations", "error", err)
 		return err
 	}
+	defer m.Close()
 
-	err = m.Up()
+	err = fn(m)
 
 	if err != nil {
 		if err != migrate.ErrNoChange {
@@ -0,0 +1,179 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// partitionedTables lists the tables partitioned by order month, referencing
+// tables first.
+var partitionedTables = []string{"items", "payments", "delivery_info", "orders"}
+
+func monthStart(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// ensurePartitions creates the partitions for the months of dates that have
+// none. The database is asked every time rather than remembering the months,
+// because another instance may remove partitions. Partitions are created
+// outside of the transactions that write orders, because creating one locks
+// the partitioned tables.
+func (db *DB) ensurePartitions(ctx context.Context, dates ...time.Time) error {
+	seen := make(map[time.Time]bool, len(dates))
+	for _, date := range dates {
+		month := monthStart(date)
+		if seen[month] {
+			continue
+		}
+		seen[month] = true
+		if _, err := db.conn.Exec(ctx, "SELECT create_order_partitions($1::date)", month); err != nil {
+			db.log.Error("failed to create order partitions", "month", month.Format("2006-01"), "error", err)
+			return err
+		}
+	}
+	return nil
+}
+
+// EnsurePartitions creates the partitions for every month from from to to
+// inclusive.
+func (db *DB) EnsurePartitions(ctx context.Context, from, to time.Time) error {
+	for month := monthStart(from); !month.After(to); month = month.AddDate(0, 1, 0) {
+		if err := db.ensurePartitions(ctx, month); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DropPartitionsBefore removes the partitions of months ending on or before
+// before, along with the keys, audit entries and status transitions of their
+// orders. With detachOnly the partitions are detached and kept as standalone
+// tables named <partition>_detached instead of being dropped. It returns the
+// removed partition suffixes, e.g. "p2024_01", and the UIDs of the removed
+// orders.
+func (db *DB) DropPartitionsBefore(ctx context.Context, before time.Time, detachOnly bool) ([]string, []string, error) {
+	rows, err := db.conn.Query(ctx, `
+        SELECT c.relname
+        FROM pg_inherits i
+        JOIN pg_class c ON c.oid = i.inhrelid
+        WHERE i.inhparent = 'orders'::regclass AND c.relname <> 'orders_default'
+        ORDER BY c.relname`)
+	if err != nil {
+		db.log.Error("failed to list order partitions", "error", err)
+		return nil, nil, err
+	}
+	names, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		db.log.Error("failed to scan order partitions", "error", err)
+		return nil, nil, err
+	}
+
+	var removed, orderUIDs []string
+	for _, name := range names {
+		suffix := strings.TrimPrefix(name, "orders_")
+		month, err := time.Parse("p2006_01", suffix)
+		if err != nil {
+			db.log.Warn("skipping order partition with unexpected name", "partition", name)
+			continue
+		}
+		if month.AddDate(0, 1, 0).After(before) {
+			continue
+		}
+		uids, err := db.removePartition(ctx, suffix, detachOnly)
+		if err != nil {
+			return removed, orderUIDs, err
+		}
+		removed = append(removed, suffix)
+		orderUIDs = append(orderUIDs, uids...)
+		db.log.Info("order partition removed", "partition", suffix, "orders", len(uids), "detached_only", detachOnly)
+	}
+	return removed, orderUIDs, nil
+}
+
+// removePartition detaches or drops the partitions with the given suffix and
+// deletes the rows that refer to their orders, in one transaction. It returns
+// the UIDs of the removed orders.
+func (db *DB) removePartition(ctx context.Context, suffix string, detachOnly bool) ([]string, error) {
+	var orderUIDs []string
+	err := db.inTx(ctx, func(tx pgx.Tx) error {
+		rows, err := tx.Query(ctx, "SELECT order_uid FROM "+pgx.Identifier{"orders_" + suffix}.Sanitize())
+		if err != nil {
+			db.log.Error("failed to list orders of partition", "partition", suffix, "error", err)
+			return err
+		}
+		orderUIDs, err = pgx.CollectRows(rows, pgx.RowTo[string])
+		if err != nil {
+			db.log.Error("failed to scan orders of partition", "partition", suffix, "error", err)
+			return err
+		}
+
+		if _, err := tx.Exec(ctx, "SET LOCAL order_audit.purge = on"); err != nil {
+			db.log.Error("failed to allow purging the audit log", "error", err)
+			return err
+		}
+		// Deleting the keys also deletes the publication records.
+		for _, query := range []string{
+			"DELETE FROM order_audit WHERE order_uid = ANY($1)",
+			"DELETE FROM order_status_transitions WHERE order_uid = ANY($1)",
+			"DELETE FROM order_keys WHERE order_uid = ANY($1)",
+		} {
+			if _, err := tx.Exec(ctx, query, orderUIDs); err != nil {
+				db.log.Error("failed to delete rows of removed orders", "partition", suffix, "error", err)
+				return err
+			}
+		}
+
+		for _, table := range partitionedTables {
+			name := table + "_" + suffix
+			partition := pgx.Identifier{name}.Sanitize()
+			if _, err := tx.Exec(ctx, fmt.Sprintf("ALTER TABLE %s DETACH PARTITION %s", table, partition)); err != nil {
+				db.log.Error("failed to detach partition", "table", table, "partition", suffix, "error", err)
+				return err
+			}
+			if detachOnly {
+				// Detached partitions keep their foreign keys to the orders
+				// table, which would stop the orders partition from being
+				// detached. They are renamed so that the month can get new
+				// partitions.
+				if err := dropForeignKeys(ctx, tx, partition); err != nil {
+					db.log.Error("failed to drop foreign keys of detached partition", "table", table, "partition", suffix, "error", err)
+					return err
+				}
+				detached := pgx.Identifier{name + "_detached"}.Sanitize()
+				if _, err := tx.Exec(ctx, fmt.Sprintf("ALTER TABLE %s RENAME TO %s", partition, detached)); err != nil {
+					db.log.Error("failed to rename detached partition", "table", table, "partition", suffix, "error", err)
+					return err
+				}
+				continue
+			}
+			if _, err := tx.Exec(ctx, "DROP TABLE "+partition); err != nil {
+				db.log.Error("failed to drop partition", "table", table, "partition", suffix, "error", err)
+				return err
+			}
+		}
+		return nil
+	})
+	return orderUIDs, err
+}
+
+func dropForeignKeys(ctx context.Context, tx pgx.Tx, table string) error {
+	rows, err := tx.Query(ctx, "SELECT conname FROM pg_constraint WHERE conrelid = $1::regclass AND contype = 'f'", table)
+	if err != nil {
+		return err
+	}
+	constraints, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return err
+	}
+	for _, name := range constraints {
+		if _, err := tx.Exec(ctx, fmt.Sprintf("ALTER TABLE %s DROP CONSTRAINT %s", table, pgx.Identifier{name}.Sanitize())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
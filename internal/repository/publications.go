@@ -0,0 +1,50 @@
+package repository
+
+import (
+	"context"
+)
+
+// MarkPublished records the orders as published now. Orders that no longer
+// exist are skipped.
+func (db *DB) MarkPublished(ctx context.Context, orderUIDs []string) error {
+	_, err := db.conn.Exec(ctx, `
+        INSERT INTO order_publications (order_uid)
+        SELECT order_uid FROM order_keys WHERE order_uid = ANY($1)
+        ON CONFLICT (order_uid) DO UPDATE SET published_at = NOW()`, orderUIDs)
+	if err != nil {
+		db.log.Error("failed to mark orders as published", "count", len(orderUIDs), "error", err)
+		return err
+	}
+	db.log.Debug("orders marked as published", "count", len(orderUIDs))
+	return nil
+}
+
+// ListUnpublished reads the primary, which has the latest publications.
+func (db *DB) ListUnpublished(ctx context.Context, after string, limit int) ([]string, error) {
+	rows, err := db.conn.Query(ctx, `
+        SELECT o.order_uid FROM orders o
+        WHERE o.order_uid > $1 AND o.deleted_at IS NULL
+          AND NOT EXISTS (SELECT 1 FROM order_publications p WHERE p.order_uid = o.order_uid)
+        ORDER BY o.order_uid
+        LIMIT $2`, after, limit)
+	if err != nil {
+		db.log.Error("failed to query unpublished orders", "error", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var uids []string
+	for rows.Next() {
+		var uid string
+		if err := rows.Scan(&uid); err != nil {
+			db.log.Error("failed to scan unpublished order UID", "error", err)
+			return nil, err
+		}
+		uids = append(uids, uid)
+	}
+	if err := rows.Err(); err != nil {
+		db.log.Error("error after scanning unpublished order UIDs", "error", err)
+		return nil, err
+	}
+	return uids, nil
+}
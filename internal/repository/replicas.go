@@ -0,0 +1,206 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// defaultWriteWindow is how long reads of a just written order go to the
+// primary until MonitorReplicas sets the window from the allowed replica lag.
+const defaultWriteWindow = 10 * time.Second
+
+// querier is the part of pgxpool.Pool used by read-only queries.
+type querier interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+type replica struct {
+	address string
+	pool    *pgxpool.Pool
+	healthy atomic.Bool
+}
+
+type primaryKey struct{}
+
+// WithPrimary returns a context whose reads always go to the primary, for
+// callers that cannot tolerate replication lag.
+func WithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryKey{}, true)
+}
+
+func usePrimary(ctx context.Context) bool {
+	primary, _ := ctx.Value(primaryKey{}).(bool)
+	return primary
+}
+
+func (db *DB) connectReplicas(addresses []string) error {
+	for _, address := range addresses {
+		pool, err := pgxpool.New(context.Background(), address)
+		if err != nil {
+			db.log.Error("replica connection problem", "address", address, "error", err)
+			return err
+		}
+		r := &replica{address: address, pool: pool}
+		if err := pool.Ping(context.Background()); err != nil {
+			db.log.Warn("replica is unavailable, reads go to the primary until it recovers", "address", address, "error", err)
+		} else {
+			r.healthy.Store(true)
+			db.log.Info("successfully connected to replica", "address", address)
+		}
+		db.replicas = append(db.replicas, r)
+	}
+	return nil
+}
+
+// reader returns where to run a read-only query. Reads go to a healthy
+// replica, chosen round-robin, unless ctx requires the primary or one of
+// orderUIDs was written recently enough that replicas may not have it yet.
+func (db *DB) reader(ctx context.Context, orderUIDs ...string) querier {
+	if len(db.replicas) == 0 || usePrimary(ctx) || db.recentlyWritten(orderUIDs) {
+		return db.conn
+	}
+	start := db.next.Add(1)
+	for i := range db.replicas {
+		r := db.replicas[(start+uint64(i))%uint64(len(db.replicas))]
+		if r.healthy.Load() {
+			return replicaQuerier{db: db, replica: r}
+		}
+	}
+	return db.conn
+}
+
+// markWritten records that the orders were written, so that reads of them go
+// to the primary for the write window.
+func (db *DB) markWritten(orderUIDs ...string) {
+	if len(db.replicas) == 0 {
+		return
+	}
+	now := time.Now()
+	for _, uid := range orderUIDs {
+		db.written.Store(uid, now)
+	}
+}
+
+func (db *DB) recentlyWritten(orderUIDs []string) bool {
+	window := time.Duration(db.writeWindow.Load())
+	for _, uid := range orderUIDs {
+		if at, ok := db.written.Load(uid); ok && time.Since(at.(time.Time)) < window {
+			return true
+		}
+	}
+	return false
+}
+
+func (db *DB) forgetWrites() {
+	window := time.Duration(db.writeWindow.Load())
+	db.written.Range(func(uid, at any) bool {
+		if time.Since(at.(time.Time)) >= window {
+			db.written.Delete(uid)
+		}
+		return true
+	})
+}
+
+// MonitorReplicas checks the replicas every interval until ctx is cancelled.
+// A replica that cannot be reached or lags behind the primary by more than
+// maxLag receives no reads until it recovers. Reads of written orders go to
+// the primary for maxLag plus interval, after which any healthy replica has
+// the write.
+func (db *DB) MonitorReplicas(ctx context.Context, interval, maxLag time.Duration) {
+	if len(db.replicas) == 0 {
+		return
+	}
+	db.writeWindow.Store(int64(maxLag + interval))
+	db.log.Info("replica monitor started", "replicas", len(db.replicas), "interval", interval, "max_lag", maxLag)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		for _, r := range db.replicas {
+			db.checkReplica(ctx, r, interval, maxLag)
+		}
+		db.forgetWrites()
+		select {
+		case <-ctx.Done():
+			db.log.Info("replica monitor stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (db *DB) checkReplica(ctx context.Context, r *replica, timeout, maxLag time.Duration) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var lag float64
+	err := r.pool.QueryRow(ctx, `
+        SELECT CASE
+            WHEN NOT pg_is_in_recovery() THEN 0
+            WHEN pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN 0
+            ELSE COALESCE(EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp()), 0)
+        END::float8`).Scan(&lag)
+	healthy := err == nil && time.Duration(lag*float64(time.Second)) <= maxLag
+	if healthy == r.healthy.Load() {
+		return
+	}
+	r.healthy.Store(healthy)
+	if healthy {
+		db.log.Info("replica is healthy again", "address", r.address, "lag_seconds", lag)
+	} else {
+		db.log.Warn("replica marked unhealthy", "address", r.address, "lag_seconds", lag, "error", err)
+	}
+}
+
+// replicaFailed reports whether err means the replica could not serve the
+// query, in which case the replica is marked unhealthy and the query should
+// be retried on the primary.
+func (db *DB) replicaFailed(ctx context.Context, r *replica, err error) bool {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) || errors.Is(err, pgx.ErrNoRows) || ctx.Err() != nil {
+		return false
+	}
+	r.healthy.Store(false)
+	db.log.Warn("read from replica failed, retrying on the primary", "address", r.address, "error", err)
+	return true
+}
+
+// replicaQuerier runs queries on a replica and retries them on the primary if
+// the replica cannot be reached.
+type replicaQuerier struct {
+	db      *DB
+	replica *replica
+}
+
+func (q replicaQuerier) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	rows, err := q.replica.pool.Query(ctx, sql, args...)
+	if err != nil && q.db.replicaFailed(ctx, q.replica, err) {
+		return q.db.conn.Query(ctx, sql, args...)
+	}
+	return rows, err
+}
+
+func (q replicaQuerier) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	return replicaRow{q: q, ctx: ctx, sql: sql, args: args}
+}
+
+type replicaRow struct {
+	q    replicaQuerier
+	ctx  context.Context
+	sql  string
+	args []any
+}
+
+func (r replicaRow) Scan(dest ...any) error {
+	err := r.q.replica.pool.QueryRow(r.ctx, r.sql, r.args...).Scan(dest...)
+	if err != nil && r.q.db.replicaFailed(r.ctx, r.q.replica, err) {
+		return r.q.db.conn.QueryRow(r.ctx, r.sql, r.args...).Scan(dest...)
+	}
+	return err
+}
@@ -0,0 +1,131 @@
+package repository
+
+import (
+	"context"
+	"firstmod/internal/models"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// reportScope restricts report queries on orders o to the filter passed as
+// $1 and $2 and to orders that count as sales.
+const reportScope = `
+            o.deleted_at IS NULL
+            AND o.status NOT IN ('cancelled', 'returned')
+            AND ($1::timestamptz IS NULL OR o.date_created >= $1)
+            AND ($2::timestamptz IS NULL OR o.date_created < $2)`
+
+// dimensionColumns maps report dimensions to the columns they group by.
+var dimensionColumns = map[models.Dimension]string{
+	models.DimensionDeliveryService: "o.delivery_service",
+	models.DimensionProvider:        "p.provider",
+	models.DimensionBank:            "p.bank",
+	models.DimensionCurrency:        "p.currency",
+	models.DimensionRegion:          "d.region",
+	models.DimensionBrand:           "i.brand",
+}
+
+func filterArgs(filter models.ReportFilter) []any {
+	bound := func(t time.Time) any {
+		if t.IsZero() {
+			return nil
+		}
+		return t
+	}
+	return []any{bound(filter.From), bound(filter.To)}
+}
+
+// SalesReport returns revenue, order count and average basket per period.
+func (db *DB) SalesReport(ctx context.Context, granularity models.Granularity, filter models.ReportFilter) ([]models.SalesPoint, error) {
+	rows, err := db.reader(ctx).Query(ctx, `
+        SELECT date_trunc($3, o.date_created) AS period, p.currency,
+            COUNT(*), COALESCE(SUM(p.amount), 0), COALESCE(AVG(p.amount), 0)::float8
+        FROM orders o
+        JOIN payments p ON p.order_uid = o.order_uid AND p.date_created = o.date_created
+        WHERE`+reportScope+`
+        GROUP BY period, p.currency
+        ORDER BY period, p.currency`, append(filterArgs(filter), string(granularity))...)
+	if err != nil {
+		db.log.Error("failed to query sales report", "granularity", granularity, "error", err)
+		return nil, err
+	}
+	points, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (models.SalesPoint, error) {
+		var p models.SalesPoint
+		err := row.Scan(&p.Period, &p.Currency, &p.Orders, &p.Revenue, &p.AverageBasket)
+		return p, err
+	})
+	if err != nil {
+		db.log.Error("failed to scan sales report rows", "error", err)
+		return nil, err
+	}
+	return points, nil
+}
+
+// Breakdown returns order counts and revenue per value of dimension. Like
+// TopItems, the brand breakdown skips items cancelled or returned on their own.
+func (db *DB) Breakdown(ctx context.Context, dimension models.Dimension, filter models.ReportFilter) ([]models.BreakdownRow, error) {
+	column, ok := dimensionColumns[dimension]
+	if !ok {
+		return nil, fmt.Errorf("unknown report dimension %q", dimension)
+	}
+	revenue, join := "SUM(p.amount)", ""
+	if dimension == models.DimensionBrand {
+		revenue, join = "SUM(i.total_price)", `JOIN items i ON i.order_uid = o.order_uid AND i.date_created = o.date_created
+            AND i.state NOT IN ('cancelled', 'returned')`
+	}
+
+	rows, err := db.reader(ctx).Query(ctx, fmt.Sprintf(`
+        SELECT %[1]s AS key, p.currency, COUNT(DISTINCT o.order_uid), COALESCE(%[2]s, 0)
+        FROM orders o
+        JOIN payments p ON p.order_uid = o.order_uid AND p.date_created = o.date_created
+        JOIN delivery_info d ON d.order_uid = o.order_uid AND d.date_created = o.date_created
+        %[3]s
+        WHERE`+reportScope+`
+        GROUP BY key, p.currency
+        ORDER BY 4 DESC, key`, column, revenue, join), filterArgs(filter)...)
+	if err != nil {
+		db.log.Error("failed to query breakdown report", "dimension", dimension, "error", err)
+		return nil, err
+	}
+	breakdown, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (models.BreakdownRow, error) {
+		var b models.BreakdownRow
+		err := row.Scan(&b.Key, &b.Currency, &b.Orders, &b.Revenue)
+		return b, err
+	})
+	if err != nil {
+		db.log.Error("failed to scan breakdown report rows", "error", err)
+		return nil, err
+	}
+	return breakdown, nil
+}
+
+// TopItems returns the limit best selling items by nm_id, ranked by revenue.
+// Items that were cancelled or returned on their own are not counted.
+func (db *DB) TopItems(ctx context.Context, filter models.ReportFilter, limit int) ([]models.TopItem, error) {
+	rows, err := db.reader(ctx).Query(ctx, `
+        SELECT i.nm_id, MAX(i.name), MAX(i.brand), p.currency, COUNT(*), COALESCE(SUM(i.total_price), 0)
+        FROM orders o
+        JOIN payments p ON p.order_uid = o.order_uid AND p.date_created = o.date_created
+        JOIN items i ON i.order_uid = o.order_uid AND i.date_created = o.date_created
+        WHERE`+reportScope+`
+            AND i.state NOT IN ('cancelled', 'returned')
+        GROUP BY i.nm_id, p.currency
+        ORDER BY 6 DESC, i.nm_id
+        LIMIT $3`, append(filterArgs(filter), limit)...)
+	if err != nil {
+		db.log.Error("failed to query top items report", "error", err)
+		return nil, err
+	}
+	items, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (models.TopItem, error) {
+		var t models.TopItem
+		err := row.Scan(&t.NmID, &t.Name, &t.Brand, &t.Currency, &t.Quantity, &t.Revenue)
+		return t, err
+	})
+	if err != nil {
+		db.log.Error("failed to scan top items report rows", "error", err)
+		return nil, err
+	}
+	return items, nil
+}
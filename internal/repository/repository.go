@@ -3,19 +3,34 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"firstmod/internal/actor"
 	"firstmod/internal/models"
+	"fmt"
 	"log/slog"
+	"sync"
+	"sync/atomic"
 
-	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 type DB struct {
 	log  *slog.Logger
 	conn *pgxpool.Pool
+
+	replicas []*replica
+	next     atomic.Uint64
+	// written holds the time each order was last written by this process.
+	written     sync.Map
+	writeWindow atomic.Int64
 }
 
-func New(log *slog.Logger, address string) (*DB, error) {
+// New connects to the primary at address and to the optional read replicas.
+// Read-only queries are spread across the healthy replicas; see reader.
+func New(log *slog.Logger, address string, replicaAddresses ...string) (*DB, error) {
 	pool, err := pgxpool.New(context.Background(), address)
 	if err != nil {
 		log.Error("connection problem", "address", address, "error", err)
@@ -29,65 +44,177 @@ func New(log *slog.Logger, address string) (*DB, error) {
 
 	log.Info("successfully connected to database", "address", address)
 
-	return &DB{
+	db := &DB{
 		log:  log,
 		conn: pool,
-	}, nil
+	}
+	db.writeWindow.Store(int64(defaultWriteWindow))
+	if err := db.connectReplicas(replicaAddresses); err != nil {
+		return nil, err
+	}
+	return db, nil
+}
+
+// Close closes the connections to the primary and the replicas.
+func (db *DB) Close() {
+	for _, r := range db.replicas {
+		r.pool.Close()
+	}
+	db.conn.Close()
 }
 
 func (db *DB) Add(ctx context.Context, order models.Order) error {
 	db.log.Debug("attempting to add new order", "order_uid", order.OrderUID)
 
-	tx, err := db.conn.Begin(ctx)
-	if err != nil {
-		db.log.Error("failed to begin transaction", "error", err)
+	if err := db.ensurePartitions(ctx, order.DateCreated); err != nil {
 		return err
 	}
-	defer func() {
-		if r := recover(); r != nil {
-			db.log.Error("recovered from panic during transaction, rolling back", "panic", r)
-			tx.Rollback(ctx)
-			panic(r)
-		} else if err != nil {
-			db.log.Error("transaction failed, rolling back", "error", err)
-			tx.Rollback(ctx)
+
+	err := db.inTx(ctx, func(tx pgx.Tx) error {
+		_, err := tx.Exec(ctx, "INSERT INTO order_keys (order_uid, transaction_uid, date_created) VALUES ($1, $2, $3)",
+			order.OrderUID, order.Payment.Transaction, order.DateCreated)
+		if err != nil {
+			return db.orderKeyError(order, err)
 		}
-	}()
 
-	orderSQL := `
+		orderSQL := `
         INSERT INTO orders (
             order_uid, track_number, entry, locale, internal_signature,
-            customer_id, delivery_service, shardkey, sm_id, date_created, oof_shard
+            customer_id, delivery_service, shardkey, sm_id, date_created, oof_shard,
+            status, status_changed_at
         ) VALUES (
-            $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11
+            $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13
         )`
-	_, err = tx.Exec(ctx, orderSQL,
-		order.OrderUID,
-		order.TrackNumber,
-		order.Entry,
-		order.Locale,
-		order.InternalSignature,
-		order.CustomerID,
-		order.DeliveryService,
-		order.Shardkey,
-		order.SmID,
-		order.DateCreated,
-		order.OofShard,
-	)
+		_, err = tx.Exec(ctx, orderSQL,
+			order.OrderUID,
+			order.TrackNumber,
+			order.Entry,
+			order.Locale,
+			order.InternalSignature,
+			order.CustomerID,
+			order.DeliveryService,
+			order.Shardkey,
+			order.SmID,
+			order.DateCreated,
+			order.OofShard,
+			order.Status,
+			order.StatusChangedAt,
+		)
+		if err != nil {
+			db.log.Error("failed to insert order", "order_uid", order.OrderUID, "error", err)
+			return err
+		}
+		db.log.Debug("order inserted successfully", "order_uid", order.OrderUID)
+
+		if err := db.insertDetails(ctx, tx, order); err != nil {
+			return err
+		}
+		return db.writeAudit(ctx, tx, models.EventOrderCreated, order.OrderUID, nil, &order)
+	})
 	if err != nil {
-		db.log.Error("failed to insert order", "order_uid", order.OrderUID, "error", err)
 		return err
 	}
-	db.log.Debug("order inserted successfully", "order_uid", order.OrderUID)
 
+	db.markWritten(order.OrderUID)
+	db.log.Info("order and related data added successfully", "order_uid", order.OrderUID)
+	return nil
+}
+
+// Update replaces the order and increments its version, keeping its lifecycle
+// statuses. If expectedVersion is not zero, the order is only updated if its
+// current version matches it. It returns the order as stored.
+func (db *DB) Update(ctx context.Context, order models.Order, expectedVersion int64) (models.Order, error) {
+	db.log.Debug("attempting to update order", "order_uid", order.OrderUID)
+
+	if err := db.ensurePartitions(ctx, order.DateCreated); err != nil {
+		return models.Order{}, err
+	}
+
+	err := db.inTx(ctx, func(tx pgx.Tx) error {
+		before, err := db.lockOrder(ctx, tx, order.OrderUID, false)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				db.log.Debug("attempted to update non-existent order", "order_uid", order.OrderUID)
+			}
+			return err
+		}
+		if err := checkVersion(before, expectedVersion); err != nil {
+			db.log.Info("order update rejected", "order_uid", order.OrderUID, "error", err)
+			return err
+		}
+		order.Version = before.Version + 1
+		order.KeepLifecycle(before)
+
+		// Details are removed first, so that a changed date_created moves
+		// the order to another partition without carrying stale rows along.
+		deleteDetailsSQL := []string{
+			"DELETE FROM delivery_info WHERE order_uid = $1",
+			"DELETE FROM payments WHERE order_uid = $1",
+			"DELETE FROM items WHERE order_uid = $1",
+		}
+		for _, query := range deleteDetailsSQL {
+			if _, err := tx.Exec(ctx, query, order.OrderUID); err != nil {
+				db.log.Error("failed to delete order details", "order_uid", order.OrderUID, "error", err)
+				return err
+			}
+		}
+
+		_, err = tx.Exec(ctx, "UPDATE order_keys SET transaction_uid = $2, date_created = $3 WHERE order_uid = $1",
+			order.OrderUID, order.Payment.Transaction, order.DateCreated)
+		if err != nil {
+			return db.orderKeyError(order, err)
+		}
+
+		orderSQL := `
+        UPDATE orders SET
+            track_number = $2, entry = $3, locale = $4, internal_signature = $5,
+            customer_id = $6, delivery_service = $7, shardkey = $8, sm_id = $9,
+            date_created = $10, oof_shard = $11, version = $12
+        WHERE order_uid = $1`
+		_, err = tx.Exec(ctx, orderSQL,
+			order.OrderUID,
+			order.TrackNumber,
+			order.Entry,
+			order.Locale,
+			order.InternalSignature,
+			order.CustomerID,
+			order.DeliveryService,
+			order.Shardkey,
+			order.SmID,
+			order.DateCreated,
+			order.OofShard,
+			order.Version,
+		)
+		if err != nil {
+			db.log.Error("failed to update order", "order_uid", order.OrderUID, "error", err)
+			return err
+		}
+
+		if err := db.insertDetails(ctx, tx, order); err != nil {
+			return err
+		}
+		return db.writeAudit(ctx, tx, models.EventOrderUpdated, order.OrderUID, &before, &order)
+	})
+	if err != nil {
+		return models.Order{}, err
+	}
+
+	db.markWritten(order.OrderUID)
+	db.log.Info("order and related data updated successfully", "order_uid", order.OrderUID, "version", order.Version)
+	return order, nil
+}
+
+// insertDetails inserts delivery info, payment and items of the order.
+func (db *DB) insertDetails(ctx context.Context, tx pgx.Tx, order models.Order) error {
 	deliverySQL := `
         INSERT INTO delivery_info (
-            order_uid, name, phone, zip, city, address, region, email
+            order_uid, date_created, name, phone, zip, city, address, region, email
         ) VALUES (
-            $1, $2, $3, $4, $5, $6, $7, $8
+            $1, $2, $3, $4, $5, $6, $7, $8, $9
         )`
-	_, err = tx.Exec(ctx, deliverySQL,
+	_, err := tx.Exec(ctx, deliverySQL,
 		order.OrderUID,
+		order.DateCreated,
 		order.DeliveryInfo.Name,
 		order.DeliveryInfo.Phone,
 		order.DeliveryInfo.Zip,
@@ -104,12 +231,14 @@ func (db *DB) Add(ctx context.Context, order models.Order) error {
 
 	paymentSQL := `
         INSERT INTO payments (
-            transaction_uid, request_id, currency, provider, amount,
+            order_uid, date_created, transaction_uid, request_id, currency, provider, amount,
             payment_dt, bank, delivery_cost, goods_total, custom_fee
         ) VALUES (
-            $1, $2, $3, $4, $5, $6, $7, $8, $9, $10
+            $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12
         )`
 	_, err = tx.Exec(ctx, paymentSQL,
+		order.OrderUID,
+		order.DateCreated,
 		order.Payment.Transaction,
 		order.Payment.RequestID,
 		order.Payment.Currency,
@@ -129,14 +258,15 @@ func (db *DB) Add(ctx context.Context, order models.Order) error {
 
 	itemSQL := `
         INSERT INTO items (
-            order_uid, chrt_id, track_number, price, rid, name,
-            sale, size, total_price, nm_id, brand, status
+            order_uid, date_created, chrt_id, track_number, price, rid, name,
+            sale, size, total_price, nm_id, brand, status, state
         ) VALUES (
-            $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12
+            $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14
         )`
 	for i, item := range order.Items {
 		_, err = tx.Exec(ctx, itemSQL,
 			order.OrderUID,
+			order.DateCreated,
 			item.ChrtID,
 			item.TrackNumber,
 			item.Price,
@@ -148,6 +278,7 @@ func (db *DB) Add(ctx context.Context, order models.Order) error {
 			item.NmID,
 			item.Brand,
 			item.Status,
+			item.State,
 		)
 		if err != nil {
 			db.log.Error("failed to insert item", "order_uid", order.OrderUID, "item_index", i, "error", err)
@@ -155,31 +286,62 @@ func (db *DB) Add(ctx context.Context, order models.Order) error {
 		}
 	}
 	db.log.Debug("items inserted successfully", "order_uid", order.OrderUID, "count", len(order.Items))
-
-	err = tx.Commit(ctx)
-	if err != nil {
-		db.log.Error("failed to commit transaction", "error", err)
-		return err
-	}
-
-	db.log.Info("order and related data added successfully", "order_uid", order.OrderUID)
 	return nil
 }
 
-func (db *DB) GetInfo(ctx context.Context, orderUID string) (models.Order, error) {
-	db.log.Debug("attempting to get order info", "order_uid", orderUID)
-
-	var order models.Order
-	order.OrderUID = orderUID
-
-	orderSQL := `
+// orderSelectSQL assembles orders with their delivery info, payment and items
+// in a single statement, so the result is a consistent snapshot even while
+// the order is being modified concurrently. Missing delivery info or payment
+// rows are reported through the has_delivery and has_payment columns.
+const orderSelectSQL = `
         SELECT
-            track_number, entry, locale, internal_signature,
-            customer_id, delivery_service, shardkey, sm_id, date_created, oof_shard
-        FROM orders
-        WHERE order_uid = $1`
+            o.order_uid, o.track_number, o.entry, o.locale, o.internal_signature,
+            o.customer_id, o.delivery_service, o.shardkey, o.sm_id, o.date_created, o.oof_shard, o.version,
+            o.status, o.status_changed_at,
+            d.order_uid IS NOT NULL AS has_delivery,
+            COALESCE(d.name, ''), COALESCE(d.phone, ''), COALESCE(d.zip, ''), COALESCE(d.city, ''),
+            COALESCE(d.address, ''), COALESCE(d.region, ''), COALESCE(d.email, ''),
+            p.order_uid IS NOT NULL AS has_payment,
+            COALESCE(p.transaction_uid, ''), COALESCE(p.request_id, 0), COALESCE(p.currency, ''),
+            COALESCE(p.provider, ''), COALESCE(p.amount, 0), COALESCE(p.payment_dt, 0), COALESCE(p.bank, ''),
+            COALESCE(p.delivery_cost, 0), COALESCE(p.goods_total, 0), COALESCE(p.custom_fee, 0),
+            COALESCE((
+                SELECT json_agg(json_build_object(
+                    'chrt_id', i.chrt_id, 'track_number', i.track_number, 'price', i.price,
+                    'rid', i.rid, 'name', i.name, 'sale', i.sale, 'size', i.size,
+                    'total_price', i.total_price, 'nm_id', i.nm_id, 'brand', i.brand, 'status', i.status,
+                    'state', i.state
+                ) ORDER BY i.id)
+                FROM items i
+                WHERE i.order_uid = o.order_uid AND i.date_created = o.date_created
+            ), '[]') AS items
+        FROM orders o
+        LEFT JOIN delivery_info d ON d.order_uid = o.order_uid AND d.date_created = o.date_created
+        LEFT JOIN payments p ON p.order_uid = o.order_uid AND p.date_created = o.date_created`
+
+type itemRow struct {
+	ChrtID      int64         `json:"chrt_id"`
+	TrackNumber string        `json:"track_number"`
+	Price       int           `json:"price"`
+	Rid         string        `json:"rid"`
+	Name        string        `json:"name"`
+	Sale        int           `json:"sale"`
+	Size        string        `json:"size"`
+	TotalPrice  int           `json:"total_price"`
+	NmID        int64         `json:"nm_id"`
+	Brand       string        `json:"brand"`
+	Status      int           `json:"status"`
+	State       models.Status `json:"state"`
+}
 
-	err := db.conn.QueryRow(ctx, orderSQL, orderUID).Scan(
+// scanOrder scans a row of orderSelectSQL, returning models.ErrDataIntegrity
+// if the order lacks its delivery info or payment.
+func scanOrder(row pgx.Row) (models.Order, error) {
+	var order models.Order
+	var hasDelivery, hasPayment bool
+	var items []itemRow
+	err := row.Scan(
+		&order.OrderUID,
 		&order.TrackNumber,
 		&order.Entry,
 		&order.Locale,
@@ -190,23 +352,10 @@ func (db *DB) GetInfo(ctx context.Context, orderUID string) (models.Order, error
 		&order.SmID,
 		&order.DateCreated,
 		&order.OofShard,
-	)
-	if err != nil {
-		if err == pgx.ErrNoRows {
-			db.log.Debug("order not found", "order_uid", orderUID)
-			return models.Order{}, sql.ErrNoRows
-		}
-		db.log.Error("failed to query order", "order_uid", orderUID, "error", err)
-		return models.Order{}, err
-	}
-	db.log.Debug("order data fetched successfully", "order_uid", orderUID)
-
-	deliverySQL := `
-        SELECT
-            name, phone, zip, city, address, region, email
-        FROM delivery_info
-        WHERE order_uid = $1`
-	err = db.conn.QueryRow(ctx, deliverySQL, orderUID).Scan(
+		&order.Version,
+		&order.Status,
+		&order.StatusChangedAt,
+		&hasDelivery,
 		&order.DeliveryInfo.Name,
 		&order.DeliveryInfo.Phone,
 		&order.DeliveryInfo.Zip,
@@ -214,19 +363,8 @@ func (db *DB) GetInfo(ctx context.Context, orderUID string) (models.Order, error
 		&order.DeliveryInfo.Address,
 		&order.DeliveryInfo.Region,
 		&order.DeliveryInfo.Email,
-	)
-	if err != nil {
-		db.log.Warn("failed to query delivery info, might not exist", "order_uid", orderUID, "error", err)
-	}
-	db.log.Debug("delivery info fetched successfully", "order_uid", orderUID)
-
-	paymentSQL := `
-        SELECT
-            request_id, currency, provider, amount,
-            payment_dt, bank, delivery_cost, goods_total, custom_fee
-        FROM payments
-        WHERE transaction_uid = $1`
-	err = db.conn.QueryRow(ctx, paymentSQL, orderUID).Scan(
+		&hasPayment,
+		&order.Payment.Transaction,
 		&order.Payment.RequestID,
 		&order.Payment.Currency,
 		&order.Payment.Provider,
@@ -236,81 +374,91 @@ func (db *DB) GetInfo(ctx context.Context, orderUID string) (models.Order, error
 		&order.Payment.DeliveryCost,
 		&order.Payment.GoodsTotal,
 		&order.Payment.CustomFee,
+		&items,
 	)
 	if err != nil {
-		db.log.Warn("failed to query payment info, might not exist", "order_uid", orderUID, "error", err)
+		return models.Order{}, err
+	}
+	for _, item := range items {
+		order.Items = append(order.Items, models.Item(item))
 	}
-	order.Payment.Transaction = orderUID
-	db.log.Debug("payment info fetched successfully", "order_uid", orderUID)
 
-	itemSQL := `
-        SELECT
-            chrt_id, track_number, price, rid, name,
-            sale, size, total_price, nm_id, brand, status
-        FROM items
-        WHERE order_uid = $1`
-	rows, err := db.conn.Query(ctx, itemSQL, orderUID)
-	if err != nil {
-		db.log.Error("failed to query items", "order_uid", orderUID, "error", err)
-		return models.Order{}, err
+	switch {
+	case !hasDelivery:
+		return order, fmt.Errorf("%w: order %s has no delivery info", models.ErrDataIntegrity, order.OrderUID)
+	case !hasPayment:
+		return order, fmt.Errorf("%w: order %s has no payment", models.ErrDataIntegrity, order.OrderUID)
 	}
-	defer rows.Close()
+	return order, nil
+}
 
-	var items []models.Item
-	for rows.Next() {
-		var item models.Item
-		err := rows.Scan(
-			&item.ChrtID,
-			&item.TrackNumber,
-			&item.Price,
-			&item.Rid,
-			&item.Name,
-			&item.Sale,
-			&item.Size,
-			&item.TotalPrice,
-			&item.NmID,
-			&item.Brand,
-			&item.Status,
-		)
-		if err != nil {
-			db.log.Error("failed to scan item row", "order_uid", orderUID, "error", err)
+func (db *DB) GetInfo(ctx context.Context, orderUID string) (models.Order, error) {
+	db.log.Debug("attempting to get order info", "order_uid", orderUID)
+
+	order, err := scanOrder(db.reader(ctx, orderUID).QueryRow(ctx, orderSelectSQL+" WHERE o.order_uid = $1 AND o.deleted_at IS NULL", orderUID))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			db.log.Debug("order not found", "order_uid", orderUID)
+			return models.Order{}, sql.ErrNoRows
+		}
+		if errors.Is(err, models.ErrDataIntegrity) {
+			db.log.Error("order is incomplete", "order_uid", orderUID, "error", err)
 			return models.Order{}, err
 		}
-		items = append(items, item)
-	}
-	if err = rows.Err(); err != nil {
-		db.log.Error("error after scanning item rows", "order_uid", orderUID, "error", err)
+		db.log.Error("failed to query order", "order_uid", orderUID, "error", err)
 		return models.Order{}, err
 	}
-	order.Items = items
-	db.log.Debug("items fetched successfully", "order_uid", orderUID, "count", len(order.Items))
 
-	db.log.Info("order info retrieved successfully", "order_uid", orderUID)
+	db.log.Info("order info retrieved successfully", "order_uid", orderUID, "items", len(order.Items))
 	return order, nil
 }
 
-func (db *DB) Delete(ctx context.Context, orderUID string) error {
+// Delete marks the order as deleted. The order stays in the database until it
+// is archived and can be restored until then. If expectedVersion is not zero,
+// the order is only deleted if its current version matches it. Delete returns
+// the order as it was deleted, with the version the deletion wrote.
+func (db *DB) Delete(ctx context.Context, orderUID, reason string, expectedVersion int64) (models.Order, error) {
 	db.log.Debug("attempting to delete order", "order_uid", orderUID)
 
-	cmdTag, err := db.conn.Exec(ctx, "DELETE FROM orders WHERE order_uid = $1", orderUID)
-	if err != nil {
-		db.log.Error("failed to delete order", "order_uid", orderUID, "error", err)
-		return err
-	}
+	var deleted models.Order
+	err := db.inTx(ctx, func(tx pgx.Tx) error {
+		before, err := db.lockOrder(ctx, tx, orderUID, false)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				db.log.Warn("attempted to delete non-existent order", "order_uid", orderUID)
+			}
+			return err
+		}
+		if err := checkVersion(before, expectedVersion); err != nil {
+			db.log.Info("order deletion rejected", "order_uid", orderUID, "error", err)
+			return err
+		}
 
-	if cmdTag.RowsAffected() == 0 {
-		db.log.Warn("attempted to delete non-existent order", "order_uid", orderUID)
-		return sql.ErrNoRows
+		_, err = tx.Exec(ctx, `
+            UPDATE orders SET deleted_at = NOW(), deleted_by = $2, delete_reason = NULLIF($3, ''), version = version + 1
+            WHERE order_uid = $1`,
+			orderUID, actor.From(ctx), reason)
+		if err != nil {
+			db.log.Error("failed to delete order", "order_uid", orderUID, "error", err)
+			return err
+		}
+		deleted = before
+		deleted.Version++
+		return db.writeAudit(ctx, tx, models.EventOrderDeleted, orderUID, &before, nil)
+	})
+	if err != nil {
+		return models.Order{}, err
 	}
 
-	db.log.Info("order and related data deleted successfully", "order_uid", orderUID)
-	return nil
+	db.markWritten(orderUID)
+	db.log.Info("order marked as deleted", "order_uid", orderUID, "deleted_by", actor.From(ctx))
+	return deleted, nil
 }
 
 func (db *DB) GetIDs(ctx context.Context) ([]string, error) {
 	db.log.Debug("attempting to get all order UIDs")
 
-	rows, err := db.conn.Query(ctx, "SELECT order_uid FROM orders")
+	rows, err := db.reader(ctx).Query(ctx, "SELECT order_uid FROM orders WHERE deleted_at IS NULL")
 	if err != nil {
 		db.log.Error("failed to query order UIDs", "error", err)
 		return nil, err
@@ -335,3 +483,104 @@ func (db *DB) GetIDs(ctx context.Context) ([]string, error) {
 	db.log.Info("successfully retrieved all order UIDs", "count", len(uids))
 	return uids, nil
 }
+
+// GetIDsPage returns up to limit UIDs of orders matching filter that are
+// greater than after, in ascending order.
+func (db *DB) GetIDsPage(ctx context.Context, filter models.OrderFilter, after string, limit int) ([]string, error) {
+	db.log.Debug("attempting to get page of order UIDs", "after", after, "limit", limit, "filter", filter)
+
+	rows, err := db.reader(ctx).Query(ctx, `
+        SELECT o.order_uid FROM orders o
+        WHERE ($1::timestamptz IS NULL OR o.date_created >= $1)
+            AND ($2::timestamptz IS NULL OR o.date_created < $2)
+            AND o.order_uid > $3 AND o.deleted_at IS NULL
+            AND ($5::text = '' OR o.status = $5)
+            AND ($6::text = '' OR o.customer_id = $6)
+            AND ($7::text = '' OR o.delivery_service = $7)
+            AND ($8::text = '' OR EXISTS (
+                SELECT 1 FROM payments p
+                WHERE p.order_uid = o.order_uid AND p.date_created = o.date_created AND p.currency = $8))
+        ORDER BY o.order_uid
+        LIMIT $4`,
+		append(filterArgs(models.ReportFilter{From: filter.From, To: filter.To}), after, limit,
+			string(filter.Status), filter.CustomerID, filter.DeliveryService, filter.Currency)...)
+	if err != nil {
+		db.log.Error("failed to query order UIDs page", "error", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var uids []string
+	for rows.Next() {
+		var uid string
+		if err := rows.Scan(&uid); err != nil {
+			db.log.Error("failed to scan order UID row", "error", err)
+			return nil, err
+		}
+		uids = append(uids, uid)
+	}
+
+	if err = rows.Err(); err != nil {
+		db.log.Error("error after scanning order UID rows", "error", err)
+		return nil, err
+	}
+
+	db.log.Debug("successfully retrieved page of order UIDs", "count", len(uids))
+	return uids, nil
+}
+
+// inTx runs fn in a transaction, committing it if fn succeeds and rolling it
+// back otherwise.
+func (db *DB) inTx(ctx context.Context, fn func(tx pgx.Tx) error) (err error) {
+	tx, err := db.conn.Begin(ctx)
+	if err != nil {
+		db.log.Error("failed to begin transaction", "error", err)
+		return err
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			db.log.Error("recovered from panic during transaction, rolling back", "panic", r)
+			tx.Rollback(ctx)
+			panic(r)
+		} else if err != nil {
+			db.log.Error("transaction failed, rolling back", "error", err)
+			tx.Rollback(ctx)
+		}
+	}()
+
+	if err = fn(tx); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		db.log.Error("failed to commit transaction", "error", err)
+		return err
+	}
+	return nil
+}
+
+// checkVersion returns models.ErrVersionMismatch if expectedVersion is set and
+// differs from the version of the order.
+func checkVersion(order models.Order, expectedVersion int64) error {
+	if expectedVersion != 0 && order.Version != expectedVersion {
+		return fmt.Errorf("%w: expected %d, current %d", models.ErrVersionMismatch, expectedVersion, order.Version)
+	}
+	return nil
+}
+
+// orderKeyError translates a failure to register the keys of order in
+// order_keys, which enforces unique order and transaction IDs across all
+// partitions.
+func (db *DB) orderKeyError(order models.Order, err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == pgerrcode.UniqueViolation {
+		if pgErr.ConstraintName == "uq_order_keys_transaction" {
+			db.log.Info("payment transaction is already used by another order", "order_uid", order.OrderUID, "transaction", order.Payment.Transaction)
+			return fmt.Errorf("%w: payment transaction %s is already used", models.ErrInvalidOrder, order.Payment.Transaction)
+		}
+		db.log.Info("order already exists", "order_uid", order.OrderUID)
+		return fmt.Errorf("%w: %s", models.ErrOrderExists, order.OrderUID)
+	}
+	db.log.Error("failed to register order keys", "order_uid", order.OrderUID, "error", err)
+	return err
+}
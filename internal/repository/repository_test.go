@@ -0,0 +1,82 @@
+package repository
+
+import (
+	"context"
+	"firstmod/internal/ports"
+	"firstmod/internal/repository/repotest"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// TestConformance runs against the Postgres database in TEST_POSTGRES_DSN.
+// Every run migrates its own schema, dropped at the end, so the tables of
+// the database are left alone; orders are removed before every test.
+func TestConformance(t *testing.T) {
+	dsn := os.Getenv("TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("TEST_POSTGRES_DSN is not set")
+	}
+	ctx := context.Background()
+	admin, err := pgxpool.New(ctx, dsn)
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	t.Cleanup(admin.Close)
+
+	schema := fmt.Sprintf("conformance_%d", time.Now().UnixNano())
+	if _, err := admin.Exec(ctx, "CREATE SCHEMA "+pgx.Identifier{schema}.Sanitize()); err != nil {
+		t.Fatalf("failed to create schema: %v", err)
+	}
+	t.Cleanup(func() {
+		if _, err := admin.Exec(ctx, "DROP SCHEMA "+pgx.Identifier{schema}.Sanitize()+" CASCADE"); err != nil {
+			t.Errorf("failed to drop schema %s: %v", schema, err)
+		}
+	})
+
+	// Extensions such as pg_trgm stay in public.
+	db, err := New(slog.New(slog.NewTextHandler(io.Discard, nil)), withSearchPath(dsn, schema+",public"))
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	t.Cleanup(db.Close)
+	if err := db.Migrate(); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+
+	repotest.Run(t, func(t *testing.T) ports.Repository {
+		_, err := db.conn.Exec(ctx,
+			"TRUNCATE orders, order_keys, order_audit, order_status_transitions, archived_orders, dead_letters, order_publications CASCADE")
+		if err != nil {
+			t.Fatalf("failed to clean database: %v", err)
+		}
+		return db
+	}, repotest.WithDropDelivery(func(t *testing.T, repo ports.Repository, orderUID string) {
+		if _, err := db.conn.Exec(ctx, "DELETE FROM delivery_info WHERE order_uid = $1", orderUID); err != nil {
+			t.Fatalf("failed to drop delivery info: %v", err)
+		}
+	}))
+}
+
+// withSearchPath adds search_path to a URL or keyword/value connection string.
+func withSearchPath(dsn, searchPath string) string {
+	if !strings.Contains(dsn, "://") {
+		return dsn + " search_path=" + searchPath
+	}
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return dsn
+	}
+	q := u.Query()
+	q.Set("search_path", searchPath)
+	u.RawQuery = q.Encode()
+	return u.String()
+}
@@ -0,0 +1,860 @@
+// Package repotest is a conformance test suite for ports.Repository
+// implementations.
+package repotest
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"firstmod/internal/actor"
+	"firstmod/internal/models"
+	"firstmod/internal/ports"
+	"fmt"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Option configures Run.
+type Option func(*suite)
+
+type suite struct {
+	dropDelivery func(t *testing.T, repo ports.Repository, orderUID string)
+}
+
+// WithDropDelivery lets Run check how orders with incomplete data are read,
+// using drop to delete the delivery info of an order behind the back of the
+// repository. Without it the check is skipped.
+func WithDropDelivery(drop func(t *testing.T, repo ports.Repository, orderUID string)) Option {
+	return func(s *suite) { s.dropDelivery = drop }
+}
+
+// Run runs the conformance tests against the repositories returned by
+// newRepo, which must return an empty repository on every call.
+func Run(t *testing.T, newRepo func(t *testing.T) ports.Repository, opts ...Option) {
+	var s suite
+	for _, opt := range opts {
+		opt(&s)
+	}
+	tests := []struct {
+		name string
+		test func(t *testing.T, repo ports.Repository)
+	}{
+		{"AddAndGet", testAddAndGet},
+		{"AddDuplicate", testAddDuplicate},
+		{"GetMissing", testGetMissing},
+		{"Update", testUpdate},
+		{"UpdateConflicts", testUpdateConflicts},
+		{"DeleteAndRestore", testDeleteAndRestore},
+		{"Archive", testArchive},
+		{"History", testHistory},
+		{"SetStatus", testSetStatus},
+		{"IDs", testIDs},
+		{"Batch", testBatch},
+		{"IncompleteOrders", s.testIncomplete},
+		{"Search", testSearch},
+		{"Reports", testReports},
+		{"DeadLetters", testDeadLetters},
+		{"Publications", testPublications},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.test(t, newRepo(t))
+		})
+	}
+}
+
+// day is the creation date of the first test order.
+var day = time.Date(2025, time.March, 3, 10, 0, 0, 0, time.UTC)
+
+// NewOrder returns a valid order as the service would store it: at its first
+// version and at the created stage.
+func NewOrder(uid string, created time.Time) models.Order {
+	return models.Order{
+		OrderUID:    uid,
+		TrackNumber: "WBILMTESTTRACK",
+		Entry:       "WBIL",
+		DeliveryInfo: models.DeliveryInfo{
+			Name:    "Test Testov",
+			Phone:   "+9720000000",
+			Zip:     "2639809",
+			City:    "Kiryat Mozkin",
+			Address: "Ploshad Mira 15",
+			Region:  "Kraiot",
+			Email:   "test@gmail.com",
+		},
+		Payment: models.Payment{
+			Transaction:  uid,
+			Currency:     "USD",
+			Provider:     "wbpay",
+			Amount:       1817,
+			PaymentDT:    1637907727,
+			Bank:         "alpha",
+			DeliveryCost: 1500,
+			GoodsTotal:   317,
+		},
+		Items: []models.Item{{
+			ChrtID:      9934930,
+			TrackNumber: "WBILMTESTTRACK",
+			Price:       453,
+			Rid:         "ab4219087a764ae0btest",
+			Name:        "Mascaras",
+			Sale:        30,
+			Size:        "0",
+			TotalPrice:  317,
+			NmID:        2389212,
+			Brand:       "Vivienne Sabo",
+			Status:      202,
+			State:       models.StatusCreated,
+		}},
+		Locale:          "en",
+		CustomerID:      "test",
+		DeliveryService: "meest",
+		Shardkey:        "9",
+		SmID:            99,
+		DateCreated:     created,
+		OofShard:        "1",
+		Version:         models.FirstVersion,
+		Status:          models.StatusCreated,
+		StatusChangedAt: created,
+	}
+}
+
+// normalize makes orders read back from a storage comparable with the
+// orders written to it.
+func normalize(order models.Order) models.Order {
+	order.DateCreated = order.DateCreated.UTC()
+	order.StatusChangedAt = order.StatusChangedAt.UTC()
+	if len(order.Items) == 0 {
+		order.Items = nil
+	}
+	return order
+}
+
+func assertOrder(t *testing.T, got, want models.Order) {
+	t.Helper()
+	if got, want := normalize(got), normalize(want); !reflect.DeepEqual(got, want) {
+		t.Fatalf("order mismatch\n got: %+v\nwant: %+v", got, want)
+	}
+}
+
+func mustAdd(t *testing.T, repo ports.Repository, orders ...models.Order) {
+	t.Helper()
+	for _, order := range orders {
+		if err := repo.Add(context.Background(), order); err != nil {
+			t.Fatalf("Add(%s): %v", order.OrderUID, err)
+		}
+	}
+}
+
+func assertErr(t *testing.T, op string, err, want error) {
+	t.Helper()
+	if !errors.Is(err, want) {
+		t.Fatalf("%s: got error %v, want %v", op, err, want)
+	}
+}
+
+func testAddAndGet(t *testing.T, repo ports.Repository) {
+	ctx := context.Background()
+	order := NewOrder("order-1", day)
+	order.Items = append(order.Items, models.Item{ChrtID: 2, Name: "Brush", Brand: "Sabo", TotalPrice: 100, State: models.StatusCreated})
+	mustAdd(t, repo, order)
+
+	got, err := repo.GetInfo(ctx, order.OrderUID)
+	if err != nil {
+		t.Fatalf("GetInfo: %v", err)
+	}
+	assertOrder(t, got, order)
+}
+
+func testAddDuplicate(t *testing.T, repo ports.Repository) {
+	ctx := context.Background()
+	mustAdd(t, repo, NewOrder("order-1", day))
+
+	err := repo.Add(ctx, NewOrder("order-1", day))
+	assertErr(t, "Add with existing UID", err, models.ErrOrderExists)
+
+	other := NewOrder("order-2", day)
+	other.Payment.Transaction = "order-1"
+	err = repo.Add(ctx, other)
+	assertErr(t, "Add with used transaction", err, models.ErrInvalidOrder)
+
+	if _, err := repo.GetInfo(ctx, "order-2"); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("rejected order was stored: %v", err)
+	}
+}
+
+func testGetMissing(t *testing.T, repo ports.Repository) {
+	_, err := repo.GetInfo(context.Background(), "missing")
+	assertErr(t, "GetInfo", err, sql.ErrNoRows)
+}
+
+func testUpdate(t *testing.T, repo ports.Repository) {
+	ctx := context.Background()
+	order := NewOrder("order-1", day)
+	mustAdd(t, repo, order)
+
+	changed := order
+	changed.DeliveryInfo.City = "Haifa"
+	changed.DateCreated = day.AddDate(0, 2, 0)
+	// A full update must not change lifecycle statuses.
+	changed.Status = models.StatusDelivered
+	changed.Items = []models.Item{order.Items[0], {ChrtID: 2, Name: "Brush", State: models.StatusPaid}}
+	changed.Items[0].Price = 500
+
+	updated, err := repo.Update(ctx, changed, models.FirstVersion)
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	want := changed
+	want.Version = models.FirstVersion + 1
+	want.Status = models.StatusCreated
+	want.Items = slices.Clone(changed.Items)
+	want.Items[1].State = models.StatusCreated
+	assertOrder(t, updated, want)
+
+	got, err := repo.GetInfo(ctx, order.OrderUID)
+	if err != nil {
+		t.Fatalf("GetInfo: %v", err)
+	}
+	assertOrder(t, got, want)
+
+	// Zero skips the version check.
+	if _, err := repo.Update(ctx, changed, 0); err != nil {
+		t.Fatalf("unconditional Update: %v", err)
+	}
+}
+
+func testUpdateConflicts(t *testing.T, repo ports.Repository) {
+	ctx := context.Background()
+	mustAdd(t, repo, NewOrder("order-1", day), NewOrder("order-2", day))
+
+	_, err := repo.Update(ctx, NewOrder("order-1", day), 7)
+	assertErr(t, "Update with stale version", err, models.ErrVersionMismatch)
+
+	_, err = repo.Update(ctx, NewOrder("missing", day), 0)
+	assertErr(t, "Update of missing order", err, sql.ErrNoRows)
+
+	stolen := NewOrder("order-1", day)
+	stolen.Payment.Transaction = "order-2"
+	_, err = repo.Update(ctx, stolen, 0)
+	assertErr(t, "Update with used transaction", err, models.ErrInvalidOrder)
+
+	got, err := repo.GetInfo(ctx, "order-1")
+	if err != nil {
+		t.Fatalf("GetInfo: %v", err)
+	}
+	assertOrder(t, got, NewOrder("order-1", day))
+}
+
+func testDeleteAndRestore(t *testing.T, repo ports.Repository) {
+	ctx := actor.WithName(context.Background(), "alice")
+	order := NewOrder("order-1", day)
+	mustAdd(t, repo, order, NewOrder("order-2", day))
+
+	_, err := repo.Delete(ctx, order.OrderUID, "duplicate", 5)
+	assertErr(t, "Delete with stale version", err, models.ErrVersionMismatch)
+	gone, err := repo.Delete(ctx, order.OrderUID, "duplicate", models.FirstVersion)
+	if err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if gone.OrderUID != order.OrderUID || gone.Version != models.FirstVersion+1 {
+		t.Fatalf("Delete returned %s at version %d, want %s at version %d",
+			gone.OrderUID, gone.Version, order.OrderUID, models.FirstVersion+1)
+	}
+
+	_, err = repo.GetInfo(ctx, order.OrderUID)
+	assertErr(t, "GetInfo of deleted order", err, sql.ErrNoRows)
+	_, err = repo.Delete(ctx, order.OrderUID, "", 0)
+	assertErr(t, "Delete of deleted order", err, sql.ErrNoRows)
+	err = repo.Add(ctx, order)
+	assertErr(t, "Add with UID of deleted order", err, models.ErrOrderExists)
+
+	ids, err := repo.GetIDs(ctx)
+	if err != nil {
+		t.Fatalf("GetIDs: %v", err)
+	}
+	if !reflect.DeepEqual(ids, []string{"order-2"}) {
+		t.Fatalf("GetIDs = %v, want only the order that is not deleted", ids)
+	}
+	batch, _, err := repo.GetInfoBatch(ctx, []string{"order-1", "order-2"})
+	if err != nil || len(batch) != 1 || batch[0].OrderUID != "order-2" {
+		t.Fatalf("GetInfoBatch = %v, %v; want only order-2", batch, err)
+	}
+
+	deleted, err := repo.ListDeleted(ctx, 10)
+	if err != nil {
+		t.Fatalf("ListDeleted: %v", err)
+	}
+	if len(deleted) != 1 || deleted[0].OrderUID != order.OrderUID || deleted[0].DeletedBy != "alice" ||
+		deleted[0].Reason != "duplicate" || deleted[0].DeletedAt.IsZero() {
+		t.Fatalf("ListDeleted = %+v", deleted)
+	}
+
+	err = repo.Restore(ctx, "order-2")
+	assertErr(t, "Restore of order that is not deleted", err, sql.ErrNoRows)
+	if err := repo.Restore(ctx, order.OrderUID); err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+	got, err := repo.GetInfo(ctx, order.OrderUID)
+	if err != nil {
+		t.Fatalf("GetInfo of restored order: %v", err)
+	}
+	want := order
+	want.Version = models.FirstVersion + 2
+	assertOrder(t, got, want)
+
+	deleted, err = repo.ListDeleted(ctx, 10)
+	if err != nil || len(deleted) != 0 {
+		t.Fatalf("ListDeleted after restore = %v, %v; want none", deleted, err)
+	}
+}
+
+// testArchive archives the same UID twice: archiving frees the UID, so it can
+// be created and deleted again.
+func testArchive(t *testing.T, repo ports.Repository) {
+	archiver, ok := repo.(ports.ArchiveRepository)
+	if !ok {
+		t.Skip("repository does not archive deleted orders")
+	}
+	ctx := context.Background()
+	for round := 1; round <= 2; round++ {
+		mustAdd(t, repo, NewOrder("order-1", day))
+		if _, err := repo.Delete(ctx, "order-1", "duplicate", 0); err != nil {
+			t.Fatalf("Delete in round %d: %v", round, err)
+		}
+		n, err := archiver.ArchiveDeleted(ctx, time.Now().Add(time.Hour), 10)
+		if err != nil || n != 1 {
+			t.Fatalf("ArchiveDeleted in round %d = %d, %v; want 1 archived order", round, n, err)
+		}
+		deleted, err := repo.ListDeleted(ctx, 10)
+		if err != nil || len(deleted) != 0 {
+			t.Fatalf("ListDeleted after archiving = %+v, %v; want none", deleted, err)
+		}
+	}
+}
+
+func testHistory(t *testing.T, repo ports.Repository) {
+	ctx := actor.WithRequestID(actor.WithSource(actor.WithName(context.Background(), "bob"), actor.SourceHTTP), "req-1")
+	order := NewOrder("order-1", day)
+	if err := repo.Add(ctx, order); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	changed := order
+	changed.DeliveryInfo.City = "Haifa"
+	if _, err := repo.Update(ctx, changed, 0); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if _, err := repo.Delete(ctx, order.OrderUID, "", 0); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if err := repo.Restore(ctx, order.OrderUID); err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+
+	entries, err := repo.History(ctx, order.OrderUID)
+	if err != nil {
+		t.Fatalf("History: %v", err)
+	}
+	wantActions := []models.EventType{
+		models.EventOrderCreated, models.EventOrderUpdated, models.EventOrderDeleted, models.EventOrderRestored,
+	}
+	if len(entries) != len(wantActions) {
+		t.Fatalf("History returned %d entries, want %d", len(entries), len(wantActions))
+	}
+	for i, entry := range entries {
+		if entry.Action != wantActions[i] || entry.Actor != "bob" || entry.Source != string(actor.SourceHTTP) ||
+			entry.RequestID != "req-1" || entry.OrderUID != order.OrderUID {
+			t.Fatalf("entry %d = %+v", i, entry)
+		}
+		if i > 0 && entry.ID <= entries[i-1].ID {
+			t.Fatalf("entries are not ordered by ID: %d after %d", entry.ID, entries[i-1].ID)
+		}
+	}
+	if entries[0].Before != nil || entries[0].After == nil {
+		t.Fatalf("created entry has before %s, after %s", entries[0].Before, entries[0].After)
+	}
+	if entries[2].Before == nil || entries[2].After != nil {
+		t.Fatalf("deleted entry has before %s, after %s", entries[2].Before, entries[2].After)
+	}
+	if string(entries[1].Diff) == "{}" {
+		t.Fatal("updated entry has an empty diff")
+	}
+
+	none, err := repo.History(ctx, "missing")
+	if err != nil || len(none) != 0 {
+		t.Fatalf("History of missing order = %v, %v; want none", none, err)
+	}
+}
+
+func testSetStatus(t *testing.T, repo ports.Repository) {
+	ctx := context.Background()
+	order := NewOrder("order-1", day)
+	order.Items = append(order.Items, models.Item{ChrtID: 2, Name: "Brush", State: models.StatusCreated})
+	mustAdd(t, repo, order)
+
+	chrtID := order.Items[1].ChrtID
+	changedAt := day.Add(time.Hour)
+	transitions := []models.StatusTransition{
+		{OrderUID: order.OrderUID, From: models.StatusCreated, To: models.StatusPaid, Actor: "carol", ChangedAt: changedAt},
+		{OrderUID: order.OrderUID, ChrtID: &chrtID, From: models.StatusCreated, To: models.StatusCancelled,
+			Actor: "carol", Reason: "out of stock", ChangedAt: changedAt},
+	}
+	var seen models.Order
+	pay := func(o *models.Order) ([]models.StatusTransition, error) {
+		seen = *o
+		o.Status = models.StatusPaid
+		o.StatusChangedAt = changedAt
+		o.Items[1].State = models.StatusCancelled
+		return transitions, nil
+	}
+
+	_, err := repo.SetStatus(ctx, order.OrderUID, 3, pay)
+	assertErr(t, "SetStatus with stale version", err, models.ErrVersionMismatch)
+	_, err = repo.SetStatus(ctx, "missing", 0, pay)
+	assertErr(t, "SetStatus of missing order", err, sql.ErrNoRows)
+	_, err = repo.SetStatus(ctx, order.OrderUID, 0, func(o *models.Order) ([]models.StatusTransition, error) {
+		o.Status = models.StatusShipped
+		return nil, models.ErrInvalidTransition
+	})
+	assertErr(t, "SetStatus with a rejected change", err, models.ErrInvalidTransition)
+
+	paid, err := repo.SetStatus(ctx, order.OrderUID, models.FirstVersion, pay)
+	if err != nil {
+		t.Fatalf("SetStatus: %v", err)
+	}
+	if seen.Status != models.StatusCreated || seen.Version != models.FirstVersion {
+		t.Fatalf("change was given the order at %s version %d, want the stored %s version %d",
+			seen.Status, seen.Version, models.StatusCreated, models.FirstVersion)
+	}
+	want := order
+	want.Status = models.StatusPaid
+	want.StatusChangedAt = changedAt
+	want.Version = models.FirstVersion + 1
+	want.Items = slices.Clone(order.Items)
+	want.Items[1].State = models.StatusCancelled
+	assertOrder(t, paid, want)
+
+	got, err := repo.GetInfo(ctx, order.OrderUID)
+	if err != nil {
+		t.Fatalf("GetInfo: %v", err)
+	}
+	assertOrder(t, got, want)
+
+	recorded, err := repo.ListTransitions(ctx, order.OrderUID)
+	if err != nil {
+		t.Fatalf("ListTransitions: %v", err)
+	}
+	if len(recorded) != len(transitions) {
+		t.Fatalf("ListTransitions returned %d transitions, want %d", len(recorded), len(transitions))
+	}
+	for i := range recorded {
+		got, want := recorded[i], transitions[i]
+		if !got.ChangedAt.Equal(want.ChangedAt) {
+			t.Fatalf("transition %d changed at %v, want %v", i, got.ChangedAt, want.ChangedAt)
+		}
+		got.ChangedAt = want.ChangedAt
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("transition %d = %+v, want %+v", i, got, want)
+		}
+	}
+}
+
+func testIDs(t *testing.T, repo ports.Repository) {
+	ctx := context.Background()
+	uids := []string{"c", "a", "e", "b", "d"}
+	for _, uid := range uids {
+		mustAdd(t, repo, NewOrder(uid, day))
+	}
+
+	all, err := repo.GetIDs(ctx)
+	if err != nil {
+		t.Fatalf("GetIDs: %v", err)
+	}
+	sort.Strings(all)
+	if !reflect.DeepEqual(all, []string{"a", "b", "c", "d", "e"}) {
+		t.Fatalf("GetIDs = %v", all)
+	}
+
+	var pages [][]string
+	after := ""
+	for {
+		page, err := repo.GetIDsPage(ctx, models.OrderFilter{}, after, 2)
+		if err != nil {
+			t.Fatalf("GetIDsPage(%q): %v", after, err)
+		}
+		if len(page) == 0 {
+			break
+		}
+		pages = append(pages, page)
+		after = page[len(page)-1]
+	}
+	want := [][]string{{"a", "b"}, {"c", "d"}, {"e"}}
+	if !reflect.DeepEqual(pages, want) {
+		t.Fatalf("pages = %v, want %v", pages, want)
+	}
+
+	other := NewOrder("f", day.AddDate(0, 1, 0))
+	other.CustomerID = "other"
+	other.Payment.Currency = "EUR"
+	mustAdd(t, repo, other)
+	for _, tt := range []struct {
+		filter models.OrderFilter
+		after  string
+		want   []string
+	}{
+		{models.OrderFilter{CustomerID: "other"}, "", []string{"f"}},
+		{models.OrderFilter{Currency: "USD"}, "c", []string{"d", "e"}},
+		{models.OrderFilter{From: day.AddDate(0, 0, 1)}, "", []string{"f"}},
+		{models.OrderFilter{To: day.AddDate(0, 0, 1), DeliveryService: "meest"}, "d", []string{"e"}},
+		{models.OrderFilter{Status: models.StatusCancelled}, "", nil},
+	} {
+		page, err := repo.GetIDsPage(ctx, tt.filter, tt.after, 10)
+		if err != nil {
+			t.Fatalf("GetIDsPage(%+v): %v", tt.filter, err)
+		}
+		if len(page) != len(tt.want) || (len(page) > 0 && !reflect.DeepEqual(page, tt.want)) {
+			t.Fatalf("GetIDsPage(%+v, %q) = %v, want %v", tt.filter, tt.after, page, tt.want)
+		}
+	}
+}
+
+func testBatch(t *testing.T, repo ports.Repository) {
+	ctx := context.Background()
+	mustAdd(t, repo, NewOrder("order-1", day))
+
+	created, err := repo.AddBatch(ctx, []models.Order{
+		NewOrder("order-1", day), NewOrder("order-2", day), NewOrder("order-3", day.AddDate(0, 1, 0)),
+	})
+	if err != nil {
+		t.Fatalf("AddBatch: %v", err)
+	}
+	sort.Strings(created)
+	if !reflect.DeepEqual(created, []string{"order-2", "order-3"}) {
+		t.Fatalf("AddBatch created %v, want the new orders only", created)
+	}
+
+	orders, incomplete, err := repo.GetInfoBatch(ctx, []string{"order-3", "missing", "order-2"})
+	if err != nil || len(incomplete) != 0 {
+		t.Fatalf("GetInfoBatch: incomplete %v, %v", incomplete, err)
+	}
+	sort.Slice(orders, func(i, j int) bool { return orders[i].OrderUID < orders[j].OrderUID })
+	if len(orders) != 2 {
+		t.Fatalf("GetInfoBatch returned %d orders, want 2", len(orders))
+	}
+	assertOrder(t, orders[0], NewOrder("order-2", day))
+	assertOrder(t, orders[1], NewOrder("order-3", day.AddDate(0, 1, 0)))
+
+	history, err := repo.History(ctx, "order-2")
+	if err != nil || len(history) != 1 || history[0].Action != models.EventOrderCreated {
+		t.Fatalf("History of batch created order = %v, %v", history, err)
+	}
+}
+
+// testIncomplete reads an order whose delivery info is gone: GetInfo reports
+// it, while GetInfoBatch skips it and returns the rest of the batch.
+func (s suite) testIncomplete(t *testing.T, repo ports.Repository) {
+	if s.dropDelivery == nil {
+		t.Skip("repository cannot store incomplete orders")
+	}
+	ctx := context.Background()
+	mustAdd(t, repo, NewOrder("order-1", day), NewOrder("order-2", day), NewOrder("order-3", day))
+	s.dropDelivery(t, repo, "order-2")
+
+	_, err := repo.GetInfo(ctx, "order-2")
+	assertErr(t, "GetInfo of incomplete order", err, models.ErrDataIntegrity)
+
+	orders, incomplete, err := repo.GetInfoBatch(ctx, []string{"order-1", "order-2", "order-3", "missing"})
+	if err != nil {
+		t.Fatalf("GetInfoBatch: %v", err)
+	}
+	if !reflect.DeepEqual(incomplete, []string{"order-2"}) {
+		t.Fatalf("GetInfoBatch reported %v as incomplete, want [order-2]", incomplete)
+	}
+	sort.Slice(orders, func(i, j int) bool { return orders[i].OrderUID < orders[j].OrderUID })
+	if len(orders) != 2 {
+		t.Fatalf("GetInfoBatch returned %d orders, want 2", len(orders))
+	}
+	assertOrder(t, orders[0], NewOrder("order-1", day))
+	assertOrder(t, orders[1], NewOrder("order-3", day))
+}
+
+func testSearch(t *testing.T, repo ports.Repository) {
+	ctx := context.Background()
+	first := NewOrder("order-1", day)
+	second := NewOrder("order-2", day)
+	second.DeliveryInfo.Name = "Ivan Petrov"
+	second.DeliveryInfo.City = "Moscow"
+	second.DeliveryInfo.Address = "<script>alert(1)</script>"
+	second.Items[0].Brand = "Lamoda"
+	deleted := NewOrder("order-3", day)
+	mustAdd(t, repo, first, second, deleted)
+	if _, err := repo.Delete(ctx, deleted.OrderUID, "", 0); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+
+	search := func(query string, limit, offset int) []string {
+		t.Helper()
+		results, err := repo.Search(ctx, query, limit, offset)
+		if err != nil {
+			t.Fatalf("Search(%q): %v", query, err)
+		}
+		uids := make([]string, 0, len(results))
+		for _, r := range results {
+			if r.Rank <= 0 {
+				t.Fatalf("Search(%q) returned %s with rank %v", query, r.OrderUID, r.Rank)
+			}
+			uids = append(uids, r.OrderUID)
+		}
+		return uids
+	}
+
+	if got := search("Petrov", 10, 0); !reflect.DeepEqual(got, []string{"order-2"}) {
+		t.Fatalf("search by name = %v", got)
+	}
+	if got := search("Lamoda", 10, 0); !reflect.DeepEqual(got, []string{"order-2"}) {
+		t.Fatalf("search by brand = %v", got)
+	}
+	if got := search("Mozkin", 10, 0); !reflect.DeepEqual(got, []string{"order-1"}) {
+		t.Fatalf("search by city = %v, want the order that is not deleted", got)
+	}
+	if got := search("qqqzzzxxx", 10, 0); len(got) != 0 {
+		t.Fatalf("search for unknown word = %v", got)
+	}
+
+	page := search("Mascaras", 1, 0)
+	next := search("Mascaras", 1, 1)
+	if len(page) != 1 || len(next) != 1 || page[0] == next[0] {
+		t.Fatalf("search pages = %v, %v; want different single orders", page, next)
+	}
+	if rest := search("Mascaras", 1, 2); len(rest) != 0 {
+		t.Fatalf("search past the last match = %v", rest)
+	}
+
+	results, err := repo.Search(ctx, "Petrov", 10, 0)
+	if err != nil || len(results) != 1 {
+		t.Fatalf("Search = %v, %v", results, err)
+	}
+	s := results[0]
+	if s.Name != "Ivan Petrov" || s.City != "Moscow" || s.CustomerID != second.CustomerID ||
+		s.Status != models.StatusCreated || !s.DateCreated.Equal(day) {
+		t.Fatalf("search summary = %+v", s)
+	}
+	if h := s.Highlights["delivery"]; !strings.Contains(h, "<mark>Petrov</mark>") || strings.Contains(h, "<script>") {
+		t.Fatalf("delivery highlight = %q, want the match marked and the stored HTML escaped", h)
+	}
+}
+
+func testReports(t *testing.T, repo ports.Repository) {
+	ctx := context.Background()
+	order := func(uid string, created time.Time, currency string, amount int) models.Order {
+		o := NewOrder(uid, created)
+		o.Payment.Currency = currency
+		o.Payment.Amount = amount
+		return o
+	}
+	nextDay := day.AddDate(0, 0, 1)
+	orders := []models.Order{
+		order("a", day, "USD", 100),
+		order("b", day.Add(time.Hour), "USD", 300),
+		order("c", day.Add(2*time.Hour), "RUB", 5000),
+		order("d", nextDay, "USD", 50),
+		order("e", day.AddDate(0, 1, 0), "USD", 1000),
+	}
+	orders[1].Payment.Bank = "sber"
+	orders[1].Items = append(orders[1].Items, models.Item{
+		ChrtID: 2, NmID: 7, Name: "Brush", Brand: "Sabo", TotalPrice: 900, State: models.StatusCreated,
+	})
+	orders[3].Items[0].State = models.StatusReturned
+	mustAdd(t, repo, orders...)
+
+	// A cancelled order is not a sale.
+	cancelled := order("f", day, "USD", 9999)
+	mustAdd(t, repo, cancelled)
+	cancel := func(o *models.Order) ([]models.StatusTransition, error) {
+		o.Status = models.StatusCancelled
+		return nil, nil
+	}
+	if _, err := repo.SetStatus(ctx, cancelled.OrderUID, 0, cancel); err != nil {
+		t.Fatalf("SetStatus: %v", err)
+	}
+
+	march := models.ReportFilter{From: day, To: day.AddDate(0, 0, 7)}
+	sales, err := repo.SalesReport(ctx, models.GranularityDay, march)
+	if err != nil {
+		t.Fatalf("SalesReport: %v", err)
+	}
+	wantSales := []models.SalesPoint{
+		{Period: day.Truncate(24 * time.Hour), Currency: "RUB", Orders: 1, Revenue: 5000, AverageBasket: 5000},
+		{Period: day.Truncate(24 * time.Hour), Currency: "USD", Orders: 2, Revenue: 400, AverageBasket: 200},
+		{Period: nextDay.Truncate(24 * time.Hour), Currency: "USD", Orders: 1, Revenue: 50, AverageBasket: 50},
+	}
+	assertSales(t, sales, wantSales)
+
+	monthly, err := repo.SalesReport(ctx, models.GranularityMonth, models.ReportFilter{})
+	if err != nil {
+		t.Fatalf("SalesReport by month: %v", err)
+	}
+	assertSales(t, monthly, []models.SalesPoint{
+		{Period: time.Date(2025, time.March, 1, 0, 0, 0, 0, time.UTC), Currency: "RUB", Orders: 1, Revenue: 5000, AverageBasket: 5000},
+		{Period: time.Date(2025, time.March, 1, 0, 0, 0, 0, time.UTC), Currency: "USD", Orders: 3, Revenue: 450, AverageBasket: 150},
+		{Period: time.Date(2025, time.April, 1, 0, 0, 0, 0, time.UTC), Currency: "USD", Orders: 1, Revenue: 1000, AverageBasket: 1000},
+	})
+
+	weekly, err := repo.SalesReport(ctx, models.GranularityWeek, march)
+	if err != nil {
+		t.Fatalf("SalesReport by week: %v", err)
+	}
+	// 2025-03-03 is a Monday.
+	if len(weekly) != 2 || !weekly[0].Period.Equal(day.Truncate(24*time.Hour)) || weekly[1].Orders != 3 {
+		t.Fatalf("weekly sales = %+v", weekly)
+	}
+
+	banks, err := repo.Breakdown(ctx, models.DimensionBank, march)
+	if err != nil {
+		t.Fatalf("Breakdown: %v", err)
+	}
+	wantBanks := []models.BreakdownRow{
+		{Key: "alpha", Currency: "RUB", Orders: 1, Revenue: 5000},
+		{Key: "sber", Currency: "USD", Orders: 1, Revenue: 300},
+		{Key: "alpha", Currency: "USD", Orders: 2, Revenue: 150},
+	}
+	if !reflect.DeepEqual(banks, wantBanks) {
+		t.Fatalf("bank breakdown = %+v, want %+v", banks, wantBanks)
+	}
+
+	brands, err := repo.Breakdown(ctx, models.DimensionBrand, march)
+	if err != nil {
+		t.Fatalf("Breakdown by brand: %v", err)
+	}
+	wantBrands := []models.BreakdownRow{
+		{Key: "Sabo", Currency: "USD", Orders: 1, Revenue: 900},
+		{Key: "Vivienne Sabo", Currency: "USD", Orders: 2, Revenue: 634},
+		{Key: "Vivienne Sabo", Currency: "RUB", Orders: 1, Revenue: 317},
+	}
+	if !reflect.DeepEqual(brands, wantBrands) {
+		t.Fatalf("brand breakdown = %+v, want %+v", brands, wantBrands)
+	}
+
+	top, err := repo.TopItems(ctx, march, 2)
+	if err != nil {
+		t.Fatalf("TopItems: %v", err)
+	}
+	wantTop := []models.TopItem{
+		{NmID: 7, Name: "Brush", Brand: "Sabo", Currency: "USD", Quantity: 1, Revenue: 900},
+		{NmID: 2389212, Name: "Mascaras", Brand: "Vivienne Sabo", Currency: "USD", Quantity: 2, Revenue: 634},
+	}
+	if !reflect.DeepEqual(top, wantTop) {
+		t.Fatalf("top items = %+v, want %+v", top, wantTop)
+	}
+}
+
+func testDeadLetters(t *testing.T, repo ports.Repository) {
+	letters, ok := repo.(ports.DeadLetterRepository)
+	if !ok {
+		t.Skip("repository does not keep dead letters")
+	}
+	ctx := context.Background()
+
+	var ids []int64
+	for i := range 3 {
+		letter, err := letters.AddDeadLetter(ctx, models.DeadLetter{
+			Topic: "orders", Partition: i, Offset: int64(10 + i), Key: "key", Value: "{not json\x00", Error: "invalid JSON",
+		})
+		if err != nil {
+			t.Fatalf("AddDeadLetter: %v", err)
+		}
+		if letter.ID == 0 || letter.Attempts != 1 || letter.FailedAt.IsZero() {
+			t.Fatalf("added dead letter %+v has no ID, attempts or failure time", letter)
+		}
+		ids = append(ids, letter.ID)
+	}
+
+	got, err := letters.GetDeadLetter(ctx, ids[1])
+	if err != nil {
+		t.Fatalf("GetDeadLetter: %v", err)
+	}
+	if got.Topic != "orders" || got.Partition != 1 || got.Offset != 11 || got.Key != "key" || got.Value != "{not json\x00" {
+		t.Errorf("GetDeadLetter = %+v", got)
+	}
+
+	if err := letters.RecordDeadLetterFailure(ctx, ids[1], "still invalid"); err != nil {
+		t.Fatalf("RecordDeadLetterFailure: %v", err)
+	}
+	if got, _ := letters.GetDeadLetter(ctx, ids[1]); got.Attempts != 2 || got.Error != "still invalid" {
+		t.Errorf("after a failed attempt got %d attempts and error %q", got.Attempts, got.Error)
+	}
+
+	page, err := letters.ListDeadLetters(ctx, ids[0], 1)
+	if err != nil || len(page) != 1 || page[0].ID != ids[1] {
+		t.Errorf("ListDeadLetters(after %d, 1) = %+v, %v", ids[0], page, err)
+	}
+
+	if err := letters.DeleteDeadLetter(ctx, ids[1]); err != nil {
+		t.Fatalf("DeleteDeadLetter: %v", err)
+	}
+	for _, err := range []error{
+		letters.DeleteDeadLetter(ctx, ids[1]),
+		letters.RecordDeadLetterFailure(ctx, ids[1], "gone"),
+	} {
+		if !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("changing a deleted dead letter returned %v, want sql.ErrNoRows", err)
+		}
+	}
+	if _, err := letters.GetDeadLetter(ctx, ids[1]); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("GetDeadLetter of a deleted dead letter returned %v, want sql.ErrNoRows", err)
+	}
+	all, err := letters.ListDeadLetters(ctx, 0, 10)
+	if err != nil || len(all) != 2 || all[0].ID != ids[0] || all[1].ID != ids[2] {
+		t.Errorf("ListDeadLetters = %+v, %v", all, err)
+	}
+}
+
+func testPublications(t *testing.T, repo ports.Repository) {
+	publications, ok := repo.(ports.PublicationRepository)
+	if !ok {
+		t.Skip("repository does not record publications")
+	}
+	ctx := context.Background()
+
+	for _, uid := range []string{"pub-a", "pub-b", "pub-c", "pub-d"} {
+		if err := repo.Add(ctx, NewOrder(uid, day)); err != nil {
+			t.Fatalf("Add %s: %v", uid, err)
+		}
+	}
+	if err := publications.MarkPublished(ctx, []string{"pub-b", "missing"}); err != nil {
+		t.Fatalf("MarkPublished: %v", err)
+	}
+	if err := publications.MarkPublished(ctx, []string{"pub-b"}); err != nil {
+		t.Fatalf("MarkPublished again: %v", err)
+	}
+	if _, err := repo.Delete(ctx, "pub-d", "test", 0); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+
+	page, err := publications.ListUnpublished(ctx, "", 1)
+	if err != nil || !slices.Equal(page, []string{"pub-a"}) {
+		t.Errorf("ListUnpublished(\"\", 1) = %v, %v", page, err)
+	}
+	page, err = publications.ListUnpublished(ctx, "pub-a", 10)
+	if err != nil || !slices.Equal(page, []string{"pub-c"}) {
+		t.Errorf("ListUnpublished(pub-a, 10) = %v, %v, want only pub-c", page, err)
+	}
+}
+
+func assertSales(t *testing.T, got, want []models.SalesPoint) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("sales report = %+v, want %+v", got, want)
+	}
+	for i := range got {
+		g, w := got[i], want[i]
+		if !g.Period.Equal(w.Period) || g.Currency != w.Currency || g.Orders != w.Orders ||
+			g.Revenue != w.Revenue || fmt.Sprintf("%.2f", g.AverageBasket) != fmt.Sprintf("%.2f", w.AverageBasket) {
+			t.Fatalf("sales point %d = %+v, want %+v", i, g, w)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package repository
+
+import (
+	"context"
+	"firstmod/internal/models"
+	"html"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ts_headline marks matches with these control characters instead of <mark>
+// tags, so the stored text can be HTML-escaped before the tags are added.
+const (
+	headlineStart = "\x01"
+	headlineStop  = "\x02"
+)
+
+// searchSQL finds orders whose delivery info or items match the query either
+// as full text or as a fuzzy (trigram) match, so misspelled names still
+// match. An order is ranked by its best matching row.
+const searchSQL = `
+        WITH q AS (
+            SELECT websearch_to_tsquery('simple', $1) AS tsq, $1::text AS raw,
+                'HighlightAll=true, StartSel="' || chr(1) || '", StopSel="' || chr(2) || '"' AS opts
+        ),
+        matches AS (
+            SELECT d.order_uid,
+                ts_rank(d.search_tsv, q.tsq) + GREATEST(
+                    similarity(d.name, q.raw), similarity(d.phone, q.raw),
+                    similarity(d.email, q.raw), similarity(d.city, q.raw)) AS rank
+            FROM delivery_info d, q
+            WHERE d.search_tsv @@ q.tsq
+                OR d.name % q.raw OR d.phone % q.raw OR d.email % q.raw OR d.city % q.raw
+            UNION ALL
+            SELECT i.order_uid,
+                ts_rank(i.search_tsv, q.tsq) + GREATEST(similarity(i.name, q.raw), similarity(i.brand, q.raw))
+            FROM items i, q
+            WHERE i.search_tsv @@ q.tsq OR i.name % q.raw OR i.brand % q.raw
+        ),
+        ranked AS (
+            SELECT order_uid, MAX(rank) AS rank FROM matches GROUP BY order_uid
+        )
+        SELECT
+            o.order_uid, o.customer_id, o.status, o.date_created,
+            d.name, d.phone, d.email, d.city, r.rank,
+            ts_headline('simple',
+                translate(concat_ws(', ', d.name, d.phone, d.email, d.city, d.address, d.region), chr(1) || chr(2), ''),
+                q.tsq, q.opts),
+            COALESCE((
+                SELECT ts_headline('simple',
+                    translate(string_agg(concat_ws(' ', i.brand, i.name), ', ' ORDER BY i.id), chr(1) || chr(2), ''),
+                    q.tsq, q.opts)
+                FROM items i
+                WHERE i.order_uid = o.order_uid AND i.date_created = o.date_created
+            ), '')
+        FROM ranked r
+        CROSS JOIN q
+        JOIN orders o ON o.order_uid = r.order_uid AND o.deleted_at IS NULL
+        JOIN delivery_info d ON d.order_uid = o.order_uid AND d.date_created = o.date_created
+        ORDER BY r.rank DESC, o.order_uid
+        LIMIT $2 OFFSET $3`
+
+// Search returns up to limit orders matching query, best matches first,
+// skipping the first offset matches.
+func (db *DB) Search(ctx context.Context, query string, limit, offset int) ([]models.OrderSummary, error) {
+	db.log.Debug("attempting to search orders", "query", query, "limit", limit, "offset", offset)
+
+	rows, err := db.reader(ctx).Query(ctx, searchSQL, query, limit, offset)
+	if err != nil {
+		db.log.Error("failed to search orders", "query", query, "error", err)
+		return nil, err
+	}
+	summaries, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (models.OrderSummary, error) {
+		var s models.OrderSummary
+		var deliveryHighlight, itemsHighlight string
+		err := row.Scan(&s.OrderUID, &s.CustomerID, &s.Status, &s.DateCreated,
+			&s.Name, &s.Phone, &s.Email, &s.City, &s.Rank, &deliveryHighlight, &itemsHighlight)
+		s.Highlights = map[string]string{"delivery": markHeadline(deliveryHighlight), "items": markHeadline(itemsHighlight)}
+		return s, err
+	})
+	if err != nil {
+		db.log.Error("failed to scan search results", "query", query, "error", err)
+		return nil, err
+	}
+
+	db.log.Debug("orders search finished", "query", query, "found", len(summaries))
+	return summaries, nil
+}
+
+// markHeadline escapes a headline returned by searchSQL and replaces its
+// match markers with <mark> tags.
+func markHeadline(headline string) string {
+	return strings.NewReplacer(headlineStart, "<mark>", headlineStop, "</mark>").Replace(html.EscapeString(headline))
+}
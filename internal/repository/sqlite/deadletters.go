@@ -0,0 +1,109 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"firstmod/internal/models"
+	"time"
+)
+
+func (db *DB) AddDeadLetter(ctx context.Context, letter models.DeadLetter) (models.DeadLetter, error) {
+	letter.Attempts = 1
+	letter.FailedAt = time.Now().UTC()
+	res, err := db.conn.ExecContext(ctx, `
+        INSERT INTO dead_letters (topic, kafka_partition, kafka_offset, message_key, value, error, attempts, failed_at)
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		letter.Topic, letter.Partition, letter.Offset, letter.Key, []byte(letter.Value), letter.Error,
+		letter.Attempts, formatTime(letter.FailedAt))
+	if err != nil {
+		db.log.Error("failed to insert dead letter", "topic", letter.Topic, "offset", letter.Offset, "error", err)
+		return models.DeadLetter{}, err
+	}
+	if letter.ID, err = res.LastInsertId(); err != nil {
+		return models.DeadLetter{}, err
+	}
+	db.log.Info("dead letter added", "dead_letter_id", letter.ID, "topic", letter.Topic, "offset", letter.Offset)
+	return letter, nil
+}
+
+const deadLetterColumns = `id, topic, kafka_partition, kafka_offset, message_key, value, error, attempts, failed_at`
+
+func scanDeadLetter(row interface{ Scan(...any) error }) (models.DeadLetter, error) {
+	var letter models.DeadLetter
+	var value []byte
+	err := row.Scan(&letter.ID, &letter.Topic, &letter.Partition, &letter.Offset, &letter.Key,
+		&value, &letter.Error, &letter.Attempts, timeValue{&letter.FailedAt})
+	letter.Value = string(value)
+	return letter, err
+}
+
+// checkAffected returns sql.ErrNoRows if the statement changed no rows.
+func checkAffected(res sql.Result) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (db *DB) GetDeadLetter(ctx context.Context, id int64) (models.DeadLetter, error) {
+	letter, err := scanDeadLetter(db.conn.QueryRowContext(ctx, "SELECT "+deadLetterColumns+" FROM dead_letters WHERE id = ?", id))
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			db.log.Error("failed to query dead letter", "dead_letter_id", id, "error", err)
+		}
+		return models.DeadLetter{}, err
+	}
+	return letter, nil
+}
+
+// ListDeadLetters returns up to limit dead letters with IDs above afterID,
+// oldest first.
+func (db *DB) ListDeadLetters(ctx context.Context, afterID int64, limit int) ([]models.DeadLetter, error) {
+	rows, err := db.conn.QueryContext(ctx, "SELECT "+deadLetterColumns+" FROM dead_letters WHERE id > ? ORDER BY id LIMIT ?", afterID, limit)
+	if err != nil {
+		db.log.Error("failed to query dead letters", "error", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var letters []models.DeadLetter
+	for rows.Next() {
+		letter, err := scanDeadLetter(rows)
+		if err != nil {
+			db.log.Error("failed to scan dead letter row", "error", err)
+			return nil, err
+		}
+		letters = append(letters, letter)
+	}
+	return letters, rows.Err()
+}
+
+func (db *DB) DeleteDeadLetter(ctx context.Context, id int64) error {
+	res, err := db.conn.ExecContext(ctx, "DELETE FROM dead_letters WHERE id = ?", id)
+	if err != nil {
+		db.log.Error("failed to delete dead letter", "dead_letter_id", id, "error", err)
+		return err
+	}
+	if err := checkAffected(res); err != nil {
+		return err
+	}
+	db.log.Info("dead letter deleted", "dead_letter_id", id)
+	return nil
+}
+
+// RecordDeadLetterFailure counts another failed attempt to process the dead
+// letter and stores the reason.
+func (db *DB) RecordDeadLetterFailure(ctx context.Context, id int64, reason string) error {
+	res, err := db.conn.ExecContext(ctx, "UPDATE dead_letters SET attempts = attempts + 1, error = ?, failed_at = ? WHERE id = ?",
+		reason, formatTime(time.Now()), id)
+	if err != nil {
+		db.log.Error("failed to record dead letter failure", "dead_letter_id", id, "error", err)
+		return err
+	}
+	return checkAffected(res)
+}
@@ -0,0 +1,40 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// MarkPublished records the orders as published now. Orders that do not
+// exist are skipped.
+func (db *DB) MarkPublished(ctx context.Context, orderUIDs []string) error {
+	now := formatTime(time.Now())
+	err := db.inTx(ctx, func(tx *sql.Tx) error {
+		for _, uid := range orderUIDs {
+			_, err := tx.ExecContext(ctx, `
+                INSERT INTO order_publications (order_uid, published_at)
+                SELECT order_uid, ? FROM orders WHERE order_uid = ?
+                ON CONFLICT (order_uid) DO UPDATE SET published_at = excluded.published_at`, now, uid)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.log.Error("failed to mark orders as published", "count", len(orderUIDs), "error", err)
+		return err
+	}
+	db.log.Debug("orders marked as published", "count", len(orderUIDs))
+	return nil
+}
+
+func (db *DB) ListUnpublished(ctx context.Context, after string, limit int) ([]string, error) {
+	return db.queryIDs(ctx, `
+        SELECT o.order_uid FROM orders o
+        WHERE o.order_uid > ? AND o.deleted_at IS NULL
+          AND NOT EXISTS (SELECT 1 FROM order_publications p WHERE p.order_uid = o.order_uid)
+        ORDER BY o.order_uid
+        LIMIT ?`, after, limit)
+}
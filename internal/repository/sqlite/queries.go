@@ -0,0 +1,202 @@
+package sqlite
+
+import (
+	"cmp"
+	"context"
+	"firstmod/internal/models"
+	"firstmod/internal/textsearch"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Search ranks orders by the share of query words found in their delivery
+// info and items. SQLite only folds the case of ASCII letters, so orders are
+// matched in Go rather than with LIKE; this scans every order, which is fine
+// for the amounts of data this storage is meant for.
+func (db *DB) Search(ctx context.Context, query string, limit, offset int) ([]models.OrderSummary, error) {
+	rows, err := db.conn.QueryContext(ctx, `
+        SELECT o.order_uid, o.customer_id, o.status, o.date_created,
+            d.name, d.phone, d.email, d.city, d.address, d.region,
+            COALESCE((
+                SELECT group_concat(text, ', ') FROM (
+                    SELECT trim(i.brand || ' ' || i.name) AS text
+                    FROM items i WHERE i.order_uid = o.order_uid ORDER BY i.id
+                )
+            ), '')
+        FROM orders o
+        JOIN delivery_info d ON d.order_uid = o.order_uid
+        WHERE o.deleted_at IS NULL
+        ORDER BY o.order_uid`)
+	if err != nil {
+		db.log.Error("failed to search orders", "query", query, "error", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	q := textsearch.Parse(query)
+	var summaries []models.OrderSummary
+	for rows.Next() {
+		var s models.OrderSummary
+		var address, region, items string
+		err := rows.Scan(&s.OrderUID, &s.CustomerID, &s.Status, timeValue{&s.DateCreated},
+			&s.Name, &s.Phone, &s.Email, &s.City, &address, &region, &items)
+		if err != nil {
+			db.log.Error("failed to scan search candidate", "query", query, "error", err)
+			return nil, err
+		}
+		delivery := strings.Join([]string{s.Name, s.Phone, s.Email, s.City, address, region}, ", ")
+		if s.Rank = q.Rank(delivery, items); s.Rank == 0 {
+			continue
+		}
+		s.Highlights = map[string]string{"delivery": q.Highlight(delivery), "items": q.Highlight(items)}
+		summaries = append(summaries, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	slices.SortStableFunc(summaries, func(a, b models.OrderSummary) int {
+		return cmp.Compare(b.Rank, a.Rank)
+	})
+	if offset >= len(summaries) {
+		return nil, nil
+	}
+	summaries = summaries[offset:]
+	if len(summaries) > limit {
+		summaries = summaries[:limit]
+	}
+	return summaries, nil
+}
+
+// reportScope restricts report queries on orders o to the filter passed as
+// ?1 and ?2 and to orders that count as sales.
+const reportScope = `
+            o.deleted_at IS NULL
+            AND o.status NOT IN ('cancelled', 'returned')
+            AND (?1 IS NULL OR o.date_created >= ?1)
+            AND (?2 IS NULL OR o.date_created < ?2)`
+
+var periodExpressions = map[models.Granularity]string{
+	models.GranularityDay:   "date(o.date_created)",
+	models.GranularityWeek:  "date(o.date_created, 'weekday 0', '-6 days')",
+	models.GranularityMonth: "strftime('%Y-%m-01', o.date_created)",
+}
+
+var dimensionColumns = map[models.Dimension]string{
+	models.DimensionDeliveryService: "o.delivery_service",
+	models.DimensionProvider:        "p.provider",
+	models.DimensionBank:            "p.bank",
+	models.DimensionCurrency:        "p.currency",
+	models.DimensionRegion:          "d.region",
+	models.DimensionBrand:           "i.brand",
+}
+
+func filterArgs(filter models.ReportFilter) []any {
+	bound := func(t time.Time) any {
+		if t.IsZero() {
+			return nil
+		}
+		return formatTime(t)
+	}
+	return []any{bound(filter.From), bound(filter.To)}
+}
+
+func (db *DB) SalesReport(ctx context.Context, granularity models.Granularity, filter models.ReportFilter) ([]models.SalesPoint, error) {
+	period, ok := periodExpressions[granularity]
+	if !ok {
+		return nil, fmt.Errorf("unknown report granularity %q", granularity)
+	}
+	rows, err := db.conn.QueryContext(ctx, fmt.Sprintf(`
+        SELECT %s AS period, p.currency, COUNT(*), COALESCE(SUM(p.amount), 0), COALESCE(AVG(p.amount), 0)
+        FROM orders o
+        JOIN payments p ON p.order_uid = o.order_uid
+        WHERE`+reportScope+`
+        GROUP BY period, p.currency
+        ORDER BY period, p.currency`, period), filterArgs(filter)...)
+	if err != nil {
+		db.log.Error("failed to query sales report", "granularity", granularity, "error", err)
+		return nil, err
+	}
+	defer rows.Close()
+	var points []models.SalesPoint
+	for rows.Next() {
+		var p models.SalesPoint
+		var day string
+		if err := rows.Scan(&day, &p.Currency, &p.Orders, &p.Revenue, &p.AverageBasket); err != nil {
+			db.log.Error("failed to scan sales report row", "error", err)
+			return nil, err
+		}
+		if p.Period, err = time.Parse(time.DateOnly, day); err != nil {
+			return nil, err
+		}
+		points = append(points, p)
+	}
+	return points, rows.Err()
+}
+
+func (db *DB) Breakdown(ctx context.Context, dimension models.Dimension, filter models.ReportFilter) ([]models.BreakdownRow, error) {
+	column, ok := dimensionColumns[dimension]
+	if !ok {
+		return nil, fmt.Errorf("unknown report dimension %q", dimension)
+	}
+	revenue, join := "SUM(p.amount)", ""
+	if dimension == models.DimensionBrand {
+		revenue, join = "SUM(i.total_price)", `JOIN items i ON i.order_uid = o.order_uid
+            AND i.state NOT IN ('cancelled', 'returned')`
+	}
+
+	rows, err := db.conn.QueryContext(ctx, fmt.Sprintf(`
+        SELECT %[1]s AS key, p.currency, COUNT(DISTINCT o.order_uid), COALESCE(%[2]s, 0)
+        FROM orders o
+        JOIN payments p ON p.order_uid = o.order_uid
+        JOIN delivery_info d ON d.order_uid = o.order_uid
+        %[3]s
+        WHERE`+reportScope+`
+        GROUP BY key, p.currency
+        ORDER BY 4 DESC, key, p.currency`, column, revenue, join), filterArgs(filter)...)
+	if err != nil {
+		db.log.Error("failed to query breakdown report", "dimension", dimension, "error", err)
+		return nil, err
+	}
+	defer rows.Close()
+	var breakdown []models.BreakdownRow
+	for rows.Next() {
+		var b models.BreakdownRow
+		if err := rows.Scan(&b.Key, &b.Currency, &b.Orders, &b.Revenue); err != nil {
+			db.log.Error("failed to scan breakdown report row", "error", err)
+			return nil, err
+		}
+		breakdown = append(breakdown, b)
+	}
+	return breakdown, rows.Err()
+}
+
+func (db *DB) TopItems(ctx context.Context, filter models.ReportFilter, limit int) ([]models.TopItem, error) {
+	rows, err := db.conn.QueryContext(ctx, `
+        SELECT i.nm_id, MAX(i.name), MAX(i.brand), p.currency, COUNT(*), COALESCE(SUM(i.total_price), 0)
+        FROM orders o
+        JOIN payments p ON p.order_uid = o.order_uid
+        JOIN items i ON i.order_uid = o.order_uid
+        WHERE`+reportScope+`
+            AND i.state NOT IN ('cancelled', 'returned')
+        GROUP BY i.nm_id, p.currency
+        ORDER BY 6 DESC, i.nm_id, p.currency
+        LIMIT ?3`, append(filterArgs(filter), limit)...)
+	if err != nil {
+		db.log.Error("failed to query top items report", "error", err)
+		return nil, err
+	}
+	defer rows.Close()
+	var items []models.TopItem
+	for rows.Next() {
+		var t models.TopItem
+		if err := rows.Scan(&t.NmID, &t.Name, &t.Brand, &t.Currency, &t.Quantity, &t.Revenue); err != nil {
+			db.log.Error("failed to scan top items report row", "error", err)
+			return nil, err
+		}
+		items = append(items, t)
+	}
+	return items, rows.Err()
+}
@@ -0,0 +1,572 @@
+// Package sqlite implements ports.Repository on an embedded SQLite database,
+// so the service can run without Postgres. Search is a plain substring match
+// and there is no partitioning, archiving or replication.
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"firstmod/internal/actor"
+	"firstmod/internal/audit"
+	"firstmod/internal/models"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+//go:embed schema.sql
+var schema string
+
+// timeLayout formats times so that their text sorts in time order.
+const timeLayout = "2006-01-02T15:04:05.000000000Z"
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format(timeLayout)
+}
+
+// timeValue scans a time stored by formatTime into t. NULL scans as the zero
+// time.
+type timeValue struct{ t *time.Time }
+
+func (v timeValue) Scan(src any) error {
+	switch s := src.(type) {
+	case nil:
+		*v.t = time.Time{}
+		return nil
+	case string:
+		t, err := time.Parse(timeLayout, s)
+		*v.t = t
+		return err
+	case []byte:
+		t, err := time.Parse(timeLayout, string(s))
+		*v.t = t
+		return err
+	}
+	return fmt.Errorf("cannot scan %T as time", src)
+}
+
+// queryer is implemented by both *sql.DB and *sql.Tx.
+type queryer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+type DB struct {
+	log  *slog.Logger
+	conn *sql.DB
+}
+
+// New opens the SQLite database at path, creating it and its schema if
+// needed. ":memory:" opens a private in-memory database.
+func New(log *slog.Logger, path string) (*DB, error) {
+	conn, err := sql.Open("sqlite", path+"?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)")
+	if err != nil {
+		log.Error("failed to open SQLite database", "path", path, "error", err)
+		return nil, err
+	}
+	// SQLite allows a single writer, and an in-memory database exists only
+	// within its connection.
+	conn.SetMaxOpenConns(1)
+
+	if _, err := conn.Exec(schema); err != nil {
+		log.Error("failed to create SQLite schema", "path", path, "error", err)
+		conn.Close()
+		return nil, err
+	}
+
+	log.Info("using SQLite order storage", "path", path)
+	return &DB{log: log, conn: conn}, nil
+}
+
+func (db *DB) Close() error {
+	return db.conn.Close()
+}
+
+// inTx runs fn in a transaction, committing it if fn succeeds and rolling it
+// back otherwise.
+func (db *DB) inTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		db.log.Error("failed to begin transaction", "error", err)
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		db.log.Error("failed to commit transaction", "error", err)
+		return err
+	}
+	return nil
+}
+
+func checkVersion(order models.Order, expectedVersion int64) error {
+	if expectedVersion != 0 && order.Version != expectedVersion {
+		return fmt.Errorf("%w: expected %d, current %d", models.ErrVersionMismatch, expectedVersion, order.Version)
+	}
+	return nil
+}
+
+// keyError translates a violation of the unique order UID or payment
+// transaction.
+func (db *DB) keyError(order models.Order, err error) error {
+	var sqliteErr *sqlite.Error
+	if errors.As(err, &sqliteErr) {
+		switch {
+		case strings.Contains(sqliteErr.Error(), "payments.transaction_uid"):
+			db.log.Info("payment transaction is already used by another order", "order_uid", order.OrderUID, "transaction", order.Payment.Transaction)
+			return fmt.Errorf("%w: payment transaction %s is already used", models.ErrInvalidOrder, order.Payment.Transaction)
+		case sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY:
+			db.log.Info("order already exists", "order_uid", order.OrderUID)
+			return fmt.Errorf("%w: %s", models.ErrOrderExists, order.OrderUID)
+		}
+	}
+	db.log.Error("failed to insert order", "order_uid", order.OrderUID, "error", err)
+	return err
+}
+
+func (db *DB) writeAudit(ctx context.Context, tx *sql.Tx, action models.EventType, orderUID string, before, after *models.Order) error {
+	entry, err := audit.NewEntry(ctx, action, orderUID, before, after, time.Now())
+	if err != nil {
+		db.log.Error("failed to build audit record", "order_uid", orderUID, "error", err)
+		return err
+	}
+	text := func(raw json.RawMessage) any {
+		if raw == nil {
+			return nil
+		}
+		return string(raw)
+	}
+	_, err = tx.ExecContext(ctx, `
+        INSERT INTO order_audit (order_uid, action, actor, source, request_id, before, after, diff, changed_at)
+        VALUES (?, ?, ?, ?, NULLIF(?, ''), ?, ?, ?, ?)`,
+		orderUID, string(action), entry.Actor, entry.Source, entry.RequestID,
+		text(entry.Before), text(entry.After), string(entry.Diff), formatTime(entry.ChangedAt))
+	if err != nil {
+		db.log.Error("failed to write audit record", "order_uid", orderUID, "action", action, "error", err)
+	}
+	return err
+}
+
+// insertOrder inserts the order with its delivery info, payment and items.
+func (db *DB) insertOrder(ctx context.Context, tx *sql.Tx, order models.Order) error {
+	_, err := tx.ExecContext(ctx, `
+        INSERT INTO orders (
+            order_uid, track_number, entry, locale, internal_signature,
+            customer_id, delivery_service, shardkey, sm_id, date_created, oof_shard,
+            version, status, status_changed_at
+        ) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		order.OrderUID, order.TrackNumber, order.Entry, order.Locale, order.InternalSignature,
+		order.CustomerID, order.DeliveryService, order.Shardkey, order.SmID, formatTime(order.DateCreated), order.OofShard,
+		order.Version, order.Status, formatTime(order.StatusChangedAt))
+	if err != nil {
+		return db.keyError(order, err)
+	}
+	return db.insertDetails(ctx, tx, order)
+}
+
+func (db *DB) insertDetails(ctx context.Context, tx *sql.Tx, order models.Order) error {
+	d := order.DeliveryInfo
+	_, err := tx.ExecContext(ctx, `
+        INSERT INTO delivery_info (order_uid, name, phone, zip, city, address, region, email)
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		order.OrderUID, d.Name, d.Phone, d.Zip, d.City, d.Address, d.Region, d.Email)
+	if err != nil {
+		db.log.Error("failed to insert delivery info", "order_uid", order.OrderUID, "error", err)
+		return err
+	}
+
+	p := order.Payment
+	_, err = tx.ExecContext(ctx, `
+        INSERT INTO payments (
+            order_uid, transaction_uid, request_id, currency, provider, amount,
+            payment_dt, bank, delivery_cost, goods_total, custom_fee
+        ) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		order.OrderUID, p.Transaction, p.RequestID, p.Currency, p.Provider, p.Amount,
+		p.PaymentDT, p.Bank, p.DeliveryCost, p.GoodsTotal, p.CustomFee)
+	if err != nil {
+		return db.keyError(order, err)
+	}
+
+	for i, item := range order.Items {
+		_, err := tx.ExecContext(ctx, `
+            INSERT INTO items (
+                order_uid, chrt_id, track_number, price, rid, name,
+                sale, size, total_price, nm_id, brand, status, state
+            ) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			order.OrderUID, item.ChrtID, item.TrackNumber, item.Price, item.Rid, item.Name,
+			item.Sale, item.Size, item.TotalPrice, item.NmID, item.Brand, item.Status, item.State)
+		if err != nil {
+			db.log.Error("failed to insert item", "order_uid", order.OrderUID, "item_index", i, "error", err)
+			return err
+		}
+	}
+	return nil
+}
+
+const orderSelectSQL = `
+        SELECT
+            o.order_uid, o.track_number, o.entry, o.locale, o.internal_signature,
+            o.customer_id, o.delivery_service, o.shardkey, o.sm_id, o.date_created, o.oof_shard, o.version,
+            o.status, o.status_changed_at,
+            d.order_uid IS NOT NULL,
+            COALESCE(d.name, ''), COALESCE(d.phone, ''), COALESCE(d.zip, ''), COALESCE(d.city, ''),
+            COALESCE(d.address, ''), COALESCE(d.region, ''), COALESCE(d.email, ''),
+            p.order_uid IS NOT NULL,
+            COALESCE(p.transaction_uid, ''), COALESCE(p.request_id, 0), COALESCE(p.currency, ''),
+            COALESCE(p.provider, ''), COALESCE(p.amount, 0), COALESCE(p.payment_dt, 0), COALESCE(p.bank, ''),
+            COALESCE(p.delivery_cost, 0), COALESCE(p.goods_total, 0), COALESCE(p.custom_fee, 0)
+        FROM orders o
+        LEFT JOIN delivery_info d ON d.order_uid = o.order_uid
+        LEFT JOIN payments p ON p.order_uid = o.order_uid`
+
+// loadOrders returns the orders matching where, ordered by UID, with their
+// items. Orders lacking delivery info or payment are returned as they are;
+// incomplete maps their UIDs to an error wrapping models.ErrDataIntegrity.
+func (db *DB) loadOrders(ctx context.Context, q queryer, where string, args ...any) (orders []models.Order, incomplete map[string]error, err error) {
+	rows, err := q.QueryContext(ctx, orderSelectSQL+" WHERE "+where+" ORDER BY o.order_uid", args...)
+	if err != nil {
+		return nil, nil, err
+	}
+	incomplete = make(map[string]error)
+	for rows.Next() {
+		var order models.Order
+		var hasDelivery, hasPayment bool
+		err := rows.Scan(
+			&order.OrderUID, &order.TrackNumber, &order.Entry, &order.Locale, &order.InternalSignature,
+			&order.CustomerID, &order.DeliveryService, &order.Shardkey, &order.SmID, timeValue{&order.DateCreated},
+			&order.OofShard, &order.Version, &order.Status, timeValue{&order.StatusChangedAt},
+			&hasDelivery,
+			&order.DeliveryInfo.Name, &order.DeliveryInfo.Phone, &order.DeliveryInfo.Zip, &order.DeliveryInfo.City,
+			&order.DeliveryInfo.Address, &order.DeliveryInfo.Region, &order.DeliveryInfo.Email,
+			&hasPayment,
+			&order.Payment.Transaction, &order.Payment.RequestID, &order.Payment.Currency,
+			&order.Payment.Provider, &order.Payment.Amount, &order.Payment.PaymentDT, &order.Payment.Bank,
+			&order.Payment.DeliveryCost, &order.Payment.GoodsTotal, &order.Payment.CustomFee,
+		)
+		if err != nil {
+			rows.Close()
+			return nil, nil, err
+		}
+		switch {
+		case !hasDelivery:
+			incomplete[order.OrderUID] = fmt.Errorf("%w: order %s has no delivery info", models.ErrDataIntegrity, order.OrderUID)
+		case !hasPayment:
+			incomplete[order.OrderUID] = fmt.Errorf("%w: order %s has no payment", models.ErrDataIntegrity, order.OrderUID)
+		}
+		orders = append(orders, order)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+	if len(orders) == 0 {
+		return nil, nil, nil
+	}
+
+	// Items are read once the orders are, since the single connection cannot
+	// run a query while another one is open.
+	index := make(map[string]int, len(orders))
+	uids := make([]string, len(orders))
+	for i, order := range orders {
+		index[order.OrderUID] = i
+		uids[i] = order.OrderUID
+	}
+	uidsJSON, err := json.Marshal(uids)
+	if err != nil {
+		return nil, nil, err
+	}
+	rows, err = q.QueryContext(ctx, `
+        SELECT order_uid, chrt_id, track_number, price, rid, name, sale, size, total_price, nm_id, brand, status, state
+        FROM items
+        WHERE order_uid IN (SELECT value FROM json_each(?))
+        ORDER BY id`, string(uidsJSON))
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var uid string
+		var item models.Item
+		err := rows.Scan(&uid, &item.ChrtID, &item.TrackNumber, &item.Price, &item.Rid, &item.Name,
+			&item.Sale, &item.Size, &item.TotalPrice, &item.NmID, &item.Brand, &item.Status, &item.State)
+		if err != nil {
+			return nil, nil, err
+		}
+		order := &orders[index[uid]]
+		order.Items = append(order.Items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+	return orders, incomplete, nil
+}
+
+// lockOrder returns the current state of the order within tx. SQLite
+// transactions are serialized, so the order cannot change until tx ends. It
+// returns sql.ErrNoRows if the order does not exist or its deletion state
+// differs from deleted.
+func (db *DB) lockOrder(ctx context.Context, tx *sql.Tx, orderUID string, deleted bool) (models.Order, error) {
+	// Incomplete orders are changed as they are.
+	orders, _, err := db.loadOrders(ctx, tx, "o.order_uid = ? AND (o.deleted_at IS NOT NULL) = ?", orderUID, deleted)
+	if err != nil {
+		db.log.Error("failed to read order", "order_uid", orderUID, "error", err)
+		return models.Order{}, err
+	}
+	if len(orders) == 0 {
+		return models.Order{}, sql.ErrNoRows
+	}
+	return orders[0], nil
+}
+
+func (db *DB) Add(ctx context.Context, order models.Order) error {
+	err := db.inTx(ctx, func(tx *sql.Tx) error {
+		if err := db.insertOrder(ctx, tx, order); err != nil {
+			return err
+		}
+		return db.writeAudit(ctx, tx, models.EventOrderCreated, order.OrderUID, nil, &order)
+	})
+	if err != nil {
+		return err
+	}
+	db.log.Info("order and related data added successfully", "order_uid", order.OrderUID)
+	return nil
+}
+
+func (db *DB) Update(ctx context.Context, order models.Order, expectedVersion int64) (models.Order, error) {
+	err := db.inTx(ctx, func(tx *sql.Tx) error {
+		before, err := db.lockOrder(ctx, tx, order.OrderUID, false)
+		if err != nil {
+			return err
+		}
+		if err := checkVersion(before, expectedVersion); err != nil {
+			return err
+		}
+		order.Version = before.Version + 1
+		order.KeepLifecycle(before)
+
+		for _, table := range []string{"delivery_info", "payments", "items"} {
+			if _, err := tx.ExecContext(ctx, "DELETE FROM "+table+" WHERE order_uid = ?", order.OrderUID); err != nil {
+				db.log.Error("failed to delete order details", "order_uid", order.OrderUID, "error", err)
+				return err
+			}
+		}
+		_, err = tx.ExecContext(ctx, `
+            UPDATE orders SET
+                track_number = ?, entry = ?, locale = ?, internal_signature = ?,
+                customer_id = ?, delivery_service = ?, shardkey = ?, sm_id = ?,
+                date_created = ?, oof_shard = ?, version = ?
+            WHERE order_uid = ?`,
+			order.TrackNumber, order.Entry, order.Locale, order.InternalSignature,
+			order.CustomerID, order.DeliveryService, order.Shardkey, order.SmID,
+			formatTime(order.DateCreated), order.OofShard, order.Version, order.OrderUID)
+		if err != nil {
+			db.log.Error("failed to update order", "order_uid", order.OrderUID, "error", err)
+			return err
+		}
+		if err := db.insertDetails(ctx, tx, order); err != nil {
+			return err
+		}
+		return db.writeAudit(ctx, tx, models.EventOrderUpdated, order.OrderUID, &before, &order)
+	})
+	if err != nil {
+		return models.Order{}, err
+	}
+	db.log.Info("order and related data updated successfully", "order_uid", order.OrderUID, "version", order.Version)
+	return order, nil
+}
+
+func (db *DB) GetInfo(ctx context.Context, orderUID string) (models.Order, error) {
+	orders, incomplete, err := db.loadOrders(ctx, db.conn, "o.order_uid = ? AND o.deleted_at IS NULL", orderUID)
+	if err != nil {
+		db.log.Error("failed to query order", "order_uid", orderUID, "error", err)
+		return models.Order{}, err
+	}
+	if len(orders) == 0 {
+		db.log.Debug("order not found", "order_uid", orderUID)
+		return models.Order{}, sql.ErrNoRows
+	}
+	if err := incomplete[orderUID]; err != nil {
+		db.log.Error("order is incomplete", "order_uid", orderUID, "error", err)
+		return models.Order{}, err
+	}
+	return orders[0], nil
+}
+
+func (db *DB) Delete(ctx context.Context, orderUID, reason string, expectedVersion int64) (models.Order, error) {
+	var deleted models.Order
+	err := db.inTx(ctx, func(tx *sql.Tx) error {
+		before, err := db.lockOrder(ctx, tx, orderUID, false)
+		if err != nil {
+			return err
+		}
+		if err := checkVersion(before, expectedVersion); err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, `
+            UPDATE orders SET deleted_at = ?, deleted_by = ?, delete_reason = NULLIF(?, ''), version = version + 1
+            WHERE order_uid = ?`,
+			formatTime(time.Now()), actor.From(ctx), reason, orderUID)
+		if err != nil {
+			db.log.Error("failed to delete order", "order_uid", orderUID, "error", err)
+			return err
+		}
+		deleted = before
+		deleted.Version++
+		return db.writeAudit(ctx, tx, models.EventOrderDeleted, orderUID, &before, nil)
+	})
+	if err != nil {
+		return models.Order{}, err
+	}
+	db.log.Info("order marked as deleted", "order_uid", orderUID, "deleted_by", actor.From(ctx))
+	return deleted, nil
+}
+
+func (db *DB) Restore(ctx context.Context, orderUID string) error {
+	err := db.inTx(ctx, func(tx *sql.Tx) error {
+		order, err := db.lockOrder(ctx, tx, orderUID, true)
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, `
+            UPDATE orders SET deleted_at = NULL, deleted_by = NULL, delete_reason = NULL, version = version + 1
+            WHERE order_uid = ?`, orderUID)
+		if err != nil {
+			db.log.Error("failed to restore order", "order_uid", orderUID, "error", err)
+			return err
+		}
+		order.Version++
+		return db.writeAudit(ctx, tx, models.EventOrderRestored, orderUID, nil, &order)
+	})
+	if err != nil {
+		return err
+	}
+	db.log.Info("order restored", "order_uid", orderUID)
+	return nil
+}
+
+func (db *DB) ListDeleted(ctx context.Context, limit int) ([]models.Deletion, error) {
+	rows, err := db.conn.QueryContext(ctx, `
+        SELECT order_uid, deleted_at, COALESCE(deleted_by, ''), COALESCE(delete_reason, '')
+        FROM orders
+        WHERE deleted_at IS NOT NULL
+        ORDER BY deleted_at DESC
+        LIMIT ?`, limit)
+	if err != nil {
+		db.log.Error("failed to query deleted orders", "error", err)
+		return nil, err
+	}
+	defer rows.Close()
+	var deletions []models.Deletion
+	for rows.Next() {
+		var d models.Deletion
+		if err := rows.Scan(&d.OrderUID, timeValue{&d.DeletedAt}, &d.DeletedBy, &d.Reason); err != nil {
+			db.log.Error("failed to scan deleted order row", "error", err)
+			return nil, err
+		}
+		deletions = append(deletions, d)
+	}
+	return deletions, rows.Err()
+}
+
+func (db *DB) GetIDs(ctx context.Context) ([]string, error) {
+	return db.queryIDs(ctx, "SELECT order_uid FROM orders WHERE deleted_at IS NULL")
+}
+
+func (db *DB) GetIDsPage(ctx context.Context, filter models.OrderFilter, after string, limit int) ([]string, error) {
+	return db.queryIDs(ctx, `
+        SELECT o.order_uid FROM orders o
+        WHERE (?1 IS NULL OR o.date_created >= ?1)
+            AND (?2 IS NULL OR o.date_created < ?2)
+            AND o.order_uid > ?3 AND o.deleted_at IS NULL
+            AND (?5 = '' OR o.status = ?5)
+            AND (?6 = '' OR o.customer_id = ?6)
+            AND (?7 = '' OR o.delivery_service = ?7)
+            AND (?8 = '' OR EXISTS (SELECT 1 FROM payments p WHERE p.order_uid = o.order_uid AND p.currency = ?8))
+        ORDER BY o.order_uid
+        LIMIT ?4`,
+		append(filterArgs(models.ReportFilter{From: filter.From, To: filter.To}), after, limit,
+			string(filter.Status), filter.CustomerID, filter.DeliveryService, filter.Currency)...)
+}
+
+func (db *DB) queryIDs(ctx context.Context, query string, args ...any) ([]string, error) {
+	rows, err := db.conn.QueryContext(ctx, query, args...)
+	if err != nil {
+		db.log.Error("failed to query order UIDs", "error", err)
+		return nil, err
+	}
+	defer rows.Close()
+	var uids []string
+	for rows.Next() {
+		var uid string
+		if err := rows.Scan(&uid); err != nil {
+			db.log.Error("failed to scan order UID row", "error", err)
+			return nil, err
+		}
+		uids = append(uids, uid)
+	}
+	return uids, rows.Err()
+}
+
+func (db *DB) AddBatch(ctx context.Context, orders []models.Order) ([]string, error) {
+	var created []string
+	err := db.inTx(ctx, func(tx *sql.Tx) error {
+		created = created[:0]
+		for _, order := range orders {
+			var exists bool
+			err := tx.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM orders WHERE order_uid = ?)", order.OrderUID).Scan(&exists)
+			if err != nil {
+				db.log.Error("failed to check order existence", "order_uid", order.OrderUID, "error", err)
+				return err
+			}
+			if exists {
+				continue
+			}
+			if err := db.insertOrder(ctx, tx, order); err != nil {
+				return err
+			}
+			if err := db.writeAudit(ctx, tx, models.EventOrderCreated, order.OrderUID, nil, &order); err != nil {
+				return err
+			}
+			created = append(created, order.OrderUID)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	db.log.Info("batch of orders added", "requested", len(orders), "created", len(created))
+	return created, nil
+}
+
+func (db *DB) GetInfoBatch(ctx context.Context, orderUIDs []string) ([]models.Order, []string, error) {
+	uidsJSON, err := json.Marshal(orderUIDs)
+	if err != nil {
+		return nil, nil, err
+	}
+	loaded, incomplete, err := db.loadOrders(ctx, db.conn,
+		"o.order_uid IN (SELECT value FROM json_each(?)) AND o.deleted_at IS NULL", string(uidsJSON))
+	if err != nil {
+		db.log.Error("failed to query batch of orders", "error", err)
+		return nil, nil, err
+	}
+	var orders []models.Order
+	var skipped []string
+	for _, order := range loaded {
+		if err := incomplete[order.OrderUID]; err != nil {
+			db.log.Error("skipping incomplete order in batch", "order_uid", order.OrderUID, "error", err)
+			skipped = append(skipped, order.OrderUID)
+			continue
+		}
+		orders = append(orders, order)
+	}
+	return orders, skipped, nil
+}
@@ -0,0 +1,34 @@
+package sqlite_test
+
+import (
+	"database/sql"
+	"firstmod/internal/ports"
+	"firstmod/internal/repository/repotest"
+	"firstmod/internal/repository/sqlite"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"testing"
+)
+
+func TestConformance(t *testing.T) {
+	var path string
+	repotest.Run(t, func(t *testing.T) ports.Repository {
+		path = filepath.Join(t.TempDir(), "orders.db")
+		db, err := sqlite.New(slog.New(slog.NewTextHandler(io.Discard, nil)), path)
+		if err != nil {
+			t.Fatalf("failed to open database: %v", err)
+		}
+		t.Cleanup(func() { db.Close() })
+		return db
+	}, repotest.WithDropDelivery(func(t *testing.T, repo ports.Repository, orderUID string) {
+		conn, err := sql.Open("sqlite", path+"?_pragma=busy_timeout(5000)")
+		if err != nil {
+			t.Fatalf("failed to open database: %v", err)
+		}
+		defer conn.Close()
+		if _, err := conn.Exec("DELETE FROM delivery_info WHERE order_uid = ?", orderUID); err != nil {
+			t.Fatalf("failed to drop delivery info: %v", err)
+		}
+	}))
+}
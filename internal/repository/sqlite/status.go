@@ -0,0 +1,130 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"firstmod/internal/models"
+	"firstmod/internal/ports"
+	"slices"
+)
+
+// History returns the audit trail of the order, oldest change first.
+func (db *DB) History(ctx context.Context, orderUID string) ([]models.AuditEntry, error) {
+	rows, err := db.conn.QueryContext(ctx, `
+        SELECT id, order_uid, action, actor, source, COALESCE(request_id, ''), before, after, diff, changed_at
+        FROM order_audit
+        WHERE order_uid = ?
+        ORDER BY id`, orderUID)
+	if err != nil {
+		db.log.Error("failed to query order history", "order_uid", orderUID, "error", err)
+		return nil, err
+	}
+	defer rows.Close()
+	var entries []models.AuditEntry
+	for rows.Next() {
+		var e models.AuditEntry
+		var before, after sql.NullString
+		var diff string
+		err := rows.Scan(&e.ID, &e.OrderUID, &e.Action, &e.Actor, &e.Source, &e.RequestID,
+			&before, &after, &diff, timeValue{&e.ChangedAt})
+		if err != nil {
+			db.log.Error("failed to scan order history row", "order_uid", orderUID, "error", err)
+			return nil, err
+		}
+		if before.Valid {
+			e.Before = []byte(before.String)
+		}
+		if after.Valid {
+			e.After = []byte(after.String)
+		}
+		e.Diff = []byte(diff)
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+// SetStatus locks the order, checks that its current version matches
+// expectedVersion unless that is zero, and lets change move it to new
+// lifecycle stages. The lifecycle rules are enforced by change. SetStatus
+// stores the new statuses of the order and its items along with the
+// transitions change returns, and returns the updated order.
+func (db *DB) SetStatus(ctx context.Context, orderUID string, expectedVersion int64, change ports.StatusChange) (models.Order, error) {
+	var order models.Order
+	var transitions []models.StatusTransition
+	err := db.inTx(ctx, func(tx *sql.Tx) error {
+		before, err := db.lockOrder(ctx, tx, orderUID, false)
+		if err != nil {
+			return err
+		}
+		if err := checkVersion(before, expectedVersion); err != nil {
+			return err
+		}
+		order = before
+		order.Items = slices.Clone(before.Items)
+		if transitions, err = change(&order); err != nil {
+			return err
+		}
+		order.Version = before.Version + 1
+
+		_, err = tx.ExecContext(ctx, "UPDATE orders SET status = ?, status_changed_at = ?, version = ? WHERE order_uid = ?",
+			order.Status, formatTime(order.StatusChangedAt), order.Version, orderUID)
+		if err != nil {
+			db.log.Error("failed to update order status", "order_uid", orderUID, "error", err)
+			return err
+		}
+		for _, t := range transitions {
+			if t.ChrtID != nil {
+				_, err := tx.ExecContext(ctx, "UPDATE items SET state = ? WHERE order_uid = ? AND chrt_id = ?",
+					t.To, orderUID, *t.ChrtID)
+				if err != nil {
+					db.log.Error("failed to update item state", "order_uid", orderUID, "chrt_id", *t.ChrtID, "error", err)
+					return err
+				}
+			}
+			_, err := tx.ExecContext(ctx, `
+                INSERT INTO order_status_transitions (order_uid, chrt_id, from_status, to_status, actor, reason, changed_at)
+                VALUES (?, ?, ?, ?, ?, NULLIF(?, ''), ?)`,
+				t.OrderUID, t.ChrtID, t.From, t.To, t.Actor, t.Reason, formatTime(t.ChangedAt))
+			if err != nil {
+				db.log.Error("failed to record status transition", "order_uid", orderUID, "error", err)
+				return err
+			}
+		}
+		return db.writeAudit(ctx, tx, models.EventOrderStatusChanged, orderUID, &before, &order)
+	})
+	if err != nil {
+		return models.Order{}, err
+	}
+	db.log.Info("order status changed", "order_uid", orderUID, "status", order.Status, "transitions", len(transitions))
+	return order, nil
+}
+
+// ListTransitions returns the status transitions of the order and its items,
+// oldest first.
+func (db *DB) ListTransitions(ctx context.Context, orderUID string) ([]models.StatusTransition, error) {
+	rows, err := db.conn.QueryContext(ctx, `
+        SELECT order_uid, chrt_id, from_status, to_status, actor, COALESCE(reason, ''), changed_at
+        FROM order_status_transitions
+        WHERE order_uid = ?
+        ORDER BY id`, orderUID)
+	if err != nil {
+		db.log.Error("failed to query status transitions", "order_uid", orderUID, "error", err)
+		return nil, err
+	}
+	defer rows.Close()
+	var transitions []models.StatusTransition
+	for rows.Next() {
+		var t models.StatusTransition
+		var chrtID sql.NullInt64
+		err := rows.Scan(&t.OrderUID, &chrtID, &t.From, &t.To, &t.Actor, &t.Reason, timeValue{&t.ChangedAt})
+		if err != nil {
+			db.log.Error("failed to scan status transition row", "order_uid", orderUID, "error", err)
+			return nil, err
+		}
+		if chrtID.Valid {
+			t.ChrtID = &chrtID.Int64
+		}
+		transitions = append(transitions, t)
+	}
+	return transitions, rows.Err()
+}
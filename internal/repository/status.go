@@ -0,0 +1,102 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"firstmod/internal/models"
+	"firstmod/internal/ports"
+	"slices"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// SetStatus locks the order, checks that its current version matches
+// expectedVersion unless that is zero, and lets change move it to new
+// lifecycle stages. The lifecycle rules are enforced by change. SetStatus
+// stores the new statuses of the order and its items along with the
+// transitions change returns, and returns the updated order.
+func (db *DB) SetStatus(ctx context.Context, orderUID string, expectedVersion int64, change ports.StatusChange) (models.Order, error) {
+	db.log.Debug("attempting to change order status", "order_uid", orderUID)
+
+	var order models.Order
+	var transitions []models.StatusTransition
+	err := db.inTx(ctx, func(tx pgx.Tx) error {
+		before, err := db.lockOrder(ctx, tx, orderUID, false)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				db.log.Debug("attempted to change status of non-existent order", "order_uid", orderUID)
+			}
+			return err
+		}
+		if err := checkVersion(before, expectedVersion); err != nil {
+			db.log.Info("order status change rejected", "order_uid", orderUID, "error", err)
+			return err
+		}
+		order = before
+		order.Items = slices.Clone(before.Items)
+		if transitions, err = change(&order); err != nil {
+			return err
+		}
+		order.Version = before.Version + 1
+
+		_, err = tx.Exec(ctx, `
+            UPDATE orders SET status = $2, status_changed_at = $3, version = $4
+            WHERE order_uid = $1`,
+			orderUID, order.Status, order.StatusChangedAt, order.Version)
+		if err != nil {
+			db.log.Error("failed to update order status", "order_uid", orderUID, "error", err)
+			return err
+		}
+		for _, t := range transitions {
+			if t.ChrtID != nil {
+				_, err := tx.Exec(ctx, "UPDATE items SET state = $3 WHERE order_uid = $1 AND chrt_id = $2",
+					orderUID, *t.ChrtID, t.To)
+				if err != nil {
+					db.log.Error("failed to update item state", "order_uid", orderUID, "chrt_id", *t.ChrtID, "error", err)
+					return err
+				}
+			}
+			_, err := tx.Exec(ctx, `
+                INSERT INTO order_status_transitions (order_uid, chrt_id, from_status, to_status, actor, reason, changed_at)
+                VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), $7)`,
+				t.OrderUID, t.ChrtID, t.From, t.To, t.Actor, t.Reason, t.ChangedAt)
+			if err != nil {
+				db.log.Error("failed to record status transition", "order_uid", orderUID, "error", err)
+				return err
+			}
+		}
+		return db.writeAudit(ctx, tx, models.EventOrderStatusChanged, orderUID, &before, &order)
+	})
+	if err != nil {
+		return models.Order{}, err
+	}
+
+	db.markWritten(orderUID)
+	db.log.Info("order status changed", "order_uid", orderUID, "status", order.Status, "transitions", len(transitions))
+	return order, nil
+}
+
+// ListTransitions returns the status transitions of the order and its items,
+// oldest first.
+func (db *DB) ListTransitions(ctx context.Context, orderUID string) ([]models.StatusTransition, error) {
+	rows, err := db.reader(ctx, orderUID).Query(ctx, `
+        SELECT order_uid, chrt_id, from_status, to_status, actor, COALESCE(reason, ''), changed_at
+        FROM order_status_transitions
+        WHERE order_uid = $1
+        ORDER BY id`, orderUID)
+	if err != nil {
+		db.log.Error("failed to query status transitions", "order_uid", orderUID, "error", err)
+		return nil, err
+	}
+	transitions, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (models.StatusTransition, error) {
+		var t models.StatusTransition
+		err := row.Scan(&t.OrderUID, &t.ChrtID, &t.From, &t.To, &t.Actor, &t.Reason, &t.ChangedAt)
+		return t, err
+	})
+	if err != nil {
+		db.log.Error("failed to scan status transition rows", "order_uid", orderUID, "error", err)
+		return nil, err
+	}
+	return transitions, nil
+}
@@ -0,0 +1,226 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"firstmod/internal/models"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+func (db *DB) AddWebhook(ctx context.Context, sub models.WebhookSubscription) (models.WebhookSubscription, error) {
+	db.log.Debug("attempting to add webhook subscription", "url", sub.URL)
+
+	eventTypes := make([]string, 0, len(sub.EventTypes))
+	for _, t := range sub.EventTypes {
+		eventTypes = append(eventTypes, string(t))
+	}
+
+	err := db.conn.QueryRow(ctx, `
+        INSERT INTO webhook_subscriptions (url, secret, event_types, enabled)
+        VALUES ($1, $2, $3, TRUE)
+        RETURNING id, enabled, created_at`,
+		sub.URL, sub.Secret, eventTypes,
+	).Scan(&sub.ID, &sub.Enabled, &sub.CreatedAt)
+	if err != nil {
+		db.log.Error("failed to insert webhook subscription", "url", sub.URL, "error", err)
+		return models.WebhookSubscription{}, err
+	}
+
+	db.log.Info("webhook subscription added", "webhook_id", sub.ID, "url", sub.URL)
+	return sub, nil
+}
+
+const webhookColumns = `id, url, secret, event_types, enabled, consecutive_failures, disabled_at, created_at`
+
+func scanWebhook(row pgx.Row) (models.WebhookSubscription, error) {
+	var sub models.WebhookSubscription
+	var eventTypes []string
+	err := row.Scan(
+		&sub.ID,
+		&sub.URL,
+		&sub.Secret,
+		&eventTypes,
+		&sub.Enabled,
+		&sub.ConsecutiveFailures,
+		&sub.DisabledAt,
+		&sub.CreatedAt,
+	)
+	for _, t := range eventTypes {
+		sub.EventTypes = append(sub.EventTypes, models.EventType(t))
+	}
+	return sub, err
+}
+
+func (db *DB) GetWebhook(ctx context.Context, id int64) (models.WebhookSubscription, error) {
+	sub, err := scanWebhook(db.conn.QueryRow(ctx, "SELECT "+webhookColumns+" FROM webhook_subscriptions WHERE id = $1", id))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			db.log.Debug("webhook subscription not found", "webhook_id", id)
+			return models.WebhookSubscription{}, sql.ErrNoRows
+		}
+		db.log.Error("failed to query webhook subscription", "webhook_id", id, "error", err)
+		return models.WebhookSubscription{}, err
+	}
+	return sub, nil
+}
+
+// ListWebhooks returns all webhook subscriptions, or only enabled ones if
+// onlyEnabled is set.
+func (db *DB) ListWebhooks(ctx context.Context, onlyEnabled bool) ([]models.WebhookSubscription, error) {
+	rows, err := db.conn.Query(ctx, "SELECT "+webhookColumns+" FROM webhook_subscriptions WHERE enabled OR NOT $1 ORDER BY id", onlyEnabled)
+	if err != nil {
+		db.log.Error("failed to query webhook subscriptions", "error", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var subs []models.WebhookSubscription
+	for rows.Next() {
+		sub, err := scanWebhook(rows)
+		if err != nil {
+			db.log.Error("failed to scan webhook subscription row", "error", err)
+			return nil, err
+		}
+		subs = append(subs, sub)
+	}
+	if err = rows.Err(); err != nil {
+		db.log.Error("error after scanning webhook subscription rows", "error", err)
+		return nil, err
+	}
+	return subs, nil
+}
+
+func (db *DB) DeleteWebhook(ctx context.Context, id int64) error {
+	cmdTag, err := db.conn.Exec(ctx, "DELETE FROM webhook_subscriptions WHERE id = $1", id)
+	if err != nil {
+		db.log.Error("failed to delete webhook subscription", "webhook_id", id, "error", err)
+		return err
+	}
+	if cmdTag.RowsAffected() == 0 {
+		return sql.ErrNoRows
+	}
+	db.log.Info("webhook subscription deleted", "webhook_id", id)
+	return nil
+}
+
+// SetWebhookEnabled enables or disables a subscription. Enabling also resets
+// its failure counter.
+func (db *DB) SetWebhookEnabled(ctx context.Context, id int64, enabled bool) error {
+	cmdTag, err := db.conn.Exec(ctx, `
+        UPDATE webhook_subscriptions SET
+            enabled = $2,
+            consecutive_failures = CASE WHEN $2 THEN 0 ELSE consecutive_failures END,
+            disabled_at = CASE WHEN $2 THEN NULL ELSE NOW() END
+        WHERE id = $1`, id, enabled)
+	if err != nil {
+		db.log.Error("failed to update webhook subscription", "webhook_id", id, "error", err)
+		return err
+	}
+	if cmdTag.RowsAffected() == 0 {
+		return sql.ErrNoRows
+	}
+	db.log.Info("webhook subscription updated", "webhook_id", id, "enabled", enabled)
+	return nil
+}
+
+// RecordWebhookResult updates the failure counter of a subscription after an
+// event was delivered or finally given up on, disabling the subscription once
+// it has failed disableAfter events in a row. It reports whether the
+// subscription got disabled.
+func (db *DB) RecordWebhookResult(ctx context.Context, id int64, success bool, disableAfter int) (bool, error) {
+	var enabled bool
+	err := db.conn.QueryRow(ctx, `
+        UPDATE webhook_subscriptions SET
+            consecutive_failures = CASE WHEN $2 THEN 0 ELSE consecutive_failures + 1 END,
+            enabled = enabled AND ($2 OR consecutive_failures + 1 < $3),
+            disabled_at = CASE WHEN enabled AND NOT $2 AND consecutive_failures + 1 >= $3 THEN NOW() ELSE disabled_at END
+        WHERE id = $1
+        RETURNING enabled`, id, success, disableAfter).Scan(&enabled)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return false, sql.ErrNoRows
+		}
+		db.log.Error("failed to record webhook result", "webhook_id", id, "error", err)
+		return false, err
+	}
+	return !enabled, nil
+}
+
+func (db *DB) AddWebhookDelivery(ctx context.Context, d models.WebhookDelivery) error {
+	_, err := db.conn.Exec(ctx, `
+        INSERT INTO webhook_deliveries (
+            subscription_id, event_id, event_type, order_uid, attempt,
+            status_code, error, success, duration_ms
+        ) VALUES (
+            $1, $2, $3, $4, $5, NULLIF($6, 0), NULLIF($7, ''), $8, $9
+        )`,
+		d.SubscriptionID,
+		int64(d.EventID),
+		string(d.EventType),
+		d.OrderUID,
+		d.Attempt,
+		d.StatusCode,
+		d.Error,
+		d.Success,
+		d.Duration.Milliseconds(),
+	)
+	if err != nil {
+		db.log.Error("failed to insert webhook delivery", "webhook_id", d.SubscriptionID, "event_id", d.EventID, "error", err)
+		return err
+	}
+	return nil
+}
+
+// ListWebhookDeliveries returns the latest deliveries of a subscription,
+// newest first.
+func (db *DB) ListWebhookDeliveries(ctx context.Context, id int64, limit int) ([]models.WebhookDelivery, error) {
+	rows, err := db.conn.Query(ctx, `
+        SELECT
+            id, subscription_id, event_id, event_type, order_uid, attempt,
+            COALESCE(status_code, 0), COALESCE(error, ''), success, duration_ms, created_at
+        FROM webhook_deliveries
+        WHERE subscription_id = $1
+        ORDER BY created_at DESC, id DESC
+        LIMIT $2`, id, limit)
+	if err != nil {
+		db.log.Error("failed to query webhook deliveries", "webhook_id", id, "error", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deliveries []models.WebhookDelivery
+	for rows.Next() {
+		var d models.WebhookDelivery
+		var eventID, durationMS int64
+		var eventType string
+		err := rows.Scan(
+			&d.ID,
+			&d.SubscriptionID,
+			&eventID,
+			&eventType,
+			&d.OrderUID,
+			&d.Attempt,
+			&d.StatusCode,
+			&d.Error,
+			&d.Success,
+			&durationMS,
+			&d.CreatedAt,
+		)
+		if err != nil {
+			db.log.Error("failed to scan webhook delivery row", "webhook_id", id, "error", err)
+			return nil, err
+		}
+		d.EventID = uint64(eventID)
+		d.EventType = models.EventType(eventType)
+		d.Duration = time.Duration(durationMS) * time.Millisecond
+		deliveries = append(deliveries, d)
+	}
+	if err = rows.Err(); err != nil {
+		db.log.Error("error after scanning webhook delivery rows", "webhook_id", id, "error", err)
+		return nil, err
+	}
+	return deliveries, nil
+}
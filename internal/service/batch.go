@@ -0,0 +1,182 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"firstmod/internal/models"
+	"firstmod/internal/ports"
+	"time"
+)
+
+// BatchCreate validates and stores orders in bulk, reporting the outcome of
+// every order in request order. If the bulk insert fails as a whole, orders
+// are retried one by one so a single bad order does not fail the others.
+func (s *OrderService) BatchCreate(ctx context.Context, orders []models.Order) ([]models.BatchItemResult, error) {
+	results := make([]models.BatchItemResult, len(orders))
+	seen := make(map[string]bool, len(orders))
+	var valid []models.Order
+	var validIdx []int
+
+	now := time.Now().UTC()
+	for i, order := range orders {
+		order.Version = models.FirstVersion
+		initLifecycle(&order, now)
+		results[i] = models.BatchItemResult{Index: i, OrderUID: order.OrderUID}
+		if err := validateOrder(order); err != nil {
+			results[i].Status = models.BatchItemInvalid
+			results[i].Error = err.Error()
+			continue
+		}
+		if seen[order.OrderUID] {
+			results[i].Status = models.BatchItemDuplicate
+			results[i].Error = "order_uid repeated within batch"
+			continue
+		}
+		seen[order.OrderUID] = true
+		valid = append(valid, order)
+		validIdx = append(validIdx, i)
+	}
+
+	if len(valid) == 0 {
+		return results, nil
+	}
+
+	created, err := s.db.AddBatch(ctx, valid)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, err
+		}
+		s.log.Warn("bulk insert failed, falling back to inserting orders one by one", "count", len(valid), "error", err)
+		created = nil
+		for j, order := range valid {
+			i := validIdx[j]
+			err := s.db.Add(ctx, order)
+			switch {
+			case err == nil:
+				created = append(created, order.OrderUID)
+			case errors.Is(err, models.ErrOrderExists):
+				results[i].Status = models.BatchItemDuplicate
+			case errors.Is(err, models.ErrInvalidOrder):
+				results[i].Status = models.BatchItemInvalid
+				results[i].Error = err.Error()
+			default:
+				if ctx.Err() != nil {
+					return nil, ctx.Err()
+				}
+				results[i].Status = models.BatchItemFailed
+				results[i].Error = err.Error()
+			}
+		}
+	}
+
+	isCreated := make(map[string]bool, len(created))
+	for _, uid := range created {
+		isCreated[uid] = true
+	}
+	var createdOrders []models.Order
+	for j, order := range valid {
+		i := validIdx[j]
+		if isCreated[order.OrderUID] {
+			results[i].Status = models.BatchItemCreated
+			createdOrders = append(createdOrders, order)
+		} else if results[i].Status == "" {
+			results[i].Status = models.BatchItemDuplicate
+		}
+	}
+
+	for _, order := range createdOrders {
+		s.cache.Set(order)
+	}
+	// A failed publication is logged and left to the consistency check.
+	_ = s.publishOrders(ctx, createdOrders)
+	for _, order := range createdOrders {
+		s.events.Publish(models.OrderEvent{Type: models.EventOrderCreated, OrderUID: order.OrderUID, Order: order})
+	}
+
+	s.log.Info("batch create finished", "requested", len(orders), "created", len(createdOrders))
+	return results, nil
+}
+
+// BatchGet returns the orders with the given UIDs in request order, serving
+// what it can from the cache, along with the UIDs that were not found and
+// those of orders whose stored data is incomplete.
+func (s *OrderService) BatchGet(ctx context.Context, orderUIDs []string) (orders []models.Order, missing, incomplete []string, err error) {
+	found := make(map[string]models.Order, len(orderUIDs))
+	var misses []string
+	for _, uid := range orderUIDs {
+		if _, ok := found[uid]; ok {
+			continue
+		}
+		if order, ok := s.cache.Get(uid); ok {
+			found[uid] = order
+			continue
+		}
+		misses = append(misses, uid)
+	}
+
+	if len(misses) > 0 {
+		var fetched []models.Order
+		fetched, incomplete, err = s.db.GetInfoBatch(ctx, misses)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		for _, order := range fetched {
+			s.cache.Set(order)
+			found[order.OrderUID] = order
+		}
+	}
+
+	broken := make(map[string]bool, len(incomplete))
+	for _, uid := range incomplete {
+		broken[uid] = true
+	}
+	orders = make([]models.Order, 0, len(found))
+	emitted := make(map[string]bool, len(orderUIDs))
+	for _, uid := range orderUIDs {
+		if emitted[uid] {
+			continue
+		}
+		emitted[uid] = true
+		if order, ok := found[uid]; ok {
+			orders = append(orders, order)
+		} else if !broken[uid] {
+			missing = append(missing, uid)
+		}
+	}
+	s.log.Debug("batch get finished", "requested", len(orderUIDs), "found", len(orders), "from_db", len(misses),
+		"incomplete", len(incomplete))
+	return orders, missing, incomplete, nil
+}
+
+func (s *OrderService) publishOrders(ctx context.Context, orders []models.Order) error {
+	if len(orders) == 0 {
+		return nil
+	}
+	messages := make([]ports.KafkaMessage, 0, len(orders))
+	uids := make([]string, 0, len(orders))
+	for _, order := range orders {
+		orderJSON, err := json.Marshal(order)
+		if err != nil {
+			s.log.Error("failed to marshal order to JSON for Kafka", "orderUID", order.OrderUID, "error", err)
+			continue
+		}
+		messages = append(messages, ports.KafkaMessage{Key: order.OrderUID, Value: orderJSON})
+		uids = append(uids, order.OrderUID)
+	}
+	if err := s.producer.PublishBatch(ctx, messages); err != nil {
+		s.log.Error("failed to publish orders to Kafka", "count", len(messages), "error", err)
+		return err
+	}
+	s.log.Info("orders published to Kafka", "count", len(messages))
+	s.markPublished(ctx, uids)
+	return nil
+}
+
+// markPublished records a publication. A failure only makes the orders look
+// unpublished to the consistency check, so it is logged and not returned.
+func (s *OrderService) markPublished(ctx context.Context, orderUIDs []string) {
+	if err := s.publications.MarkPublished(ctx, orderUIDs); err != nil {
+		s.log.Error("failed to record publication of orders", "count", len(orderUIDs), "error", err)
+	}
+}
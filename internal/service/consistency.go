@@ -0,0 +1,117 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"firstmod/internal/models"
+	"time"
+)
+
+const (
+	consistencyPageSize      = 500
+	defaultConsistencySample = 100
+)
+
+// CheckConsistency compares the cache with the database and finds the orders
+// that were never published to Kafka, repairing what opts asks for. The
+// database is read page by page while orders keep changing, so an order
+// changed during the check may show up as a difference.
+func (s *OrderService) CheckConsistency(ctx context.Context, opts models.ConsistencyOptions) (models.ConsistencyReport, error) {
+	if opts.Sample <= 0 {
+		opts.Sample = defaultConsistencySample
+	}
+	report := models.ConsistencyReport{CheckedAt: time.Now().UTC()}
+
+	inDB := make(map[string]bool)
+	for last := ""; ; {
+		uids, err := s.db.GetIDsPage(ctx, models.OrderFilter{}, last, consistencyPageSize)
+		if err != nil {
+			return report, err
+		}
+		if len(uids) == 0 {
+			break
+		}
+		orders, incomplete, err := s.db.GetInfoBatch(ctx, uids)
+		if err != nil {
+			return report, err
+		}
+		for _, uid := range incomplete {
+			inDB[uid] = true
+			report.DBOrders++
+			report.Incomplete.Add(uid, opts.Sample)
+		}
+		for _, order := range orders {
+			inDB[order.OrderUID] = true
+			report.DBOrders++
+			cached, ok := s.cache.Get(order.OrderUID)
+			switch {
+			case !ok:
+				report.MissingInCache.Add(order.OrderUID, opts.Sample)
+			case cached.Version != order.Version:
+				report.StaleInCache.Add(order.OrderUID, opts.Sample)
+			default:
+				continue
+			}
+			// A newer cached version is kept: the database read may have been
+			// served by a lagging replica.
+			if opts.RepairCache && s.cache.Set(order) {
+				report.CacheRepaired++
+			}
+		}
+		if len(uids) < consistencyPageSize {
+			break
+		}
+		last = uids[len(uids)-1]
+	}
+
+	for _, uid := range s.cache.GetAllUIDs() {
+		report.CachedOrders++
+		if inDB[uid] {
+			continue
+		}
+		// The order may have been created after the scan passed its UID.
+		if _, err := s.db.GetInfo(ctx, uid); !errors.Is(err, sql.ErrNoRows) {
+			if err != nil {
+				return report, err
+			}
+			continue
+		}
+		report.ExtraInCache.Add(uid, opts.Sample)
+		if opts.RepairCache {
+			s.cache.Delete(uid, 0)
+			report.CacheRepaired++
+		}
+	}
+
+	for last := ""; ; {
+		uids, err := s.publications.ListUnpublished(ctx, last, consistencyPageSize)
+		if err != nil {
+			return report, err
+		}
+		for _, uid := range uids {
+			report.Unpublished.Add(uid, opts.Sample)
+		}
+		if opts.Republish && len(uids) > 0 {
+			// Incomplete orders are already reported by the scan above.
+			orders, _, err := s.db.GetInfoBatch(ctx, uids)
+			if err != nil {
+				return report, err
+			}
+			if err := s.publishOrders(ctx, orders); err != nil {
+				return report, err
+			}
+			report.Republished += len(orders)
+		}
+		if len(uids) < consistencyPageSize {
+			break
+		}
+		last = uids[len(uids)-1]
+	}
+
+	s.log.Info("consistency check finished", "db_orders", report.DBOrders, "cached_orders", report.CachedOrders,
+		"missing_in_cache", report.MissingInCache.Count, "stale_in_cache", report.StaleInCache.Count,
+		"extra_in_cache", report.ExtraInCache.Count, "incomplete", report.Incomplete.Count, "unpublished", report.Unpublished.Count,
+		"cache_repaired", report.CacheRepaired, "republished", report.Republished)
+	return report, nil
+}
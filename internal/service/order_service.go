@@ -2,29 +2,51 @@ package service
 
 import (
 	"context"
+	"database/sql"
+	"encoding/base64"
 	"encoding/json"
 	"firstmod/internal/models"
 	"firstmod/internal/ports"
+	"fmt"
 	"log/slog"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 500
+	exportPageSize  = 500
 )
 
 type OrderService struct {
-	db       ports.Repository
-	cache    ports.CacheRepository
-	producer ports.KafkaProducer
-	log      *slog.Logger
+	db           ports.Repository
+	cache        ports.CacheRepository
+	producer     ports.KafkaProducer
+	events       ports.EventBus
+	publications ports.PublicationRepository
+	log          *slog.Logger
 }
 
-func NewOrderService(db ports.Repository, cache ports.CacheRepository, log *slog.Logger, producer ports.KafkaProducer) *OrderService {
+func NewOrderService(db ports.Repository, cache ports.CacheRepository, log *slog.Logger, producer ports.KafkaProducer, events ports.EventBus, publications ports.PublicationRepository) *OrderService {
 	return &OrderService{
-		db:       db,
-		cache:    cache,
-		log:      log,
-		producer: producer,
+		db:           db,
+		cache:        cache,
+		log:          log,
+		producer:     producer,
+		events:       events,
+		publications: publications,
 	}
 }
 
 func (s *OrderService) Add(ctx context.Context, order models.Order) error {
+	if err := validateOrder(order); err != nil {
+		s.log.Debug("order rejected by validation", "orderUID", order.OrderUID, "error", err)
+		return err
+	}
+	order.Version = models.FirstVersion
+	initLifecycle(&order, time.Now().UTC())
 	err := s.db.Add(ctx, order)
 	if err != nil {
 		return err
@@ -41,12 +63,33 @@ func (s *OrderService) Add(ctx context.Context, order models.Order) error {
 			s.log.Error("failed to publish order to Kafka", "orderUID", order.OrderUID, "error", publishErr)
 		} else {
 			s.log.Info("order published to Kafka", "orderUID", order.OrderUID)
+			s.markPublished(ctx, []string{order.OrderUID})
 		}
 	}
 
+	s.events.Publish(models.OrderEvent{Type: models.EventOrderCreated, OrderUID: order.OrderUID, Order: order})
 	return nil
 }
 
+// Update replaces the order and returns it with its new version. If
+// expectedVersion is not zero, the update fails with models.ErrVersionMismatch
+// unless it matches the current version of the order.
+func (s *OrderService) Update(ctx context.Context, order models.Order, expectedVersion int64) (models.Order, error) {
+	if err := validateOrder(order); err != nil {
+		s.log.Debug("order update rejected by validation", "orderUID", order.OrderUID, "error", err)
+		return models.Order{}, err
+	}
+	order, err := s.db.Update(ctx, order, expectedVersion)
+	if err != nil {
+		return models.Order{}, err
+	}
+	s.cache.Set(order)
+	s.log.Debug("order updated in DB and cache", "orderUID", order.OrderUID, "version", order.Version)
+
+	s.events.Publish(models.OrderEvent{Type: models.EventOrderUpdated, OrderUID: order.OrderUID, Order: order})
+	return order, nil
+}
+
 func (s *OrderService) GetOrder(ctx context.Context, orderUID string) (models.Order, error) {
 	if order, found := s.cache.Get(orderUID); found {
 		s.log.Debug("order retrieved from cache", "orderUID", orderUID)
@@ -64,16 +107,55 @@ func (s *OrderService) GetOrder(ctx context.Context, orderUID string) (models.Or
 	return order, nil
 }
 
-func (s *OrderService) Delete(ctx context.Context, orderUID string) error {
-	err := s.db.Delete(ctx, orderUID)
+// Delete soft-deletes the order. If expectedVersion is not zero, the deletion
+// fails with models.ErrVersionMismatch unless it matches the current version.
+func (s *OrderService) Delete(ctx context.Context, orderUID, reason string, expectedVersion int64) error {
+	// Stream and webhook subscribers filter on the order fields, so the event
+	// carries the order as it was deleted.
+	deleted, err := s.db.Delete(ctx, orderUID, reason, expectedVersion)
 	if err != nil {
 		return err
 	}
-	s.cache.Delete(orderUID)
+	s.cache.Delete(orderUID, deleted.Version)
 	s.log.Debug("order successfully deleted from DB and Cache", "orderUID", orderUID)
+
+	s.events.Publish(models.OrderEvent{Type: models.EventOrderDeleted, OrderUID: orderUID, Order: deleted})
+	return nil
+}
+
+func (s *OrderService) Restore(ctx context.Context, orderUID string) error {
+	if err := s.db.Restore(ctx, orderUID); err != nil {
+		return err
+	}
+	order, err := s.db.GetInfo(ctx, orderUID)
+	if err != nil {
+		s.log.Error("failed to load restored order", "orderUID", orderUID, "error", err)
+		return err
+	}
+	s.cache.Set(order)
+	s.log.Debug("restored order added back to cache", "orderUID", orderUID)
+
+	s.events.Publish(models.OrderEvent{Type: models.EventOrderRestored, OrderUID: orderUID, Order: order})
 	return nil
 }
 
+func (s *OrderService) ListDeleted(ctx context.Context, limit int) ([]models.Deletion, error) {
+	return s.db.ListDeleted(ctx, limit)
+}
+
+// History returns the audit trail of the order. It returns sql.ErrNoRows if
+// the order has never existed.
+func (s *OrderService) History(ctx context.Context, orderUID string) ([]models.AuditEntry, error) {
+	entries, err := s.db.History(ctx, orderUID)
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, sql.ErrNoRows
+	}
+	return entries, nil
+}
+
 func (s *OrderService) GetOrderIDs(ctx context.Context) ([]string, error) {
 	uids := s.cache.GetAllUIDs()
 	if len(uids) == 0 {
@@ -88,6 +170,82 @@ func (s *OrderService) GetOrderIDs(ctx context.Context) ([]string, error) {
 	return uids, nil
 }
 
+// ListOrders returns a page of orders matching filter, ordered by UID. The
+// returned token is empty when there are no more pages.
+func (s *OrderService) ListOrders(ctx context.Context, filter models.OrderFilter, pageToken string, pageSize int) ([]models.Order, string, error) {
+	if pageSize <= 0 {
+		pageSize = defaultPageSize
+	}
+	if pageSize > maxPageSize {
+		pageSize = maxPageSize
+	}
+	after, err := base64.RawURLEncoding.DecodeString(pageToken)
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: %s", models.ErrInvalidPageToken, pageToken)
+	}
+
+	uids, err := s.db.GetIDsPage(ctx, filter, string(after), pageSize)
+	if err != nil {
+		return nil, "", err
+	}
+	orders := make([]models.Order, 0, len(uids))
+	for _, uid := range uids {
+		order, err := s.GetOrder(ctx, uid)
+		if err != nil {
+			s.log.Warn("failed to get order while listing", "orderUID", uid, "error", err)
+			continue
+		}
+		orders = append(orders, order)
+	}
+
+	nextToken := ""
+	if len(uids) == pageSize {
+		nextToken = base64.RawURLEncoding.EncodeToString([]byte(uids[len(uids)-1]))
+	}
+	s.log.Debug("listed orders page", "count", len(orders), "has_more", nextToken != "")
+	return orders, nextToken, nil
+}
+
+// ExportOrders calls fn for every order matching filter, ordered by UID. The
+// orders are read from the database page by page, bypassing the cache, so
+// the export does not hold all of them in memory.
+func (s *OrderService) ExportOrders(ctx context.Context, filter models.OrderFilter, fn func(models.Order) error) error {
+	exported := 0
+	for last := ""; ; {
+		uids, err := s.db.GetIDsPage(ctx, filter, last, exportPageSize)
+		if err != nil {
+			return err
+		}
+		if len(uids) == 0 {
+			break
+		}
+		orders, incomplete, err := s.db.GetInfoBatch(ctx, uids)
+		if err != nil {
+			return err
+		}
+		if len(incomplete) > 0 {
+			s.log.Warn("incomplete orders left out of export", "order_uids", incomplete)
+		}
+		slices.SortFunc(orders, func(a, b models.Order) int { return strings.Compare(a.OrderUID, b.OrderUID) })
+		for _, order := range orders {
+			if err := fn(order); err != nil {
+				return err
+			}
+			exported++
+		}
+		if len(uids) < exportPageSize {
+			break
+		}
+		last = uids[len(uids)-1]
+	}
+	s.log.Info("orders exported", "count", exported)
+	return nil
+}
+
+func (s *OrderService) Subscribe(ctx context.Context, afterID uint64) <-chan models.OrderEvent {
+	return s.events.Subscribe(ctx, afterID)
+}
+
 func (s *OrderService) LoadCacheFromDB(ctx context.Context) error {
 	return s.cache.LoadToCacheFromDB(ctx, s.db)
 }
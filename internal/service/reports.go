@@ -0,0 +1,59 @@
+package service
+
+import (
+	"context"
+	"firstmod/internal/models"
+	"fmt"
+)
+
+const (
+	defaultTopItems = 10
+	maxTopItems     = 100
+)
+
+func (s *OrderService) SalesReport(ctx context.Context, granularity models.Granularity, filter models.ReportFilter) ([]models.SalesPoint, error) {
+	switch granularity {
+	case "":
+		granularity = models.GranularityDay
+	case models.GranularityDay, models.GranularityWeek, models.GranularityMonth:
+	default:
+		return nil, fmt.Errorf("%w: unknown granularity %q", models.ErrInvalidReport, granularity)
+	}
+	if err := validateReportFilter(filter); err != nil {
+		return nil, err
+	}
+	return s.db.SalesReport(ctx, granularity, filter)
+}
+
+func (s *OrderService) Breakdown(ctx context.Context, dimension models.Dimension, filter models.ReportFilter) ([]models.BreakdownRow, error) {
+	switch dimension {
+	case models.DimensionDeliveryService, models.DimensionProvider, models.DimensionBank,
+		models.DimensionCurrency, models.DimensionRegion, models.DimensionBrand:
+	default:
+		return nil, fmt.Errorf("%w: unknown dimension %q", models.ErrInvalidReport, dimension)
+	}
+	if err := validateReportFilter(filter); err != nil {
+		return nil, err
+	}
+	return s.db.Breakdown(ctx, dimension, filter)
+}
+
+func (s *OrderService) TopItems(ctx context.Context, filter models.ReportFilter, limit int) ([]models.TopItem, error) {
+	if limit <= 0 {
+		limit = defaultTopItems
+	}
+	if limit > maxTopItems {
+		limit = maxTopItems
+	}
+	if err := validateReportFilter(filter); err != nil {
+		return nil, err
+	}
+	return s.db.TopItems(ctx, filter, limit)
+}
+
+func validateReportFilter(filter models.ReportFilter) error {
+	if !filter.From.IsZero() && !filter.To.IsZero() && !filter.From.Before(filter.To) {
+		return fmt.Errorf("%w: from must be before to", models.ErrInvalidReport)
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"firstmod/internal/models"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const maxSearchQueryLength = 200
+
+// Search returns a page of orders matching query, best matches first. The
+// returned token is empty when there are no more pages.
+func (s *OrderService) Search(ctx context.Context, query, pageToken string, pageSize int) ([]models.OrderSummary, string, error) {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return nil, "", fmt.Errorf("%w: search query is required", models.ErrInvalidSearch)
+	}
+	if len(query) > maxSearchQueryLength {
+		return nil, "", fmt.Errorf("%w: search query is longer than %d bytes", models.ErrInvalidSearch, maxSearchQueryLength)
+	}
+	if pageSize <= 0 {
+		pageSize = defaultPageSize
+	}
+	if pageSize > maxPageSize {
+		pageSize = maxPageSize
+	}
+
+	offset := 0
+	if pageToken != "" {
+		raw, err := base64.RawURLEncoding.DecodeString(pageToken)
+		if err != nil {
+			return nil, "", fmt.Errorf("%w: %s", models.ErrInvalidPageToken, pageToken)
+		}
+		offset, err = strconv.Atoi(string(raw))
+		if err != nil || offset < 0 {
+			return nil, "", fmt.Errorf("%w: %s", models.ErrInvalidPageToken, pageToken)
+		}
+	}
+
+	summaries, err := s.db.Search(ctx, query, pageSize, offset)
+	if err != nil {
+		return nil, "", err
+	}
+
+	nextToken := ""
+	if len(summaries) == pageSize {
+		nextToken = base64.RawURLEncoding.EncodeToString([]byte(strconv.Itoa(offset + pageSize)))
+	}
+	s.log.Debug("searched orders", "query", query, "count", len(summaries), "has_more", nextToken != "")
+	return summaries, nextToken, nil
+}
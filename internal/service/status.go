@@ -0,0 +1,106 @@
+package service
+
+import (
+	"context"
+	"firstmod/internal/actor"
+	"firstmod/internal/models"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// initLifecycle puts a new order and its items into the created stage.
+func initLifecycle(order *models.Order, now time.Time) {
+	order.Status = models.StatusCreated
+	order.StatusChangedAt = now
+	order.Items = slices.Clone(order.Items)
+	for i := range order.Items {
+		order.Items[i].State = models.StatusCreated
+	}
+}
+
+// Transition moves the order, or only the requested items, to another
+// lifecycle stage. Moving the order also moves every item that can follow
+// it; items that were cancelled or returned on their own stay as they are.
+// If all items end up cancelled or returned, the order follows them: to the
+// requested status if it can, otherwise to the final status of its items.
+// The order is checked and changed while the repository holds it locked, so
+// the check never sees a stale copy.
+func (s *OrderService) Transition(ctx context.Context, orderUID string, req models.TransitionRequest, expectedVersion int64) (models.Order, error) {
+	if !req.Status.Valid() {
+		return models.Order{}, fmt.Errorf("%w: unknown status %q", models.ErrInvalidTransition, req.Status)
+	}
+	now := time.Now().UTC()
+	who := actor.From(ctx)
+
+	order, err := s.db.SetStatus(ctx, orderUID, expectedVersion, func(order *models.Order) ([]models.StatusTransition, error) {
+		var changes []models.StatusTransition
+		record := func(chrtID *int64, from, to models.Status) {
+			changes = append(changes, models.StatusTransition{
+				OrderUID: orderUID, ChrtID: chrtID, From: from, To: to,
+				Actor: who, Reason: req.Reason, ChangedAt: now,
+			})
+		}
+		moveItem := func(i int) {
+			item := &order.Items[i]
+			chrtID := item.ChrtID
+			record(&chrtID, item.State, req.Status)
+			item.State = req.Status
+		}
+
+		if len(req.ChrtIDs) == 0 {
+			if !order.Status.CanTransition(req.Status) {
+				return nil, fmt.Errorf("%w: order cannot move from %s to %s", models.ErrInvalidTransition, order.Status, req.Status)
+			}
+			record(nil, order.Status, req.Status)
+			order.Status = req.Status
+			order.StatusChangedAt = now
+			for i, item := range order.Items {
+				if item.State.CanTransition(req.Status) {
+					moveItem(i)
+				}
+			}
+			return changes, nil
+		}
+		for _, chrtID := range req.ChrtIDs {
+			i := slices.IndexFunc(order.Items, func(item models.Item) bool { return item.ChrtID == chrtID })
+			if i < 0 {
+				return nil, fmt.Errorf("%w: order has no item %d", models.ErrInvalidTransition, chrtID)
+			}
+			if !order.Items[i].State.CanTransition(req.Status) {
+				return nil, fmt.Errorf("%w: item %d cannot move from %s to %s", models.ErrInvalidTransition, chrtID, order.Items[i].State, req.Status)
+			}
+			moveItem(i)
+		}
+		allFinal := !slices.ContainsFunc(order.Items, func(item models.Item) bool { return !item.State.Final() })
+		if allFinal {
+			for _, to := range []models.Status{req.Status, models.StatusReturned, models.StatusCancelled} {
+				hasItem := slices.ContainsFunc(order.Items, func(item models.Item) bool { return item.State == to })
+				if hasItem && order.Status.CanTransition(to) {
+					record(nil, order.Status, to)
+					order.Status = to
+					order.StatusChangedAt = now
+					break
+				}
+			}
+		}
+		return changes, nil
+	})
+	if err != nil {
+		return models.Order{}, err
+	}
+	s.cache.Set(order)
+	s.log.Debug("order status changed in DB and cache", "orderUID", orderUID, "status", order.Status, "version", order.Version)
+
+	s.events.Publish(models.OrderEvent{Type: models.EventOrderStatusChanged, OrderUID: orderUID, Order: order})
+	return order, nil
+}
+
+// Transitions returns the status transitions of the order. It returns
+// sql.ErrNoRows if the order does not exist.
+func (s *OrderService) Transitions(ctx context.Context, orderUID string) ([]models.StatusTransition, error) {
+	if _, err := s.GetOrder(ctx, orderUID); err != nil {
+		return nil, err
+	}
+	return s.db.ListTransitions(ctx, orderUID)
+}
@@ -0,0 +1,29 @@
+package service
+
+import (
+	"firstmod/internal/models"
+	"fmt"
+)
+
+func validateOrder(order models.Order) error {
+	switch {
+	case order.OrderUID == "":
+		return fmt.Errorf("%w: order_uid is required", models.ErrInvalidOrder)
+	case order.TrackNumber == "":
+		return fmt.Errorf("%w: track_number is required", models.ErrInvalidOrder)
+	case order.CustomerID == "":
+		return fmt.Errorf("%w: customer_id is required", models.ErrInvalidOrder)
+	case order.Payment.Transaction == "":
+		return fmt.Errorf("%w: payment transaction is required", models.ErrInvalidOrder)
+	case order.DateCreated.IsZero():
+		return fmt.Errorf("%w: date_created is required", models.ErrInvalidOrder)
+	case order.Payment.Amount < 0:
+		return fmt.Errorf("%w: payment amount must not be negative", models.ErrInvalidOrder)
+	}
+	for i, item := range order.Items {
+		if item.Price < 0 || item.TotalPrice < 0 {
+			return fmt.Errorf("%w: item %d has negative price", models.ErrInvalidOrder, i)
+		}
+	}
+	return nil
+}
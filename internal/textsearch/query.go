@@ -0,0 +1,108 @@
+// Package textsearch matches search queries against plain text for storages
+// without full-text search of their own.
+package textsearch
+
+import (
+	"html"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// Query is a parsed search query in the spirit of websearch_to_tsquery: words
+// match case-insensitively anywhere in the text, "quoted phrases" are kept
+// together and words prefixed with "-" exclude the texts containing them.
+type Query struct {
+	Terms    []string
+	Excluded []string
+	pattern  *regexp.Regexp
+}
+
+// Parse parses query. A query without terms matches nothing.
+func Parse(query string) Query {
+	var q Query
+	for _, word := range split(query) {
+		word = strings.ToLower(word)
+		switch {
+		case word == "or":
+		case strings.HasPrefix(word, "-") && len(word) > 1:
+			q.Excluded = append(q.Excluded, word[1:])
+		case strings.Trim(word, "-") != "":
+			q.Terms = append(q.Terms, word)
+		}
+	}
+	if len(q.Terms) > 0 {
+		quoted := make([]string, len(q.Terms))
+		for i, term := range q.Terms {
+			quoted[i] = regexp.QuoteMeta(term)
+		}
+		q.pattern = regexp.MustCompile("(?i)" + strings.Join(quoted, "|"))
+	}
+	return q
+}
+
+// split splits query into words, keeping quoted phrases as single words.
+func split(query string) []string {
+	var words []string
+	var word strings.Builder
+	quoted := false
+	flush := func() {
+		if word.Len() > 0 {
+			words = append(words, word.String())
+			word.Reset()
+		}
+	}
+	for _, r := range query {
+		switch {
+		case r == '"':
+			flush()
+			quoted = !quoted
+		case unicode.IsSpace(r) && !quoted:
+			flush()
+		default:
+			word.WriteRune(r)
+		}
+	}
+	flush()
+	return words
+}
+
+// Rank returns the share of the terms found in fields, from 0 for no match
+// to 1 when every term is found. Fields containing an excluded word rank 0.
+func (q Query) Rank(fields ...string) float64 {
+	if len(q.Terms) == 0 {
+		return 0
+	}
+	text := strings.ToLower(strings.Join(fields, "\n"))
+	for _, word := range q.Excluded {
+		if strings.Contains(text, word) {
+			return 0
+		}
+	}
+	found := 0
+	for _, term := range q.Terms {
+		if strings.Contains(text, term) {
+			found++
+		}
+	}
+	return float64(found) / float64(len(q.Terms))
+}
+
+// Highlight HTML-escapes text and wraps the occurrences of the terms in it
+// in <mark> tags.
+func (q Query) Highlight(text string) string {
+	if q.pattern == nil {
+		return html.EscapeString(text)
+	}
+	var b strings.Builder
+	last := 0
+	for _, match := range q.pattern.FindAllStringIndex(text, -1) {
+		b.WriteString(html.EscapeString(text[last:match[0]]))
+		b.WriteString("<mark>")
+		b.WriteString(html.EscapeString(text[match[0]:match[1]]))
+		b.WriteString("</mark>")
+		last = match[1]
+	}
+	b.WriteString(html.EscapeString(text[last:]))
+	return b.String()
+}
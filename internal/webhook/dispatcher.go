@@ -0,0 +1,268 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"firstmod/internal/models"
+	"firstmod/internal/ports"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	SignatureHeader = "X-Webhook-Signature"
+	TimestampHeader = "X-Webhook-Timestamp"
+	EventHeader     = "X-Webhook-Event"
+	EventIDHeader   = "X-Webhook-Event-ID"
+)
+
+// queueSize is the number of events a single subscription may have waiting
+// for delivery; further events for it are dropped until the queue drains.
+const queueSize = 256
+
+type Config struct {
+	// MaxAttempts is the number of times a single event is sent before giving up.
+	MaxAttempts int
+	// InitialBackoff is the delay before the first retry; it doubles on every
+	// following retry.
+	InitialBackoff time.Duration
+	// DisableAfter is the number of events in a row that may fail all their
+	// attempts before the subscription is disabled.
+	DisableAfter int
+	Timeout      time.Duration
+	// Concurrency is the number of subscriptions delivered to at once. Events
+	// for one subscription are always delivered one at a time, in order.
+	Concurrency int
+}
+
+type job struct {
+	sub   models.WebhookSubscription
+	event models.OrderEvent
+	body  []byte
+}
+
+// Dispatcher delivers order events from the event bus to webhook subscribers.
+type Dispatcher struct {
+	log    *slog.Logger
+	store  ports.WebhookRepository
+	events ports.EventBus
+	client *http.Client
+	cfg    Config
+	sem    chan struct{}
+	wg     sync.WaitGroup
+
+	mu     sync.Mutex
+	queues map[int64]chan job
+}
+
+func NewDispatcher(log *slog.Logger, store ports.WebhookRepository, events ports.EventBus, cfg Config) *Dispatcher {
+	if cfg.MaxAttempts < 1 {
+		cfg.MaxAttempts = 1
+	}
+	if cfg.Concurrency < 1 {
+		cfg.Concurrency = 1
+	}
+	return &Dispatcher{
+		log:    log,
+		store:  store,
+		events: events,
+		client: &http.Client{Timeout: cfg.Timeout},
+		cfg:    cfg,
+		sem:    make(chan struct{}, cfg.Concurrency),
+		queues: make(map[int64]chan job),
+	}
+}
+
+// Run delivers events until ctx is cancelled, then waits for deliveries in
+// progress to stop.
+func (d *Dispatcher) Run(ctx context.Context) {
+	d.log.Info("webhook dispatcher started")
+	defer d.wg.Wait()
+
+	var lastID uint64
+	for ctx.Err() == nil {
+		events := d.events.Subscribe(ctx, lastID)
+		for event := range events {
+			lastID = event.ID
+			d.dispatch(ctx, event)
+		}
+		if ctx.Err() == nil {
+			d.log.Warn("webhook dispatcher fell behind the event stream, resubscribing", "last_event_id", lastID)
+		}
+	}
+	d.log.Info("webhook dispatcher stopped")
+}
+
+func (d *Dispatcher) dispatch(ctx context.Context, event models.OrderEvent) {
+	subs, err := d.store.ListWebhooks(ctx, true)
+	if err != nil {
+		d.log.Error("failed to load webhook subscriptions", "event_id", event.ID, "error", err)
+		return
+	}
+	body, err := json.Marshal(event)
+	if err != nil {
+		d.log.Error("failed to marshal order event for webhooks", "event_id", event.ID, "error", err)
+		return
+	}
+	for _, sub := range subs {
+		if sub.Accepts(event.Type) {
+			d.enqueue(ctx, job{sub: sub, event: event, body: body})
+		}
+	}
+}
+
+// enqueue hands the job to the worker of its subscription, starting one if
+// needed. It never blocks, so a slow subscriber cannot hold up the event bus.
+func (d *Dispatcher) enqueue(ctx context.Context, j job) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	queue, ok := d.queues[j.sub.ID]
+	if !ok {
+		queue = make(chan job, queueSize)
+		d.queues[j.sub.ID] = queue
+		d.wg.Add(1)
+		go d.work(ctx, j.sub.ID, queue)
+	}
+	select {
+	case queue <- j:
+	default:
+		d.log.Warn("webhook delivery queue is full, dropping event", "webhook_id", j.sub.ID, "event_id", j.event.ID)
+	}
+}
+
+// work delivers the queued events of one subscription in order and exits
+// once the queue is empty.
+func (d *Dispatcher) work(ctx context.Context, id int64, queue chan job) {
+	defer d.wg.Done()
+	for {
+		d.mu.Lock()
+		var j job
+		select {
+		case j = <-queue:
+		default:
+			delete(d.queues, id)
+		}
+		d.mu.Unlock()
+		if j.body == nil {
+			return
+		}
+
+		select {
+		case d.sem <- struct{}{}:
+		case <-ctx.Done():
+			d.mu.Lock()
+			delete(d.queues, id)
+			d.mu.Unlock()
+			return
+		}
+		d.deliver(ctx, j.sub, j.event, j.body)
+		<-d.sem
+	}
+}
+
+func (d *Dispatcher) deliver(ctx context.Context, sub models.WebhookSubscription, event models.OrderEvent, body []byte) {
+	// Bookkeeping must survive shutdown so the last attempt is not lost.
+	storeCtx := context.WithoutCancel(ctx)
+
+	backoff := d.cfg.InitialBackoff
+	success := false
+	for attempt := 1; attempt <= d.cfg.MaxAttempts; attempt++ {
+		start := time.Now()
+		statusCode, err := d.send(ctx, sub, event, body)
+		delivery := models.WebhookDelivery{
+			SubscriptionID: sub.ID,
+			EventID:        event.ID,
+			EventType:      event.Type,
+			OrderUID:       event.OrderUID,
+			Attempt:        attempt,
+			StatusCode:     statusCode,
+			Success:        err == nil,
+			Duration:       time.Since(start),
+		}
+		if err != nil {
+			delivery.Error = err.Error()
+		}
+		if storeErr := d.store.AddWebhookDelivery(storeCtx, delivery); storeErr != nil {
+			d.log.Error("failed to record webhook delivery", "webhook_id", sub.ID, "event_id", event.ID, "error", storeErr)
+		}
+
+		if err == nil {
+			d.log.Debug("webhook delivered", "webhook_id", sub.ID, "event_id", event.ID, "attempt", attempt)
+			success = true
+			break
+		}
+		d.log.Warn("webhook delivery failed", "webhook_id", sub.ID, "event_id", event.ID, "attempt", attempt, "error", err)
+		if attempt == d.cfg.MaxAttempts {
+			break
+		}
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return
+		}
+		backoff *= 2
+	}
+
+	disabled, err := d.store.RecordWebhookResult(storeCtx, sub.ID, success, d.cfg.DisableAfter)
+	if err != nil {
+		d.log.Error("failed to record webhook result", "webhook_id", sub.ID, "error", err)
+		return
+	}
+	if disabled {
+		d.log.Warn("webhook subscription disabled after repeated failures", "webhook_id", sub.ID, "url", sub.URL)
+	}
+}
+
+func (d *Dispatcher) send(ctx context.Context, sub models.WebhookSubscription, event models.OrderEvent, body []byte) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, string(event.Type))
+	req.Header.Set(EventIDHeader, strconv.FormatUint(event.ID, 10))
+	req.Header.Set(TimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(SignatureHeader, Sign(sub.Secret, timestamp, body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// Sign returns the signature receivers use to verify a delivery: the hex
+// encoded HMAC-SHA256 of "<timestamp>.<body>" keyed with the subscription
+// secret, prefixed with "sha256=".
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.", timestamp)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func GenerateSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,31 @@
+CREATE TABLE IF NOT EXISTS webhook_subscriptions (
+    id                   BIGSERIAL PRIMARY KEY,
+    url                  TEXT NOT NULL,
+    secret               VARCHAR(255) NOT NULL,
+    event_types          TEXT[] NOT NULL DEFAULT '{}', -- пустой массив означает все типы событий
+    enabled              BOOLEAN NOT NULL DEFAULT TRUE,
+    consecutive_failures INT NOT NULL DEFAULT 0,
+    disabled_at          TIMESTAMP WITH TIME ZONE,
+    created_at           TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    id              BIGSERIAL PRIMARY KEY,
+    subscription_id BIGINT NOT NULL,
+    event_id        BIGINT NOT NULL,
+    event_type      VARCHAR(50) NOT NULL,
+    order_uid       VARCHAR(255) NOT NULL,
+    attempt         INT NOT NULL,
+    status_code     INT,
+    error           TEXT,
+    success         BOOLEAN NOT NULL,
+    duration_ms     BIGINT NOT NULL,
+    created_at      TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    CONSTRAINT fk_deliveries_subscription
+        FOREIGN KEY (subscription_id)
+        REFERENCES webhook_subscriptions (id)
+        ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_subscription
+    ON webhook_deliveries (subscription_id, created_at DESC);
@@ -0,0 +1,15 @@
+-- Возврат к старой схеме теряет настоящие идентификаторы транзакций:
+-- transaction_uid снова становится равным order_uid.
+ALTER TABLE payments DROP CONSTRAINT fk_payment_order;
+ALTER TABLE payments DROP CONSTRAINT uq_payments_transaction;
+ALTER TABLE payments DROP CONSTRAINT payments_pkey;
+
+UPDATE payments SET transaction_uid = order_uid;
+
+ALTER TABLE payments ADD CONSTRAINT payments_pkey PRIMARY KEY (transaction_uid);
+ALTER TABLE payments
+    ADD CONSTRAINT fk_payment_order
+        FOREIGN KEY (transaction_uid)
+        REFERENCES orders (order_uid)
+        ON DELETE CASCADE;
+ALTER TABLE payments DROP COLUMN order_uid;
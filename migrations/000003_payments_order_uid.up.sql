@@ -0,0 +1,17 @@
+-- Платёж ссылается на заказ через собственный order_uid, а transaction_uid
+-- хранит настоящий идентификатор транзакции.
+ALTER TABLE payments ADD COLUMN order_uid VARCHAR(255);
+
+-- До этой миграции transaction_uid был обязан совпадать с order_uid заказа.
+UPDATE payments SET order_uid = transaction_uid;
+
+ALTER TABLE payments ALTER COLUMN order_uid SET NOT NULL;
+ALTER TABLE payments DROP CONSTRAINT fk_payment_order;
+ALTER TABLE payments DROP CONSTRAINT payments_pkey;
+ALTER TABLE payments ADD CONSTRAINT payments_pkey PRIMARY KEY (order_uid);
+ALTER TABLE payments ADD CONSTRAINT uq_payments_transaction UNIQUE (transaction_uid);
+ALTER TABLE payments
+    ADD CONSTRAINT fk_payment_order
+        FOREIGN KEY (order_uid)
+        REFERENCES orders (order_uid)
+        ON DELETE CASCADE;
@@ -0,0 +1,8 @@
+DROP TABLE IF EXISTS archived_orders;
+DROP INDEX IF EXISTS idx_orders_deleted_at;
+-- Заказы, помеченные удалёнными, удаляются окончательно.
+DELETE FROM orders WHERE deleted_at IS NOT NULL;
+ALTER TABLE orders
+    DROP COLUMN delete_reason,
+    DROP COLUMN deleted_by,
+    DROP COLUMN deleted_at;
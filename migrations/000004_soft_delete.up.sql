@@ -0,0 +1,17 @@
+ALTER TABLE orders
+    ADD COLUMN deleted_at    TIMESTAMP WITH TIME ZONE,
+    ADD COLUMN deleted_by    VARCHAR(255),
+    ADD COLUMN delete_reason TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_orders_deleted_at ON orders (deleted_at) WHERE deleted_at IS NOT NULL;
+
+-- Архив хранит заказ целиком в виде JSON, чтобы не зависеть от дальнейших
+-- изменений схемы основных таблиц.
+CREATE TABLE IF NOT EXISTS archived_orders (
+    order_uid     VARCHAR(255) PRIMARY KEY,
+    data          JSONB NOT NULL,
+    deleted_at    TIMESTAMP WITH TIME ZONE NOT NULL,
+    deleted_by    VARCHAR(255),
+    delete_reason TEXT,
+    archived_at   TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS order_audit;
+DROP FUNCTION IF EXISTS order_audit_append_only();
@@ -0,0 +1,26 @@
+-- Журнал изменений заказов. Записи только добавляются; внешнего ключа на
+-- orders нет, чтобы история сохранялась после архивации заказа.
+CREATE TABLE IF NOT EXISTS order_audit (
+    id         BIGSERIAL PRIMARY KEY,
+    order_uid  VARCHAR(255) NOT NULL,
+    action     VARCHAR(50) NOT NULL,
+    actor      VARCHAR(255) NOT NULL,
+    source     VARCHAR(50) NOT NULL,
+    request_id VARCHAR(255),
+    before     JSONB,
+    after      JSONB,
+    diff       JSONB NOT NULL DEFAULT '{}',
+    changed_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_order_audit_order_uid ON order_audit (order_uid, id);
+
+CREATE OR REPLACE FUNCTION order_audit_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'order_audit is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER order_audit_append_only
+    BEFORE UPDATE OR DELETE ON order_audit
+    FOR EACH ROW EXECUTE FUNCTION order_audit_append_only();
@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS order_status_transitions;
+DROP INDEX IF EXISTS idx_orders_status;
+ALTER TABLE items DROP COLUMN IF EXISTS state;
+ALTER TABLE orders
+    DROP COLUMN IF EXISTS status,
+    DROP COLUMN IF EXISTS status_changed_at;
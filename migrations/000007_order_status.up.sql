@@ -0,0 +1,26 @@
+ALTER TABLE orders
+    ADD COLUMN status            VARCHAR(20) NOT NULL DEFAULT 'created',
+    ADD COLUMN status_changed_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW();
+
+ALTER TABLE items ADD COLUMN state VARCHAR(20) NOT NULL DEFAULT 'created';
+
+ALTER TABLE orders ADD CONSTRAINT chk_orders_status
+    CHECK (status IN ('created', 'paid', 'assembling', 'shipped', 'delivered', 'cancelled', 'returned'));
+ALTER TABLE items ADD CONSTRAINT chk_items_state
+    CHECK (state IN ('created', 'paid', 'assembling', 'shipped', 'delivered', 'cancelled', 'returned'));
+
+-- Переходы заказа (chrt_id IS NULL) и отдельных товаров между статусами.
+CREATE TABLE IF NOT EXISTS order_status_transitions (
+    id          BIGSERIAL PRIMARY KEY,
+    order_uid   VARCHAR(255) NOT NULL,
+    chrt_id     BIGINT,
+    from_status VARCHAR(20) NOT NULL,
+    to_status   VARCHAR(20) NOT NULL,
+    actor       VARCHAR(255) NOT NULL,
+    reason      TEXT,
+    changed_at  TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_order_status_transitions_order_uid
+    ON order_status_transitions (order_uid, id);
+CREATE INDEX IF NOT EXISTS idx_orders_status ON orders (status);
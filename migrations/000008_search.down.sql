@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS idx_items_brand_trgm;
+DROP INDEX IF EXISTS idx_items_name_trgm;
+DROP INDEX IF EXISTS idx_delivery_info_city_trgm;
+DROP INDEX IF EXISTS idx_delivery_info_email_trgm;
+DROP INDEX IF EXISTS idx_delivery_info_phone_trgm;
+DROP INDEX IF EXISTS idx_delivery_info_name_trgm;
+ALTER TABLE items DROP COLUMN IF EXISTS search_tsv;
+ALTER TABLE delivery_info DROP COLUMN IF EXISTS search_tsv;
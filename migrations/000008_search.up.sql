@@ -0,0 +1,23 @@
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+-- Конфигурация 'simple' не выполняет стемминг, зато одинаково работает для
+-- имён, телефонов и адресов на любом языке.
+ALTER TABLE delivery_info ADD COLUMN search_tsv tsvector GENERATED ALWAYS AS (
+    to_tsvector('simple',
+        coalesce(name, '') || ' ' || coalesce(phone, '') || ' ' || coalesce(email, '') || ' ' ||
+        coalesce(city, '') || ' ' || coalesce(address, '') || ' ' || coalesce(region, ''))
+) STORED;
+
+ALTER TABLE items ADD COLUMN search_tsv tsvector GENERATED ALWAYS AS (
+    to_tsvector('simple', coalesce(name, '') || ' ' || coalesce(brand, ''))
+) STORED;
+
+CREATE INDEX IF NOT EXISTS idx_delivery_info_search ON delivery_info USING GIN (search_tsv);
+CREATE INDEX IF NOT EXISTS idx_items_search ON items USING GIN (search_tsv);
+
+CREATE INDEX IF NOT EXISTS idx_delivery_info_name_trgm ON delivery_info USING GIN (name gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS idx_delivery_info_phone_trgm ON delivery_info USING GIN (phone gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS idx_delivery_info_email_trgm ON delivery_info USING GIN (email gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS idx_delivery_info_city_trgm ON delivery_info USING GIN (city gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS idx_items_name_trgm ON items USING GIN (name gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS idx_items_brand_trgm ON items USING GIN (brand gin_trgm_ops);
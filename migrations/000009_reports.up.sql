@@ -0,0 +1,2 @@
+CREATE INDEX IF NOT EXISTS idx_orders_date_created ON orders (date_created);
+CREATE INDEX IF NOT EXISTS idx_items_nm_id ON items (nm_id);
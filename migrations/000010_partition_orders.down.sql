@@ -0,0 +1,142 @@
+ALTER TABLE items RENAME TO items_partitioned;
+ALTER TABLE payments RENAME TO payments_partitioned;
+ALTER TABLE delivery_info RENAME TO delivery_info_partitioned;
+ALTER TABLE orders RENAME TO orders_partitioned;
+ALTER INDEX orders_pkey RENAME TO orders_partitioned_pkey;
+ALTER INDEX delivery_info_pkey RENAME TO delivery_info_partitioned_pkey;
+ALTER INDEX payments_pkey RENAME TO payments_partitioned_pkey;
+ALTER INDEX items_pkey RENAME TO items_partitioned_pkey;
+ALTER SEQUENCE items_id_seq RENAME TO items_partitioned_id_seq;
+DROP INDEX idx_orders_deleted_at, idx_orders_status, idx_orders_date_created, idx_items_order_uid,
+    idx_items_nm_id, idx_delivery_info_search, idx_items_search, idx_delivery_info_name_trgm,
+    idx_delivery_info_phone_trgm, idx_delivery_info_email_trgm, idx_delivery_info_city_trgm,
+    idx_items_name_trgm, idx_items_brand_trgm;
+
+CREATE TABLE orders (
+    order_uid          VARCHAR(255) PRIMARY KEY,
+    track_number       VARCHAR(255) NOT NULL,
+    entry              VARCHAR(255) NOT NULL,
+    locale             VARCHAR(10) NOT NULL,
+    internal_signature VARCHAR(255),
+    customer_id        VARCHAR(255) NOT NULL,
+    delivery_service   VARCHAR(255) NOT NULL,
+    shardkey           VARCHAR(255) NOT NULL,
+    sm_id              BIGINT NOT NULL,
+    date_created       TIMESTAMP WITH TIME ZONE NOT NULL,
+    oof_shard          VARCHAR(255) NOT NULL,
+    deleted_at         TIMESTAMP WITH TIME ZONE,
+    deleted_by         VARCHAR(255),
+    delete_reason      TEXT,
+    version            BIGINT NOT NULL DEFAULT 1,
+    status             VARCHAR(20) NOT NULL DEFAULT 'created',
+    status_changed_at  TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    CONSTRAINT chk_orders_status
+        CHECK (status IN ('created', 'paid', 'assembling', 'shipped', 'delivered', 'cancelled', 'returned'))
+);
+
+CREATE TABLE delivery_info (
+    order_uid  VARCHAR(255) PRIMARY KEY,
+    name       VARCHAR(255) NOT NULL,
+    phone      VARCHAR(255) NOT NULL,
+    zip        VARCHAR(20) NOT NULL,
+    city       VARCHAR(255) NOT NULL,
+    address    VARCHAR(255) NOT NULL,
+    region     VARCHAR(255) NOT NULL,
+    email      VARCHAR(255) NOT NULL,
+    search_tsv tsvector GENERATED ALWAYS AS (
+        to_tsvector('simple',
+            coalesce(name, '') || ' ' || coalesce(phone, '') || ' ' || coalesce(email, '') || ' ' ||
+            coalesce(city, '') || ' ' || coalesce(address, '') || ' ' || coalesce(region, ''))
+    ) STORED,
+    CONSTRAINT fk_delivery_order FOREIGN KEY (order_uid) REFERENCES orders (order_uid) ON DELETE CASCADE
+);
+
+CREATE TABLE payments (
+    order_uid       VARCHAR(255) PRIMARY KEY,
+    transaction_uid VARCHAR(255) NOT NULL,
+    request_id      BIGINT,
+    currency        VARCHAR(10) NOT NULL,
+    provider        VARCHAR(255) NOT NULL,
+    amount          INT NOT NULL,
+    payment_dt      BIGINT NOT NULL,
+    bank            VARCHAR(255) NOT NULL,
+    delivery_cost   INT NOT NULL,
+    goods_total     INT NOT NULL,
+    custom_fee      INT NOT NULL,
+    CONSTRAINT uq_payments_transaction UNIQUE (transaction_uid),
+    CONSTRAINT fk_payment_order FOREIGN KEY (order_uid) REFERENCES orders (order_uid) ON DELETE CASCADE
+);
+
+CREATE TABLE items (
+    id           SERIAL PRIMARY KEY,
+    order_uid    VARCHAR(255) NOT NULL,
+    chrt_id      BIGINT NOT NULL,
+    track_number VARCHAR(255) NOT NULL,
+    price        INT NOT NULL,
+    rid          VARCHAR(255) NOT NULL,
+    name         VARCHAR(255) NOT NULL,
+    sale         INT NOT NULL,
+    size         VARCHAR(50) NOT NULL,
+    total_price  INT NOT NULL,
+    nm_id        BIGINT NOT NULL,
+    brand        VARCHAR(255) NOT NULL,
+    status       INT NOT NULL,
+    state        VARCHAR(20) NOT NULL DEFAULT 'created',
+    search_tsv   tsvector GENERATED ALWAYS AS (
+        to_tsvector('simple', coalesce(name, '') || ' ' || coalesce(brand, ''))
+    ) STORED,
+    CONSTRAINT chk_items_state
+        CHECK (state IN ('created', 'paid', 'assembling', 'shipped', 'delivered', 'cancelled', 'returned')),
+    CONSTRAINT fk_items_order FOREIGN KEY (order_uid) REFERENCES orders (order_uid) ON DELETE CASCADE
+);
+
+INSERT INTO orders (
+    order_uid, track_number, entry, locale, internal_signature, customer_id, delivery_service,
+    shardkey, sm_id, date_created, oof_shard, deleted_at, deleted_by, delete_reason,
+    version, status, status_changed_at
+)
+SELECT
+    order_uid, track_number, entry, locale, internal_signature, customer_id, delivery_service,
+    shardkey, sm_id, date_created, oof_shard, deleted_at, deleted_by, delete_reason,
+    version, status, status_changed_at
+FROM orders_partitioned;
+
+INSERT INTO delivery_info (order_uid, name, phone, zip, city, address, region, email)
+SELECT order_uid, name, phone, zip, city, address, region, email FROM delivery_info_partitioned;
+
+INSERT INTO payments (
+    order_uid, transaction_uid, request_id, currency, provider, amount,
+    payment_dt, bank, delivery_cost, goods_total, custom_fee
+)
+SELECT
+    order_uid, transaction_uid, request_id, currency, provider, amount,
+    payment_dt, bank, delivery_cost, goods_total, custom_fee
+FROM payments_partitioned;
+
+INSERT INTO items (
+    id, order_uid, chrt_id, track_number, price, rid, name, sale, size,
+    total_price, nm_id, brand, status, state
+)
+SELECT
+    id, order_uid, chrt_id, track_number, price, rid, name, sale, size,
+    total_price, nm_id, brand, status, state
+FROM items_partitioned;
+
+SELECT setval('items_id_seq', COALESCE((SELECT MAX(id) FROM items), 0) + 1, false);
+
+DROP TABLE items_partitioned, payments_partitioned, delivery_info_partitioned, orders_partitioned;
+DROP TABLE order_keys;
+DROP FUNCTION create_order_partitions(DATE);
+
+CREATE INDEX idx_orders_deleted_at ON orders (deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX idx_orders_status ON orders (status);
+CREATE INDEX idx_orders_date_created ON orders (date_created);
+CREATE INDEX idx_items_nm_id ON items (nm_id);
+CREATE INDEX idx_delivery_info_search ON delivery_info USING GIN (search_tsv);
+CREATE INDEX idx_items_search ON items USING GIN (search_tsv);
+CREATE INDEX idx_delivery_info_name_trgm ON delivery_info USING GIN (name gin_trgm_ops);
+CREATE INDEX idx_delivery_info_phone_trgm ON delivery_info USING GIN (phone gin_trgm_ops);
+CREATE INDEX idx_delivery_info_email_trgm ON delivery_info USING GIN (email gin_trgm_ops);
+CREATE INDEX idx_delivery_info_city_trgm ON delivery_info USING GIN (city gin_trgm_ops);
+CREATE INDEX idx_items_name_trgm ON items USING GIN (name gin_trgm_ops);
+CREATE INDEX idx_items_brand_trgm ON items USING GIN (brand gin_trgm_ops);
@@ -0,0 +1,208 @@
+-- Заказы и связанные таблицы разбиваются на помесячные партиции по date_created.
+-- Первичные и внешние ключи партиционированных таблиц обязаны включать ключ
+-- партиционирования, поэтому уникальность order_uid и transaction_uid по всем
+-- партициям обеспечивает отдельная таблица order_keys.
+
+ALTER TABLE items RENAME TO items_unpartitioned;
+ALTER TABLE payments RENAME TO payments_unpartitioned;
+ALTER TABLE delivery_info RENAME TO delivery_info_unpartitioned;
+ALTER TABLE orders RENAME TO orders_unpartitioned;
+ALTER INDEX orders_pkey RENAME TO orders_unpartitioned_pkey;
+ALTER INDEX delivery_info_pkey RENAME TO delivery_info_unpartitioned_pkey;
+ALTER INDEX payments_pkey RENAME TO payments_unpartitioned_pkey;
+ALTER INDEX items_pkey RENAME TO items_unpartitioned_pkey;
+ALTER SEQUENCE items_id_seq RENAME TO items_unpartitioned_id_seq;
+
+CREATE TABLE order_keys (
+    order_uid       VARCHAR(255) PRIMARY KEY,
+    transaction_uid VARCHAR(255),
+    date_created    TIMESTAMP WITH TIME ZONE NOT NULL,
+    CONSTRAINT uq_order_keys_transaction UNIQUE (transaction_uid)
+);
+
+CREATE TABLE orders (
+    order_uid          VARCHAR(255) NOT NULL,
+    track_number       VARCHAR(255) NOT NULL,
+    entry              VARCHAR(255) NOT NULL,
+    locale             VARCHAR(10) NOT NULL,
+    internal_signature VARCHAR(255),
+    customer_id        VARCHAR(255) NOT NULL,
+    delivery_service   VARCHAR(255) NOT NULL,
+    shardkey           VARCHAR(255) NOT NULL,
+    sm_id              BIGINT NOT NULL,
+    date_created       TIMESTAMP WITH TIME ZONE NOT NULL,
+    oof_shard          VARCHAR(255) NOT NULL,
+    deleted_at         TIMESTAMP WITH TIME ZONE,
+    deleted_by         VARCHAR(255),
+    delete_reason      TEXT,
+    version            BIGINT NOT NULL DEFAULT 1,
+    status             VARCHAR(20) NOT NULL DEFAULT 'created',
+    status_changed_at  TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (order_uid, date_created),
+    CONSTRAINT chk_orders_status
+        CHECK (status IN ('created', 'paid', 'assembling', 'shipped', 'delivered', 'cancelled', 'returned'))
+) PARTITION BY RANGE (date_created);
+
+CREATE TABLE delivery_info (
+    order_uid    VARCHAR(255) NOT NULL,
+    date_created TIMESTAMP WITH TIME ZONE NOT NULL,
+    name         VARCHAR(255) NOT NULL,
+    phone        VARCHAR(255) NOT NULL,
+    zip          VARCHAR(20) NOT NULL,
+    city         VARCHAR(255) NOT NULL,
+    address      VARCHAR(255) NOT NULL,
+    region       VARCHAR(255) NOT NULL,
+    email        VARCHAR(255) NOT NULL,
+    search_tsv   tsvector GENERATED ALWAYS AS (
+        to_tsvector('simple',
+            coalesce(name, '') || ' ' || coalesce(phone, '') || ' ' || coalesce(email, '') || ' ' ||
+            coalesce(city, '') || ' ' || coalesce(address, '') || ' ' || coalesce(region, ''))
+    ) STORED,
+    PRIMARY KEY (order_uid, date_created),
+    CONSTRAINT fk_delivery_order
+        FOREIGN KEY (order_uid, date_created)
+        REFERENCES orders (order_uid, date_created)
+        ON DELETE CASCADE ON UPDATE CASCADE
+) PARTITION BY RANGE (date_created);
+
+CREATE TABLE payments (
+    order_uid       VARCHAR(255) NOT NULL,
+    date_created    TIMESTAMP WITH TIME ZONE NOT NULL,
+    transaction_uid VARCHAR(255) NOT NULL,
+    request_id      BIGINT,
+    currency        VARCHAR(10) NOT NULL,
+    provider        VARCHAR(255) NOT NULL,
+    amount          INT NOT NULL,
+    payment_dt      BIGINT NOT NULL,
+    bank            VARCHAR(255) NOT NULL,
+    delivery_cost   INT NOT NULL,
+    goods_total     INT NOT NULL,
+    custom_fee      INT NOT NULL,
+    PRIMARY KEY (order_uid, date_created),
+    CONSTRAINT fk_payment_order
+        FOREIGN KEY (order_uid, date_created)
+        REFERENCES orders (order_uid, date_created)
+        ON DELETE CASCADE ON UPDATE CASCADE
+) PARTITION BY RANGE (date_created);
+
+CREATE TABLE items (
+    id           BIGSERIAL,
+    order_uid    VARCHAR(255) NOT NULL,
+    date_created TIMESTAMP WITH TIME ZONE NOT NULL,
+    chrt_id      BIGINT NOT NULL,
+    track_number VARCHAR(255) NOT NULL,
+    price        INT NOT NULL,
+    rid          VARCHAR(255) NOT NULL,
+    name         VARCHAR(255) NOT NULL,
+    sale         INT NOT NULL,
+    size         VARCHAR(50) NOT NULL,
+    total_price  INT NOT NULL,
+    nm_id        BIGINT NOT NULL,
+    brand        VARCHAR(255) NOT NULL,
+    status       INT NOT NULL,
+    state        VARCHAR(20) NOT NULL DEFAULT 'created',
+    search_tsv   tsvector GENERATED ALWAYS AS (
+        to_tsvector('simple', coalesce(name, '') || ' ' || coalesce(brand, ''))
+    ) STORED,
+    PRIMARY KEY (id, date_created),
+    CONSTRAINT chk_items_state
+        CHECK (state IN ('created', 'paid', 'assembling', 'shipped', 'delivered', 'cancelled', 'returned')),
+    CONSTRAINT fk_items_order
+        FOREIGN KEY (order_uid, date_created)
+        REFERENCES orders (order_uid, date_created)
+        ON DELETE CASCADE ON UPDATE CASCADE
+) PARTITION BY RANGE (date_created);
+
+-- Создаёт партиции всех четырёх таблиц для месяца, в который попадает month
+-- (границы месяца считаются в UTC). Партиции называются <таблица>_pYYYY_MM.
+CREATE OR REPLACE FUNCTION create_order_partitions(month DATE) RETURNS void AS $$
+DECLARE
+    month_start TIMESTAMP := date_trunc('month', month::timestamp);
+    suffix      TEXT := to_char(month_start, '"p"YYYY_MM');
+    tbl         TEXT;
+BEGIN
+    IF to_regclass('items_' || suffix) IS NOT NULL THEN
+        RETURN;
+    END IF;
+    PERFORM pg_advisory_xact_lock(hashtext('create_order_partitions'));
+    FOREACH tbl IN ARRAY ARRAY['orders', 'delivery_info', 'payments', 'items'] LOOP
+        EXECUTE format('CREATE TABLE IF NOT EXISTS %I PARTITION OF %I FOR VALUES FROM (%L) TO (%L)',
+            tbl || '_' || suffix, tbl,
+            month_start AT TIME ZONE 'UTC', (month_start + INTERVAL '1 month') AT TIME ZONE 'UTC');
+    END LOOP;
+END;
+$$ LANGUAGE plpgsql;
+
+DO $$
+DECLARE
+    m TIMESTAMP;
+BEGIN
+    FOR m IN
+        SELECT DISTINCT date_trunc('month', date_created AT TIME ZONE 'UTC') FROM orders_unpartitioned
+        UNION
+        SELECT date_trunc('month', NOW() AT TIME ZONE 'UTC') + make_interval(months => g)
+        FROM generate_series(0, 3) AS g
+    LOOP
+        PERFORM create_order_partitions(m::date);
+    END LOOP;
+END;
+$$;
+
+INSERT INTO order_keys (order_uid, transaction_uid, date_created)
+SELECT o.order_uid, p.transaction_uid, o.date_created
+FROM orders_unpartitioned o
+LEFT JOIN payments_unpartitioned p ON p.order_uid = o.order_uid;
+
+INSERT INTO orders (
+    order_uid, track_number, entry, locale, internal_signature, customer_id, delivery_service,
+    shardkey, sm_id, date_created, oof_shard, deleted_at, deleted_by, delete_reason,
+    version, status, status_changed_at
+)
+SELECT
+    order_uid, track_number, entry, locale, internal_signature, customer_id, delivery_service,
+    shardkey, sm_id, date_created, oof_shard, deleted_at, deleted_by, delete_reason,
+    version, status, status_changed_at
+FROM orders_unpartitioned;
+
+INSERT INTO delivery_info (order_uid, date_created, name, phone, zip, city, address, region, email)
+SELECT d.order_uid, o.date_created, d.name, d.phone, d.zip, d.city, d.address, d.region, d.email
+FROM delivery_info_unpartitioned d
+JOIN orders_unpartitioned o ON o.order_uid = d.order_uid;
+
+INSERT INTO payments (
+    order_uid, date_created, transaction_uid, request_id, currency, provider, amount,
+    payment_dt, bank, delivery_cost, goods_total, custom_fee
+)
+SELECT
+    p.order_uid, o.date_created, p.transaction_uid, p.request_id, p.currency, p.provider, p.amount,
+    p.payment_dt, p.bank, p.delivery_cost, p.goods_total, p.custom_fee
+FROM payments_unpartitioned p
+JOIN orders_unpartitioned o ON o.order_uid = p.order_uid;
+
+INSERT INTO items (
+    id, order_uid, date_created, chrt_id, track_number, price, rid, name, sale, size,
+    total_price, nm_id, brand, status, state
+)
+SELECT
+    i.id, i.order_uid, o.date_created, i.chrt_id, i.track_number, i.price, i.rid, i.name, i.sale, i.size,
+    i.total_price, i.nm_id, i.brand, i.status, i.state
+FROM items_unpartitioned i
+JOIN orders_unpartitioned o ON o.order_uid = i.order_uid;
+
+SELECT setval('items_id_seq', COALESCE((SELECT MAX(id) FROM items), 0) + 1, false);
+
+DROP TABLE items_unpartitioned, payments_unpartitioned, delivery_info_unpartitioned, orders_unpartitioned;
+
+CREATE INDEX idx_orders_deleted_at ON orders (deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX idx_orders_status ON orders (status);
+CREATE INDEX idx_orders_date_created ON orders (date_created);
+CREATE INDEX idx_items_order_uid ON items (order_uid);
+CREATE INDEX idx_items_nm_id ON items (nm_id);
+CREATE INDEX idx_delivery_info_search ON delivery_info USING GIN (search_tsv);
+CREATE INDEX idx_items_search ON items USING GIN (search_tsv);
+CREATE INDEX idx_delivery_info_name_trgm ON delivery_info USING GIN (name gin_trgm_ops);
+CREATE INDEX idx_delivery_info_phone_trgm ON delivery_info USING GIN (phone gin_trgm_ops);
+CREATE INDEX idx_delivery_info_email_trgm ON delivery_info USING GIN (email gin_trgm_ops);
+CREATE INDEX idx_delivery_info_city_trgm ON delivery_info USING GIN (city gin_trgm_ops);
+CREATE INDEX idx_items_name_trgm ON items USING GIN (name gin_trgm_ops);
+CREATE INDEX idx_items_brand_trgm ON items USING GIN (brand gin_trgm_ops);
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS dead_letters (
+    id              BIGSERIAL PRIMARY KEY,
+    topic           TEXT NOT NULL,
+    kafka_partition INT NOT NULL,
+    kafka_offset    BIGINT NOT NULL,
+    message_key     TEXT NOT NULL DEFAULT '',
+    value           BYTEA NOT NULL, -- исходное сообщение, может быть не JSON
+    error           TEXT NOT NULL,
+    attempts        INT NOT NULL DEFAULT 1,
+    failed_at       TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1,11 @@
+-- Заказы, опубликованные в Kafka. По ней проверка согласованности находит
+-- заказы, событие о которых не дошло до Kafka. Заказы, созданные до появления
+-- таблицы, считаются опубликованными.
+CREATE TABLE IF NOT EXISTS order_publications (
+    order_uid    VARCHAR(255) PRIMARY KEY REFERENCES order_keys (order_uid) ON DELETE CASCADE,
+    published_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+INSERT INTO order_publications (order_uid)
+SELECT order_uid FROM order_keys
+ON CONFLICT DO NOTHING;
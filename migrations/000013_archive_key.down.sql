@@ -0,0 +1,6 @@
+DELETE FROM archived_orders a
+USING archived_orders b
+WHERE a.order_uid = b.order_uid AND a.deleted_at < b.deleted_at;
+
+ALTER TABLE archived_orders DROP CONSTRAINT IF EXISTS archived_orders_pkey;
+ALTER TABLE archived_orders ADD PRIMARY KEY (order_uid);
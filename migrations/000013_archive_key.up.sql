@@ -0,0 +1,4 @@
+-- UID заказа освобождается при архивации, поэтому один и тот же UID может
+-- попасть в архив несколько раз — с разным временем удаления.
+ALTER TABLE archived_orders DROP CONSTRAINT IF EXISTS archived_orders_pkey;
+ALTER TABLE archived_orders ADD PRIMARY KEY (order_uid, deleted_at);
@@ -0,0 +1,35 @@
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM orders_default) THEN
+        RAISE EXCEPTION 'orders_default is not empty: create the partitions of its months first';
+    END IF;
+END;
+$$;
+
+DROP TABLE IF EXISTS items_default, payments_default, delivery_info_default, orders_default;
+
+CREATE OR REPLACE FUNCTION create_order_partitions(month DATE) RETURNS void AS $$
+DECLARE
+    month_start TIMESTAMP := date_trunc('month', month::timestamp);
+    suffix      TEXT := to_char(month_start, '"p"YYYY_MM');
+    tbl         TEXT;
+BEGIN
+    IF to_regclass('items_' || suffix) IS NOT NULL THEN
+        RETURN;
+    END IF;
+    PERFORM pg_advisory_xact_lock(hashtext('create_order_partitions'));
+    FOREACH tbl IN ARRAY ARRAY['orders', 'delivery_info', 'payments', 'items'] LOOP
+        EXECUTE format('CREATE TABLE IF NOT EXISTS %I PARTITION OF %I FOR VALUES FROM (%L) TO (%L)',
+            tbl || '_' || suffix, tbl,
+            month_start AT TIME ZONE 'UTC', (month_start + INTERVAL '1 month') AT TIME ZONE 'UTC');
+    END LOOP;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP FUNCTION IF EXISTS order_partition_exists(TEXT);
+
+CREATE OR REPLACE FUNCTION order_audit_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'order_audit is append-only';
+END;
+$$ LANGUAGE plpgsql;
@@ -0,0 +1,76 @@
+-- Журнал изменений остаётся append-only, но удалённые по сроку хранения
+-- партиции забирают с собой и его записи: удаление разрешено транзакции,
+-- установившей order_audit.purge = on.
+CREATE OR REPLACE FUNCTION order_audit_append_only() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP = 'DELETE' AND current_setting('order_audit.purge', true) = 'on' THEN
+        RETURN OLD;
+    END IF;
+    RAISE EXCEPTION 'order_audit is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+-- Заказы месяца, для которого ещё нет партиций (или партиции которого уже
+-- удалены), попадают в партиции по умолчанию, а не ломают запись.
+CREATE TABLE IF NOT EXISTS orders_default PARTITION OF orders DEFAULT;
+CREATE TABLE IF NOT EXISTS delivery_info_default PARTITION OF delivery_info DEFAULT;
+CREATE TABLE IF NOT EXISTS payments_default PARTITION OF payments DEFAULT;
+CREATE TABLE IF NOT EXISTS items_default PARTITION OF items DEFAULT;
+
+CREATE OR REPLACE FUNCTION order_partition_exists(suffix TEXT) RETURNS boolean AS $$
+    SELECT EXISTS (
+        SELECT 1
+        FROM pg_inherits i
+        JOIN pg_class c ON c.oid = i.inhrelid
+        WHERE i.inhparent = 'items'::regclass AND c.relname = 'items_' || suffix
+    );
+$$ LANGUAGE sql STABLE;
+
+-- Наличие партиций проверяется по pg_inherits: отсоединённая партиция с тем же
+-- именем не считается. Строки месяца, накопившиеся в партициях по умолчанию,
+-- переносятся в созданные партиции.
+CREATE OR REPLACE FUNCTION create_order_partitions(month DATE) RETURNS void AS $$
+DECLARE
+    month_start TIMESTAMP := date_trunc('month', month::timestamp);
+    range_from  TIMESTAMPTZ := month_start AT TIME ZONE 'UTC';
+    range_to    TIMESTAMPTZ := (month_start + INTERVAL '1 month') AT TIME ZONE 'UTC';
+    suffix      TEXT := to_char(month_start, '"p"YYYY_MM');
+    tables      TEXT[] := ARRAY['orders', 'delivery_info', 'payments', 'items'];
+    tbl         TEXT;
+    cols        TEXT;
+    moved       BOOLEAN;
+BEGIN
+    IF order_partition_exists(suffix) THEN
+        RETURN;
+    END IF;
+    PERFORM pg_advisory_xact_lock(hashtext('create_order_partitions'));
+    IF order_partition_exists(suffix) THEN
+        RETURN;
+    END IF;
+
+    moved := EXISTS (SELECT 1 FROM orders_default WHERE date_created >= range_from AND date_created < range_to);
+    IF moved THEN
+        FOREACH tbl IN ARRAY tables LOOP
+            EXECUTE format('DROP TABLE IF EXISTS pg_temp.%I', 'moved_' || tbl);
+            EXECUTE format('CREATE TEMP TABLE %I ON COMMIT DROP AS SELECT * FROM %I WHERE date_created >= $1 AND date_created < $2',
+                'moved_' || tbl, tbl || '_default') USING range_from, range_to;
+        END LOOP;
+        -- Строки связанных таблиц удаляются каскадом.
+        DELETE FROM orders_default WHERE date_created >= range_from AND date_created < range_to;
+    END IF;
+
+    FOREACH tbl IN ARRAY tables LOOP
+        EXECUTE format('CREATE TABLE %I PARTITION OF %I FOR VALUES FROM (%L) TO (%L)',
+            tbl || '_' || suffix, tbl, range_from, range_to);
+    END LOOP;
+
+    IF moved THEN
+        FOREACH tbl IN ARRAY tables LOOP
+            SELECT string_agg(quote_ident(attname), ', ' ORDER BY attnum) INTO cols
+            FROM pg_attribute
+            WHERE attrelid = tbl::regclass AND attnum > 0 AND NOT attisdropped AND attgenerated = '';
+            EXECUTE format('INSERT INTO %I (%s) SELECT %s FROM %I', tbl, cols, cols, 'moved_' || tbl);
+        END LOOP;
+    END IF;
+END;
+$$ LANGUAGE plpgsql;